@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const defaultAckState = "2"
+
+// AckConfig - Incident acknowledgement endpoint, enabled when a bearer token is set
+type AckConfig struct {
+	BearerToken     string `yaml:"bearer_token"`
+	BearerTokenFile string `yaml:"bearer_token_file"`
+	// State ID set on acknowledged incidents, 2 ("In Progress") by default
+	State json.Number `yaml:"state"`
+}
+
+// enabled returns true if a bearer token is configured
+func (c AckConfig) enabled() bool {
+	return len(c.BearerToken) > 0 || len(c.BearerTokenFile) > 0
+}
+
+// ackRequest is the body of an acknowledgement request
+type ackRequest struct {
+	GroupKey       string `json:"group_key"`
+	IncidentNumber string `json:"incident_number"`
+	User           string `json:"user"`
+	// Name of the ServiceNow instance of the incident, the default instance when not set
+	Instance string `json:"instance"`
+}
+
+// ack sets the incident of a group key or number to the acknowledged state, and assigns it to the user if any
+func ack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONResponse(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+	// The acknowledgement is handled with one configuration and set of instances, even if reloaded meanwhile
+	configLock.RLock()
+	defer configLock.RUnlock()
+	if err := authorizeBearerToken(r, config.Ack.BearerToken, config.Ack.BearerTokenFile); err != nil {
+		log.Warnf("Unauthorized acknowledgement request: %v", err)
+		writeJSONResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	request := ackRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(request.GroupKey) == 0 && len(request.IncidentNumber) == 0 {
+		writeJSONResponse(w, http.StatusBadRequest, "group_key or incident_number is missing")
+		return
+	}
+	if _, ok := serviceNowInstances[request.Instance]; len(request.Instance) > 0 && !ok {
+		writeJSONResponse(w, http.StatusBadRequest, fmt.Sprintf("Unknown instance %q", request.Instance))
+		return
+	}
+
+	incident, err := findAckIncident(request)
+	if err != nil {
+		writeJSONResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if incident == nil {
+		writeJSONResponse(w, http.StatusNotFound, "No updatable incident found")
+		return
+	}
+
+	state := config.Ack.State.String()
+	if len(state) == 0 {
+		state = defaultAckState
+	}
+	ackParam := Incident{"state": state}
+	if len(request.User) > 0 {
+		ackParam["assigned_to"] = request.User
+		if sysID, found, _ := directory.lookupUser(request.User); found && len(request.Instance) == 0 {
+			ackParam["assigned_to"] = sysID
+		}
+	}
+
+	log.WithFields(incidentLogFields(incident)).Infof("Acknowledging incident (%s) for user %q", incident.GetNumber(), request.User)
+	_, err = serviceNowByName(request.Instance).UpdateIncident(ackParam, incident.GetSysID())
+	if groupKey, ok := incident[config.Workflow.IncidentGroupKeyField].(string); ok && len(groupKey) > 0 {
+		history.record(groupKey, "ack", "ack", incident.GetNumber(), err)
+		incidents.invalidate(groupKey)
+	}
+	if err != nil {
+		serviceNowError.Inc()
+		writeJSONResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, fmt.Sprintf("Incident %s acknowledged", incident.GetNumber()))
+}
+
+// findAckIncident returns the incident of the number, or the updatable incident of the group key
+func findAckIncident(request ackRequest) (Incident, error) {
+	params := map[string]string{config.Workflow.IncidentGroupKeyField: request.GroupKey}
+	if len(request.IncidentNumber) > 0 {
+		params = map[string]string{"number": request.IncidentNumber}
+	}
+
+	found, err := serviceNowByName(request.Instance).GetIncidents(withSource(config.Workflow.Source, params))
+	if err != nil {
+		serviceNowError.Inc()
+		return nil, err
+	}
+	if updatable := filterUpdatableIncidents(found); len(updatable) > 0 {
+		return updatable[0], nil
+	}
+	return nil, nil
+}
+
+// authorizeBearerToken checks the Authorization header against the token, or the content of the token file
+func authorizeBearerToken(r *http.Request, token string, tokenFile string) error {
+	if len(tokenFile) > 0 {
+		var err error
+		if token, err = readSecretFile(tokenFile); err != nil {
+			return err
+		}
+	}
+	if len(token) == 0 {
+		return errors.New("no bearer token is configured")
+	}
+
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return errors.New("missing bearer token")
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, "Bearer ")), []byte(token)) != 1 {
+		return errors.New("invalid bearer token")
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAck_OK(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Ack = AckConfig{BearerToken: "secret"}
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", map[string]string{"number": "INC42"}).Return([]Incident{{"sys_id": "42", "number": "INC42", "state": "1", "u_prometheus_alertgroup_id": "abc"}}, nil)
+	snClientMock.On("UpdateIncident", Incident{"state": "2", "assigned_to": "jdoe"}, "42").Return(Incident{}, nil)
+
+	req := httptest.NewRequest("POST", "/api/v1/ack", strings.NewReader(`{"incident_number": "INC42", "user": "jdoe"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(ack).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusOK)
+	}
+	snClientMock.AssertExpectations(t)
+	if entries, _ := history.get("abc"); len(entries) == 0 || entries[len(entries)-1].Action != "ack" {
+		t.Errorf("Acknowledgement must be recorded in the group key history: %v", entries)
+	}
+}
+
+func TestAck_Errors(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Ack = AckConfig{BearerToken: "secret"}
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{{"sys_id": "42", "state": "7"}}, nil)
+
+	tests := []struct {
+		name          string
+		authorization string
+		body          string
+		status        int
+	}{
+		{"missing token", "", `{"group_key": "abc"}`, http.StatusUnauthorized},
+		{"invalid token", "Bearer wrong", `{"group_key": "abc"}`, http.StatusUnauthorized},
+		{"missing incident reference", "Bearer secret", `{"user": "jdoe"}`, http.StatusBadRequest},
+		{"no updatable incident", "Bearer secret", `{"group_key": "abc"}`, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/api/v1/ack", strings.NewReader(test.body))
+		if len(test.authorization) > 0 {
+			req.Header.Set("Authorization", test.authorization)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(ack).ServeHTTP(rr, req)
+		if status := rr.Code; status != test.status {
+			t.Errorf("%s: wrong status code: got %v, want %v", test.name, status, test.status)
+		}
+	}
+	snClientMock.AssertNotCalled(t, "UpdateIncident", mock.Anything, mock.Anything)
+}
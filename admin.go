@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ResyncConfig - Group key resync endpoint (/-/resync), enabled when a bearer token is set
+type ResyncConfig struct {
+	BearerToken     string `yaml:"bearer_token"`
+	BearerTokenFile string `yaml:"bearer_token_file"`
+}
+
+// enabled returns true if a bearer token is configured
+func (c ResyncConfig) enabled() bool {
+	return len(c.BearerToken) > 0 || len(c.BearerTokenFile) > 0
+}
+
+// resync re-evaluates the latest payload stored for the group_key query parameter against ServiceNow
+func resync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONResponse(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+	auth := currentConfig().Resync
+	if err := authorizeBearerToken(r, auth.BearerToken, auth.BearerTokenFile); err != nil {
+		log.Warnf("Unauthorized resync request: %v", err)
+		writeJSONResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	groupKey := r.URL.Query().Get("group_key")
+	if len(groupKey) == 0 {
+		writeJSONResponse(w, http.StatusBadRequest, "group_key parameter is missing")
+		return
+	}
+
+	data, ok := lastPayloads.get(groupKey)
+	if !ok {
+		writeJSONResponse(w, http.StatusNotFound, fmt.Sprintf("No payload received for group key: %s", groupKey))
+		return
+	}
+
+	ctx := withRequestID(context.Background(), handleRequestID(w, r))
+	groupKeyLog(groupKey, nil).Infof("Resync requested for alert group key: %s", groupKey)
+	progress.clear(groupKey)
+	incidents.invalidate(groupKey)
+	if err := onAlertGroup(ctx, data); err != nil {
+		groupKeyLog(groupKey, nil).Errorf("Error resyncing incident for alert group key %s : %v", groupKey, err)
+		writeJSONResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, "Success")
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+// resyncRequest returns a resync request authenticated with the resync bearer token
+func resyncRequest(method string, target string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	return req
+}
+
+func TestResync_OK(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Resync = ResyncConfig{BearerToken: "secret"}
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{}, nil)
+	snClientMock.On("UpdateIncident", mock.Anything, mock.Anything).Return(Incident{}, errors.New("Update should not be called"))
+
+	// Send a firing payload first so it is stored as the latest one of its group
+	data, err := ioutil.ReadFile("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	http.HandlerFunc(webhook).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/webhook", bytes.NewReader(data)))
+
+	req := resyncRequest("POST", "/-/resync?group_key=unknown")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(resync).ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusNotFound)
+	}
+
+	payload := template.Data{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	req = resyncRequest("POST", "/-/resync?group_key="+getGroupKey(payload))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(resync).ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusOK)
+	}
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 2)
+}
+
+func TestResync_BadRequest(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Resync = ResyncConfig{BearerToken: "secret"}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(resync).ServeHTTP(rr, resyncRequest("POST", "/-/resync"))
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusBadRequest)
+	}
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(resync).ServeHTTP(rr, resyncRequest("GET", "/-/resync?group_key=42"))
+	if status := rr.Code; status != http.StatusMethodNotAllowed {
+		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestResync_Unauthorized(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+
+	for _, c := range []ResyncConfig{{}, {BearerToken: "secret"}} {
+		config.Resync = c
+		req := httptest.NewRequest("POST", "/-/resync?group_key=42", nil)
+		req.Header.Set("Authorization", "Bearer wrong")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(resync).ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusUnauthorized {
+			t.Errorf("Wrong status code with %+v: got %v, want %v", c, status, http.StatusUnauthorized)
+		}
+	}
+	snClientMock.AssertNotCalled(t, "GetIncidents", mock.Anything)
+}
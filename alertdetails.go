@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	tmpltext "text/template"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// Journal fields the alert details, rendered in the comments field, are written to
+const (
+	alertDetailsComments  = "comments"
+	alertDetailsWorkNotes = "work_notes"
+	alertDetailsSplit     = "split"
+)
+
+const (
+	defaultAlertDetailsSummary   = "{{ .CommonLabels.alertname }} is {{ .Status }}{{ with .CommonAnnotations.summary }}: {{ . }}{{ end }}"
+	defaultAlertDetailsAlertList = "{{ range .Alerts }}[{{ .Status }}]{{ range .Labels.SortedPairs }} {{ .Name }}={{ .Value }}{{ end }}\n" +
+		"{{ range .Annotations.SortedPairs }}  {{ .Name }}: {{ .Value }}\n{{ end }}{{ end }}"
+)
+
+// AlertDetailsConfig - Journal field receiving the alert details rendered in the comments field, comments being visible
+// to the callers while work_notes are not
+type AlertDetailsConfig struct {
+	// comments (default), work_notes, or split: a summary in comments and the alert list in work_notes
+	Field string `yaml:"field"`
+	// Common annotation of the alert group overriding the field, with the same values
+	Annotation string `yaml:"annotation"`
+	// Templates of the split comments and work_notes
+	Summary   string `yaml:"summary"`
+	AlertList string `yaml:"alert_list"`
+}
+
+func (c AlertDetailsConfig) validate() error {
+	if !isAlertDetailsField(c.Field) {
+		return fmt.Errorf("alert_details field %q is invalid, must be one of: comments, work_notes, split", c.Field)
+	}
+	for name, text := range map[string]string{"summary": c.Summary, "alert_list": c.AlertList} {
+		if _, err := tmpltext.New(name).Funcs(templateFuncs("")).Parse(text); err != nil {
+			return fmt.Errorf("alert_details %s template is invalid: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func isAlertDetailsField(field string) bool {
+	switch field {
+	case "", alertDetailsComments, alertDetailsWorkNotes, alertDetailsSplit:
+		return true
+	}
+	return false
+}
+
+// enabled returns true if the alert details may be written elsewhere than in comments
+func (c AlertDetailsConfig) enabled() bool {
+	return len(c.Annotation) > 0 || (len(c.Field) > 0 && c.Field != alertDetailsComments)
+}
+
+// field returns the journal field of the alert group details, the annotation overriding the configured one
+func (c AlertDetailsConfig) field(ctx context.Context, data template.Data) string {
+	if value := data.CommonAnnotations[c.Annotation]; len(c.Annotation) > 0 && len(value) > 0 {
+		if isAlertDetailsField(value) {
+			return value
+		}
+		alertGroupLog(ctx, data).Warnf("Alert details field %q of alert group key: %s is invalid, %s is used", value, getGroupKey(data), c.Field)
+	}
+	if len(c.Field) == 0 {
+		return alertDetailsComments
+	}
+	return c.Field
+}
+
+// applyAlertDetails moves the rendered alert details from comments to work_notes, or splits them, as configured
+func applyAlertDetails(ctx context.Context, incident Incident, data template.Data) {
+	c := config.Workflow.AlertDetails
+	switch c.field(ctx, data) {
+	case alertDetailsWorkNotes:
+		if details, ok := incident[alertDetailsComments]; ok {
+			delete(incident, alertDetailsComments)
+			incident[alertDetailsWorkNotes] = details
+		}
+	case alertDetailsSplit:
+		templates := map[string]string{alertDetailsComments: c.Summary, alertDetailsWorkNotes: c.AlertList}
+		defaults := map[string]string{alertDetailsComments: defaultAlertDetailsSummary, alertDetailsWorkNotes: defaultAlertDetailsAlertList}
+		for field, text := range templates {
+			if len(text) == 0 {
+				text = defaults[field]
+			}
+			value, err := applyTemplate(field, text, capRenderedAlerts(normalizeAlertTimes(ctx, data)))
+			if err != nil {
+				webhookIncidentTemplateError.Inc()
+				log.Errorf("Error parsing alert details %s template, error:%v", field, err)
+				continue
+			}
+			incident[field] = value
+		}
+	}
+}
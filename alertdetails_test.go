@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestApplyAlertDetails(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { config.Workflow.AlertDetails = AlertDetailsConfig{} }()
+	data := template.Data{
+		Status:            "firing",
+		CommonLabels:      template.KV{"alertname": "DiskFull"},
+		CommonAnnotations: template.KV{"summary": "Disk is full"},
+		Alerts: template.Alerts{{
+			Status:      "firing",
+			Labels:      template.KV{"alertname": "DiskFull", "host": "db1"},
+			Annotations: template.KV{"summary": "Disk is full"},
+		}},
+	}
+
+	config.Workflow.AlertDetails = AlertDetailsConfig{}
+	incident := Incident{"comments": "details"}
+	applyAlertDetails(context.Background(), incident, data)
+	if incident["comments"] != "details" || incident["work_notes"] != nil {
+		t.Errorf("Alert details must be kept in comments by default: %v", incident)
+	}
+
+	config.Workflow.AlertDetails = AlertDetailsConfig{Field: alertDetailsWorkNotes}
+	incident = Incident{"comments": "details"}
+	applyAlertDetails(context.Background(), incident, data)
+	if incident["comments"] != nil || incident["work_notes"] != "details" {
+		t.Errorf("Alert details must be moved to work_notes: %v", incident)
+	}
+
+	config.Workflow.AlertDetails = AlertDetailsConfig{Field: alertDetailsSplit}
+	incident = Incident{"comments": "details"}
+	applyAlertDetails(context.Background(), incident, data)
+	if incident["comments"] != "DiskFull is firing: Disk is full" {
+		t.Errorf("Unexpected summary: %q", incident["comments"])
+	}
+	if want := "[firing] alertname=DiskFull host=db1\n  summary: Disk is full\n"; incident["work_notes"] != want {
+		t.Errorf("Unexpected alert list: got %q, want %q", incident["work_notes"], want)
+	}
+
+	config.Workflow.AlertDetails = AlertDetailsConfig{Field: alertDetailsSplit, Annotation: "details"}
+	data.CommonAnnotations["details"] = alertDetailsComments
+	incident = Incident{"comments": "details"}
+	applyAlertDetails(context.Background(), incident, data)
+	if incident["comments"] != "details" || incident["work_notes"] != nil {
+		t.Errorf("Annotation must override the alert details field: %v", incident)
+	}
+}
+
+func TestAlertDetailsConfig_Validate(t *testing.T) {
+	if err := (AlertDetailsConfig{Field: "notes"}).validate(); err == nil {
+		t.Errorf("Expected an invalid field error")
+	}
+	if err := (AlertDetailsConfig{Field: alertDetailsSplit, Summary: "{{ .Status"}).validate(); err == nil {
+		t.Errorf("Expected an invalid template error")
+	}
+	if err := (AlertDetailsConfig{Field: alertDetailsWorkNotes}).validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestLoadConfig_AlertDetailsUpdateFields(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	if incidentUpdateFields[alertDetailsWorkNotes] {
+		t.Errorf("work_notes must not be updated by default")
+	}
+	content := "service_now:\n  instance_name: test\n  user_name: user\n  password: pass\n" +
+		"workflow:\n  incident_group_key_field: u_key\n  incident_update_fields: [comments]\n  alert_details:\n    field: work_notes\n"
+	if _, err := loadConfigContent([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if !incidentUpdateFields[alertDetailsWorkNotes] {
+		t.Errorf("work_notes must be updated as comments are")
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const defaultAlertListFileName = "alerts.json"
+
+// AlertListConfig - Cap of the alerts rendered in incident templates, the full list being attached to the incident
+type AlertListConfig struct {
+	// Maximum number of alerts rendered in templates, unlimited when not set
+	MaxRendered int    `yaml:"max_rendered"`
+	FileName    string `yaml:"file_name"`
+}
+
+// capRenderedAlerts returns a copy of the alert group with at most max_rendered alerts
+func capRenderedAlerts(data template.Data) template.Data {
+	maxRendered := config.AlertList.MaxRendered
+	if maxRendered <= 0 || len(data.Alerts) <= maxRendered {
+		return data
+	}
+	data.Alerts = data.Alerts[:maxRendered:maxRendered]
+	return data
+}
+
+// attachAlertList attaches the full list of alerts to the incident when it exceeds max_rendered,
+// errors are logged but ignored
+func attachAlertList(ctx context.Context, data template.Data, incident Incident) {
+	maxRendered := config.AlertList.MaxRendered
+	if maxRendered <= 0 || len(data.Alerts) <= maxRendered || len(incident.GetSysID()) == 0 {
+		return
+	}
+	fileName := config.AlertList.FileName
+	if len(fileName) == 0 {
+		fileName = defaultAlertListFileName
+	}
+
+	content, err := json.MarshalIndent(data.Alerts, "", "  ")
+	if err == nil {
+		err = serviceNowFor(ctx, data).AttachFile("incident", incident.GetSysID(), fileName, "application/json", content)
+	}
+	if err != nil {
+		incidentLog(ctx, data, incident).Errorf("Error attaching alert list to incident (%s) for alert group key: %s, %v", incident.GetNumber(), getGroupKey(data), err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCapRenderedAlerts(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	data := template.Data{Alerts: template.Alerts{{Status: "firing"}, {Status: "firing"}, {Status: "resolved"}}}
+
+	if got := capRenderedAlerts(data); len(got.Alerts) != 3 {
+		t.Errorf("Alerts must not be capped by default: got %d", len(got.Alerts))
+	}
+
+	config.AlertList = AlertListConfig{MaxRendered: 2}
+	if got := capRenderedAlerts(data); len(got.Alerts) != 2 {
+		t.Errorf("Unexpected number of rendered alerts: got %d, want 2", len(got.Alerts))
+	}
+	if len(data.Alerts) != 3 {
+		t.Errorf("Alert group must not be modified")
+	}
+
+	incident := renderIncident(context.Background(), data, map[string]string{"description": "{{ len .Alerts }} alert(s)"})
+	if incident["description"] != "2 alert(s)" {
+		t.Errorf("Unexpected rendered description: %v", incident["description"])
+	}
+}
+
+func TestAttachAlertList(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.AlertList = AlertListConfig{MaxRendered: 1}
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	var attached template.Alerts
+	snClientMock.On("AttachFile", "incident", "42", "alerts.json", "application/json", mock.Anything).Run(func(args mock.Arguments) {
+		json.Unmarshal(args.Get(4).([]byte), &attached)
+	}).Return(nil)
+
+	attachAlertList(context.Background(), template.Data{Alerts: template.Alerts{{Status: "firing"}}}, Incident{"sys_id": "42"})
+	snClientMock.AssertNotCalled(t, "AttachFile", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	attachAlertList(context.Background(), template.Data{Alerts: template.Alerts{{Status: "firing"}, {Status: "resolved"}}}, Incident{"sys_id": "42"})
+	snClientMock.AssertNumberOfCalls(t, "AttachFile", 1)
+	if len(attached) != 2 {
+		t.Errorf("The full alert list must be attached: got %d alert(s)", len(attached))
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// AnnotationFieldsConfig - Incident fields set by the alert rules, through common annotations holding a JSON object
+// of fields, e.g. servicenow_fields: '{"category": "network"}'
+type AnnotationFieldsConfig struct {
+	// Common annotations holding the JSON objects, applied in order
+	Annotations []string `yaml:"annotations"`
+	// Incident fields which can be set, the other ones are ignored
+	AllowedFields []string `yaml:"allowed_fields"`
+}
+
+func (c AnnotationFieldsConfig) validate(workflow WorkflowConfig) error {
+	if len(c.Annotations) == 0 {
+		return nil
+	}
+	if len(c.AllowedFields) == 0 {
+		return fmt.Errorf("annotation_fields allowed_fields is missing")
+	}
+	for _, field := range c.AllowedFields {
+		if field == workflow.IncidentGroupKeyField {
+			return fmt.Errorf("annotation_fields allowed_fields must not include the incident_group_key_field %s", field)
+		}
+		if workflow.Source.enabled() && field == workflow.Source.Field {
+			return fmt.Errorf("annotation_fields allowed_fields must not include the source field %s", field)
+		}
+	}
+	return nil
+}
+
+func (c AnnotationFieldsConfig) allowed(field string) bool {
+	for _, allowed := range c.AllowedFields {
+		if allowed == field {
+			return true
+		}
+	}
+	return false
+}
+
+// applyAnnotationFields sets the allowed incident fields of the JSON objects of the configured common annotations.
+// Values are set as is, they are not templates. Other fields, and annotations which are not JSON objects, are
+// ignored.
+func applyAnnotationFields(ctx context.Context, incident Incident, data template.Data) {
+	annotationFields := config.AnnotationFields
+	for _, annotation := range annotationFields.Annotations {
+		value := strings.TrimSpace(data.CommonAnnotations[annotation])
+		if len(value) == 0 {
+			continue
+		}
+		fields := map[string]interface{}{}
+		decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+		decoder.UseNumber()
+		if err := decoder.Decode(&fields); err != nil {
+			webhookAnnotationFieldsRejected.WithLabelValues("invalid_json").Inc()
+			alertGroupLog(ctx, data).Warnf("Annotation %s of alert group key: %s is not a JSON object of incident fields: %v", annotation, getGroupKey(data), err)
+			continue
+		}
+
+		names := make([]string, 0, len(fields))
+		for field := range fields {
+			names = append(names, field)
+		}
+		sort.Strings(names)
+		for _, field := range names {
+			if !annotationFields.allowed(field) {
+				webhookAnnotationFieldsRejected.WithLabelValues("not_allowed").Inc()
+				alertGroupLog(ctx, data).Warnf("Field %s of annotation %s of alert group key: %s is not allowed, it is ignored", field, annotation, getGroupKey(data))
+				continue
+			}
+			switch fieldValue := fields[field].(type) {
+			case string, json.Number, bool:
+				incident[field] = fmt.Sprint(fieldValue)
+			default:
+				webhookAnnotationFieldsRejected.WithLabelValues("invalid_value").Inc()
+				alertGroupLog(ctx, data).Warnf("Field %s of annotation %s of alert group key: %s is not a string, number or boolean, it is ignored", field, annotation, getGroupKey(data))
+			}
+		}
+	}
+}
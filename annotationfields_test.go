@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestApplyAnnotationFields(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { config.AnnotationFields = AnnotationFieldsConfig{} }()
+	config.AnnotationFields = AnnotationFieldsConfig{
+		Annotations:   []string{"servicenow_fields", "team_fields"},
+		AllowedFields: []string{"category", "subcategory", "impact", "u_paged"},
+	}
+
+	tests := []struct {
+		annotations template.KV
+		want        Incident
+		rejected    string
+	}{
+		{
+			template.KV{"servicenow_fields": `{"category": "network", "impact": 1, "u_paged": true}`},
+			Incident{"category": "network", "impact": "1", "u_paged": "true", "urgency": "3"},
+			"",
+		},
+		{
+			template.KV{"servicenow_fields": `{"category": "network"}`, "team_fields": `{"category": "database", "subcategory": "oracle"}`},
+			Incident{"category": "database", "subcategory": "oracle", "urgency": "3"},
+			"",
+		},
+		{
+			template.KV{"servicenow_fields": `{"category": "network", "urgency": "1"}`},
+			Incident{"category": "network", "urgency": "3"},
+			"not_allowed",
+		},
+		{
+			template.KV{"servicenow_fields": `{"category": ["network"]}`},
+			Incident{"urgency": "3"},
+			"invalid_value",
+		},
+		{
+			template.KV{"servicenow_fields": `category=network`},
+			Incident{"urgency": "3"},
+			"invalid_json",
+		},
+	}
+	for _, test := range tests {
+		var rejected float64
+		if len(test.rejected) > 0 {
+			rejected = testutil.ToFloat64(webhookAnnotationFieldsRejected.WithLabelValues(test.rejected))
+		}
+		incident := Incident{"urgency": "3"}
+		applyAnnotationFields(context.Background(), incident, template.Data{CommonAnnotations: test.annotations})
+		if !reflect.DeepEqual(incident, test.want) {
+			t.Errorf("Unexpected incident of %v: got %v, want %v", test.annotations, incident, test.want)
+		}
+		if len(test.rejected) > 0 && testutil.ToFloat64(webhookAnnotationFieldsRejected.WithLabelValues(test.rejected)) != rejected+1 {
+			t.Errorf("Field of %v must be rejected as %s", test.annotations, test.rejected)
+		}
+	}
+}
+
+func TestAnnotationFieldsConfig_Validate(t *testing.T) {
+	if err := (AnnotationFieldsConfig{Annotations: []string{"servicenow_fields"}, AllowedFields: []string{"category"}}).validate(WorkflowConfig{IncidentGroupKeyField: "u_group_key"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := (AnnotationFieldsConfig{Annotations: []string{"servicenow_fields"}}).validate(WorkflowConfig{IncidentGroupKeyField: "u_group_key"}); err == nil {
+		t.Errorf("Annotations without allowed fields must be invalid")
+	}
+	if err := (AnnotationFieldsConfig{Annotations: []string{"servicenow_fields"}, AllowedFields: []string{"u_group_key"}}).validate(WorkflowConfig{IncidentGroupKeyField: "u_group_key"}); err == nil {
+		t.Errorf("Group key field must not be allowed")
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// Number of objects waiting to be archived, beyond which objects are dropped
+const archiveQueueSize = 1000
+
+// ArchiverConfig - Archiving of received payloads and ServiceNow exchanges
+type ArchiverConfig struct {
+	// Type of the archive storage: "file" or "s3" (any S3 compatible API, including GCS interoperability endpoint)
+	Type            string `yaml:"type"`
+	Prefix          string `yaml:"prefix"`
+	Directory       string `yaml:"directory"`
+	Endpoint        string `yaml:"endpoint"`
+	Bucket          string `yaml:"bucket"`
+	Region          string `yaml:"region"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+}
+
+// Archiver stores an object under the given key
+type Archiver interface {
+	Archive(key string, content []byte) error
+}
+
+// fileArchiver stores objects in a local directory
+type fileArchiver struct {
+	directory string
+}
+
+// s3Archiver stores objects in a S3 compatible bucket
+type s3Archiver struct {
+	endpoint        string
+	bucket          string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	client          *http.Client
+}
+
+// archiveObject is an object waiting to be archived, with the archiver configured when it was queued
+type archiveObject struct {
+	archiver Archiver
+	kind     string
+	key      string
+	content  []byte
+}
+
+// archiveQueue archives the objects in background, so that the processing of the notifications and the
+// ServiceNow calls do not wait for the archive storage
+type archiveQueue struct {
+	mu       sync.Mutex
+	archiver Archiver
+	objects  chan archiveObject
+	pending  int64
+}
+
+var archives = newArchiveQueue()
+
+func newArchiveQueue() *archiveQueue {
+	q := &archiveQueue{objects: make(chan archiveObject, archiveQueueSize)}
+	go q.run()
+	return q
+}
+
+// configure sets the archiver of the objects queued from now on, nil disabling archiving
+func (q *archiveQueue) configure(archiver Archiver) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.archiver = archiver
+}
+
+// enqueue queues the object for archiving, dropping it if the queue is full
+func (q *archiveQueue) enqueue(kind string, key string, content []byte) {
+	q.mu.Lock()
+	archiver := q.archiver
+	q.mu.Unlock()
+	if archiver == nil {
+		return
+	}
+
+	atomic.AddInt64(&q.pending, 1)
+	select {
+	case q.objects <- archiveObject{archiver: archiver, kind: kind, key: key, content: content}:
+	default:
+		atomic.AddInt64(&q.pending, -1)
+		webhookArchiveDropped.Inc()
+		log.Debugf("Archive queue is full, %s %s is dropped", kind, key)
+	}
+}
+
+// length returns the number of objects queued or being archived
+func (q *archiveQueue) length() int {
+	return int(atomic.LoadInt64(&q.pending))
+}
+
+// enabled returns true if an archiver is configured
+func (q *archiveQueue) enabled() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.archiver != nil
+}
+
+// run archives the queued objects
+func (q *archiveQueue) run() {
+	for object := range q.objects {
+		if err := object.archiver.Archive(object.key, object.content); err != nil {
+			webhookArchiveError.Inc()
+			log.Errorf("Error archiving %s: %v", object.kind, err)
+		}
+		atomic.AddInt64(&q.pending, -1)
+	}
+}
+
+// serviceNowExchange is the archived content of a ServiceNow API call
+type serviceNowExchange struct {
+	Method       string          `json:"method"`
+	URL          string          `json:"url"`
+	Request      json.RawMessage `json:"request,omitempty"`
+	StatusCode   int             `json:"status_code"`
+	ResponseBody json.RawMessage `json:"response,omitempty"`
+}
+
+func (c ArchiverConfig) validate() error {
+	switch c.Type {
+	case "":
+		return nil
+	case "file":
+		if len(c.Directory) == 0 {
+			return errors.New("archiver directory is missing")
+		}
+	case "s3":
+		if len(c.Endpoint) == 0 || len(c.Bucket) == 0 || len(c.Region) == 0 {
+			return errors.New("archiver endpoint, bucket and region are mandatory for s3 type")
+		}
+	default:
+		return fmt.Errorf("archiver type %q is unknown", c.Type)
+	}
+	return nil
+}
+
+// newArchiver returns the configured archiver, or nil if archiving is disabled
+func newArchiver(c ArchiverConfig) Archiver {
+	switch c.Type {
+	case "file":
+		return &fileArchiver{directory: c.Directory}
+	case "s3":
+		return &s3Archiver{
+			endpoint:        strings.TrimSuffix(c.Endpoint, "/"),
+			bucket:          c.Bucket,
+			region:          c.Region,
+			accessKeyID:     c.AccessKeyID,
+			secretAccessKey: c.SecretAccessKey,
+			client:          &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+	return nil
+}
+
+// Archive writes the content in a file under the archive directory
+func (a *fileArchiver) Archive(key string, content []byte) error {
+	path := filepath.Join(a.directory, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+// Archive uploads the content to the bucket with an AWS signature V4 signed PUT request
+func (a *s3Archiver) Archive(key string, content []byte) error {
+	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/%s/%s", a.endpoint, a.bucket, key), bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	a.sign(req, content, now().UTC())
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("archive storage returned the HTTP error code: %v", resp.StatusCode)
+	}
+	return nil
+}
+
+// sign adds the AWS signature V4 headers to the request
+func (a *s3Archiver) sign(req *http.Request, content []byte, t time.Time) {
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	payloadHash := sha256Hex(content)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" + "x-amz-content-sha256:" + payloadHash + "\n" + "x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + a.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+a.secretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, a.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", a.accessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(content []byte) string {
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// archiveKey returns a date partitioned object key
+func archiveKey(kind string, groupKey string) string {
+	t := now().UTC()
+	return fmt.Sprintf("%s%s/%s-%s-%s.json", config.Archiver.Prefix, t.Format("2006/01/02"), t.Format("150405.000000000"), groupKey, kind)
+}
+
+// archivePayload archives a payload received from Alertmanager
+func archivePayload(data template.Data) {
+	if !archives.enabled() {
+		return
+	}
+	content, err := json.Marshal(data)
+	if err != nil {
+		webhookArchiveError.Inc()
+		log.Errorf("Error archiving payload: %v", err)
+		return
+	}
+	archives.enqueue("payload", archiveKey("payload", getGroupKey(data)), content)
+}
+
+// deadLetterPayload archives a payload which could not be processed and must not be retried. Without archiver,
+// the payload is logged so that it can still be replayed.
+func deadLetterPayload(data template.Data) {
+	webhookDeadLetters.Inc()
+	content, err := json.Marshal(data)
+	if err != nil {
+		webhookArchiveError.Inc()
+		log.Errorf("Error archiving dead-lettered payload of alert group key: %s: %v", getGroupKey(data), err)
+		return
+	}
+	if !archives.enabled() {
+		log.Errorf("Dead-lettered payload of alert group key: %s, no archiver is configured: %s", getGroupKey(data), content)
+		return
+	}
+	archives.enqueue("dead-lettered payload", archiveKey("dead-letter", getGroupKey(data)), content)
+}
+
+// archiveServiceNowExchange archives a request sent to ServiceNow and its response
+func archiveServiceNowExchange(req *http.Request, statusCode int, responseBody []byte) {
+	if !archives.enabled() {
+		return
+	}
+	exchange := serviceNowExchange{
+		Method:     req.Method,
+		URL:        req.URL.String(),
+		StatusCode: statusCode,
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			requestBody, _ := ioutil.ReadAll(body)
+			if json.Valid(requestBody) {
+				exchange.Request = requestBody
+			}
+		}
+	}
+	if json.Valid(responseBody) {
+		exchange.ResponseBody = responseBody
+	}
+
+	content, err := json.Marshal(exchange)
+	if err != nil {
+		webhookArchiveError.Inc()
+		log.Errorf("Error archiving ServiceNow exchange: %v", err)
+		return
+	}
+	archives.enqueue("ServiceNow exchange", archiveKey("servicenow", strings.ToLower(req.Method)), content)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// waitArchived waits for the queued objects to be archived
+func waitArchived(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if !waitUntil(ctx, func() bool { return archives.length() == 0 }) {
+		t.Fatalf("Objects are not archived: %d left", archives.length())
+	}
+}
+
+// blockingArchiver blocks the archiving until released
+type blockingArchiver struct {
+	release chan struct{}
+}
+
+func (a blockingArchiver) Archive(key string, content []byte) error {
+	<-a.release
+	return nil
+}
+
+func TestFileArchiver_ArchivePayload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config.Archiver = ArchiverConfig{Type: "file", Directory: dir, Prefix: "payloads/"}
+	archives.configure(newArchiver(config.Archiver))
+	defer archives.configure(nil)
+	now = func() time.Time { return time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "TestAlert"}}
+	archivePayload(data)
+	waitArchived(t)
+
+	files, err := filepath.Glob(filepath.Join(dir, "payloads", "2020", "01", "02", "*-payload.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Wrong archived files: got %v, want 1 file", files)
+	}
+	content, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"alertname":"TestAlert"`) {
+		t.Errorf("Unexpected archived content: %s", content)
+	}
+}
+
+func TestS3Archiver_Archive(t *testing.T) {
+	var gotPath, gotAuthorization, gotBody string
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		gotPath = r.URL.Path
+		gotAuthorization = r.Header.Get("Authorization")
+		gotBody = string(body)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer ts.Close()
+
+	a := newArchiver(ArchiverConfig{
+		Type:            "s3",
+		Endpoint:        ts.URL,
+		Bucket:          "bucket",
+		Region:          "us-east-1",
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+	})
+	if err := a.Archive("2020/01/02/key.json", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if gotPath != "/bucket/2020/01/02/key.json" {
+		t.Errorf("Unexpected path: got %v", gotPath)
+	}
+	if !strings.HasPrefix(gotAuthorization, "AWS4-HMAC-SHA256 Credential=AKID/") {
+		t.Errorf("Unexpected authorization header: got %v", gotAuthorization)
+	}
+	if gotBody != `{}` {
+		t.Errorf("Unexpected body: got %v", gotBody)
+	}
+}
+
+func TestArchivePayload_QueueFull(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	a := blockingArchiver{release: make(chan struct{})}
+	archives.configure(a)
+	defer archives.configure(nil)
+
+	// The payloads are archived in background, the ones beyond the queue size being dropped
+	dropped := testutil.ToFloat64(webhookArchiveDropped)
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "QueueFull"}}
+	for i := 0; i < archiveQueueSize+10; i++ {
+		archivePayload(data)
+	}
+	if got := testutil.ToFloat64(webhookArchiveDropped) - dropped; got < 9 {
+		t.Errorf("Unexpected dropped archives: got %v, want at least 9", got)
+	}
+
+	close(a.release)
+	waitArchived(t)
+}
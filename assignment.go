@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const defaultAssignmentGroupCacheTTL = 10 * time.Minute
+
+// AssignmentGroupOverrideConfig - Assignment group provided by the alerts themselves
+type AssignmentGroupOverrideConfig struct {
+	// Common label or annotation holding the assignment group name or sys_id
+	Label    string        `yaml:"label"`
+	Validate bool          `yaml:"validate"`
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+type groupValidation struct {
+	valid   bool
+	expires time.Time
+}
+
+// groupValidationCache caches the existence and activity of assignment groups
+type groupValidationCache struct {
+	mu      sync.Mutex
+	entries map[string]groupValidation
+}
+
+var assignmentGroups = &groupValidationCache{entries: make(map[string]groupValidation)}
+
+// applyAssignmentGroupOverride sets the assignment group provided by the alerts, if any and if valid.
+// Otherwise the default assignment group is kept and a work note explains the fallback.
+func applyAssignmentGroupOverride(ctx context.Context, data template.Data, incident Incident) {
+	override := config.Workflow.AssignmentGroupOverride
+	if len(override.Label) == 0 {
+		return
+	}
+
+	group := data.CommonLabels[override.Label]
+	if len(group) == 0 {
+		group = data.CommonAnnotations[override.Label]
+	}
+	if len(group) == 0 {
+		return
+	}
+
+	if override.Validate {
+		valid, err := assignmentGroups.isValid(serviceNowInstanceName(data), group)
+		if err != nil || !valid {
+			alertGroupLog(ctx, data).Warnf("Assignment group override %s for alert group key: %s is not an existing active group, default assignment group is used", group, getGroupKey(data))
+			incident["work_notes"] = fmt.Sprintf("Assignment group override %q is not an existing active group, the default assignment group was used.", group)
+			return
+		}
+	}
+	incident["assignment_group"] = group
+}
+
+// isValid returns true if the group, by name or sys_id, exists and is active in the ServiceNow instance
+func (c *groupValidationCache) isValid(instance string, group string) (bool, error) {
+	// The directory holds the groups of the default instance
+	if len(instance) == 0 {
+		if _, found, loaded := directory.lookupGroup(group); loaded {
+			return found, nil
+		}
+	}
+
+	// A group name which cannot be matched in a query cannot be validated
+	if !isQueryValue(group) {
+		return false, nil
+	}
+
+	key := instance + "/" + group
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now().Before(entry.expires) {
+		return entry.valid, nil
+	}
+
+	groups, err := serviceNowByName(instance).GetRecords("sys_user_group", map[string]string{
+		"sysparm_query":  fmt.Sprintf("name=%s^ORsys_id=%s", group, group),
+		"sysparm_fields": "sys_id,name,active",
+		"sysparm_limit":  "1",
+	})
+	if err != nil {
+		serviceNowError.Inc()
+		return false, err
+	}
+	valid := len(groups) > 0 && groups[0]["active"] == "true"
+
+	ttl := config.Workflow.AssignmentGroupOverride.CacheTTL
+	if ttl <= 0 {
+		ttl = defaultAssignmentGroupCacheTTL
+	}
+	c.mu.Lock()
+	c.entries[key] = groupValidation{valid: valid, expires: now().Add(ttl)}
+	c.mu.Unlock()
+	lookupCache.persist()
+	return valid, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestApplyAssignmentGroupOverride(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.AssignmentGroupOverride = AssignmentGroupOverrideConfig{Label: "team", Validate: true}
+	assignmentGroups = &groupValidationCache{entries: make(map[string]groupValidation)}
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetRecords", "sys_user_group", mock.MatchedBy(func(params map[string]string) bool {
+		return params["sysparm_query"] == "name=DBA^ORsys_id=DBA"
+	})).Return([]Incident{{"sys_id": "1", "name": "DBA", "active": "true"}}, nil)
+	snClientMock.On("GetRecords", "sys_user_group", mock.Anything).Return([]Incident{}, nil)
+
+	incident := Incident{"assignment_group": "Default"}
+	applyAssignmentGroupOverride(context.Background(), template.Data{CommonLabels: template.KV{"team": "DBA"}}, incident)
+	applyAssignmentGroupOverride(context.Background(), template.Data{CommonLabels: template.KV{"team": "DBA"}}, incident)
+	if incident["assignment_group"] != "DBA" {
+		t.Errorf("Unexpected assignment group: got %v, want %v", incident["assignment_group"], "DBA")
+	}
+	// Second validation must come from the cache
+	snClientMock.AssertNumberOfCalls(t, "GetRecords", 1)
+
+	incident = Incident{"assignment_group": "Default"}
+	applyAssignmentGroupOverride(context.Background(), template.Data{CommonAnnotations: template.KV{"team": "Unknown"}}, incident)
+	if incident["assignment_group"] != "Default" {
+		t.Errorf("Unexpected assignment group: got %v, want %v", incident["assignment_group"], "Default")
+	}
+	if _, ok := incident["work_notes"]; !ok {
+		t.Errorf("A work note explaining the fallback is expected")
+	}
+}
+
+func TestAssignmentGroupRouting(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { fieldRules = nil }()
+	var err error
+	fieldRules, err = compileFieldRules(map[string][]FieldRuleConfig{
+		"assignment_group": {
+			{If: `team="db"`, Value: "DBA"},
+			{If: `alertname=~"(Switch|Router|Link).*"`, Value: "NetOps"},
+			{If: `team="db", severity="critical"`, Value: "DBA on-call"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Workflow.AssignmentGroupOverride = AssignmentGroupOverrideConfig{Label: "assignment_group"}
+
+	tests := []struct {
+		labels template.KV
+		want   string
+	}{
+		{template.KV{"alertname": "PostgresDown", "team": "db", "severity": "critical"}, "DBA"},
+		{template.KV{"alertname": "SwitchPortDown"}, "NetOps"},
+		{template.KV{"alertname": "DiskFull"}, config.DefaultIncident["assignment_group"]},
+		{template.KV{"alertname": "LinkDown", "assignment_group": "Datacenter"}, "Datacenter"},
+	}
+	for _, test := range tests {
+		incident, _ := alertGroupToIncident(context.Background(), template.Data{Status: "firing", GroupLabels: template.KV{"alertname": test.labels["alertname"]}, CommonLabels: test.labels})
+		if incident["assignment_group"] != test.want {
+			t.Errorf("Unexpected assignment group for labels %v: got %v, want %v", test.labels, incident["assignment_group"], test.want)
+		}
+	}
+}
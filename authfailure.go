@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultAuthFailureNotificationInterval = 15 * time.Minute
+
+// AuthFailureNotificationConfig - Notification sent when ServiceNow keeps rejecting the credentials after they were reloaded
+type AuthFailureNotificationConfig struct {
+	// URL receiving a JSON POST, e.g. a Slack incoming webhook, notifications are disabled when not set
+	URL string `yaml:"url"`
+	// Minimum interval between two notifications, 15m by default
+	Interval time.Duration `yaml:"interval"`
+}
+
+func (c AuthFailureNotificationConfig) interval() time.Duration {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return defaultAuthFailureNotificationInterval
+}
+
+// authFailureNotification is the body of an authentication failure notification, its text field being
+// displayed by Slack and compatible incoming webhooks
+type authFailureNotification struct {
+	Text       string `json:"text"`
+	Instance   string `json:"instance"`
+	StatusCode int    `json:"status_code"`
+	Message    string `json:"message,omitempty"`
+}
+
+// isAuthenticationFailure returns true for the HTTP codes of rejected credentials
+func isAuthenticationFailure(statusCode int) bool {
+	return statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden
+}
+
+// onAuthenticationFailure counts a request rejected despite the credentials reload, and notifies it if configured
+func (snClient *ServiceNowClient) onAuthenticationFailure(err *serviceNowHTTPError) {
+	serviceNowAuthFailures.WithLabelValues(strconv.Itoa(err.statusCode)).Inc()
+	log.Errorf("ServiceNow rejected the credentials of %s (HTTP %d): %s", snClient.baseURL, err.statusCode, err.message)
+
+	notification := snClient.authFailureNotification
+	if len(notification.URL) == 0 {
+		return
+	}
+	snClient.mu.Lock()
+	if !snClient.lastAuthFailureNotification.IsZero() && now().Sub(snClient.lastAuthFailureNotification) < notification.interval() {
+		snClient.mu.Unlock()
+		return
+	}
+	snClient.lastAuthFailureNotification = now()
+	snClient.mu.Unlock()
+
+	go sendAuthFailureNotification(notification.URL, authFailureNotification{
+		Text:       fmt.Sprintf("ServiceNow %s rejects the credentials of alertmanager-webhook-servicenow (HTTP %d), incidents are not created nor updated.", snClient.baseURL, err.statusCode),
+		Instance:   snClient.baseURL,
+		StatusCode: err.statusCode,
+		Message:    err.message,
+	})
+}
+
+// sendAuthFailureNotification posts the notification, errors are logged
+func sendAuthFailureNotification(url string, notification authFailureNotification) {
+	body, _ := json.Marshal(notification)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			err = fmt.Errorf("HTTP code %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		log.Errorf("Error sending the ServiceNow authentication failure notification: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAuthenticationFailureNotification(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"User Not Authenticated"}}`))
+	}))
+	defer ts.Close()
+
+	notifications := make(chan authFailureNotification, 2)
+	notified := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notification := authFailureNotification{}
+		json.NewDecoder(r.Body).Decode(&notification)
+		notifications <- notification
+	}))
+	defer notified.Close()
+
+	snClient, err := NewServiceNowClient("instancename", "username", "password")
+	if err != nil {
+		t.Fatalf("Error occured on NewServiceNowClient: %s", err)
+	}
+	snClient.baseURL = ts.URL
+	snClient.authFailureNotification = AuthFailureNotificationConfig{URL: notified.URL, Interval: time.Hour}
+
+	before := testutil.ToFloat64(serviceNowAuthFailures.WithLabelValues("401"))
+	for i := 0; i < 2; i++ {
+		_, err := snClient.GetIncidents(map[string]string{})
+		if err == nil || serviceNowErrorClass(err) != errorClassAuthentication {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if failures := testutil.ToFloat64(serviceNowAuthFailures.WithLabelValues("401")) - before; failures != 2 {
+		t.Errorf("Unexpected authentication failures count: got %v, want 2", failures)
+	}
+
+	select {
+	case notification := <-notifications:
+		if notification.StatusCode != http.StatusUnauthorized || notification.Instance != ts.URL || len(notification.Text) == 0 {
+			t.Errorf("Unexpected notification: %+v", notification)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Authentication failure was not notified")
+	}
+	select {
+	case notification := <-notifications:
+		t.Errorf("Notifications must be sent once per interval, got %+v", notification)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
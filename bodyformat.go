@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// Formats of the incident text fields, rendered templates being plain text
+const (
+	fieldFormatPlain    = "plain"
+	fieldFormatMarkdown = "markdown"
+	fieldFormatHTML     = "html"
+	fieldFormatJSON     = "json"
+)
+
+var fieldFormatConverters = map[string]func(field string, value string) string{
+	fieldFormatPlain:    func(field string, value string) string { return value },
+	fieldFormatMarkdown: func(field string, value string) string { return toMarkdown(value) },
+	fieldFormatHTML:     toHTML,
+	fieldFormatJSON:     func(field string, value string) string { return toJSON(value) },
+}
+
+// validateFieldFormats checks the format of each field is supported
+func validateFieldFormats(formats map[string]string) error {
+	fields := make([]string, 0, len(formats))
+	for field := range formats {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	errs := strings.Builder{}
+	for _, field := range fields {
+		if _, ok := fieldFormatConverters[formats[field]]; !ok {
+			errs.WriteString(fmt.Sprintf("field_formats %s: unknown format %q\n", field, formats[field]))
+		}
+	}
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(errs.String(), "\n"))
+	}
+	return nil
+}
+
+// formatIncident converts the text fields of the incident to their configured format
+func formatIncident(incident Incident) {
+	for field := range config.FieldFormats {
+		if value, ok := incident[field].(string); ok {
+			incident[field] = formatFieldValue(field, value)
+		}
+	}
+}
+
+// formatFieldValue converts the plain text value of the field to its configured format, if any
+func formatFieldValue(field string, value string) string {
+	if convert, ok := fieldFormatConverters[config.FieldFormats[field]]; ok {
+		return convert(field, value)
+	}
+	return value
+}
+
+// toMarkdown escapes the Markdown markup characters and keeps the line breaks
+func toMarkdown(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune("\\`*_{}[]<>()#+-!|", r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return strings.Replace(b.String(), "\n", "  \n", -1)
+}
+
+// toHTML escapes the value and keeps the line breaks. Journal fields only render HTML within [code] tags.
+func toHTML(field string, value string) string {
+	escaped := strings.Replace(html.EscapeString(value), "\n", "<br>", -1)
+	if isJournalField(field) {
+		return "[code]" + escaped + "[/code]"
+	}
+	return escaped
+}
+
+// toJSON wraps the value in a JSON document
+func toJSON(value string) string {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(struct {
+		Text string `json:"text"`
+	}{Text: value})
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// isJournalField returns true for the journal fields of the incident table
+func isJournalField(field string) bool {
+	return field == "comments" || field == "work_notes" || field == config.Journal.Field
+}
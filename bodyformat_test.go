@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestFormatFieldValue(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.FieldFormats = map[string]string{
+		"description":       fieldFormatHTML,
+		"work_notes":        fieldFormatHTML,
+		"short_description": fieldFormatMarkdown,
+		"u_payload":         fieldFormatJSON,
+		"comments":          fieldFormatPlain,
+	}
+	defer func() { config.FieldFormats = nil }()
+
+	tests := []struct {
+		field string
+		value string
+		want  string
+	}{
+		{"description", "cpu > 90%\nhost <a>", "cpu &gt; 90%<br>host &lt;a&gt;"},
+		{"work_notes", "line 1\nline 2", "[code]line 1<br>line 2[/code]"},
+		{"short_description", "disk_full *now*\nsee #1", "disk\\_full \\*now\\*  \nsee \\#1"},
+		{"u_payload", "a \"b\" <c>", `{"text":"a \"b\" <c>"}`},
+		{"comments", "unchanged <b>", "unchanged <b>"},
+		{"close_notes", "unchanged <b>", "unchanged <b>"},
+	}
+	for _, test := range tests {
+		if got := formatFieldValue(test.field, test.value); got != test.want {
+			t.Errorf("Unexpected %s value: got %q, want %q", test.field, got, test.want)
+		}
+	}
+}
+
+func TestFormatFieldValue_Journal(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.FieldFormats = map[string]string{"work_notes": fieldFormatHTML}
+	config.Journal = JournalConfig{Templates: JournalTemplates{AlertsAdded: "{{ .Status }} & new"}}
+	defer func() { config.FieldFormats = nil; config.Journal = JournalConfig{} }()
+
+	incident := Incident{}
+	applyJournal(context.Background(), template.Data{Status: "firing"}, journalAlertsAdded, incident)
+	if incident["work_notes"] != "[code]firing &amp; new[/code]" {
+		t.Errorf("Unexpected journal entry: %v", incident["work_notes"])
+	}
+}
+
+func TestValidateFieldFormats(t *testing.T) {
+	if err := validateFieldFormats(map[string]string{"description": "html", "comments": "rtf"}); err == nil || err.Error() != `field_formats comments: unknown format "rtf"` {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := validateFieldFormats(map[string]string{"description": "markdown"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var checkConfigCommand = kingpin.Command("check-config", "Check the configuration file: its fields, templates and incident field values. Exits non-zero if invalid, e.g. in CI before deploys.")
+
+// incidentValueRanges are the ranges of the numeric values of the incident fields checked
+var incidentValueRanges = map[string][2]int{
+	"impact":   {1, 3},
+	"urgency":  {1, 3},
+	"priority": {1, 5},
+}
+
+// incidentTemplate is an incident field template of the configuration
+type incidentTemplate struct {
+	origin string
+	field  string
+	text   string
+}
+
+// checkConfig loads and validates the configuration file, then checks that its incident templates compile and that
+// their literal values are sane
+func checkConfig(path string) error {
+	if _, err := loadConfig(path); err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, t := range incidentTemplates() {
+		tmpl, err := newFieldTemplate(t.field, "")
+		if err == nil {
+			_, err = tmpl.Parse(t.text)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s %s template is invalid: %v", t.origin, t.field, err))
+			continue
+		}
+		if err := checkIncidentValue(t.field, t.text); err != nil {
+			errs = append(errs, fmt.Sprintf("%s %s: %v", t.origin, t.field, err))
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// incidentTemplates returns the incident field templates of the loaded configuration
+func incidentTemplates() []incidentTemplate {
+	var templates []incidentTemplate
+	add := func(origin string, fields map[string]string) {
+		for field, text := range fields {
+			templates = append(templates, incidentTemplate{origin: origin, field: field, text: text})
+		}
+	}
+
+	add("default_incident", config.DefaultIncident)
+	for value, profile := range config.Profiles.Profiles {
+		add("profiles "+value, profile.DefaultIncident)
+	}
+	for name, set := range config.TemplateSets.Sets {
+		add("template_sets "+name, set.DefaultIncident)
+	}
+	for _, variant := range config.TemplateVariants.Variants {
+		add("template_variants "+variant.Name, variant.DefaultIncident)
+	}
+	for _, level := range config.SeverityMapping.Levels {
+		add("severity_mapping "+level.Value, level.Fields)
+	}
+	add("shadow", config.Shadow.DefaultIncident)
+	add("unknown_resolved", config.Workflow.UnknownResolved.Fields)
+	return templates
+}
+
+// checkIncidentValue checks that the literal value of an incident field with a numeric range, such as impact, is in
+// its range. Templated values and display values without a leading number (e.g. "High") are not checked.
+func checkIncidentValue(field string, text string) error {
+	valueRange, ok := incidentValueRanges[field]
+	if !ok || strings.Contains(text, "{{") {
+		return nil
+	}
+	digits := strings.TrimLeftFunc(text, unicode.IsSpace)
+	if end := strings.IndexFunc(digits, func(r rune) bool { return !unicode.IsDigit(r) }); end >= 0 {
+		digits = digits[:end]
+	}
+	value, err := strconv.Atoi(digits)
+	if err != nil {
+		return nil
+	}
+	if value < valueRange[0] || value > valueRange[1] {
+		return fmt.Errorf("value %q is out of range, must be between %d and %d", text, valueRange[0], valueRange[1])
+	}
+	return nil
+}
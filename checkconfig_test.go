@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCheckConfig(t *testing.T) {
+	if err := checkConfig("config/servicenow_example.yml"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	file, err := ioutil.TempFile("", "servicenow*.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`
+service_now:
+  instance_name: "instance"
+  user_name: "user"
+  password: "password"
+workflow:
+  incident_group_key_field: "u_other_reference_1"
+default_incident:
+  impact: "4 - Low"
+  urgency: "2 - Medium"
+  short_description: "{{ .CommonLabels.alertname "
+severity_mapping:
+  levels:
+    - value: "critical"
+      fields:
+        priority: "0"
+`)
+	file.Close()
+
+	err = checkConfig(file.Name())
+	if err == nil {
+		t.Fatalf("Invalid configuration must fail the check")
+	}
+	want := []string{
+		`default_incident impact: value "4 - Low" is out of range, must be between 1 and 3`,
+		"default_incident short_description template is invalid",
+		`severity_mapping critical priority: value "0" is out of range, must be between 1 and 5`,
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("Unexpected errors: %v", err)
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, want[i]) {
+			t.Errorf("Unexpected error: got %q, want %q", line, want[i])
+		}
+	}
+	loadConfig("config/servicenow_example.yml")
+}
+
+func TestCheckIncidentValue(t *testing.T) {
+	tests := []struct {
+		field string
+		text  string
+		valid bool
+	}{
+		{"impact", "2-High", true},
+		{"impact", "3", true},
+		{"impact", "High", true},
+		{"impact", "{{ .CommonLabels.impact }}", true},
+		{"impact", "0", false},
+		{"urgency", " 5 - Planning", false},
+		{"priority", "5", true},
+		{"state", "42", true},
+	}
+	for _, test := range tests {
+		if err := checkIncidentValue(test.field, test.text); (err == nil) != test.valid {
+			t.Errorf("Unexpected check of %s %q: %v", test.field, test.text, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	cloudEventsSpecVersion     = "1.0"
+	cloudEventsStructuredMedia = "application/cloudevents+json"
+)
+
+// CloudEventsConfig - CloudEvents entry point configuration
+type CloudEventsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Accepted event types, any type is accepted if empty
+	Types []string `yaml:"types"`
+}
+
+// cloudEvent is a CloudEvent in structured content mode
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	ID              string          `json:"id"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+	DataBase64      string          `json:"data_base64"`
+}
+
+// cloudEvents receives CloudEvents wrapped alert payloads, in binary or structured content mode
+func cloudEvents(w http.ResponseWriter, r *http.Request) {
+	timer := prometheus.NewTimer(webhookRequestDuration.WithLabelValues("/cloudevents"))
+	defer timer.ObserveDuration()
+	// Processing may continue in background after the response, the context only carries the request ID
+	ctx := withRequestID(context.Background(), handleRequestID(w, r))
+	cfg := currentConfig()
+	if !authorizeWebhook(w, r, cfg.WebhookAuth, "/cloudevents") {
+		return
+	}
+	logger := requestLog(ctx)
+	event, err := readCloudEvent(r)
+	if err == nil {
+		err = validateCloudEvent(event, cfg.CloudEvents)
+	}
+	if err != nil {
+		logger.Errorf("Error reading CloudEvent : %v", err)
+		sendJSONResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	logger.Infof("Received CloudEvent: ID=%s, Type=%s, Source=%s", event.ID, event.Type, event.Source)
+
+	body := []byte(event.Data)
+	if len(event.DataBase64) > 0 {
+		if body, err = base64.StdEncoding.DecodeString(event.DataBase64); err != nil {
+			sendJSONResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	data, err := decodeBody(body)
+	if err != nil {
+		logger.Errorf("Error reading CloudEvent data : %v", err)
+		sendJSONResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if isDryRunRequest(r) {
+		processDryRun(ctx, w, data)
+		return
+	}
+	if forwardToShardOwner(ctx, w, r, cfg.Sharding, data) {
+		return
+	}
+
+	processAlertGroup(ctx, w, cfg, data)
+}
+
+// readCloudEvent reads a CloudEvent from a request, in structured mode if the content type
+// is application/cloudevents+json, in binary mode from ce-* headers otherwise
+func readCloudEvent(r *http.Request) (cloudEvent, error) {
+	defer r.Body.Close()
+	event := cloudEvent{}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return event, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == cloudEventsStructuredMedia {
+		err = json.Unmarshal(body, &event)
+		return event, err
+	}
+
+	event.SpecVersion = r.Header.Get("ce-specversion")
+	event.Type = r.Header.Get("ce-type")
+	event.Source = r.Header.Get("ce-source")
+	event.ID = r.Header.Get("ce-id")
+	event.DataContentType = mediaType
+	event.Data = body
+	return event, nil
+}
+
+func validateCloudEvent(event cloudEvent, c CloudEventsConfig) error {
+	if event.SpecVersion != cloudEventsSpecVersion {
+		return fmt.Errorf("unsupported CloudEvents specversion %q", event.SpecVersion)
+	}
+	if len(event.ID) == 0 || len(event.Source) == 0 || len(event.Type) == 0 {
+		return errors.New("CloudEvent id, source and type are mandatory")
+	}
+	if len(c.Types) == 0 {
+		return nil
+	}
+	for _, t := range c.Types {
+		if event.Type == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("CloudEvent type %q is not accepted", event.Type)
+}
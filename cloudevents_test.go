@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCloudEvents(t *testing.T) {
+	// Load a simple example of a body coming from AlertManager
+	data, err := ioutil.ReadFile("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	structured := append(append([]byte(`{"specversion":"1.0","type":"alert","source":"/am","id":"1","data":`), data...), '}')
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		body    []byte
+		want    int
+	}{
+		{
+			name:    "binary",
+			headers: map[string]string{"ce-specversion": "1.0", "ce-type": "alert", "ce-source": "/am", "ce-id": "1", "Content-Type": "application/json"},
+			body:    data,
+			want:    http.StatusOK,
+		},
+		{
+			name:    "structured",
+			headers: map[string]string{"Content-Type": "application/cloudevents+json; charset=utf-8"},
+			body:    structured,
+			want:    http.StatusOK,
+		},
+		{
+			name:    "missing_attributes",
+			headers: map[string]string{"ce-specversion": "1.0", "Content-Type": "application/json"},
+			body:    data,
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "type_not_accepted",
+			headers: map[string]string{"ce-specversion": "1.0", "ce-type": "other", "ce-source": "/am", "ce-id": "1"},
+			body:    data,
+			want:    http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loadConfig("config/servicenow_example.yml")
+			config.CloudEvents = CloudEventsConfig{Enabled: true, Types: []string{"alert"}}
+			snClientMock := new(MockedSnClient)
+			serviceNow = snClientMock
+			snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+			snClientMock.On("CreateIncident", mock.Anything).Return(Incident{}, nil)
+			snClientMock.On("UpdateIncident", mock.Anything, mock.Anything).Return(Incident{}, errors.New("Update should not be called"))
+
+			req := httptest.NewRequest("POST", "/cloudevents", bytes.NewReader(tt.body))
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(cloudEvents).ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.want {
+				t.Errorf("Wrong status code: got %v, want %v (%v)", status, tt.want, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultCoalescingPressureThreshold = 10
+
+// CoalescingConfig - Coalescing of the notifications of a group key under load. Alertmanager
+// notifications carry the full state of their group, so only the latest one needs processing.
+type CoalescingConfig struct {
+	// Window reached under alert storm, coalescing is disabled when not set
+	MaxWindow time.Duration `yaml:"max_window"`
+	// Number of in-flight notifications above which the window widens
+	PressureThreshold int `yaml:"pressure_threshold"`
+}
+
+func (c CoalescingConfig) validate() error {
+	if c.MaxWindow < 0 {
+		return fmt.Errorf("coalescing max_window must not be negative")
+	}
+	if c.PressureThreshold < 0 {
+		return fmt.Errorf("coalescing pressure_threshold must not be negative")
+	}
+	return nil
+}
+
+type coalescedGroup struct {
+	latest int
+	refs   int
+}
+
+// notificationCoalescer delays notifications by a window adapted to the number of
+// in-flight notifications, and detects the ones superseded meanwhile by a newer one
+type notificationCoalescer struct {
+	mu       sync.Mutex
+	inflight int
+	groups   map[string]*coalescedGroup
+}
+
+var coalescer = &notificationCoalescer{groups: make(map[string]*coalescedGroup)}
+
+// window returns the coalescing window for the number of in-flight notifications: none under the
+// pressure threshold, widening linearly up to the maximum window at twice the threshold
+func (c *notificationCoalescer) window(inflight int) time.Duration {
+	maxWindow := config.Coalescing.MaxWindow
+	if maxWindow <= 0 {
+		return 0
+	}
+	threshold := config.Coalescing.PressureThreshold
+	if threshold <= 0 {
+		threshold = defaultCoalescingPressureThreshold
+	}
+	if inflight <= threshold {
+		return 0
+	}
+	if inflight >= 2*threshold {
+		return maxWindow
+	}
+	return time.Duration(int64(maxWindow) * int64(inflight-threshold) / int64(threshold))
+}
+
+// arrive registers an in-flight notification of the group key, waits for the current window,
+// and returns true if a newer notification of the group key arrived meanwhile. The returned
+// function must be called when the notification has been processed.
+func (c *notificationCoalescer) arrive(groupKey string) (bool, func()) {
+	c.mu.Lock()
+	c.inflight++
+	group, ok := c.groups[groupKey]
+	if !ok {
+		group = &coalescedGroup{}
+		c.groups[groupKey] = group
+	}
+	group.latest++
+	group.refs++
+	seq := group.latest
+	window := c.window(c.inflight)
+	webhookInflightNotifications.Set(float64(c.inflight))
+	webhookCoalescingWindow.Set(window.Seconds())
+	c.mu.Unlock()
+
+	done := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.inflight--
+		group.refs--
+		if group.refs == 0 {
+			delete(c.groups, groupKey)
+		}
+		webhookInflightNotifications.Set(float64(c.inflight))
+		webhookCoalescingWindow.Set(c.window(c.inflight).Seconds())
+	}
+
+	if window > 0 {
+		time.Sleep(window)
+	}
+
+	c.mu.Lock()
+	superseded := config.Coalescing.MaxWindow > 0 && group.latest != seq
+	c.mu.Unlock()
+	if superseded {
+		webhookNotificationsCoalesced.Inc()
+	}
+	return superseded, done
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotificationCoalescer_Window(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	c := &notificationCoalescer{groups: make(map[string]*coalescedGroup)}
+	if window := c.window(100); window != 0 {
+		t.Errorf("Window must be zero when coalescing is disabled, got %v", window)
+	}
+
+	config.Coalescing = CoalescingConfig{MaxWindow: 10 * time.Second, PressureThreshold: 10}
+	tests := map[int]time.Duration{
+		1:  0,
+		10: 0,
+		15: 5 * time.Second,
+		20: 10 * time.Second,
+		50: 10 * time.Second,
+	}
+	for inflight, want := range tests {
+		if window := c.window(inflight); window != want {
+			t.Errorf("Unexpected window for %d in-flight notifications: got %v, want %v", inflight, window, want)
+		}
+	}
+}
+
+func TestNotificationCoalescer_Superseded(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Coalescing = CoalescingConfig{MaxWindow: 50 * time.Millisecond, PressureThreshold: 1}
+	c := &notificationCoalescer{groups: make(map[string]*coalescedGroup)}
+
+	// Keep the pressure above the threshold so that notifications wait for the window
+	_, doneOther := c.arrive("other")
+	defer doneOther()
+
+	results := make([]bool, 2)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			time.Sleep(time.Duration(i) * 10 * time.Millisecond)
+			superseded, done := c.arrive("group")
+			defer done()
+			results[i] = superseded
+		}(i)
+	}
+	wg.Wait()
+
+	if !results[0] || results[1] {
+		t.Errorf("Only the older notification must be superseded, got %v", results)
+	}
+	if _, ok := c.groups["group"]; ok {
+		t.Errorf("Processed group must be forgotten")
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/prometheus/alertmanager/template"
+)
+
+// observePayloadComposition records the composition of a received alert group, to tune the
+// Alertmanager grouping and the webhook capacity
+func observePayloadComposition(data template.Data) {
+	receiver := allowlistedLabelValue(data.Receiver, config.Metrics.ReceiverAllowlist)
+	webhookPayloadAlerts.WithLabelValues(receiver).Observe(float64(len(data.Alerts)))
+	for _, alert := range data.Alerts {
+		webhookAlertLabels.Observe(float64(len(alert.Labels)))
+	}
+	webhookReceivedAlerts.WithLabelValues(receiver, "firing").Add(float64(len(data.Alerts.Firing())))
+	webhookReceivedAlerts.WithLabelValues(receiver, "resolved").Add(float64(len(data.Alerts.Resolved())))
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObservePayloadComposition(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Metrics.ReceiverAllowlist = []string{"team"}
+
+	firing := testutil.ToFloat64(webhookReceivedAlerts.WithLabelValues("team", "firing"))
+	resolved := testutil.ToFloat64(webhookReceivedAlerts.WithLabelValues("team", "resolved"))
+	other := testutil.ToFloat64(webhookReceivedAlerts.WithLabelValues("other", "firing"))
+
+	observePayloadComposition(template.Data{
+		Receiver: "team",
+		Alerts: template.Alerts{
+			{Status: "firing", Labels: template.KV{"alertname": "a", "instance": "1"}},
+			{Status: "firing", Labels: template.KV{"alertname": "a", "instance": "2"}},
+			{Status: "resolved", Labels: template.KV{"alertname": "a", "instance": "3"}},
+		},
+	})
+	observePayloadComposition(template.Data{Receiver: "unknown", Alerts: template.Alerts{{Status: "firing"}}})
+
+	if got := testutil.ToFloat64(webhookReceivedAlerts.WithLabelValues("team", "firing")) - firing; got != 2 {
+		t.Errorf("Unexpected firing alerts: got %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(webhookReceivedAlerts.WithLabelValues("team", "resolved")) - resolved; got != 1 {
+		t.Errorf("Unexpected resolved alerts: got %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(webhookReceivedAlerts.WithLabelValues("other", "firing")) - other; got != 1 {
+		t.Errorf("Receivers out of the allowlist must be reported as other: got %v, want 1", got)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var unknownFieldError = regexp.MustCompile(`^line (\d+): field (\S+) not found in type (\S+)$`)
+
+// explainConfigError rewrites strict YAML unmarshal errors of unknown fields
+// into errors suggesting the closest known field
+func explainConfigError(err error) error {
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return err
+	}
+
+	var errs strings.Builder
+	for _, e := range typeErr.Errors {
+		m := unknownFieldError.FindStringSubmatch(e)
+		if m == nil {
+			errs.WriteString(e + "\n")
+			continue
+		}
+		errs.WriteString(fmt.Sprintf("line %s: unknown field %q", m[1], m[2]))
+		if suggestion := closestField(m[2], yamlFields(reflect.TypeOf(Config{}), m[3])); len(suggestion) > 0 {
+			errs.WriteString(fmt.Sprintf(", did you mean %q?", suggestion))
+		}
+		errs.WriteString("\n")
+	}
+	return errors.New("Config file is invalid\n" + errs.String())
+}
+
+// yamlFields returns the YAML field names of the struct type named typeName,
+// looked up recursively from the given type
+func yamlFields(t reflect.Type, typeName string) []string {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if t.String() == typeName {
+			if name := strings.Split(field.Tag.Get("yaml"), ",")[0]; len(name) > 0 {
+				fields = append(fields, name)
+			}
+		} else if nested := yamlFields(field.Type, typeName); nested != nil {
+			return nested
+		}
+	}
+	return fields
+}
+
+// closestField returns the field with the smallest edit distance to name, if close enough
+func closestField(name string, fields []string) string {
+	maxDistance := len(name)/3 + 1
+	suggestion := ""
+	for _, field := range fields {
+		if d := levenshtein(name, field); d <= maxDistance {
+			maxDistance = d
+			suggestion = field
+		}
+	}
+	return suggestion
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
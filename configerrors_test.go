@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLoadConfigContent_UnknownField(t *testing.T) {
+	configFile := `
+service_now:
+ instance_name: "instance"
+ usr_name: "SA"
+ password: "SA!"
+workflow:
+ ncident_group_key_field: "u_other_reference_1"
+`
+	_, err := loadConfigContent([]byte(configFile))
+	if err == nil {
+		t.Fatal("Should have an error on unknown fields")
+	}
+
+	want := `Config file is invalid
+line 4: unknown field "usr_name", did you mean "user_name"?
+line 7: unknown field "ncident_group_key_field", did you mean "incident_group_key_field"?
+`
+	if err.Error() != want {
+		t.Errorf("Unexpected error: got %v, want %v", err, want)
+	}
+}
+
+func Test_closestField(t *testing.T) {
+	fields := []string{"instance_name", "user_name", "password"}
+	if got := closestField("pasword", fields); got != "password" {
+		t.Errorf("closestField() = %v, want %v", got, "password")
+	}
+	if got := closestField("proxy_url", fields); got != "" {
+		t.Errorf("closestField() = %v, want none", got)
+	}
+}
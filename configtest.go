@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	testConfigCommand = kingpin.Command("test-config", "Run the test cases of a YAML file against the configuration, without calling ServiceNow.")
+	testConfigFile    = testConfigCommand.Arg("file", "YAML file of test cases.").Required().String()
+)
+
+// ConfigTestSuite - Test cases run against the configuration
+type ConfigTestSuite struct {
+	Tests []ConfigTestCase `yaml:"tests"`
+}
+
+// ConfigTestCase - Payload fixture and the expected incident action
+type ConfigTestCase struct {
+	Name string `yaml:"name"`
+	// Payload file, relative to the test cases file if not absolute
+	Payload string `yaml:"payload"`
+	// Incidents returned by ServiceNow for the group key
+	ExistingIncidents []map[string]string `yaml:"existing_incidents"`
+	Expect            ConfigTestExpect    `yaml:"expect"`
+}
+
+// ConfigTestExpect - Expected incident action (create, update, reopen or none), ServiceNow instance and fields
+// sent to ServiceNow
+type ConfigTestExpect struct {
+	Action string `yaml:"action"`
+	// Name of the service_now_instances entry the alert group is routed to, empty for the default instance
+	Instance *string           `yaml:"instance"`
+	Fields   map[string]string `yaml:"fields"`
+}
+
+// recordingSnClient is a ServiceNow client recording the incidents sent, without any request
+type recordingSnClient struct {
+	existingIncidents []Incident
+	sent              Incident
+}
+
+func (c *recordingSnClient) CreateIncident(incidentParam Incident) (Incident, error) {
+	c.sent = incidentParam
+	return Incident{"sys_id": "test", "number": "TEST", "state": "1"}, nil
+}
+
+func (c *recordingSnClient) GetIncidents(params map[string]string) ([]Incident, error) {
+	return c.existingIncidents, nil
+}
+
+func (c *recordingSnClient) GetRecords(table string, params map[string]string) ([]Incident, error) {
+	// Referenced records, such as assignment groups, are considered valid
+	return []Incident{{"sys_id": "test", "active": "true"}}, nil
+}
+
+func (c *recordingSnClient) CreateRecord(table string, recordParam Incident) (Incident, error) {
+	return Incident{"sys_id": "test"}, nil
+}
+
+func (c *recordingSnClient) AttachFile(table string, sysID string, fileName string, contentType string, content []byte) error {
+	return nil
+}
+
+func (c *recordingSnClient) UpdateIncident(incidentParam Incident, sysID string) (Incident, error) {
+	c.sent = incidentParam
+	return Incident{"sys_id": sysID}, nil
+}
+
+// runConfigTests runs the test cases of the file against the loaded configuration, and returns the number of failed tests
+func runConfigTests(path string, w io.Writer) (int, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	suite := ConfigTestSuite{}
+	if err := yaml.UnmarshalStrict(content, &suite); err != nil {
+		return 0, explainConfigError(err)
+	}
+
+	// Test cases must not depend on state or side effects of previous ones, nor have side effects outside of the
+	// webhook: payloads are not archived, and hooks, external commands and URLs, are not invoked
+	archives.configure(nil)
+	incidents.configure(IncidentCacheConfig{})
+	progress.configure(0, "")
+	scheduler = newActionScheduler()
+	configLock.Lock()
+	config.Hooks = nil
+	configLock.Unlock()
+
+	failed := 0
+	for _, test := range suite.Tests {
+		failures, err := runConfigTest(test, filepath.Dir(path))
+		if err != nil {
+			failures = []string{err.Error()}
+		}
+		if len(failures) == 0 {
+			fmt.Fprintf(w, "PASS %s\n", test.Name)
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "FAIL %s\n", test.Name)
+		for _, failure := range failures {
+			fmt.Fprintf(w, "  %s\n", failure)
+		}
+	}
+	fmt.Fprintf(w, "%d passed, %d failed\n", len(suite.Tests)-failed, failed)
+	return failed, nil
+}
+
+// runConfigTest runs a test case and returns its failures
+func runConfigTest(test ConfigTestCase, dir string) ([]string, error) {
+	payload := test.Payload
+	if !filepath.IsAbs(payload) {
+		payload = filepath.Join(dir, payload)
+	}
+	body, err := ioutil.ReadFile(payload)
+	if err != nil {
+		return nil, err
+	}
+	data, err := decodeBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	snClient := &recordingSnClient{}
+	for _, incident := range test.ExistingIncidents {
+		existingIncident := Incident{}
+		for field, value := range incident {
+			existingIncident[field] = value
+		}
+		snClient.existingIncidents = append(snClient.existingIncidents, existingIncident)
+	}
+	serviceNow = snClient
+	// Alert groups of all the instances are recorded
+	serviceNowInstances = nil
+	history = newGroupHistory(defaultHistoryMaxEntries)
+
+	if err := onAlertGroup(context.Background(), data); err != nil {
+		return nil, err
+	}
+
+	var failures []string
+	action := "none"
+	if entries, _ := history.get(getGroupKey(data)); len(entries) > 0 {
+		action = entries[len(entries)-1].Action
+	}
+	if len(test.Expect.Action) > 0 && action != test.Expect.Action {
+		failures = append(failures, fmt.Sprintf("action: got %q, want %q", action, test.Expect.Action))
+	}
+	if instance := serviceNowInstanceName(data); test.Expect.Instance != nil && instance != *test.Expect.Instance {
+		failures = append(failures, fmt.Sprintf("instance: got %q, want %q", instance, *test.Expect.Instance))
+	}
+
+	fields := make([]string, 0, len(test.Expect.Fields))
+	for field := range test.Expect.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		got, ok := snClient.sent[field]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("field %s: not sent, want %q", field, test.Expect.Fields[field]))
+		} else if fmt.Sprint(got) != test.Expect.Fields[field] {
+			failures = append(failures, fmt.Sprintf("field %s: got %q, want %q", field, fmt.Sprint(got), test.Expect.Fields[field]))
+		}
+	}
+	return failures, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunConfigTests_Example(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+
+	var out bytes.Buffer
+	failed, err := runConfigTests("config/servicenow_example_tests.yml", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 0 {
+		t.Errorf("Example config tests must pass:\n%s", out.String())
+	}
+}
+
+func TestRunConfigTests_NoHooks(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	invoked := 0
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		invoked++
+		w.Write([]byte(`{"veto": true}`))
+	}))
+	defer hook.Close()
+	config.Hooks = []HookConfig{{Name: "veto", URL: hook.URL}}
+
+	var out bytes.Buffer
+	failed, err := runConfigTests("config/servicenow_example_tests.yml", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 0 || invoked != 0 {
+		t.Errorf("Hooks must not be invoked by config tests, invoked %d times:\n%s", invoked, out.String())
+	}
+}
+
+func TestRunConfigTests_Failure(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	dir, err := ioutil.TempDir("", "configtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	payload, err := filepath.Abs("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := `tests:
+  - name: "wrong expectations"
+    payload: "` + payload + `"
+    expect:
+      action: "update"
+      instance: "prod"
+      fields:
+        category: "Network"
+`
+	path := filepath.Join(dir, "tests.yml")
+	if err := ioutil.WriteFile(path, []byte(tests), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	failed, err := runConfigTests(path, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 1 {
+		t.Errorf("Unexpected failed tests: got %v, want %v", failed, 1)
+	}
+	for _, want := range []string{`action: got "create", want "update"`, `instance: got "", want "prod"`, `field category: got "<Software>", want "Network"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Missing failure %s in output:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// CorrelationIDConfig - Source of the ID stored in the incident group key field and used to find the incident of an
+// alert group, instead of the group key hash. Allows lining up with incidents created by other tools.
+type CorrelationIDConfig struct {
+	// Common annotation (or label) holding the ID
+	Annotation string `yaml:"annotation"`
+	// Go template rendering the ID
+	Template string `yaml:"template"`
+	// URL of a service returning the ID ({"id": "..."}) of the alert group payload POSTed to it
+	ServiceURL string `yaml:"service_url"`
+	// Timeout of a call to the service, 10s by default
+	Timeout time.Duration `yaml:"timeout"`
+	// Duration the IDs returned by the service are cached by group key, 24h by default
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+const (
+	defaultCorrelationTimeout  = 10 * time.Second
+	defaultCorrelationCacheTTL = 24 * time.Hour
+)
+
+func (c CorrelationIDConfig) validate() error {
+	sources := 0
+	for _, source := range []string{c.Annotation, c.Template, c.ServiceURL} {
+		if len(source) > 0 {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("correlation_id must have only one of annotation, template or service_url")
+	}
+	if c.Timeout < 0 || c.CacheTTL < 0 {
+		return fmt.Errorf("correlation_id timeout and cache_ttl must not be negative")
+	}
+	return nil
+}
+
+func (c CorrelationIDConfig) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return defaultCorrelationTimeout
+}
+
+func (c CorrelationIDConfig) cacheTTL() time.Duration {
+	if c.CacheTTL > 0 {
+		return c.CacheTTL
+	}
+	return defaultCorrelationCacheTTL
+}
+
+// correlationID is an ID returned by the correlation ID service
+type correlationID struct {
+	id      string
+	expires time.Time
+}
+
+// correlationIDs caches the IDs returned by the correlation ID service, by group key, until they expire
+var correlationIDs = struct {
+	sync.Mutex
+	ids map[string]correlationID
+}{ids: make(map[string]correlationID)}
+
+// getCorrelationID returns the ID of the alert group incident from the configured source,
+// falling back to the group key hash if the source provides no ID. It must be called while
+// configLock is held, as onAlertGroup does: locking again would deadlock with a pending reload.
+func getCorrelationID(ctx context.Context, data template.Data) string {
+	c := config.Workflow.CorrelationID
+	var id string
+	var err error
+	switch {
+	case len(c.Annotation) > 0:
+		id = data.CommonAnnotations[c.Annotation]
+		if len(id) == 0 {
+			id = data.CommonLabels[c.Annotation]
+		}
+	case len(c.Template) > 0:
+		id, err = applyTemplate("correlation_id", c.Template, data)
+	case len(c.ServiceURL) > 0:
+		id, err = fetchCorrelationID(ctx, c, data)
+	}
+	if err != nil {
+		alertGroupLog(ctx, data).Errorf("Error getting correlation ID for alert group key: %s, group key is used: %v", getGroupKey(data), err)
+	}
+	if !isQueryValue(id) {
+		alertGroupLog(ctx, data).Errorf("Correlation ID %q of alert group key: %s cannot be looked up, group key is used", id, getGroupKey(data))
+		return getGroupKey(data)
+	}
+	if len(id) == 0 {
+		return getGroupKey(data)
+	}
+	return id
+}
+
+// fetchCorrelationID returns the ID given by the correlation ID service for the alert group, cached by group key
+func fetchCorrelationID(ctx context.Context, c CorrelationIDConfig, data template.Data) (string, error) {
+	groupKey := getGroupKey(data)
+	correlationIDs.Lock()
+	cached, ok := correlationIDs.ids[groupKey]
+	correlationIDs.Unlock()
+	if ok && now().Before(cached.expires) {
+		return cached.id, nil
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	req, err := http.NewRequest("POST", c.ServiceURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("correlation ID service returned the HTTP error code: %v", resp.StatusCode)
+	}
+
+	response := struct {
+		ID string `json:"id"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+	if len(response.ID) > 0 {
+		correlationIDs.Lock()
+		for key, cached := range correlationIDs.ids {
+			if !now().Before(cached.expires) {
+				delete(correlationIDs.ids, key)
+			}
+		}
+		correlationIDs.ids[groupKey] = correlationID{id: response.ID, expires: now().Add(c.cacheTTL())}
+		correlationIDs.Unlock()
+	}
+	return response.ID, nil
+}
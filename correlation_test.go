@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestGetCorrelationID(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	data := template.Data{
+		GroupLabels:       template.KV{"alertname": "test"},
+		CommonLabels:      template.KV{"alertname": "test", "service": "db"},
+		CommonAnnotations: template.KV{"legacy_id": "LEGACY-1"},
+	}
+
+	if id := getCorrelationID(context.Background(), data); id != getGroupKey(data) {
+		t.Errorf("Group key must be used by default, got %v", id)
+	}
+
+	config.Workflow.CorrelationID = CorrelationIDConfig{Annotation: "legacy_id"}
+	if id := getCorrelationID(context.Background(), data); id != "LEGACY-1" {
+		t.Errorf("Unexpected annotation correlation ID: got %v, want %v", id, "LEGACY-1")
+	}
+	if id := getCorrelationID(context.Background(), template.Data{GroupLabels: data.GroupLabels}); id != getGroupKey(data) {
+		t.Errorf("Group key must be used when the annotation is missing, got %v", id)
+	}
+
+	config.Workflow.CorrelationID = CorrelationIDConfig{Template: "{{ .CommonLabels.service }}-{{ .CommonLabels.alertname }}"}
+	if id := getCorrelationID(context.Background(), data); id != "db-test" {
+		t.Errorf("Unexpected template correlation ID: got %v, want %v", id, "db-test")
+	}
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"id": "SERVICE-1"}`))
+	}))
+	defer ts.Close()
+	config.Workflow.CorrelationID = CorrelationIDConfig{ServiceURL: ts.URL}
+	for i := 0; i < 2; i++ {
+		if id := getCorrelationID(context.Background(), data); id != "SERVICE-1" {
+			t.Errorf("Unexpected service correlation ID: got %v, want %v", id, "SERVICE-1")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Service correlation ID must be cached, got %v calls", calls)
+	}
+}
+
+func TestGetCorrelationID_QueryValue(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.CorrelationID = CorrelationIDConfig{Annotation: "legacy_id"}
+	for _, id := range []string{"LEGACY-1^ORactive=true", "LEGACY=1", "LEGACY-1\n"} {
+		data := template.Data{GroupLabels: template.KV{"alertname": "test"}, CommonAnnotations: template.KV{"legacy_id": id}}
+		if got := getCorrelationID(context.Background(), data); got != getGroupKey(data) {
+			t.Errorf("Correlation ID %q must not be used in queries, got %v", id, got)
+		}
+	}
+}
+
+func TestFetchCorrelationID_CacheAndTimeout(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { now = time.Now }()
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/slow" {
+			time.Sleep(time.Second)
+		}
+		w.Write([]byte(`{"id": "SERVICE-1"}`))
+	}))
+	defer ts.Close()
+
+	data := template.Data{GroupLabels: template.KV{"alertname": "cached"}}
+	c := CorrelationIDConfig{ServiceURL: ts.URL, CacheTTL: time.Minute}
+	for _, offset := range []time.Duration{0, 30 * time.Second, 2 * time.Minute} {
+		now = func() time.Time { return time.Now().Add(offset) }
+		if _, err := fetchCorrelationID(context.Background(), c, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Service correlation ID must be cached until it expires, got %v calls", calls)
+	}
+
+	c = CorrelationIDConfig{ServiceURL: ts.URL + "/slow", Timeout: 50 * time.Millisecond}
+	if _, err := fetchCorrelationID(context.Background(), c, template.Data{GroupLabels: template.KV{"alertname": "slow"}}); err == nil {
+		t.Errorf("Slow correlation ID service must time out")
+	}
+}
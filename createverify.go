@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// CreateVerificationConfig - Read of the created incidents confirming they exist and their fields round-tripped, as
+// ServiceNow business rules may silently rewrite or discard fields
+type CreateVerificationConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Fields compared with the written values, only the existence of the incident is checked when not set
+	Fields []string `yaml:"fields"`
+}
+
+// verifyCreatedIncident starts verifying the created incident in the background, if configured.
+// It must be called while configLock is held, the verification using the configuration at that time.
+func verifyCreatedIncident(ctx context.Context, data template.Data, written Incident, created Incident) {
+	if !config.Workflow.CreateVerification.Enabled || isDryRun(ctx) {
+		return
+	}
+	go checkCreatedIncident(serviceNowFor(ctx, data), config.Workflow.Source, getGroupKey(data), written, created, config.Workflow.CreateVerification.Fields)
+}
+
+// checkCreatedIncident reads the created incident, and logs and counts it if missing or if its fields differ from
+// the written ones
+func checkCreatedIncident(client ServiceNow, source SourceConfig, groupKey string, written Incident, created Incident, fields []string) {
+	found, err := client.GetIncidents(withSource(source, map[string]string{
+		"sysparm_query":  "sys_id=" + created.GetSysID(),
+		"sysparm_fields": strings.Join(append([]string{"sys_id", "number"}, fields...), ","),
+	}))
+	if err != nil {
+		serviceNowError.Inc()
+		webhookCreateVerifications.WithLabelValues("error").Inc()
+		groupKeyLog(groupKey, created).Errorf("Error verifying created incident (%s) for alert group key: %s: %v", created.GetNumber(), groupKey, err)
+		return
+	}
+	if len(found) == 0 {
+		webhookCreateVerifications.WithLabelValues("missing").Inc()
+		groupKeyLog(groupKey, created).Warnf("Created incident (%s) for alert group key: %s is not found, it may have been discarded by ServiceNow", created.GetNumber(), groupKey)
+		return
+	}
+
+	if discrepancies := compareWrittenFields(written, found[0], fields); len(discrepancies) > 0 {
+		webhookCreateVerifications.WithLabelValues("mismatch").Inc()
+		groupKeyLog(groupKey, created).Warnf("Created incident (%s) for alert group key: %s differs from the written one: %s", created.GetNumber(), groupKey, strings.Join(discrepancies, ", "))
+		return
+	}
+	webhookCreateVerifications.WithLabelValues("ok").Inc()
+}
+
+// compareWrittenFields returns the discrepancies between the written and the read fields, sorted by field
+func compareWrittenFields(written Incident, read Incident, fields []string) []string {
+	var discrepancies []string
+	for _, field := range fields {
+		want, ok := written[field]
+		if !ok {
+			continue
+		}
+		got, ok := read[field]
+		if !ok {
+			discrepancies = append(discrepancies, fmt.Sprintf("%s is discarded", field))
+		} else if fmt.Sprint(got) != fmt.Sprint(want) {
+			discrepancies = append(discrepancies, fmt.Sprintf("%s is %q instead of %q", field, fmt.Sprint(got), fmt.Sprint(want)))
+		}
+	}
+	sort.Strings(discrepancies)
+	return discrepancies
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCheckCreatedIncident(t *testing.T) {
+	written := Incident{"short_description": "Disk full", "impact": "2", "comments": "Alerts"}
+	created := Incident{"sys_id": "42", "number": "INC42"}
+	fields := []string{"short_description", "impact"}
+	tests := []struct {
+		result string
+		found  []Incident
+		err    error
+	}{
+		{"ok", []Incident{{"sys_id": "42", "short_description": "Disk full", "impact": "2"}}, nil},
+		{"mismatch", []Incident{{"sys_id": "42", "short_description": "Disk full", "impact": "3"}}, nil},
+		{"missing", []Incident{}, nil},
+		{"error", []Incident{}, errors.New("timeout")},
+	}
+	for _, test := range tests {
+		snClientMock := new(MockedSnClient)
+		snClientMock.On("GetIncidents", mock.Anything).Return(test.found, test.err)
+
+		before := testutil.ToFloat64(webhookCreateVerifications.WithLabelValues(test.result))
+		checkCreatedIncident(snClientMock, SourceConfig{}, "group", written, created, fields)
+		snClientMock.AssertCalled(t, "GetIncidents", map[string]string{"sysparm_query": "sys_id=42", "sysparm_fields": "sys_id,number,short_description,impact"})
+		if got := testutil.ToFloat64(webhookCreateVerifications.WithLabelValues(test.result)); got != before+1 {
+			t.Errorf("Unexpected %s verifications: got %v, want %v", test.result, got, before+1)
+		}
+	}
+}
+
+func TestCompareWrittenFields(t *testing.T) {
+	written := Incident{"short_description": "Disk full", "impact": "2"}
+	read := Incident{"short_description": "Rewritten", "urgency": "1"}
+	got := compareWrittenFields(written, read, []string{"urgency", "short_description", "impact"})
+	want := []string{"impact is discarded", `short_description is "Rewritten" instead of "Disk full"`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected discrepancies: got %v, want %v", got, want)
+	}
+}
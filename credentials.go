@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envVarReference matches ${NAME} references to environment variables, and their $${NAME} escaped form
+var envVarReference = regexp.MustCompile(`\$(\$?)\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnvVars replaces the ${NAME} references of the configuration content by the value of the environment
+// variables, $${NAME} being kept as ${NAME}. References to undefined variables are errors.
+func expandEnvVars(content []byte) ([]byte, error) {
+	var missing []string
+	expanded := envVarReference.ReplaceAllFunc(content, func(reference []byte) []byte {
+		m := envVarReference.FindSubmatch(reference)
+		if len(m[1]) > 0 {
+			return reference[1:]
+		}
+		value, ok := os.LookupEnv(string(m[2]))
+		if !ok {
+			missing = append(missing, string(m[2]))
+		}
+		return []byte(value)
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("environment variables referenced in the configuration are not set: %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// loadUserNameFiles sets the user names of the ServiceNow configurations from their user_name_file, if any
+func loadUserNameFiles(c *Config) error {
+	if err := loadUserNameFile(&c.ServiceNow); err != nil {
+		return err
+	}
+	for i := range c.ServiceNowInstances {
+		if err := loadUserNameFile(&c.ServiceNowInstances[i].ServiceNow); err != nil {
+			return fmt.Errorf("service_now_instances %s %v", c.ServiceNowInstances[i].Name, err)
+		}
+	}
+	return nil
+}
+
+func loadUserNameFile(c *ServiceNowConfig) error {
+	if len(c.UserNameFile) == 0 {
+		return nil
+	}
+	userName, err := readSecretFile(c.UserNameFile)
+	if err != nil {
+		return fmt.Errorf("unable to read user_name_file: %v", err)
+	}
+	c.UserName = userName
+	return nil
+}
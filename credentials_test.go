@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnvVars(t *testing.T) {
+	os.Setenv("WEBHOOK_TEST_PASSWORD", "s3cr3t")
+	defer os.Unsetenv("WEBHOOK_TEST_PASSWORD")
+
+	content := `password: "${WEBHOOK_TEST_PASSWORD}"
+replacement: "$${name}/${1}"
+template: "{{ $value := .Status }}{{ $value }}"`
+	want := `password: "s3cr3t"
+replacement: "${name}/${1}"
+template: "{{ $value := .Status }}{{ $value }}"`
+	got, err := expandEnvVars([]byte(content))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Unexpected expansion: got %s, want %s", got, want)
+	}
+
+	if _, err := expandEnvVars([]byte(`password: "${WEBHOOK_TEST_UNDEFINED}"`)); err == nil || !strings.Contains(err.Error(), "WEBHOOK_TEST_UNDEFINED") {
+		t.Errorf("Undefined variable must be an error: %v", err)
+	}
+}
+
+func TestLoadConfig_Credentials(t *testing.T) {
+	defer loadConfig("config/servicenow_example.yml")
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	userNameFile := filepath.Join(dir, "user")
+	if err := ioutil.WriteFile(userNameFile, []byte("file-user\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("WEBHOOK_TEST_INSTANCE", "env-instance")
+	defer os.Unsetenv("WEBHOOK_TEST_INSTANCE")
+
+	loaded, err := loadConfigContent([]byte(`
+service_now:
+  instance_name: "${WEBHOOK_TEST_INSTANCE}"
+  user_name_file: "` + userNameFile + `"
+  password: "password"
+workflow:
+  incident_group_key_field: "u_other_reference_1"
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loaded.ServiceNow.InstanceName != "env-instance" || loaded.ServiceNow.UserName != "file-user" {
+		t.Errorf("Unexpected ServiceNow config: %+v", loaded.ServiceNow)
+	}
+
+	if _, err := loadConfigContent([]byte("service_now:\n  user_name_file: \"" + filepath.Join(dir, "missing") + "\"\n")); err == nil {
+		t.Errorf("Missing user_name_file must be an error")
+	}
+}
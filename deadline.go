@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// backgroundAttempts is the number of attempts of a notification processed in background, as
+// Alertmanager has been answered and won't retry it
+const backgroundAttempts = 5
+
+// processWithDeadline manages the incident of the alert group, returning false if the deadline is
+// reached first. Processing then continues in background: retryable errors are queued when the queue
+// is enabled, retried with the backoff otherwise, and the payload is dead-lettered once they are
+// exhausted or on a non retryable error. The done function is called once processing ends.
+func processWithDeadline(ctx context.Context, data template.Data, deadline time.Duration, retry RetryConfig, done func()) (bool, error) {
+	if deadline <= 0 {
+		defer done()
+		return true, onAlertGroup(ctx, data)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- onAlertGroup(ctx, data)
+	}()
+
+	timer := time.NewTimer(deadline)
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		done()
+		return true, err
+	case <-timer.C:
+	}
+
+	webhookDeadlineExceeded.Inc()
+	alertGroupLog(ctx, data).Warnf("Notification of alert group key: %s is not processed within %s, processing continues in background", getGroupKey(data), deadline)
+	go func() {
+		defer done()
+		completeInBackground(ctx, data, <-result, retry)
+	}()
+	return false, nil
+}
+
+// completeInBackground handles the result of a notification processed after its deadline
+func completeInBackground(ctx context.Context, data template.Data, err error, retry RetryConfig) {
+	for attempt := 1; err != nil && isRetryableError(err); attempt++ {
+		if queue.enabled() {
+			if err = queue.enqueue(data); err == nil {
+				alertGroupLog(ctx, data).Infof("Notification of alert group key: %s failed in background and is queued", getGroupKey(data))
+				return
+			}
+			break
+		}
+		if attempt >= backgroundAttempts {
+			break
+		}
+		backoff := retry.backoff(attempt, 0)
+		alertGroupLog(ctx, data).Warnf("Error managing incident from alert in background, retrying in %s : %v", backoff, err)
+		time.Sleep(backoff)
+		err = onAlertGroup(ctx, data)
+	}
+	if err != nil {
+		alertGroupLog(ctx, data).Errorf("Error managing incident from alert in background, payload is dead-lettered : %v", err)
+		deadLetterPayload(data)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestProcessWithDeadline_Completed(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, errors.New("lookup failed"))
+
+	doneCalled := false
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "deadline-completed"}}
+	completed, err := processWithDeadline(context.Background(), data, time.Second, RetryConfig{}, func() { doneCalled = true })
+	if !completed || err == nil {
+		t.Errorf("Processing must complete with its error: got %v, %v", completed, err)
+	}
+	if !doneCalled {
+		t.Error("Done must be called once processing ends")
+	}
+}
+
+func TestProcessAlertGroup_DeadlineExceeded(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.RequestDeadline = 10 * time.Millisecond
+
+	release := make(chan struct{})
+	created := make(chan struct{})
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil).Run(func(mock.Arguments) {
+		<-release
+	})
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{"sys_id": "42", "number": "INC42"}, nil).Run(func(mock.Arguments) {
+		close(created)
+	})
+
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "deadline-exceeded"}}
+	rr := httptest.NewRecorder()
+	processAlertGroup(context.Background(), rr, config, data)
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("Wrong status code: got %v, want %v", rr.Code, http.StatusAccepted)
+	}
+
+	close(release)
+	select {
+	case <-created:
+	case <-time.After(time.Second):
+		t.Fatal("Processing must continue in background")
+	}
+
+	// Wait for the background processing to end before the next test
+	for inflight := 1; inflight > 0; time.Sleep(time.Millisecond) {
+		coalescer.mu.Lock()
+		inflight = coalescer.inflight
+		coalescer.mu.Unlock()
+	}
+}
+
+func TestProcessAlertGroup_DeadlineExceededRetried(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.RequestDeadline = 10 * time.Millisecond
+	config.ServiceNow.Retry.InitialBackoff = time.Millisecond
+	deadLetters := testutil.ToFloat64(webhookDeadLetters)
+
+	// ServiceNow fails after the deadline, then recovers
+	release := make(chan struct{})
+	created := make(chan struct{})
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, &serviceNowHTTPError{statusCode: http.StatusServiceUnavailable}).Run(func(mock.Arguments) {
+		<-release
+	}).Once()
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{"sys_id": "42", "number": "INC42"}, nil).Run(func(mock.Arguments) {
+		close(created)
+	})
+
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "deadline-retried"}}
+	rr := httptest.NewRecorder()
+	processAlertGroup(context.Background(), rr, config, data)
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("Wrong status code: got %v, want %v", rr.Code, http.StatusAccepted)
+	}
+
+	close(release)
+	select {
+	case <-created:
+	case <-time.After(time.Second):
+		t.Fatal("Retryable background failure must be retried")
+	}
+	for inflight := 1; inflight > 0; time.Sleep(time.Millisecond) {
+		coalescer.mu.Lock()
+		inflight = coalescer.inflight
+		coalescer.mu.Unlock()
+	}
+	if got := testutil.ToFloat64(webhookDeadLetters); got != deadLetters {
+		t.Errorf("Retryable background failure must not be dead-lettered: got %v dead letters, want %v", got, deadLetters)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DirectoryConfig - In-memory directory of the ServiceNow assignment groups and users, refreshed periodically and used
+// instead of per-incident reference lookups
+type DirectoryConfig struct {
+	// Interval between two refreshes, the directory is disabled when not set
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+// serviceNowDirectory maps the names of the active assignment groups and users to their sys_id
+type serviceNowDirectory struct {
+	mu          sync.RWMutex
+	loaded      bool
+	refreshedAt time.Time
+	groups      map[string]string
+	groupIDs    map[string]bool
+	users       map[string]string
+	userIDs     map[string]bool
+}
+
+var directory = &serviceNowDirectory{}
+
+// refresh reloads the directory, the previous one being kept on error
+func (d *serviceNowDirectory) refresh() error {
+	groups, groupIDs, err := loadDirectoryRecords("sys_user_group", "name")
+	if err != nil {
+		return err
+	}
+	users, userIDs, err := loadDirectoryRecords("sys_user", "user_name")
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.loaded = true
+	d.refreshedAt = now()
+	d.groups, d.groupIDs = groups, groupIDs
+	d.users, d.userIDs = users, userIDs
+	d.mu.Unlock()
+	webhookDirectoryEntries.WithLabelValues("group").Set(float64(len(groups)))
+	webhookDirectoryEntries.WithLabelValues("user").Set(float64(len(users)))
+	webhookDirectoryLastRefresh.Set(float64(now().Unix()))
+	lookupCache.persist()
+	return nil
+}
+
+// loadDirectoryRecords returns the active records of the table by name, and their sys_id
+func loadDirectoryRecords(table string, nameField string) (map[string]string, map[string]bool, error) {
+	records, err := serviceNow.GetRecords(table, map[string]string{
+		"sysparm_query":  "active=true",
+		"sysparm_fields": "sys_id," + nameField,
+	})
+	if err != nil {
+		serviceNowError.Inc()
+		return nil, nil, err
+	}
+	names := make(map[string]string, len(records))
+	sysIDs := make(map[string]bool, len(records))
+	for _, record := range records {
+		sysID := record.GetSysID()
+		if len(sysID) == 0 {
+			continue
+		}
+		names[fmt.Sprint(record[nameField])] = sysID
+		sysIDs[sysID] = true
+	}
+	return names, sysIDs, nil
+}
+
+// lookupGroup returns the sys_id of the active group, by name or sys_id. Found is only meaningful when loaded is true.
+func (d *serviceNowDirectory) lookupGroup(group string) (sysID string, found bool, loaded bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return lookupDirectory(d.groups, d.groupIDs, group, d.loaded)
+}
+
+// lookupUser returns the sys_id of the active user, by user name or sys_id. Found is only meaningful when loaded is true.
+func (d *serviceNowDirectory) lookupUser(user string) (sysID string, found bool, loaded bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return lookupDirectory(d.users, d.userIDs, user, d.loaded)
+}
+
+func lookupDirectory(names map[string]string, sysIDs map[string]bool, key string, loaded bool) (string, bool, bool) {
+	if !loaded {
+		return "", false, false
+	}
+	if sysIDs[key] {
+		return key, true, true
+	}
+	sysID, ok := names[key]
+	return sysID, ok, true
+}
+
+// run refreshes the directory at every interval
+func (d *serviceNowDirectory) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := d.refresh(); err != nil {
+			log.Errorf("Error refreshing the ServiceNow directory: %v", err)
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestServiceNowDirectory(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetRecords", "sys_user_group", mock.Anything).Return([]Incident{{"sys_id": "g1", "name": "DBA"}}, nil)
+	snClientMock.On("GetRecords", "sys_user", mock.Anything).Return([]Incident{{"sys_id": "u1", "user_name": "jdoe"}}, nil)
+
+	d := &serviceNowDirectory{}
+	if _, _, loaded := d.lookupGroup("DBA"); loaded {
+		t.Errorf("Directory must not be loaded before its first refresh")
+	}
+	if err := d.refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		lookup func(string) (string, bool, bool)
+		key    string
+		sysID  string
+		found  bool
+	}{
+		{d.lookupGroup, "DBA", "g1", true},
+		{d.lookupGroup, "g1", "g1", true},
+		{d.lookupGroup, "Unknown", "", false},
+		{d.lookupUser, "jdoe", "u1", true},
+		{d.lookupUser, "DBA", "", false},
+	}
+	for _, test := range tests {
+		sysID, found, loaded := test.lookup(test.key)
+		if sysID != test.sysID || found != test.found || !loaded {
+			t.Errorf("Unexpected lookup of %s: got %s %v %v", test.key, sysID, found, loaded)
+		}
+	}
+}
+
+func TestServiceNowDirectory_RefreshError(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetRecords", "sys_user_group", mock.Anything).Return([]Incident{{"sys_id": "g1", "name": "DBA"}}, nil).Once()
+	snClientMock.On("GetRecords", "sys_user", mock.Anything).Return([]Incident{}, nil).Once()
+	snClientMock.On("GetRecords", "sys_user_group", mock.Anything).Return([]Incident{}, errors.New("unavailable"))
+
+	d := &serviceNowDirectory{}
+	if err := d.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.refresh(); err == nil {
+		t.Fatal("Refresh error expected")
+	}
+	if _, found, _ := d.lookupGroup("DBA"); !found {
+		t.Errorf("Previous directory must be kept on refresh error")
+	}
+}
+
+func TestGroupValidationCache_Directory(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetRecords", "sys_user_group", mock.Anything).Return([]Incident{{"sys_id": "g1", "name": "DBA"}}, nil).Once()
+	snClientMock.On("GetRecords", "sys_user", mock.Anything).Return([]Incident{}, nil).Once()
+	defer func() { directory = &serviceNowDirectory{} }()
+	directory = &serviceNowDirectory{}
+	if err := directory.refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := &groupValidationCache{entries: make(map[string]groupValidation)}
+	for group, want := range map[string]bool{"DBA": true, "Unknown": false} {
+		if valid, err := cache.isValid("", group); err != nil || valid != want {
+			t.Errorf("Unexpected validation of %s: got %v %v, want %v", group, valid, err, want)
+		}
+	}
+	snClientMock.AssertNumberOfCalls(t, "GetRecords", 2)
+}
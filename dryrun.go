@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/alertmanager/template"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var dryRunFlag = kingpin.Flag("dry-run", "Process notifications without writing to ServiceNow, logging the requests which would be sent instead.").Bool()
+
+// dryRunWrite is a ServiceNow write request which would be sent
+type dryRunWrite struct {
+	Operation string   `json:"operation"`
+	Table     string   `json:"table,omitempty"`
+	SysID     string   `json:"sys_id,omitempty"`
+	Fields    Incident `json:"fields,omitempty"`
+	FileName  string   `json:"file_name,omitempty"`
+}
+
+// dryRunSnClient reads from a ServiceNow client and logs the writes instead of sending them, recording them
+// when processing an alert group
+type dryRunSnClient struct {
+	ServiceNow
+	ctx      context.Context
+	groupKey string
+	writes   []dryRunWrite
+}
+
+// withContext returns the client reading with the request ID of the context, and logging it with the writes. The
+// writes of the returned client are not recorded.
+func (c *dryRunSnClient) withContext(ctx context.Context) ServiceNow {
+	return &dryRunSnClient{ServiceNow: withRequestScope(ctx, c.ServiceNow), ctx: ctx}
+}
+
+func (c *dryRunSnClient) record(write dryRunWrite) {
+	content, _ := json.Marshal(write)
+	if len(c.groupKey) == 0 {
+		requestLog(c.ctx).Infof("Dry run, ServiceNow request not sent: %s", content)
+		return
+	}
+	requestLog(c.ctx).WithField("group_key", c.groupKey).Infof("Dry run for alert group key: %s, ServiceNow request not sent: %s", c.groupKey, content)
+	c.writes = append(c.writes, write)
+}
+
+func (c *dryRunSnClient) CreateIncident(incidentParam Incident) (Incident, error) {
+	c.record(dryRunWrite{Operation: "create_incident", Fields: incidentParam})
+	return Incident{"sys_id": "dry-run", "number": "DRYRUN", "state": "1"}, nil
+}
+
+func (c *dryRunSnClient) CreateRecord(table string, recordParam Incident) (Incident, error) {
+	c.record(dryRunWrite{Operation: "create_record", Table: table, Fields: recordParam})
+	return Incident{"sys_id": "dry-run"}, nil
+}
+
+func (c *dryRunSnClient) UpdateIncident(incidentParam Incident, sysID string) (Incident, error) {
+	c.record(dryRunWrite{Operation: "update_incident", SysID: sysID, Fields: incidentParam})
+	return Incident{"sys_id": sysID}, nil
+}
+
+func (c *dryRunSnClient) AttachFile(table string, sysID string, fileName string, contentType string, content []byte) error {
+	c.record(dryRunWrite{Operation: "attach_file", Table: table, SysID: sysID, FileName: fileName})
+	return nil
+}
+
+type dryRunKey struct{}
+
+// newDryRunSnClient returns the dry run client recording the writes of the alert group
+func newDryRunSnClient(ctx context.Context, data template.Data) *dryRunSnClient {
+	client := &dryRunSnClient{ServiceNow: serviceNowByName(serviceNowInstanceName(data)), ctx: ctx, groupKey: getGroupKey(data)}
+	if dryRunClient, ok := client.ServiceNow.(*dryRunSnClient); ok {
+		client.ServiceNow = dryRunClient.ServiceNow
+	}
+	client.ServiceNow = withRequestScope(ctx, client.ServiceNow)
+	return client
+}
+
+// withDryRun returns a context processing the alert group in dry run with the client
+func withDryRun(ctx context.Context, client *dryRunSnClient) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, client)
+}
+
+// dryRunFrom returns the dry run client of the context, false if the alert group is not processed in dry run
+func dryRunFrom(ctx context.Context) (*dryRunSnClient, bool) {
+	client, ok := ctx.Value(dryRunKey{}).(*dryRunSnClient)
+	return client, ok
+}
+
+// isDryRun returns true if the alert group is processed in dry run, its side effects being skipped
+func isDryRun(ctx context.Context) bool {
+	_, ok := dryRunFrom(ctx)
+	return ok
+}
+
+// isDryRunRequest returns true if the webhook runs in dry run, or the request asks for it
+func isDryRunRequest(r *http.Request) bool {
+	if *dryRunFlag {
+		return true
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	return dryRun
+}
+
+// dryRunAlertGroup processes the alert group as onAlertGroup, recording the ServiceNow writes instead of sending them
+func dryRunAlertGroup(ctx context.Context, data template.Data) ([]dryRunWrite, error) {
+	configLock.RLock()
+	defer configLock.RUnlock()
+
+	if isPerAlert(data) && len(data.Alerts) > 1 {
+		var writes []dryRunWrite
+		var firstErr error
+		for _, alertData := range splitPerAlert(data) {
+			alertWrites, err := handleDryRunAlertGroup(ctx, alertData)
+			writes = append(writes, alertWrites...)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return writes, firstErr
+	}
+	return handleDryRunAlertGroup(ctx, data)
+}
+
+// handleDryRunAlertGroup dry runs the alert group of a single group key, the configuration lock being held
+func handleDryRunAlertGroup(ctx context.Context, data template.Data) ([]dryRunWrite, error) {
+	alertGroupLog(ctx, data).Infof("Received alert group in dry run: Status=%s, GroupLabels=%v, CommonLabels=%v, CommonAnnotations=%v",
+		data.Status, data.GroupLabels, data.CommonLabels, data.CommonAnnotations)
+
+	// Dry runs see the incidents of the alert group once the notification being processed is done with them
+	unlock := progress.lock(getGroupKey(data))
+	defer unlock()
+	client := newDryRunSnClient(ctx, data)
+
+	err := manageAlertGroupIncident(withDryRun(ctx, client), rewriteAlertURLs(ctx, data))
+	return client.writes, err
+}
+
+// dryRunResponse is the webhook response of a dry run
+type dryRunResponse struct {
+	Status    int
+	Message   string
+	RequestID string `json:",omitempty"`
+	Requests  []dryRunWrite
+}
+
+// processDryRun processes the alert group in dry run and sends the ServiceNow writes which would be sent
+func processDryRun(ctx context.Context, w http.ResponseWriter, data template.Data) {
+	observePayloadComposition(data)
+	writes, err := dryRunAlertGroup(ctx, data)
+	if err != nil {
+		alertGroupLog(ctx, data).Errorf("Error managing incident from alert in dry run : %v", err)
+		sendJSONResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	webhookRequests.WithLabelValues(strconv.Itoa(http.StatusOK)).Inc()
+	webhookLastRequest.SetToCurrentTime()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dryRunResponse{Status: http.StatusOK, Message: "Dry run, no request sent to ServiceNow", RequestID: w.Header().Get(requestIDHeader), Requests: writes})
+}
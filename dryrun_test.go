@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWebhookDryRun(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	history = newGroupHistory(defaultHistoryMaxEntries)
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+
+	payload, err := ioutil.ReadFile("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(webhook).ServeHTTP(rr, httptest.NewRequest("POST", "/webhook?dry_run=true", bytes.NewReader(payload)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Wrong status code: got %v, want %v", rr.Code, http.StatusOK)
+	}
+
+	response := dryRunResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Requests) != 1 || response.Requests[0].Operation != "create_incident" {
+		t.Fatalf("Unexpected dry run requests: %+v", response.Requests)
+	}
+	if response.Requests[0].Fields["assignment_group"] != "<assignment group>" {
+		t.Errorf("Unexpected incident fields: %v", response.Requests[0].Fields)
+	}
+	snClientMock.AssertNotCalled(t, "CreateIncident", mock.Anything)
+
+	data, _ := decodeBody(payload)
+	if entries, _ := history.get(getGroupKey(data)); len(entries) > 0 {
+		t.Errorf("A dry run must not be recorded in the group key history, got %+v", entries)
+	}
+}
+
+func TestDryRun_RequestScoped(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "DryRunScoped"}}
+
+	// Only the processing of the dry run request uses the dry run client
+	client := newDryRunSnClient(context.Background(), data)
+	ctx := withDryRun(context.Background(), client)
+	if !isDryRun(ctx) || serviceNowFor(ctx, data) != client {
+		t.Errorf("Dry run request must use the dry run client")
+	}
+	if isDryRun(context.Background()) || serviceNowFor(context.Background(), data) != snClientMock {
+		t.Errorf("A concurrent notification of the alert group must not be in dry run")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	tmpltext "text/template"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// Actions on alert groups without alerts matching their status
+const (
+	emptyGroupProcess = "process"
+	emptyGroupSkip    = "skip"
+	emptyGroupComment = "comment"
+	emptyGroupResolve = "resolve"
+)
+
+const defaultEmptyGroupComment = "Alertmanager notification received without firing alerts."
+
+// EmptyAlertGroupConfig - Handling of notifications without alerts, or firing without firing alerts
+type EmptyAlertGroupConfig struct {
+	// process as any other notification (default), skip, comment the updatable incident, or resolve it
+	Action  string `yaml:"action"`
+	Comment string `yaml:"comment"`
+}
+
+func (c EmptyAlertGroupConfig) validate() error {
+	switch c.Action {
+	case "", emptyGroupProcess, emptyGroupSkip, emptyGroupComment, emptyGroupResolve:
+	default:
+		return fmt.Errorf("empty_alert_group action %q is invalid, must be one of: process, skip, comment, resolve", c.Action)
+	}
+	if _, err := tmpltext.New("comment").Funcs(templateFuncs("")).Parse(c.Comment); err != nil {
+		return fmt.Errorf("empty_alert_group comment template is invalid: %v", err)
+	}
+	return nil
+}
+
+func (c EmptyAlertGroupConfig) action() string {
+	if len(c.Action) == 0 {
+		return emptyGroupProcess
+	}
+	return c.Action
+}
+
+// isEmptyAlertGroup returns true if the payload holds no alert, or is firing without any firing alert,
+// e.g. after truncation by Alertmanager
+func isEmptyAlertGroup(data template.Data) bool {
+	if len(data.Alerts) == 0 {
+		return true
+	}
+	return data.Status == "firing" && len(data.Alerts.Firing()) == 0
+}
+
+// onEmptyAlertGroup applies the configured action to an alert group without alerts, never creating an incident
+func onEmptyAlertGroup(ctx context.Context, data template.Data, updatableIncident Incident) error {
+	action := config.Workflow.EmptyAlertGroup.action()
+	if action == emptyGroupSkip || updatableIncident == nil {
+		alertGroupLog(ctx, data).Infof("Alert group key: %s has no %s alert, no incident will be created/updated.", getGroupKey(data), data.Status)
+		return nil
+	}
+	if action == emptyGroupResolve {
+		alertGroupLog(ctx, data).Infof("Alert group key: %s has no %s alert, it is handled as resolved.", getGroupKey(data), data.Status)
+		return onResolvedGroup(ctx, data, updatableIncident)
+	}
+
+	text := config.Workflow.EmptyAlertGroup.Comment
+	if len(text) == 0 {
+		text = defaultEmptyGroupComment
+	}
+	comment, err := applyTemplate("comment", text, data)
+	if err != nil {
+		webhookIncidentTemplateError.Inc()
+		return err
+	}
+	field := config.Journal.Field
+	if len(field) == 0 {
+		field = defaultJournalField
+	}
+
+	incidentLog(ctx, data, updatableIncident).Infof("Alert group key: %s has no %s alert, incident (%s) is commented.", getGroupKey(data), data.Status, updatableIncident.GetNumber())
+	commentParam := Incident{field: comment}
+	_, err = serviceNowFor(ctx, data).UpdateIncident(commentParam, updatableIncident.GetSysID())
+	observeIncidentAction(ctx, data, commentParam, "comment", updatableIncident.GetNumber(), err)
+	if err != nil {
+		serviceNowError.Inc()
+		return stageError(stageUpdate, err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIsEmptyAlertGroup(t *testing.T) {
+	tests := []struct {
+		data template.Data
+		want bool
+	}{
+		{template.Data{Status: "firing"}, true},
+		{template.Data{Status: "resolved"}, true},
+		{template.Data{Status: "firing", Alerts: template.Alerts{{Status: "resolved"}}}, true},
+		{template.Data{Status: "firing", Alerts: template.Alerts{{Status: "firing"}}}, false},
+		{template.Data{Status: "resolved", Alerts: template.Alerts{{Status: "resolved"}}}, false},
+	}
+	for _, test := range tests {
+		if got := isEmptyAlertGroup(test.data); got != test.want {
+			t.Errorf("Unexpected result for %+v: got %v, want %v", test.data, got, test.want)
+		}
+	}
+}
+
+func TestOnAlertGroup_EmptySkip(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.EmptyAlertGroup = EmptyAlertGroupConfig{Action: "skip"}
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "empty-skip"}}
+	if err := onAlertGroup(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertNotCalled(t, "CreateIncident", mock.Anything)
+}
+
+func TestOnAlertGroup_EmptyComment(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.EmptyAlertGroup = EmptyAlertGroupConfig{Action: "comment", Comment: "Empty notification for {{ .Receiver }}"}
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{{"state": "1", "number": "INC42", "sys_id": "42"}}, nil)
+	snClientMock.On("UpdateIncident", Incident{"work_notes": "Empty notification for team"}, "42").Return(Incident{}, nil)
+
+	data := template.Data{
+		Status:      "firing",
+		Receiver:    "team",
+		GroupLabels: template.KV{"alertname": "empty-comment"},
+		Alerts:      template.Alerts{{Status: "resolved"}},
+	}
+	if err := onAlertGroup(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertNumberOfCalls(t, "UpdateIncident", 1)
+	snClientMock.AssertNotCalled(t, "CreateIncident", mock.Anything)
+}
+
+func TestOnAlertGroup_EmptyCommentWithoutIncident(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.EmptyAlertGroup = EmptyAlertGroupConfig{Action: "comment"}
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "empty-no-incident"}}
+	if err := onAlertGroup(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertNotCalled(t, "CreateIncident", mock.Anything)
+	snClientMock.AssertNotCalled(t, "UpdateIncident", mock.Anything, mock.Anything)
+}
+
+func TestOnAlertGroup_EmptyResolve(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.EmptyAlertGroup = EmptyAlertGroupConfig{Action: "resolve"}
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{{"state": "1", "number": "INC42", "sys_id": "42"}}, nil)
+	snClientMock.On("UpdateIncident", mock.Anything, "42").Return(Incident{}, nil)
+
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "empty-resolve"}}
+	if err := onAlertGroup(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertNumberOfCalls(t, "UpdateIncident", 1)
+	snClientMock.AssertNotCalled(t, "CreateIncident", mock.Anything)
+}
+
+func TestEmptyAlertGroupConfig_Validate(t *testing.T) {
+	if err := (EmptyAlertGroupConfig{Action: "close"}).validate(); err == nil {
+		t.Error("Unknown action must be rejected")
+	}
+	if err := (EmptyAlertGroupConfig{Action: "comment", Comment: "{{ .Receiver"}).validate(); err == nil {
+		t.Error("Invalid comment template must be rejected")
+	}
+	if err := (EmptyAlertGroupConfig{}).validate(); err != nil {
+		t.Errorf("Default config must be valid: %v", err)
+	}
+}
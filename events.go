@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const (
+	eventTable = "em_event"
+	// Event Management severity clearing the alert of the message key
+	eventSeverityClear = "0"
+)
+
+// EventManagementConfig - Event mode, where alert groups are pushed as Event Management events (em_event records)
+// instead of incidents, ServiceNow alert rules and correlation creating the incidents
+type EventManagementConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Templates of the event fields, see defaultEventFields
+	Source      string `yaml:"source"`
+	Node        string `yaml:"node"`
+	Type        string `yaml:"type"`
+	Resource    string `yaml:"resource"`
+	MetricName  string `yaml:"metric_name"`
+	Description string `yaml:"description"`
+	// Event severity (1: critical, 2: major, 3: minor, 4: warning, 5: OK) by value of the severity label
+	Severities map[string]string `yaml:"severities"`
+	// Event severity of the unmapped severity label values, 4 (warning) by default
+	DefaultSeverity string `yaml:"default_severity"`
+}
+
+var (
+	defaultEventFields = map[string]string{
+		"source":      "Prometheus",
+		"node":        `{{ .CommonLabels.instance }}`,
+		"type":        `{{ .CommonLabels.job }}`,
+		"resource":    "",
+		"metric_name": `{{ .CommonLabels.alertname }}`,
+		"description": "{{ range .Alerts }}{{ .Annotations.summary }}\n{{ end }}",
+	}
+	defaultEventSeverities = map[string]string{
+		"critical": "1",
+		"major":    "2",
+		"error":    "2",
+		"minor":    "3",
+		"warning":  "4",
+		"info":     "5",
+	}
+)
+
+const defaultEventSeverity = "4"
+
+func (c EventManagementConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	errs := strings.Builder{}
+	values := make([]string, 0, len(c.Severities))
+	for value := range c.Severities {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	for _, value := range values {
+		if !isEventSeverity(c.Severities[value]) {
+			errs.WriteString(fmt.Sprintf("event_management severity of %s must be between 1 and 5\n", value))
+		}
+	}
+	if len(c.DefaultSeverity) > 0 && !isEventSeverity(c.DefaultSeverity) {
+		errs.WriteString("event_management default_severity must be between 1 and 5\n")
+	}
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(errs.String(), "\n"))
+	}
+	return nil
+}
+
+func isEventSeverity(severity string) bool {
+	return len(severity) == 1 && severity >= "1" && severity <= "5"
+}
+
+// fields returns the configured templates of the event fields, defaults for the unset ones
+func (c EventManagementConfig) fields() map[string]string {
+	configured := map[string]string{
+		"source":      c.Source,
+		"node":        c.Node,
+		"type":        c.Type,
+		"resource":    c.Resource,
+		"metric_name": c.MetricName,
+		"description": c.Description,
+	}
+	fields := make(map[string]string, len(configured))
+	for field, text := range configured {
+		if len(text) == 0 {
+			text = defaultEventFields[field]
+		}
+		fields[field] = text
+	}
+	return fields
+}
+
+// severity returns the event severity of the alert group, resolved groups clearing the alert
+func (c EventManagementConfig) severity(data template.Data) string {
+	if data.Status == "resolved" {
+		return eventSeverityClear
+	}
+	value := data.CommonLabels[config.SeverityMapping.label()]
+	if severity, ok := c.Severities[value]; ok {
+		return severity
+	}
+	if len(c.Severities) == 0 {
+		if severity, ok := defaultEventSeverities[value]; ok {
+			return severity
+		}
+	}
+	if len(c.DefaultSeverity) > 0 {
+		return c.DefaultSeverity
+	}
+	return defaultEventSeverity
+}
+
+// alertGroupToEvent maps the alert group to an Event Management event, keyed by the group key so that
+// ServiceNow correlates the notifications of the group in a single alert
+func alertGroupToEvent(ctx context.Context, data template.Data) (Incident, error) {
+	c := config.EventManagement
+	event := Incident{
+		"message_key": getGroupKey(data),
+		"severity":    c.severity(data),
+	}
+	for field, text := range c.fields() {
+		value, err := applyTemplate(field, text, capRenderedAlerts(normalizeAlertTimes(ctx, data)))
+		if err != nil {
+			webhookIncidentTemplateError.Inc()
+			return nil, fmt.Errorf("error rendering event %s: %v", field, err)
+		}
+		event[field] = strings.TrimSpace(value)
+	}
+
+	additionalInfo := make(map[string]string, len(data.CommonLabels)+len(data.CommonAnnotations))
+	for name, value := range data.CommonAnnotations {
+		additionalInfo[name] = value
+	}
+	for name, value := range data.CommonLabels {
+		additionalInfo[name] = value
+	}
+	additionalInfo["receiver"] = data.Receiver
+	additionalInfo["external_url"] = data.ExternalURL
+	info, err := json.Marshal(additionalInfo)
+	if err != nil {
+		return nil, err
+	}
+	event["additional_info"] = string(info)
+
+	redactIncident(event)
+	return event, nil
+}
+
+// sendAlertGroupEvent pushes the event of the alert group to ServiceNow, the group key lock being held
+func sendAlertGroupEvent(ctx context.Context, data template.Data) error {
+	event, err := alertGroupToEvent(ctx, data)
+	if err != nil {
+		alertGroupLog(ctx, data).Errorf("Error mapping alert group key: %s to an event: %v", getGroupKey(data), err)
+		return err
+	}
+
+	created, err := serviceNowFor(ctx, data).CreateRecord(eventTable, event)
+	if isDryRun(ctx) {
+		return err
+	}
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	webhookEvents.WithLabelValues(event["severity"].(string), result).Inc()
+	history.record(getGroupKey(data), data.Status, "event", created.GetSysID(), err)
+	if err != nil {
+		serviceNowError.Inc()
+		return err
+	}
+	alertGroupLog(ctx, data).Infof("Event (%s) with severity %s sent for alert group key: %s", created.GetSysID(), event["severity"], getGroupKey(data))
+	progress.complete(data, stepIncident, created.GetSysID())
+	return nil
+}
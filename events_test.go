@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAlertGroupToEvent(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.EventManagement = EventManagementConfig{Enabled: true, Node: "{{ .CommonLabels.host }}"}
+	defer func() { config.EventManagement = EventManagementConfig{} }()
+
+	data := template.Data{
+		Status:            "firing",
+		Receiver:          "ops",
+		GroupLabels:       template.KV{"alertname": "DiskFull"},
+		CommonLabels:      template.KV{"alertname": "DiskFull", "host": "db1", "job": "node", "severity": "critical"},
+		CommonAnnotations: template.KV{"summary": "Disk full"},
+		Alerts:            template.Alerts{{Status: "firing", Annotations: template.KV{"summary": "Disk full on /var"}}},
+	}
+	event, err := alertGroupToEvent(context.Background(), data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"message_key": getGroupKey(data),
+		"severity":    "1",
+		"source":      "Prometheus",
+		"node":        "db1",
+		"type":        "node",
+		"resource":    "",
+		"metric_name": "DiskFull",
+		"description": "Disk full on /var",
+	}
+	for field, value := range want {
+		if event[field] != value {
+			t.Errorf("Unexpected %s: got %q, want %q", field, event[field], value)
+		}
+	}
+	info := map[string]string{}
+	if err := json.Unmarshal([]byte(event["additional_info"].(string)), &info); err != nil || info["summary"] != "Disk full" || info["receiver"] != "ops" {
+		t.Errorf("Unexpected additional info: %v (%v)", event["additional_info"], err)
+	}
+
+	data.Status = "resolved"
+	if event, _ := alertGroupToEvent(context.Background(), data); event["severity"] != eventSeverityClear {
+		t.Errorf("Resolved alert group must clear the alert, got severity %v", event["severity"])
+	}
+}
+
+func TestEventManagementSeverity(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	firing := func(severity string) template.Data {
+		return template.Data{Status: "firing", CommonLabels: template.KV{"severity": severity}}
+	}
+
+	tests := []struct {
+		config   EventManagementConfig
+		severity string
+		want     string
+	}{
+		{EventManagementConfig{}, "warning", "4"},
+		{EventManagementConfig{}, "unknown", defaultEventSeverity},
+		{EventManagementConfig{DefaultSeverity: "3"}, "unknown", "3"},
+		{EventManagementConfig{Severities: map[string]string{"page": "1"}}, "page", "1"},
+		{EventManagementConfig{Severities: map[string]string{"page": "1"}}, "critical", defaultEventSeverity},
+	}
+	for _, test := range tests {
+		if got := test.config.severity(firing(test.severity)); got != test.want {
+			t.Errorf("Unexpected severity of %s: got %s, want %s", test.severity, got, test.want)
+		}
+	}
+}
+
+func TestEventManagementConfig_Validate(t *testing.T) {
+	c := EventManagementConfig{Enabled: true, Severities: map[string]string{"page": "0", "ticket": "3"}, DefaultSeverity: "6"}
+	want := "event_management severity of page must be between 1 and 5\nevent_management default_severity must be between 1 and 5"
+	if err := c.validate(); err == nil || err.Error() != want {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	c.Enabled = false
+	if err := c.validate(); err != nil {
+		t.Errorf("Disabled event mode must not be validated: %v", err)
+	}
+}
+
+func TestManageAlertGroupIncident_EventMode(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.EventManagement = EventManagementConfig{Enabled: true}
+	defer func() { config.EventManagement = EventManagementConfig{} }()
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("CreateRecord", eventTable, mock.Anything).Return(Incident{"sys_id": "ev1"}, nil).Once()
+	snClientMock.On("CreateRecord", eventTable, mock.Anything).Return(Incident{}, errors.New("unavailable")).Once()
+
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "EventMode"}, CommonLabels: template.KV{"severity": "major"}}
+	if err := manageAlertGroupIncident(context.Background(), data); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	snClientMock.AssertNotCalled(t, "GetIncidents", mock.Anything)
+	snClientMock.AssertNotCalled(t, "CreateIncident", mock.Anything)
+	if err := manageAlertGroupIncident(context.Background(), data); err == nil {
+		t.Errorf("Expected the event error")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Field at the start of each condition of an encoded query, e.g. ^ORshort_descriptionLIKEdisk, field names being
+// lower case and operators upper case or symbols
+var queryConditionFieldRegexp = regexp.MustCompile(`(^|\^(?:OR|NQ|ORDERBYDESC|ORDERBY)?)([a-z0-9_.]+)`)
+
+// validateFieldMap checks the incident fields are mapped to distinct fields of the target table
+func validateFieldMap(fieldMap map[string]string) error {
+	fields := make([]string, 0, len(fieldMap))
+	for field := range fieldMap {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	errs := strings.Builder{}
+	targets := make(map[string]string, len(fieldMap))
+	for _, field := range fields {
+		target := fieldMap[field]
+		if len(target) == 0 {
+			errs.WriteString(fmt.Sprintf("field_map %s: target field is missing\n", field))
+			continue
+		}
+		if other, ok := targets[target]; ok {
+			errs.WriteString(fmt.Sprintf("field_map %s: target field %s is already mapped from %s\n", field, target, other))
+			continue
+		}
+		targets[target] = field
+	}
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(errs.String(), "\n"))
+	}
+	return nil
+}
+
+// setFieldMap sets the mapping of the incident fields to the fields of the target table, and its reverse
+func (snClient *ServiceNowClient) setFieldMap(fieldMap map[string]string) {
+	snClient.fieldMap = fieldMap
+	snClient.reverseFieldMap = make(map[string]string, len(fieldMap))
+	for field, target := range fieldMap {
+		snClient.reverseFieldMap[target] = field
+	}
+}
+
+// toTableFields renames the mapped incident fields to the fields of the target table
+func (snClient *ServiceNowClient) toTableFields(incident Incident) Incident {
+	return renameFields(incident, snClient.fieldMap)
+}
+
+// fromTableFields renames the mapped fields of a record of the target table to the incident fields
+func (snClient *ServiceNowClient) fromTableFields(record Incident) Incident {
+	return renameFields(record, snClient.reverseFieldMap)
+}
+
+// toTableParams renames the mapped incident fields of the Table API query parameters: field parameters,
+// sysparm_fields and the conditions of sysparm_query
+func (snClient *ServiceNowClient) toTableParams(params map[string]string) map[string]string {
+	if len(snClient.fieldMap) == 0 {
+		return params
+	}
+	renamed := make(map[string]string, len(params))
+	for name, value := range params {
+		switch {
+		case name == "sysparm_fields":
+			fields := strings.Split(value, ",")
+			for i, field := range fields {
+				if target, ok := snClient.fieldMap[field]; ok {
+					fields[i] = target
+				}
+			}
+			value = strings.Join(fields, ",")
+		case name == "sysparm_query":
+			value = queryConditionFieldRegexp.ReplaceAllStringFunc(value, func(condition string) string {
+				match := queryConditionFieldRegexp.FindStringSubmatch(condition)
+				if target, ok := snClient.fieldMap[match[2]]; ok {
+					return match[1] + target
+				}
+				return condition
+			})
+		case !strings.HasPrefix(name, "sysparm_"):
+			if target, ok := snClient.fieldMap[name]; ok {
+				name = target
+			}
+		}
+		renamed[name] = value
+	}
+	return renamed
+}
+
+func renameFields(record Incident, names map[string]string) Incident {
+	if len(names) == 0 || record == nil {
+		return record
+	}
+	renamed := make(Incident, len(record))
+	for field, value := range record {
+		if name, ok := names[field]; ok {
+			field = name
+		}
+		renamed[field] = value
+	}
+	return renamed
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestServiceNowClient_FieldMap(t *testing.T) {
+	var paths []string
+	var bodies []map[string]interface{}
+	var query url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.Method == http.MethodGet {
+			query = r.URL.Query()
+			fmt.Fprint(w, `{"result":[{"sys_id":"42","u_number":"ALR0001","u_state":"1"}]}`)
+			return
+		}
+		body := map[string]interface{}{}
+		content, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(content, &body)
+		bodies = append(bodies, body)
+		fmt.Fprint(w, `{"result":{"sys_id":"42","u_number":"ALR0001"}}`)
+	}))
+	defer ts.Close()
+
+	snClient, err := NewServiceNowClient("instancename", "username", "password")
+	if err != nil {
+		t.Fatalf("Error occured on NewServiceNowClient: %s", err)
+	}
+	snClient.baseURL = ts.URL
+	applyServiceNowClientOptions(snClient, ServiceNowConfig{
+		Table:    "u_monitoring_alert",
+		FieldMap: map[string]string{"number": "u_number", "state": "u_state", "short_description": "u_title", "correlation_id": "u_alert_key"},
+	})
+
+	created, err := snClient.CreateIncident(Incident{"short_description": "disk full", "impact": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.GetNumber() != "ALR0001" {
+		t.Errorf("Unexpected created record number: %v", created)
+	}
+	if _, err := snClient.UpdateIncident(Incident{"state": "6"}, "42"); err != nil {
+		t.Fatal(err)
+	}
+	found, err := snClient.GetIncidents(map[string]string{
+		"sysparm_query":  "correlation_idSTARTSWITHabc^ORshort_description=x^ORDERBYDESCnumber^u_otherISEMPTY",
+		"sysparm_fields": "sys_id,number,state",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].GetNumber() != "ALR0001" || found[0].GetState() != "1" {
+		t.Errorf("Unexpected records: %v", found)
+	}
+
+	for _, path := range paths {
+		if path != "/api/now/v2/table/u_monitoring_alert" && path != "/api/now/v2/table/u_monitoring_alert/42" {
+			t.Errorf("Unexpected table request: %s", path)
+		}
+	}
+	if bodies[0]["u_title"] != "disk full" || bodies[0]["impact"] != "2" || bodies[0]["short_description"] != nil {
+		t.Errorf("Unexpected created fields: %v", bodies[0])
+	}
+	if bodies[1]["u_state"] != "6" {
+		t.Errorf("Unexpected updated fields: %v", bodies[1])
+	}
+	if got := query.Get("sysparm_query"); got != "u_alert_keySTARTSWITHabc^ORu_title=x^ORDERBYDESCu_number^u_otherISEMPTY" {
+		t.Errorf("Unexpected query: %s", got)
+	}
+	if got := query.Get("sysparm_fields"); got != "sys_id,u_number,u_state" {
+		t.Errorf("Unexpected fields: %s", got)
+	}
+}
+
+func TestValidateFieldMap(t *testing.T) {
+	err := validateFieldMap(map[string]string{"short_description": "u_title", "description": "u_title", "state": ""})
+	want := "field_map short_description: target field u_title is already mapped from description\nfield_map state: target field is missing"
+	if err == nil || err.Error() != want {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := validateFieldMap(map[string]string{"short_description": "u_title"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
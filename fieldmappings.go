@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	tmpltext "text/template"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// FieldMappingConfig - Incident field set from a common label, a common annotation or a template of the alert group.
+// The default value, if any, is used when the source is missing or empty, the default_incident value is kept otherwise.
+type FieldMappingConfig struct {
+	Label      string  `yaml:"label"`
+	Annotation string  `yaml:"annotation"`
+	Template   string  `yaml:"template"`
+	Default    *string `yaml:"default"`
+}
+
+func (m FieldMappingConfig) validate() error {
+	sources := 0
+	for _, source := range []string{m.Label, m.Annotation, m.Template} {
+		if len(source) > 0 {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("exactly one of label, annotation or template must be set")
+	}
+	if _, err := tmpltext.New("template").Funcs(templateFuncs("")).Parse(m.Template); err != nil {
+		return fmt.Errorf("template is invalid: %v", err)
+	}
+	return nil
+}
+
+// validateFieldMappings checks the mapping of each field
+func validateFieldMappings(mappings map[string]FieldMappingConfig) error {
+	fields := make([]string, 0, len(mappings))
+	for field := range mappings {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	errs := strings.Builder{}
+	for _, field := range fields {
+		if err := mappings[field].validate(); err != nil {
+			errs.WriteString(fmt.Sprintf("field_mappings %s: %v\n", field, err))
+		}
+	}
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(errs.String(), "\n"))
+	}
+	return nil
+}
+
+// value returns the mapped value of the alert group, false if the source is missing and there is no default
+func (m FieldMappingConfig) value(ctx context.Context, field string, data template.Data) (string, bool) {
+	var value string
+	switch {
+	case len(m.Label) > 0:
+		value = data.CommonLabels[m.Label]
+	case len(m.Annotation) > 0:
+		value = data.CommonAnnotations[m.Annotation]
+	default:
+		rendered, err := applyTemplate(field, m.Template, capRenderedAlerts(normalizeAlertTimes(ctx, data)))
+		if err != nil {
+			webhookIncidentTemplateError.Inc()
+			log.Errorf("Error parsing field_mappings template of %s, error:%v", field, err)
+		}
+		value = strings.TrimSpace(rendered)
+	}
+	if len(value) > 0 {
+		return value, true
+	}
+	if m.Default != nil {
+		return *m.Default, true
+	}
+	return "", false
+}
+
+// applyFieldMappings sets the mapped incident fields, after templating so that the label and annotation values
+// are used as is
+func applyFieldMappings(ctx context.Context, incident Incident, data template.Data) {
+	for field, mapping := range config.FieldMappings {
+		if value, ok := mapping.value(ctx, field, data); ok {
+			incident[field] = value
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestApplyFieldMappings(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	unknown := "Unknown"
+	config.FieldMappings = map[string]FieldMappingConfig{
+		"category":           {Label: "service_category"},
+		"u_business_service": {Annotation: "business_service", Default: &unknown},
+		"subcategory":        {Template: "{{ .CommonLabels.team }}/{{ .CommonLabels.app }}"},
+		"u_owner":            {Label: "owner"},
+	}
+	defer func() { config.FieldMappings = nil }()
+
+	data := template.Data{
+		Status:       "firing",
+		GroupLabels:  template.KV{"alertname": "FieldMappings"},
+		CommonLabels: template.KV{"service_category": "{{ Database }}", "team": "db", "app": "billing"},
+	}
+	incident := renderIncident(context.Background(), data, config.DefaultIncident)
+	want := map[string]interface{}{
+		"category":           "{{ Database }}",
+		"u_business_service": "Unknown",
+		"subcategory":        "db/billing",
+		"u_owner":            nil,
+	}
+	for field, value := range want {
+		if incident[field] != value {
+			t.Errorf("Unexpected %s: got %v, want %v", field, incident[field], value)
+		}
+	}
+}
+
+func TestValidateFieldMappings(t *testing.T) {
+	err := validateFieldMappings(map[string]FieldMappingConfig{
+		"category":    {Label: "a", Annotation: "b"},
+		"subcategory": {Template: "{{ .CommonLabels"},
+		"u_team":      {Label: "team"},
+	})
+	want := "field_mappings category: exactly one of label, annotation or template must be set\n" +
+		"field_mappings subcategory: template is invalid: template: template:1: unclosed action"
+	if err == nil || err.Error() != want {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
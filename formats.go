@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// Payload formats accepted on the webhook
+const (
+	formatAlertmanager   = "alertmanager"
+	formatGrafanaLegacy  = "grafana_legacy"
+	grafanaLegacyOkState = "ok"
+	// Annotation listing the malformed fields of a partially decoded alert
+	decodeErrorsAnnotation = "decode_errors"
+)
+
+// grafanaLegacyPayload is the webhook payload of Grafana legacy alerting
+type grafanaLegacyPayload struct {
+	Title       string            `json:"title"`
+	RuleID      int64             `json:"ruleId"`
+	RuleName    string            `json:"ruleName"`
+	RuleURL     string            `json:"ruleUrl"`
+	State       string            `json:"state"`
+	Message     string            `json:"message"`
+	ImageURL    string            `json:"imageUrl"`
+	Tags        map[string]string `json:"tags"`
+	EvalMatches []struct {
+		Value  float64           `json:"value"`
+		Metric string            `json:"metric"`
+		Tags   map[string]string `json:"tags"`
+	} `json:"evalMatches"`
+}
+
+// detectPayloadFormat returns the format of a payload from its top level fields.
+// Alertmanager and Grafana unified alerting payloads share the same format.
+func detectPayloadFormat(fields map[string]json.RawMessage) string {
+	if _, ok := fields["alerts"]; ok {
+		return formatAlertmanager
+	}
+	_, hasRuleName := fields["ruleName"]
+	_, hasEvalMatches := fields["evalMatches"]
+	if hasRuleName || hasEvalMatches {
+		return formatGrafanaLegacy
+	}
+	return formatAlertmanager
+}
+
+// decodePayload decodes a payload of the given format into the Alertmanager data structure
+func decodePayload(format string, body []byte) (template.Data, error) {
+	data := template.Data{}
+	switch format {
+	case formatGrafanaLegacy:
+		payload := grafanaLegacyPayload{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return data, err
+		}
+		return payload.toData(), nil
+	case formatAlertmanager:
+		err := json.Unmarshal(body, &data)
+		if err != nil {
+			return decodeAlertsTolerantly(body, err)
+		}
+		return data, nil
+	}
+	return data, fmt.Errorf("unknown payload format %q", format)
+}
+
+// tolerantPayload is an Alertmanager payload whose alerts are decoded one by one
+type tolerantPayload struct {
+	Receiver          string            `json:"receiver"`
+	Status            string            `json:"status"`
+	Alerts            []json.RawMessage `json:"alerts"`
+	GroupLabels       template.KV       `json:"groupLabels"`
+	CommonLabels      template.KV       `json:"commonLabels"`
+	CommonAnnotations template.KV       `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+}
+
+// decodeAlertsTolerantly decodes an Alertmanager payload whose alerts have malformed fields, e.g. a bad
+// timestamp. Malformed fields are left empty and listed in the decode_errors annotation of their alert,
+// entries which are not alerts are dropped. The decoding error is returned if the payload itself is malformed.
+func decodeAlertsTolerantly(body []byte, decodeErr error) (template.Data, error) {
+	payload := tolerantPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return template.Data{}, decodeErr
+	}
+	data := template.Data{
+		Receiver:          payload.Receiver,
+		Status:            payload.Status,
+		Alerts:            make(template.Alerts, 0, len(payload.Alerts)),
+		GroupLabels:       payload.GroupLabels,
+		CommonLabels:      payload.CommonLabels,
+		CommonAnnotations: payload.CommonAnnotations,
+		ExternalURL:       payload.ExternalURL,
+	}
+
+	for i, rawAlert := range payload.Alerts {
+		if alert, ok := decodeAlertTolerantly(i, rawAlert); ok {
+			data.Alerts = append(data.Alerts, alert)
+		}
+	}
+
+	webhookPartiallyDecodedPayloads.Inc()
+	return data, nil
+}
+
+// decodeAlertTolerantly decodes the alert at the index of the payload, leaving its malformed fields empty and listing
+// them in its decode_errors annotation. It returns false if the entry is not an alert.
+func decodeAlertTolerantly(i int, rawAlert json.RawMessage) (template.Alert, bool) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(rawAlert, &fields); err != nil {
+		log.Warnf("Alert %d of the payload is dropped as it can't be decoded: %v", i, err)
+		return template.Alert{}, false
+	}
+	alert := template.Alert{}
+	targets := map[string]interface{}{
+		"status":       &alert.Status,
+		"labels":       &alert.Labels,
+		"annotations":  &alert.Annotations,
+		"startsAt":     &alert.StartsAt,
+		"endsAt":       &alert.EndsAt,
+		"generatorURL": &alert.GeneratorURL,
+		"fingerprint":  &alert.Fingerprint,
+	}
+	var decodeErrors []string
+	for name, value := range fields {
+		target, ok := targets[name]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(value, target); err != nil {
+			decodeErrors = append(decodeErrors, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(decodeErrors) > 0 {
+		sort.Strings(decodeErrors)
+		if alert.Annotations == nil {
+			alert.Annotations = template.KV{}
+		}
+		alert.Annotations[decodeErrorsAnnotation] = strings.Join(decodeErrors, "; ")
+		log.Warnf("Alert %d of the payload is partially decoded: %s", i, alert.Annotations[decodeErrorsAnnotation])
+	}
+	return alert, true
+}
+
+// toData adapts a Grafana legacy alert to the Alertmanager data structure, with one alert per evaluation match
+func (p grafanaLegacyPayload) toData() template.Data {
+	status := "firing"
+	if strings.ToLower(p.State) == grafanaLegacyOkState {
+		status = "resolved"
+	}
+
+	commonLabels := template.KV{"alertname": p.RuleName}
+	for k, v := range p.Tags {
+		commonLabels[k] = v
+	}
+	annotations := template.KV{"summary": p.Title, "description": p.Message}
+	if len(p.ImageURL) > 0 {
+		annotations["image_url"] = p.ImageURL
+	}
+
+	newAlert := func(labels template.KV) template.Alert {
+		alert := template.Alert{
+			Status:       status,
+			Labels:       labels,
+			Annotations:  annotations,
+			StartsAt:     now(),
+			GeneratorURL: p.RuleURL,
+		}
+		if status == "resolved" {
+			alert.EndsAt = alert.StartsAt
+		}
+		return alert
+	}
+
+	var alerts template.Alerts
+	for _, match := range p.EvalMatches {
+		labels := template.KV{}
+		for k, v := range commonLabels {
+			labels[k] = v
+		}
+		for k, v := range match.Tags {
+			labels[k] = v
+		}
+		if len(match.Metric) > 0 {
+			labels["metric"] = match.Metric
+		}
+		alerts = append(alerts, newAlert(labels))
+	}
+	if len(alerts) == 0 {
+		alerts = append(alerts, newAlert(commonLabels))
+	}
+
+	return template.Data{
+		Receiver:          "grafana",
+		Status:            status,
+		Alerts:            alerts,
+		GroupLabels:       template.KV{"alertname": p.RuleName},
+		CommonLabels:      commonLabels,
+		CommonAnnotations: annotations,
+		ExternalURL:       p.RuleURL,
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDecodePayload_GrafanaLegacy(t *testing.T) {
+	body := []byte(`{
+  "title": "[Alerting] Disk full",
+  "ruleId": 1,
+  "ruleName": "Disk full",
+  "ruleUrl": "https://grafana.example.com/d/abc",
+  "state": "alerting",
+  "message": "Disk is almost full",
+  "tags": {"team": "ops"},
+  "evalMatches": [
+    {"value": 95, "metric": "disk_used", "tags": {"instance": "server01"}},
+    {"value": 97, "metric": "disk_used", "tags": {"instance": "server02"}}
+  ]
+}`)
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		t.Fatal(err)
+	}
+	format := detectPayloadFormat(fields)
+	if format != formatGrafanaLegacy {
+		t.Fatalf("Wrong format: got %v, want %v", format, formatGrafanaLegacy)
+	}
+
+	data, err := decodePayload(format, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Status != "firing" || len(data.Alerts) != 2 {
+		t.Errorf("Unexpected data: got status %v with %v alerts", data.Status, len(data.Alerts))
+	}
+	if data.GroupLabels["alertname"] != "Disk full" || data.CommonLabels["team"] != "ops" {
+		t.Errorf("Unexpected labels: got %v and %v", data.GroupLabels, data.CommonLabels)
+	}
+	if data.Alerts[1].Labels["instance"] != "server02" || data.CommonAnnotations["summary"] != "[Alerting] Disk full" {
+		t.Errorf("Unexpected alert: got %v", data.Alerts[1])
+	}
+}
+
+func TestDecodePayload_GrafanaLegacyOk(t *testing.T) {
+	data, err := decodePayload(formatGrafanaLegacy, []byte(`{"ruleName": "Disk full", "state": "ok"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Status != "resolved" || len(data.Alerts) != 1 || data.Alerts[0].Status != "resolved" {
+		t.Errorf("Unexpected data: got %v", data)
+	}
+}
+
+func TestDecodePayload_PartialAlert(t *testing.T) {
+	body := []byte(`{"receiver": "team", "status": "firing", "commonLabels": {"alertname": "Disk full"}, "alerts": [
+		{"status": "firing", "labels": {"instance": "server01"}, "startsAt": "2020-01-01T00:00:00Z"},
+		{"status": "firing", "labels": {"instance": "server02"}, "startsAt": "yesterday", "annotations": {"summary": "Disk full"}},
+		"not an alert"
+	]}`)
+	data, err := decodePayload(formatAlertmanager, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Receiver != "team" || data.CommonLabels["alertname"] != "Disk full" || len(data.Alerts) != 2 {
+		t.Fatalf("Unexpected data: got %v", data)
+	}
+	if _, ok := data.Alerts[0].Annotations[decodeErrorsAnnotation]; ok || data.Alerts[0].StartsAt.IsZero() {
+		t.Errorf("Unexpected first alert: got %v", data.Alerts[0])
+	}
+	bad := data.Alerts[1]
+	if bad.Labels["instance"] != "server02" || bad.Annotations["summary"] != "Disk full" || !bad.StartsAt.IsZero() {
+		t.Errorf("Unexpected partially decoded alert: got %v", bad)
+	}
+	if !strings.HasPrefix(bad.Annotations[decodeErrorsAnnotation], "startsAt: ") {
+		t.Errorf("Unexpected decode errors: got %q", bad.Annotations[decodeErrorsAnnotation])
+	}
+}
+
+func TestDecodePayload_MalformedPayload(t *testing.T) {
+	if _, err := decodePayload(formatAlertmanager, []byte(`{"status": 1, "alerts": []}`)); err == nil {
+		t.Errorf("Malformed payload must not be decoded")
+	}
+}
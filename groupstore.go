@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// Number of group keys whose latest payload is kept, the least recently received ones being evicted above it
+const defaultGroupStoreMaxKeys = 10000
+
+// storedPayload is the latest payload of a group key, with the time it was received
+type storedPayload struct {
+	data     template.Data
+	received time.Time
+}
+
+// groupStore keeps the latest payload received for each alert group key
+type groupStore struct {
+	mu       sync.Mutex
+	maxKeys  int
+	payloads map[string]storedPayload
+}
+
+var lastPayloads = newGroupStore(defaultGroupStoreMaxKeys)
+
+func newGroupStore(maxKeys int) *groupStore {
+	return &groupStore{maxKeys: maxKeys, payloads: make(map[string]storedPayload)}
+}
+
+// set stores the payload as the latest one of its group key, evicting the least recently received group key
+// above the maximum
+func (s *groupStore) set(data template.Data) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.payloads[getGroupKey(data)] = storedPayload{data: data, received: now()}
+	if len(s.payloads) <= s.maxKeys {
+		return
+	}
+	var oldestKey string
+	var oldest time.Time
+	for groupKey, payload := range s.payloads {
+		if len(oldestKey) == 0 || payload.received.Before(oldest) {
+			oldestKey, oldest = groupKey, payload.received
+		}
+	}
+	delete(s.payloads, oldestKey)
+}
+
+// get returns the latest payload of the group key
+func (s *groupStore) get(groupKey string) (template.Data, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	payload, ok := s.payloads[groupKey]
+	return payload.data, ok
+}
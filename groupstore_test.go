@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestGroupStore_MaxKeys(t *testing.T) {
+	defer func() { now = time.Now }()
+	s := newGroupStore(2)
+	start := time.Now()
+	var groupKeys []string
+	for i, alertname := range []string{"First", "Second", "Third"} {
+		now = func() time.Time { return start.Add(time.Duration(i) * time.Second) }
+		data := template.Data{GroupLabels: template.KV{"alertname": alertname}}
+		s.set(data)
+		groupKeys = append(groupKeys, getGroupKey(data))
+	}
+
+	// The least recently received group key is evicted
+	if _, ok := s.get(groupKeys[0]); ok {
+		t.Errorf("Oldest group key must be evicted")
+	}
+	for _, groupKey := range groupKeys[1:] {
+		if _, ok := s.get(groupKey); !ok {
+			t.Errorf("Group key %s must be kept", groupKey)
+		}
+	}
+}
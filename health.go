@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthProbeTimeout = 10 * time.Second
+	defaultReadinessCacheTTL  = 30 * time.Second
+)
+
+// HealthProbeConfig - Periodic probe of the ServiceNow reachability and authentication
+type HealthProbeConfig struct {
+	// Interval between two probes, the probe is disabled when not set
+	Interval time.Duration `yaml:"interval"`
+	// Timeout of a probe, 10s by default
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func (c HealthProbeConfig) getTimeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return defaultHealthProbeTimeout
+}
+
+// ReadinessConfig - Readiness endpoint checking the ServiceNow connectivity
+type ReadinessConfig struct {
+	// Probe ServiceNow with an authenticated request, the webhook is ready as soon as started when not set
+	CheckServiceNow bool `yaml:"check_service_now"`
+	// Duration a probe result is reused for, 30s by default
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+func (c ReadinessConfig) cacheTTL() time.Duration {
+	if c.CacheTTL > 0 {
+		return c.CacheTTL
+	}
+	return defaultReadinessCacheTTL
+}
+
+// serviceNowHealth is the result of the last ServiceNow health probe
+type serviceNowHealth struct {
+	Up        bool      `json:"up"`
+	Error     string    `json:"error,omitempty"`
+	Duration  float64   `json:"duration_seconds"`
+	LastProbe time.Time `json:"last_probe"`
+}
+
+// healthProber probes ServiceNow and keeps the last result
+type healthProber struct {
+	mu   sync.RWMutex
+	last *serviceNowHealth
+	// Serializes the probes of readiness checks, concurrent checks sharing the same probe
+	probeMu sync.Mutex
+}
+
+var health = &healthProber{}
+
+// probe checks ServiceNow with a lightweight read of the incident table and records the result
+func (p *healthProber) probe(timeout time.Duration) serviceNowHealth {
+	result := serviceNowHealth{LastProbe: now()}
+	start := time.Now()
+	err := serviceNowHealthCheck(timeout)
+	result.Duration = time.Since(start).Seconds()
+	if err != nil {
+		result.Error = err.Error()
+		log.Warnf("ServiceNow health probe failed: %v", err)
+		serviceNowUp.Set(0)
+	} else {
+		result.Up = true
+		serviceNowUp.Set(1)
+	}
+	serviceNowProbeDuration.Set(result.Duration)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.last = &result
+	return result
+}
+
+// serviceNowHealthCheck probes the ServiceNow client when it supports health checks, or reads one incident
+func serviceNowHealthCheck(timeout time.Duration) error {
+	configLock.RLock()
+	client, source := serviceNow, config.Workflow.Source
+	configLock.RUnlock()
+	if checker, ok := client.(interface{ checkHealth(time.Duration) error }); ok {
+		return checker.checkHealth(timeout)
+	}
+	_, err := client.GetIncidents(withSource(source, map[string]string{"sysparm_limit": "1", "sysparm_fields": "sys_id"}))
+	return err
+}
+
+// status returns the result of the last probe, nil if ServiceNow was never probed
+func (p *healthProber) status() *serviceNowHealth {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.last
+}
+
+// recent returns the result of the last probe if not older than maxAge, probing ServiceNow otherwise
+func (p *healthProber) recent(maxAge time.Duration, timeout time.Duration) serviceNowHealth {
+	p.probeMu.Lock()
+	defer p.probeMu.Unlock()
+	if last := p.status(); last != nil && now().Sub(last.LastProbe) < maxAge {
+		return *last
+	}
+	return p.probe(timeout)
+}
+
+// run probes ServiceNow at every interval
+func (p *healthProber) run(c HealthProbeConfig) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		p.probe(c.getTimeout())
+		<-ticker.C
+	}
+}
+
+// checkHealth reads one record of the incident table within the timeout, failing on any non 2xx answer
+// such as an authentication error
+func (snClient *ServiceNowClient) checkHealth(timeout time.Duration) error {
+	url := fmt.Sprintf(tableAPI, snClient.baseURL, snClient.getIncidentTable()) + "?sysparm_limit=1&sysparm_fields=sys_id"
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	resp, err := snClient.send(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("ServiceNow answered HTTP code %v", resp.StatusCode)
+	}
+	return nil
+}
+
+// healthy answers 200 as long as the webhook serves requests
+func healthy(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, http.StatusOK, "Healthy")
+}
+
+// ready answers 200 when the webhook can process notifications, checking the ServiceNow connectivity if configured
+func ready(w http.ResponseWriter, r *http.Request) {
+	configLock.RLock()
+	readiness := config.Readiness
+	timeout := config.HealthProbe.getTimeout()
+	configLock.RUnlock()
+	if !readiness.CheckServiceNow {
+		writeJSONResponse(w, http.StatusOK, "Ready")
+		return
+	}
+	result := health.recent(readiness.cacheTTL(), timeout)
+	if !result.Up {
+		writeJSONResponse(w, http.StatusServiceUnavailable, "ServiceNow is not reachable: "+result.Error)
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, "Ready")
+}
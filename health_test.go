@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHealthProber_Probe(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil).Once()
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, errors.New("unavailable")).Once()
+
+	p := &healthProber{}
+	if p.status() != nil {
+		t.Errorf("Status must be nil before the first probe")
+	}
+
+	if result := p.probe(time.Second); !result.Up || testutil.ToFloat64(serviceNowUp) != 1 {
+		t.Errorf("ServiceNow must be up: %+v", result)
+	}
+	result := p.probe(time.Second)
+	if result.Up || result.Error != "unavailable" || testutil.ToFloat64(serviceNowUp) != 0 {
+		t.Errorf("ServiceNow must be down: %+v", result)
+	}
+	if status := p.status(); status == nil || status.Up {
+		t.Errorf("Unexpected last status: %+v", status)
+	}
+}
+
+func TestServiceNowClient_CheckHealth(t *testing.T) {
+	statusCode := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sysparm_limit") != "1" {
+			t.Errorf("Unexpected health probe query: %s", r.URL.RawQuery)
+		}
+		w.WriteHeader(statusCode)
+		w.Write([]byte(`{"result":[]}`))
+	}))
+	defer ts.Close()
+
+	snClient, _ := NewServiceNowClient("instance", "user", "password")
+	snClient.baseURL = ts.URL
+
+	if err := snClient.checkHealth(time.Second); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	statusCode = http.StatusUnauthorized
+	err := snClient.checkHealth(time.Second)
+	if err == nil || err.Error() != "ServiceNow answered HTTP code 401" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestReady(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	current := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+	health = &healthProber{}
+	defer func() { health = &healthProber{} }()
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, errors.New("unauthorized")).Once()
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+
+	check := func(handler http.HandlerFunc, want int) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+		if rr.Code != want {
+			t.Errorf("Wrong status code: got %v, want %v (%s)", rr.Code, want, rr.Body.String())
+		}
+	}
+
+	check(healthy, http.StatusOK)
+	check(ready, http.StatusOK)
+	snClientMock.AssertNotCalled(t, "GetIncidents", mock.Anything)
+
+	config.Readiness = ReadinessConfig{CheckServiceNow: true, CacheTTL: time.Minute}
+	check(ready, http.StatusServiceUnavailable)
+	// The failed probe is cached
+	check(ready, http.StatusServiceUnavailable)
+	snClientMock.AssertNumberOfCalls(t, "GetIncidents", 1)
+
+	current = current.Add(2 * time.Minute)
+	check(ready, http.StatusOK)
+	snClientMock.AssertNumberOfCalls(t, "GetIncidents", 2)
+}
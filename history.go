@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHistoryMaxEntries   = 100
+	defaultHistoryMaxGroupKeys = 10000
+)
+
+// HistoryConfig - In-memory history of actions per group key
+type HistoryConfig struct {
+	MaxEntries int `yaml:"max_entries"`
+	// Number of group keys whose history is kept, the least recently updated ones being evicted above it
+	MaxGroupKeys int `yaml:"max_group_keys"`
+}
+
+// historyEntry is an action done for a group key
+type historyEntry struct {
+	Time     time.Time `json:"time"`
+	Status   string    `json:"status"`
+	Action   string    `json:"action"`
+	Incident string    `json:"incident,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// groupHistory keeps the latest actions done for each group key
+type groupHistory struct {
+	mu           sync.Mutex
+	maxEntries   int
+	maxGroupKeys int
+	entries      map[string][]historyEntry
+}
+
+var history = newGroupHistory(defaultHistoryMaxEntries)
+
+func newGroupHistory(maxEntries int) *groupHistory {
+	return &groupHistory{maxEntries: maxEntries, maxGroupKeys: defaultHistoryMaxGroupKeys, entries: make(map[string][]historyEntry)}
+}
+
+// configure sets the number of entries kept per group key and the number of group keys, or the defaults if not
+// positive
+func (h *groupHistory) configure(c HistoryConfig) {
+	maxEntries := c.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultHistoryMaxEntries
+	}
+	maxGroupKeys := c.MaxGroupKeys
+	if maxGroupKeys <= 0 {
+		maxGroupKeys = defaultHistoryMaxGroupKeys
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.maxEntries = maxEntries
+	h.maxGroupKeys = maxGroupKeys
+}
+
+// record adds an action to the history of the group key, dropping the oldest entries above the maximum
+func (h *groupHistory) record(groupKey string, status string, action string, incidentNumber string, err error) {
+	entry := historyEntry{
+		Time:     now().UTC(),
+		Status:   status,
+		Action:   action,
+		Incident: incidentNumber,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := append(h.entries[groupKey], entry)
+	if len(entries) > h.maxEntries {
+		entries = entries[len(entries)-h.maxEntries:]
+	}
+	h.entries[groupKey] = entries
+	if len(h.entries) > h.maxGroupKeys {
+		h.evictOldest()
+	}
+}
+
+// evictOldest removes the history of the least recently updated group key, must be called with the lock held
+func (h *groupHistory) evictOldest() {
+	var oldestKey string
+	var oldest time.Time
+	for groupKey, entries := range h.entries {
+		if updated := entries[len(entries)-1].Time; len(oldestKey) == 0 || updated.Before(oldest) {
+			oldestKey, oldest = groupKey, updated
+		}
+	}
+	delete(h.entries, oldestKey)
+}
+
+// get returns a copy of the history of the group key
+func (h *groupHistory) get(groupKey string) ([]historyEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries, ok := h.entries[groupKey]
+	return append([]historyEntry(nil), entries...), ok
+}
+
+// recentEntry is an action done for a group key, with its group key
+type recentEntry struct {
+	GroupKey string `json:"group_key"`
+	historyEntry
+}
+
+// recent returns the latest actions done for all group keys, most recent first, all of them if limit is not positive
+func (h *groupHistory) recent(limit int) []recentEntry {
+	h.mu.Lock()
+	var entries []recentEntry
+	for groupKey, groupEntries := range h.entries {
+		for _, entry := range groupEntries {
+			entries = append(entries, recentEntry{GroupKey: groupKey, historyEntry: entry})
+		}
+	}
+	h.mu.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// groupHistoryHandler serves the history of a group key on /api/v1/groups/{key}/history
+func groupHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/groups/"), "/")
+	if len(parts) != 2 || len(parts[0]) == 0 || parts[1] != "history" {
+		writeJSONResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	entries, ok := history.get(parts[0])
+	if !ok {
+		writeJSONResponse(w, http.StatusNotFound, "No history for group key: "+parts[0])
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGroupHistory_Record(t *testing.T) {
+	h := newGroupHistory(2)
+	h.record("key", "firing", "create", "INC1", nil)
+	h.record("key", "firing", "update", "INC1", nil)
+	h.record("key", "resolved", "update", "INC1", errors.New("Error"))
+
+	entries, ok := h.get("key")
+	if !ok || len(entries) != 2 {
+		t.Fatalf("Wrong history: got %v, want 2 entries", entries)
+	}
+	if entries[0].Status != "firing" || entries[1].Error != "Error" {
+		t.Errorf("Unexpected history entries: %v", entries)
+	}
+}
+
+func TestGroupHistory_MaxGroupKeys(t *testing.T) {
+	defer func() { now = time.Now }()
+	h := newGroupHistory(defaultHistoryMaxEntries)
+	h.configure(HistoryConfig{MaxGroupKeys: 2})
+	start := time.Now()
+	for i, groupKey := range []string{"first", "second", "first", "third"} {
+		now = func() time.Time { return start.Add(time.Duration(i) * time.Second) }
+		h.record(groupKey, "firing", "update", "INC1", nil)
+	}
+
+	// The least recently updated group key is evicted
+	if _, ok := h.get("second"); ok {
+		t.Errorf("Least recently updated group key must be evicted")
+	}
+	for _, groupKey := range []string{"first", "third"} {
+		if _, ok := h.get(groupKey); !ok {
+			t.Errorf("Group key %s must be kept", groupKey)
+		}
+	}
+}
+
+func TestGroupHistoryHandler(t *testing.T) {
+	now = func() time.Time { return time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+	history = newGroupHistory(defaultHistoryMaxEntries)
+	history.record("42", "firing", "create", "INC42", nil)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(groupHistoryHandler).ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/groups/42/history", nil))
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusOK)
+	}
+	want := `[{"time":"2020-01-01T12:00:00Z","status":"firing","action":"create","incident":"INC42"}]` + "\n"
+	if rr.Body.String() != want {
+		t.Errorf("Unexpected body: got %v, want %v", rr.Body.String(), want)
+	}
+
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(groupHistoryHandler).ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/groups/43/history", nil))
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const (
+	defaultHookTimeout = 5 * time.Second
+	hookFailureIgnore  = "ignore"
+	hookFailureAbort   = "abort"
+)
+
+// HookConfig - Extension hook invoked with the rendered incident before it is sent to ServiceNow, whose
+// response can modify the incident fields or veto the action
+type HookConfig struct {
+	Name string `yaml:"name"`
+	// Command run with the hook request on stdin, writing the hook response on stdout
+	Command []string `yaml:"command"`
+	// URL the hook request is POSTed to, answering the hook response
+	URL string `yaml:"url"`
+	// Actions the hook is invoked for (create, update, reopen), all by default
+	Actions []string `yaml:"actions"`
+	// Timeout of an invocation, 5s by default
+	Timeout time.Duration `yaml:"timeout"`
+	// Behavior when the hook fails: ignore (default), the action is sent, or abort, the notification fails
+	OnFailure string `yaml:"on_failure"`
+}
+
+// hookRequest is the JSON document sent to a hook
+type hookRequest struct {
+	Action         string   `json:"action"`
+	Receiver       string   `json:"receiver"`
+	GroupKey       string   `json:"group_key"`
+	Status         string   `json:"status"`
+	IncidentNumber string   `json:"incident_number,omitempty"`
+	Incident       Incident `json:"incident"`
+}
+
+// hookResponse is the JSON document answered by a hook, an empty response leaving the incident unchanged
+type hookResponse struct {
+	// Veto cancels the action
+	Veto   bool   `json:"veto"`
+	Reason string `json:"reason"`
+	// Fields set in the incident, a null value removing the field
+	Fields map[string]interface{} `json:"fields"`
+}
+
+func (c HookConfig) validate() error {
+	var errs strings.Builder
+	if len(c.Name) == 0 {
+		errs.WriteString("hook name is missing\n")
+	}
+	if (len(c.Command) == 0) == (len(c.URL) == 0) {
+		errs.WriteString(fmt.Sprintf("hook %q must have one of command or url\n", c.Name))
+	}
+	for _, action := range c.Actions {
+		if action != "create" && action != "update" && action != "reopen" {
+			errs.WriteString(fmt.Sprintf("hook %q action %q is invalid, must be one of: create, update, reopen\n", c.Name, action))
+		}
+	}
+	if c.OnFailure != "" && c.OnFailure != hookFailureIgnore && c.OnFailure != hookFailureAbort {
+		errs.WriteString(fmt.Sprintf("hook %q on_failure %q is invalid, must be one of: ignore, abort\n", c.Name, c.OnFailure))
+	}
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(errs.String(), "\n"))
+	}
+	return nil
+}
+
+// handles returns true if the hook is invoked for the action
+func (c HookConfig) handles(action string) bool {
+	if len(c.Actions) == 0 {
+		return true
+	}
+	for _, a := range c.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func (c HookConfig) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return defaultHookTimeout
+}
+
+// invoke sends the request to the hook and returns its response
+func (c HookConfig) invoke(request hookRequest) (hookResponse, error) {
+	response := hookResponse{}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return response, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout())
+	defer cancel()
+
+	var output []byte
+	if len(c.Command) > 0 {
+		cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+		cmd.Stdin = bytes.NewReader(body)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if output, err = cmd.Output(); err != nil {
+			return response, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+	} else {
+		req, err := http.NewRequest("POST", c.URL, bytes.NewReader(body))
+		if err != nil {
+			return response, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return response, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return response, fmt.Errorf("hook returned the HTTP error code: %v", resp.StatusCode)
+		}
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(resp.Body); err != nil {
+			return response, err
+		}
+		output = buf.Bytes()
+	}
+
+	if len(bytes.TrimSpace(output)) == 0 {
+		return response, nil
+	}
+	err = json.Unmarshal(output, &response)
+	return response, err
+}
+
+// runIncidentHooks invokes the hooks of the action in order, applying their field changes to the incident.
+// It returns true if a hook vetoed the action, and an error if a hook failed with the abort failure behavior.
+func runIncidentHooks(ctx context.Context, data template.Data, action string, incidentNumber string, incident Incident) (bool, error) {
+	if isDryRun(ctx) {
+		return false, nil
+	}
+	for _, hook := range config.Hooks {
+		if !hook.handles(action) {
+			continue
+		}
+		response, err := hook.invoke(hookRequest{
+			Action:         action,
+			Receiver:       data.Receiver,
+			GroupKey:       getGroupKey(data),
+			Status:         data.Status,
+			IncidentNumber: incidentNumber,
+			Incident:       incident,
+		})
+		if err != nil {
+			webhookHookInvocations.WithLabelValues(hook.Name, "error").Inc()
+			alertGroupLog(ctx, data).Errorf("Error invoking hook %s for %s of alert group key: %s, %v", hook.Name, action, getGroupKey(data), err)
+			if hook.OnFailure == hookFailureAbort {
+				return false, fmt.Errorf("hook %s failed: %v", hook.Name, err)
+			}
+			continue
+		}
+		if response.Veto {
+			webhookHookInvocations.WithLabelValues(hook.Name, "veto").Inc()
+			alertGroupLog(ctx, data).Infof("Hook %s vetoed %s of alert group key: %s, %s", hook.Name, action, getGroupKey(data), response.Reason)
+			history.record(getGroupKey(data), data.Status, "veto", incidentNumber, nil)
+			return true, nil
+		}
+		webhookHookInvocations.WithLabelValues(hook.Name, "allow").Inc()
+		for field, value := range response.Fields {
+			if value == nil {
+				delete(incident, field)
+				continue
+			}
+			incident[field] = value
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRunIncidentHooks_HTTP(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := hookRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		if request.Action != "create" || request.Receiver != "team" || request.Incident["impact"] != "2" {
+			t.Errorf("Unexpected hook request: %+v", request)
+		}
+		w.Write([]byte(`{"fields": {"impact": "1", "u_cost_center": "42", "urgency": null}}`))
+	}))
+	defer ts.Close()
+	config.Hooks = []HookConfig{{Name: "cost-center", URL: ts.URL}}
+
+	incident := Incident{"impact": "2", "urgency": "2"}
+	vetoed, err := runIncidentHooks(context.Background(), template.Data{Receiver: "team"}, "create", "", incident)
+	if vetoed || err != nil {
+		t.Fatalf("Unexpected hook result: %v %v", vetoed, err)
+	}
+	if incident["impact"] != "1" || incident["u_cost_center"] != "42" {
+		t.Errorf("Unexpected incident: %v", incident)
+	}
+	if _, ok := incident["urgency"]; ok {
+		t.Errorf("Field set to null must be removed: %v", incident)
+	}
+}
+
+func TestRunIncidentHooks_Command(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Hooks = []HookConfig{
+		{Name: "update-only", Command: []string{"sh", "-c", `echo '{"veto": true}'`}, Actions: []string{"update"}},
+		{Name: "failing", Command: []string{"sh", "-c", "exit 1"}},
+		{Name: "echo", Command: []string{"sh", "-c", `cat > /dev/null; echo '{"fields": {"u_hooked": "true"}}'`}},
+	}
+
+	incident := Incident{}
+	vetoed, err := runIncidentHooks(context.Background(), template.Data{}, "create", "", incident)
+	if vetoed || err != nil || incident["u_hooked"] != "true" {
+		t.Errorf("Unexpected hook result: %v %v %v", vetoed, err, incident)
+	}
+
+	config.Hooks[1].OnFailure = "abort"
+	if _, err := runIncidentHooks(context.Background(), template.Data{}, "create", "", Incident{}); err == nil {
+		t.Errorf("Failing hook must abort the action")
+	}
+}
+
+func TestOnAlertGroup_HookVeto(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Hooks = []HookConfig{{Name: "veto", Command: []string{"sh", "-c", `echo '{"veto": true, "reason": "maintenance"}'`}}}
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+
+	data := template.Data{Status: "firing", Alerts: template.Alerts{{Status: "firing"}}, GroupLabels: template.KV{"alertname": "hook-veto"}}
+	if err := onAlertGroup(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertNotCalled(t, "CreateIncident", mock.Anything)
+}
+
+func TestHookConfig_Validate(t *testing.T) {
+	err := HookConfig{Name: "bad", URL: "http://hook", Command: []string{"true"}, Actions: []string{"delete"}, OnFailure: "retry"}.validate()
+	expected := `hook "bad" must have one of command or url` + "\n" +
+		`hook "bad" action "delete" is invalid, must be one of: create, update, reopen` + "\n" +
+		`hook "bad" on_failure "retry" is invalid, must be one of: ignore, abort`
+	if err == nil || err.Error() != expected {
+		t.Errorf("Unexpected error; got: %v, want: %v", err, expected)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const defaultIncidentCacheMaxEntries = 10000
+
+// Eviction policies of the incident cache when it is full
+const (
+	evictionLRU  = "lru"
+	evictionFIFO = "fifo"
+)
+
+// IncidentCacheConfig - Cache of the incidents found for each group key, saving ServiceNow lookups.
+// Changes done in ServiceNow on cached incidents are not seen until the entry expires.
+type IncidentCacheConfig struct {
+	TTL            time.Duration `yaml:"ttl"`
+	MaxEntries     int           `yaml:"max_entries"`
+	EvictionPolicy string        `yaml:"eviction_policy"`
+}
+
+func (c IncidentCacheConfig) validate() error {
+	if c.TTL < 0 {
+		return fmt.Errorf("incident_cache ttl must not be negative")
+	}
+	if c.MaxEntries < 0 {
+		return fmt.Errorf("incident_cache max_entries must not be negative")
+	}
+	switch c.EvictionPolicy {
+	case "", evictionLRU, evictionFIFO:
+		return nil
+	}
+	return fmt.Errorf("incident_cache eviction_policy %q is not supported, must be one of: lru, fifo", c.EvictionPolicy)
+}
+
+type incidentCacheEntry struct {
+	groupKey  string
+	incidents []Incident
+	expires   time.Time
+}
+
+// incidentCache keeps the incidents of the group keys, bounded in time and size
+type incidentCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	lru        bool
+	order      *list.List
+	entries    map[string]*list.Element
+}
+
+var incidents = newIncidentCache()
+
+func newIncidentCache() *incidentCache {
+	return &incidentCache{order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// configure enables the cache when the TTL is positive, dropping the current entries
+func (c *incidentCache) configure(cfg IncidentCacheConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = cfg.TTL
+	c.maxEntries = cfg.MaxEntries
+	if c.maxEntries <= 0 {
+		c.maxEntries = defaultIncidentCacheMaxEntries
+	}
+	c.lru = cfg.EvictionPolicy != evictionFIFO
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+	webhookIncidentCacheEntries.Set(0)
+}
+
+// get returns a copy of the cached incidents of the group key
+func (c *incidentCache) get(groupKey string) ([]Incident, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	element, ok := c.entries[groupKey]
+	if ok && now().After(element.Value.(*incidentCacheEntry).expires) {
+		c.remove(element, "expired")
+		ok = false
+	}
+	if !ok {
+		webhookIncidentCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+
+	webhookIncidentCacheRequests.WithLabelValues("hit").Inc()
+	if c.lru {
+		c.order.MoveToFront(element)
+	}
+	return append([]Incident(nil), element.Value.(*incidentCacheEntry).incidents...), true
+}
+
+// set caches the incidents of the group key, evicting entries above the maximum
+func (c *incidentCache) set(groupKey string, groupIncidents []Incident) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 {
+		return
+	}
+
+	entry := &incidentCacheEntry{groupKey: groupKey, incidents: append([]Incident(nil), groupIncidents...), expires: now().Add(c.ttl)}
+	if element, ok := c.entries[groupKey]; ok {
+		element.Value = entry
+		if c.lru {
+			c.order.MoveToFront(element)
+		}
+		return
+	}
+
+	c.entries[groupKey] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back(), "size")
+	}
+	webhookIncidentCacheEntries.Set(float64(c.order.Len()))
+}
+
+// update replaces the cached incident having the same sys_id, or adds it. The
+// entry of the group key is invalidated if the incident has no sys_id.
+func (c *incidentCache) update(groupKey string, incident Incident) {
+	if len(incident.GetSysID()) == 0 {
+		c.invalidate(groupKey)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[groupKey]
+	if !ok {
+		return
+	}
+	entry := element.Value.(*incidentCacheEntry)
+	for i, cached := range entry.incidents {
+		if cached.GetSysID() == incident.GetSysID() {
+			entry.incidents[i] = incident
+			return
+		}
+	}
+	entry.incidents = append(entry.incidents, incident)
+}
+
+// invalidate removes the entry of the group key
+func (c *incidentCache) invalidate(groupKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[groupKey]; ok {
+		c.remove(element, "invalidated")
+	}
+}
+
+// remove deletes an entry, must be called with the lock held
+func (c *incidentCache) remove(element *list.Element, reason string) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*incidentCacheEntry).groupKey)
+	webhookIncidentCacheEvictions.WithLabelValues(reason).Inc()
+	webhookIncidentCacheEntries.Set(float64(c.order.Len()))
+}
+
+// cacheIncidentResult keeps the cached incidents of the alert group consistent with the result of an incident action
+func cacheIncidentResult(ctx context.Context, data template.Data, incident Incident, err error) {
+	if isDryRun(ctx) {
+		return
+	}
+	if err != nil {
+		incidents.invalidate(getGroupKey(data))
+		return
+	}
+	incidents.update(getGroupKey(data), incident)
+}
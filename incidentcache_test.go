@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIncidentCache_Eviction(t *testing.T) {
+	current := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	for _, test := range []struct {
+		policy  string
+		evicted string
+	}{
+		{evictionLRU, "b"},
+		{evictionFIFO, "a"},
+	} {
+		c := newIncidentCache()
+		c.configure(IncidentCacheConfig{TTL: time.Minute, MaxEntries: 2, EvictionPolicy: test.policy})
+		c.set("a", []Incident{{"sys_id": "1"}})
+		c.set("b", []Incident{{"sys_id": "2"}})
+		c.get("a")
+		c.set("c", []Incident{{"sys_id": "3"}})
+
+		if _, ok := c.get(test.evicted); ok {
+			t.Errorf("Entry %s must be evicted with %s policy", test.evicted, test.policy)
+		}
+		if _, ok := c.get("c"); !ok {
+			t.Errorf("Entry c must be cached with %s policy", test.policy)
+		}
+	}
+
+	c := newIncidentCache()
+	c.configure(IncidentCacheConfig{TTL: time.Minute})
+	c.set("a", []Incident{{"sys_id": "1"}})
+	current = current.Add(2 * time.Minute)
+	if _, ok := c.get("a"); ok {
+		t.Errorf("Expired entry must not be returned")
+	}
+}
+
+func TestIncidentCache_Update(t *testing.T) {
+	c := newIncidentCache()
+	c.configure(IncidentCacheConfig{TTL: time.Minute})
+	c.set("a", []Incident{{"sys_id": "1", "state": "7"}})
+
+	c.update("a", Incident{"sys_id": "2", "state": "1"})
+	c.update("a", Incident{"sys_id": "1", "state": "2"})
+	got, _ := c.get("a")
+	if len(got) != 2 || got[0]["state"] != "2" || got[1].GetSysID() != "2" {
+		t.Errorf("Unexpected cached incidents: %v", got)
+	}
+
+	c.update("a", Incident{})
+	if _, ok := c.get("a"); ok {
+		t.Errorf("Entry must be invalidated by an incident without sys_id")
+	}
+}
+
+func TestOnAlertGroup_IncidentCache(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.IncidentCache = IncidentCacheConfig{TTL: time.Minute}
+	incidents.configure(config.IncidentCache)
+	defer incidents.configure(IncidentCacheConfig{})
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{"sys_id": "42", "number": "INC42", "state": "1"}, nil)
+	snClientMock.On("UpdateIncident", mock.Anything, "42").Return(Incident{"sys_id": "42", "number": "INC42", "state": "1"}, nil)
+
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "cache"}}
+	if err := onAlertGroup(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	if err := onAlertGroup(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+
+	snClientMock.AssertNumberOfCalls(t, "GetIncidents", 1)
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+	snClientMock.AssertNumberOfCalls(t, "UpdateIncident", 1)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// resolveIncludes merges the configuration fragments matched by the include directive (glob patterns,
+// relative to the configuration file) into the configuration. Fragments are merged in file name order:
+// maps are merged key by key, lists are appended, and a value defined differently twice is an error.
+func resolveIncludes(configFile string, configData []byte) ([]byte, error) {
+	root := yaml.MapSlice{}
+	if err := yaml.Unmarshal(configData, &root); err != nil {
+		return configData, nil
+	}
+
+	var patterns []string
+	var merged interface{} = yaml.MapSlice{}
+	for _, item := range root {
+		if item.Key == "include" {
+			if err := decodeIncludePatterns(item.Value, &patterns); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		merged = append(merged.(yaml.MapSlice), item)
+	}
+	if len(patterns) == 0 {
+		return configData, nil
+	}
+
+	var files []string
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(configFile), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("include pattern %q is invalid: %v", pattern, err)
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		fragment := yaml.MapSlice{}
+		if err := yaml.Unmarshal(content, &fragment); err != nil {
+			return nil, fmt.Errorf("included file %s is invalid: %v", file, err)
+		}
+		for _, item := range fragment {
+			if item.Key == "include" {
+				return nil, fmt.Errorf("included file %s can't include other files", file)
+			}
+		}
+		if merged, err = mergeYAML(merged, fragment, ""); err != nil {
+			return nil, fmt.Errorf("included file %s: %v", file, err)
+		}
+	}
+	return yaml.Marshal(merged)
+}
+
+func decodeIncludePatterns(value interface{}, patterns *[]string) error {
+	content, err := yaml.Marshal(value)
+	if err == nil {
+		err = yaml.UnmarshalStrict(content, patterns)
+	}
+	if err != nil {
+		return fmt.Errorf("include must be a list of file patterns")
+	}
+	return nil
+}
+
+// mergeYAML merges the fragment value into the base value at path
+func mergeYAML(base interface{}, fragment interface{}, path string) (interface{}, error) {
+	switch fragmentValue := fragment.(type) {
+	case yaml.MapSlice:
+		baseMap, ok := base.(yaml.MapSlice)
+		if !ok && base != nil {
+			return nil, fmt.Errorf("%s is not a map in the configuration", path)
+		}
+		for _, item := range fragmentValue {
+			itemPath := fmt.Sprintf("%v", item.Key)
+			if len(path) > 0 {
+				itemPath = path + "." + itemPath
+			}
+			found := false
+			for i := range baseMap {
+				if baseMap[i].Key == item.Key {
+					value, err := mergeYAML(baseMap[i].Value, item.Value, itemPath)
+					if err != nil {
+						return nil, err
+					}
+					baseMap[i].Value = value
+					found = true
+					break
+				}
+			}
+			if !found {
+				baseMap = append(baseMap, item)
+			}
+		}
+		return baseMap, nil
+	case []interface{}:
+		baseList, ok := base.([]interface{})
+		if !ok && base != nil {
+			return nil, fmt.Errorf("%s is not a list in the configuration", path)
+		}
+		return append(baseList, fragmentValue...), nil
+	default:
+		if base != nil && !reflect.DeepEqual(base, fragment) {
+			return nil, fmt.Errorf("%s is already defined with another value", path)
+		}
+		return fragment, nil
+	}
+}
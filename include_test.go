@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeIncludeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "include")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadConfig_Include(t *testing.T) {
+	defer loadConfig("config/servicenow_example.yml")
+	dir := writeIncludeFiles(t, map[string]string{
+		"servicenow.yml": `
+include:
+  - "teams/*.yml"
+service_now:
+  instance_name: "instance"
+  user_name: "user"
+  password: "password"
+workflow:
+  incident_group_key_field: "u_prometheus_alertgroup_id"
+  no_update_states: [6, 7]
+default_incident:
+  impact: "2"
+`,
+		"teams/b.yml": `
+redactions:
+  - regex: "secret=\\S+"
+journal:
+  receivers:
+    "team-b":
+      created: "Created for team b"
+`,
+		"teams/a.yml": `
+redactions:
+  - regex: "token=\\S+"
+default_incident:
+  impact: "2"
+journal:
+  receivers:
+    "team-a":
+      created: "Created for team a"
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	loaded, err := loadConfig(filepath.Join(dir, "servicenow.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.ServiceNow.InstanceName != "instance" {
+		t.Errorf("Wrong instance name: %s", loaded.ServiceNow.InstanceName)
+	}
+	if len(loaded.Redactions) != 2 || loaded.Redactions[0].Regex != "token=\\S+" {
+		t.Errorf("Redactions must be appended in file name order: %+v", loaded.Redactions)
+	}
+	if len(loaded.Journal.Receivers) != 2 {
+		t.Errorf("Journal receivers must be merged: %+v", loaded.Journal.Receivers)
+	}
+}
+
+func TestLoadConfig_IncludeConflict(t *testing.T) {
+	defer loadConfig("config/servicenow_example.yml")
+	dir := writeIncludeFiles(t, map[string]string{
+		"servicenow.yml": `
+include: ["team.yml"]
+service_now:
+  instance_name: "instance"
+  user_name: "user"
+  password: "password"
+`,
+		"team.yml": `
+service_now:
+  instance_name: "other"
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	_, err := loadConfig(filepath.Join(dir, "servicenow.yml"))
+	if err == nil || !strings.Contains(err.Error(), "service_now.instance_name is already defined") {
+		t.Errorf("Expected a conflict error, got: %v", err)
+	}
+}
+
+func TestLoadConfig_IncludeNested(t *testing.T) {
+	defer loadConfig("config/servicenow_example.yml")
+	dir := writeIncludeFiles(t, map[string]string{
+		"servicenow.yml": `
+include: ["team.yml"]
+service_now:
+  instance_name: "instance"
+  user_name: "user"
+  password: "password"
+`,
+		"team.yml": `
+include: ["other.yml"]
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	if _, err := loadConfig(filepath.Join(dir, "servicenow.yml")); err == nil {
+		t.Error("Nested includes must be rejected")
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// InhibitionConfig - Inhibition of incident creation for target alert groups while a source
+// alert group has an open incident. Target alert groups are added as work notes to the source incident.
+type InhibitionConfig struct {
+	// Common labels of source alert groups
+	SourceMatch map[string]string `yaml:"source_match"`
+	// Common labels of target alert groups
+	TargetMatch map[string]string `yaml:"target_match"`
+	// Labels which must have equal values in the source and target alert groups
+	Equal []string `yaml:"equal"`
+}
+
+// inhibitionSource is a firing source alert group with an open incident
+type inhibitionSource struct {
+	labels   template.KV
+	incident Incident
+}
+
+// inhibitionSources keeps the incidents of the firing source alert groups, by group key
+type inhibitionSources struct {
+	mu      sync.Mutex
+	sources map[string]inhibitionSource
+}
+
+var inhibitions = &inhibitionSources{sources: make(map[string]inhibitionSource)}
+
+// matchLabels returns true if the labels have all the expected values
+func matchLabels(labels template.KV, match map[string]string) bool {
+	for name, value := range match {
+		if labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// track keeps the incident of a firing source alert group, and forgets it when the alert group is resolved
+func (s *inhibitionSources) track(ctx context.Context, data template.Data, incident Incident) {
+	if len(config.Inhibitions) == 0 || isDryRun(ctx) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if data.Status != "firing" || len(incident.GetSysID()) == 0 {
+		delete(s.sources, getGroupKey(data))
+		return
+	}
+	for _, rule := range config.Inhibitions {
+		if matchLabels(data.CommonLabels, rule.SourceMatch) {
+			s.sources[getGroupKey(data)] = inhibitionSource{labels: data.CommonLabels, incident: incident}
+			return
+		}
+	}
+}
+
+// find returns the incident of a source alert group inhibiting the target alert group, if any
+func (s *inhibitionSources) find(data template.Data) Incident {
+	if len(config.Inhibitions) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groupKeys := make([]string, 0, len(s.sources))
+	for groupKey := range s.sources {
+		groupKeys = append(groupKeys, groupKey)
+	}
+	sort.Strings(groupKeys)
+
+	for _, rule := range config.Inhibitions {
+		if !matchLabels(data.CommonLabels, rule.TargetMatch) {
+			continue
+		}
+		for _, groupKey := range groupKeys {
+			source := s.sources[groupKey]
+			if groupKey == getGroupKey(data) || !matchLabels(source.labels, rule.SourceMatch) {
+				continue
+			}
+			// Incidents of another ServiceNow instance can't be updated
+			if serviceNowInstanceName(template.Data{CommonLabels: source.labels}) != serviceNowInstanceName(data) {
+				continue
+			}
+			equal := true
+			for _, name := range rule.Equal {
+				if source.labels[name] != data.CommonLabels[name] {
+					equal = false
+					break
+				}
+			}
+			if equal {
+				return source.incident
+			}
+		}
+	}
+	return nil
+}
+
+// inhibitedWorkNote describes the inhibited alert group in the source incident
+func inhibitedWorkNote(data template.Data) string {
+	var str strings.Builder
+	str.WriteString(fmt.Sprintf("Inhibited alert group %s with %d firing alert(s):\n", formatLabels(data.GroupLabels), len(data.Alerts.Firing())))
+	for _, alert := range data.Alerts.Firing() {
+		str.WriteString(fmt.Sprintf("- %s\n", formatLabels(alert.Labels)))
+	}
+	return str.String()
+}
+
+// formatLabels formats labels as {name="value", ...}
+func formatLabels(labels template.KV) string {
+	pairs := make([]string, 0, len(labels))
+	for _, pair := range labels.SortedPairs() {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", pair.Name, pair.Value))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// inhibitIncident adds the alert group as a work note to the inhibiting incident instead of creating
+// a new incident, and returns true if the alert group was inhibited
+func inhibitIncident(ctx context.Context, data template.Data) (bool, error) {
+	sourceIncident := inhibitions.find(data)
+	if sourceIncident == nil {
+		return false, nil
+	}
+
+	incidentLog(ctx, data, sourceIncident).Infof("Alert group key: %s is inhibited by incident (%s)", getGroupKey(data), sourceIncident.GetNumber())
+	_, err := serviceNowFor(ctx, data).UpdateIncident(Incident{"work_notes": inhibitedWorkNote(data)}, sourceIncident.GetSysID())
+	history.record(getGroupKey(data), data.Status, "inhibit", sourceIncident.GetNumber(), err)
+	if err != nil {
+		serviceNowError.Inc()
+		return true, err
+	}
+	progress.complete(data, stepIncident, sourceIncident.GetNumber())
+	return true, nil
+}
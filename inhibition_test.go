@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOnAlertGroup_Inhibition(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Inhibitions = []InhibitionConfig{{
+		SourceMatch: map[string]string{"alertname": "ClusterDown"},
+		TargetMatch: map[string]string{"alertname": "NodeDown"},
+		Equal:       []string{"cluster"},
+	}}
+	inhibitions = &inhibitionSources{sources: make(map[string]inhibitionSource)}
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{"sys_id": "42", "number": "INC42"}, nil)
+	snClientMock.On("UpdateIncident", mock.MatchedBy(func(param Incident) bool {
+		note, _ := param["work_notes"].(string)
+		return strings.Contains(note, `node="node1"`)
+	}), "42").Return(Incident{}, nil)
+
+	source := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "ClusterDown"}, CommonLabels: template.KV{"alertname": "ClusterDown", "cluster": "a"}}
+	if err := onAlertGroup(context.Background(), source); err != nil {
+		t.Fatal(err)
+	}
+
+	target := func(cluster string, node string) template.Data {
+		labels := template.KV{"alertname": "NodeDown", "cluster": cluster, "node": node}
+		return template.Data{
+			Status:       "firing",
+			Alerts:       template.Alerts{{Status: "firing", Labels: labels}},
+			GroupLabels:  template.KV{"alertname": "NodeDown", "node": node},
+			CommonLabels: labels,
+		}
+	}
+	if err := onAlertGroup(context.Background(), target("a", "node1")); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+	snClientMock.AssertNumberOfCalls(t, "UpdateIncident", 1)
+
+	// Different cluster, not inhibited
+	if err := onAlertGroup(context.Background(), target("b", "node2")); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 2)
+
+	// Source resolved, not inhibited anymore
+	source.Status = "resolved"
+	if err := onAlertGroup(context.Background(), source); err != nil {
+		t.Fatal(err)
+	}
+	if err := onAlertGroup(context.Background(), target("a", "node3")); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 3)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// ServiceNowInstanceConfig - Additional ServiceNow instance, receiving the alert groups whose common labels match
+type ServiceNowInstanceConfig struct {
+	Name string `yaml:"name"`
+	// Common labels of the alert groups routed to the instance
+	Match      map[string]string `yaml:"match"`
+	ServiceNow ServiceNowConfig  `yaml:"service_now"`
+}
+
+func validateServiceNowInstances(instances []ServiceNowInstanceConfig) error {
+	var errs strings.Builder
+	names := make(map[string]bool)
+	for i, instance := range instances {
+		if len(instance.Name) == 0 {
+			errs.WriteString(fmt.Sprintf("service_now_instances %d name is missing\n", i))
+		} else if names[instance.Name] {
+			errs.WriteString(fmt.Sprintf("service_now_instances %s is defined more than once\n", instance.Name))
+		}
+		names[instance.Name] = true
+		if len(instance.Match) == 0 {
+			errs.WriteString(fmt.Sprintf("service_now_instances %s match must not be empty\n", instance.Name))
+		}
+		if len(instance.ServiceNow.InstanceName) == 0 {
+			errs.WriteString(fmt.Sprintf("service_now_instances %s instance_name is missing\n", instance.Name))
+		}
+		if instance.ServiceNow.OAuth2.enabled() {
+			if err := instance.ServiceNow.OAuth2.validate(); err != nil {
+				errs.WriteString(fmt.Sprintf("service_now_instances %s %v\n", instance.Name, err))
+			}
+		} else if len(instance.ServiceNow.UserName) == 0 || (len(instance.ServiceNow.Password) == 0 && len(instance.ServiceNow.PasswordFile) == 0) {
+			errs.WriteString(fmt.Sprintf("service_now_instances %s user_name or password is missing\n", instance.Name))
+		}
+		if err := instance.ServiceNow.TableAPIParams.validate(); err != nil {
+			errs.WriteString(fmt.Sprintf("service_now_instances %s %v\n", instance.Name, err))
+		}
+		if err := validateFieldMap(instance.ServiceNow.FieldMap); err != nil {
+			errs.WriteString(fmt.Sprintf("service_now_instances %s %v\n", instance.Name, err))
+		}
+		if err := validateProxy(instance.ServiceNow.ProxyURL, instance.ServiceNow.ProxyAuth); err != nil {
+			errs.WriteString(fmt.Sprintf("service_now_instances %s %v\n", instance.Name, err))
+		}
+		if err := instance.ServiceNow.TLSConfig.validate(); err != nil {
+			errs.WriteString(fmt.Sprintf("service_now_instances %s %v\n", instance.Name, err))
+		}
+		if err := instance.ServiceNow.QuietHours.validate(); err != nil {
+			errs.WriteString(fmt.Sprintf("service_now_instances %s %v\n", instance.Name, err))
+		}
+	}
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(errs.String(), "\n"))
+	}
+	return nil
+}
+
+// loadServiceNowInstances creates the clients of the additional instances, by name
+func loadServiceNowInstances(instances []ServiceNowInstanceConfig) (map[string]*ServiceNowClient, error) {
+	clients := make(map[string]*ServiceNowClient, len(instances))
+	for _, instance := range instances {
+		snClient, err := newServiceNowClientFromConfig(instance.ServiceNow)
+		if err != nil {
+			return nil, fmt.Errorf("service_now_instances %s: %v", instance.Name, err)
+		}
+		clients[instance.Name] = snClient
+	}
+	return clients, nil
+}
+
+// serviceNowInstanceName returns the name of the first instance matching the common labels of the
+// alert group, or an empty name for the default instance
+func serviceNowInstanceName(data template.Data) string {
+	if profile := selectProfile(data); profile != nil && len(profile.Instance) > 0 {
+		return profile.Instance
+	}
+	for _, instance := range config.ServiceNowInstances {
+		if matchLabels(data.CommonLabels, instance.Match) {
+			return instance.Name
+		}
+	}
+	return ""
+}
+
+// serviceNowConfigByName returns the configuration of the ServiceNow instance of the name, the default instance
+// configuration for an empty name
+func serviceNowConfigByName(name string) ServiceNowConfig {
+	for _, instance := range config.ServiceNowInstances {
+		if instance.Name == name {
+			return instance.ServiceNow
+		}
+	}
+	return config.ServiceNow
+}
+
+// serviceNowFor returns the ServiceNow instance of the alert group, sending its requests with the request ID of the context
+func serviceNowFor(ctx context.Context, data template.Data) ServiceNow {
+	if client, ok := dryRunFrom(ctx); ok {
+		return client
+	}
+	return withRequestScope(ctx, serviceNowByName(serviceNowInstanceName(data)))
+}
+
+// serviceNowByName returns the ServiceNow instance of the name, the default instance for an empty name
+func serviceNowByName(name string) ServiceNow {
+	client := serviceNow
+	if instance, ok := serviceNowInstances[name]; ok {
+		client = instance
+	}
+	if *dryRunFlag {
+		return &dryRunSnClient{ServiceNow: client, ctx: context.Background()}
+	}
+	return client
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOnAlertGroup_ServiceNowInstances(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { serviceNowInstances = nil }()
+	config.ServiceNowInstances = []ServiceNowInstanceConfig{
+		{Name: "prod", Match: map[string]string{"team": "payments"}},
+	}
+
+	defaultMock := new(MockedSnClient)
+	serviceNow = defaultMock
+	prodMock := new(MockedSnClient)
+	serviceNowInstances = map[string]ServiceNow{"prod": prodMock}
+	for _, snClientMock := range []*MockedSnClient{defaultMock, prodMock} {
+		snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+		snClientMock.On("CreateIncident", mock.Anything).Return(Incident{"number": "INC1", "sys_id": "1"}, nil)
+	}
+
+	alerts := template.Alerts{{Status: "firing"}}
+	payments := template.Data{Status: "firing", Alerts: alerts, GroupLabels: template.KV{"alertname": "instances-payments"},
+		CommonLabels: template.KV{"alertname": "instances-payments", "team": "payments"}}
+	if err := onAlertGroup(context.Background(), payments); err != nil {
+		t.Fatal(err)
+	}
+	prodMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+	defaultMock.AssertNotCalled(t, "CreateIncident", mock.Anything)
+
+	shared := template.Data{Status: "firing", Alerts: alerts, GroupLabels: template.KV{"alertname": "instances-shared"},
+		CommonLabels: template.KV{"alertname": "instances-shared", "team": "search"}}
+	if err := onAlertGroup(context.Background(), shared); err != nil {
+		t.Fatal(err)
+	}
+	defaultMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+	prodMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+}
+
+func TestValidateServiceNowInstances(t *testing.T) {
+	valid := ServiceNowInstanceConfig{
+		Name:       "prod",
+		Match:      map[string]string{"team": "payments"},
+		ServiceNow: ServiceNowConfig{InstanceName: "prod", UserName: "user", Password: "password"},
+	}
+	if err := validateServiceNowInstances([]ServiceNowInstanceConfig{valid}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	err := validateServiceNowInstances([]ServiceNowInstanceConfig{valid, valid, {Name: "empty"}})
+	expected := "service_now_instances prod is defined more than once\n" +
+		"service_now_instances empty match must not be empty\n" +
+		"service_now_instances empty instance_name is missing\n" +
+		"service_now_instances empty user_name or password is missing"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Unexpected error; got: %v, want: %v", err, expected)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	tmpltext "text/template"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const defaultJournalField = "work_notes"
+
+// Incident lifecycle events written to the incident journal
+const (
+	journalCreated        = "created"
+	journalAlertsAdded    = "alerts_added"
+	journalAlertsResolved = "alerts_resolved"
+	journalAutoClosed     = "auto_closed"
+)
+
+// JournalConfig - Journal entries written on incident lifecycle events
+type JournalConfig struct {
+	// Journal field of the incident, work_notes by default
+	Field string `yaml:"field"`
+	// Field of the incident holding the hash of the last journal entry, used to skip duplicate entries
+	HashField string                      `yaml:"hash_field"`
+	Templates JournalTemplates            `yaml:"templates"`
+	Receivers map[string]JournalTemplates `yaml:"receivers"`
+}
+
+// JournalTemplates - Journal entry template of each incident lifecycle event
+type JournalTemplates struct {
+	Created        string `yaml:"created"`
+	AlertsAdded    string `yaml:"alerts_added"`
+	AlertsResolved string `yaml:"alerts_resolved"`
+	AutoClosed     string `yaml:"auto_closed"`
+}
+
+// get returns the template of the event
+func (t JournalTemplates) get(event string) string {
+	switch event {
+	case journalCreated:
+		return t.Created
+	case journalAlertsAdded:
+		return t.AlertsAdded
+	case journalAlertsResolved:
+		return t.AlertsResolved
+	case journalAutoClosed:
+		return t.AutoClosed
+	}
+	return ""
+}
+
+func (c JournalConfig) validate() error {
+	var errs strings.Builder
+	templates := map[string]JournalTemplates{"": c.Templates}
+	for receiver, receiverTemplates := range c.Receivers {
+		templates[receiver] = receiverTemplates
+	}
+	for receiver, receiverTemplates := range templates {
+		for _, event := range []string{journalCreated, journalAlertsAdded, journalAlertsResolved, journalAutoClosed} {
+			if _, err := tmpltext.New(event).Funcs(templateFuncs("")).Parse(receiverTemplates.get(event)); err != nil {
+				errs.WriteString(fmt.Sprintf("journal %s template of receiver %q is invalid: %v\n", event, receiver, err))
+			}
+		}
+	}
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(errs.String(), "\n"))
+	}
+	return nil
+}
+
+// applyJournal sets the journal entry of the event in the incident, using the
+// template of the receiver if any, then the one of its template set, or the default template of the event
+func applyJournal(ctx context.Context, data template.Data, event string, incident Incident) {
+	text := config.Journal.Receivers[data.Receiver].get(event)
+	if set := selectTemplateSet(data); len(text) == 0 && set != nil {
+		text = set.Journal.get(event)
+	}
+	if len(text) == 0 {
+		text = config.Journal.Templates.get(event)
+	}
+	if len(text) == 0 {
+		return
+	}
+
+	entry, err := applyTemplate(event, text, capRenderedAlerts(normalizeAlertTimes(ctx, data)))
+	if err != nil {
+		webhookIncidentTemplateError.Inc()
+		log.Errorf("Error parsing journal template for event:%s, error:%v", event, err)
+		return
+	}
+
+	field := config.Journal.Field
+	if len(field) == 0 {
+		field = defaultJournalField
+	}
+	incident[field] = formatFieldValue(field, entry)
+	if len(config.Journal.HashField) > 0 {
+		incident[config.Journal.HashField] = journalHash(event, entry)
+	}
+}
+
+// journalHash returns the content hash of the journal entry of the event
+func journalHash(event string, entry string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(event+"\n"+entry)))
+}
+
+// skipDuplicateJournal removes the journal entry from the incident update when the existing
+// incident already holds the same entry, e.g. when a notification is retried or processed by
+// several replicas
+func skipDuplicateJournal(existingIncident Incident, incident Incident) {
+	hashField := config.Journal.HashField
+	if len(hashField) == 0 || incident[hashField] == nil {
+		return
+	}
+	if fmt.Sprint(existingIncident[hashField]) != fmt.Sprint(incident[hashField]) {
+		return
+	}
+
+	field := config.Journal.Field
+	if len(field) == 0 {
+		field = defaultJournalField
+	}
+	log.WithFields(incidentLogFields(existingIncident)).Infof("Skipping duplicate journal entry for incident %s", existingIncident.GetNumber())
+	webhookJournalDuplicates.Inc()
+	delete(incident, field)
+	delete(incident, hashField)
+}
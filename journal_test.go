@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestApplyJournal(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Journal = JournalConfig{
+		Templates: JournalTemplates{
+			Created:        "Created by {{ .Receiver }}",
+			AlertsResolved: "{{ len .Alerts.Resolved }} alert(s) resolved",
+		},
+		Receivers: map[string]JournalTemplates{
+			"dba": {Created: "DBA incident"},
+		},
+	}
+
+	tests := []struct {
+		receiver string
+		event    string
+		want     interface{}
+	}{
+		{"team", journalCreated, "Created by team"},
+		{"dba", journalCreated, "DBA incident"},
+		{"dba", journalAlertsResolved, "1 alert(s) resolved"},
+		{"team", journalAlertsAdded, nil},
+	}
+	for _, test := range tests {
+		incident := Incident{}
+		data := template.Data{Receiver: test.receiver, Alerts: template.Alerts{{Status: "resolved"}}}
+		applyJournal(context.Background(), data, test.event, incident)
+		if incident["work_notes"] != test.want {
+			t.Errorf("Unexpected %s journal for receiver %s: got %v, want %v", test.event, test.receiver, incident["work_notes"], test.want)
+		}
+	}
+}
+
+func TestApplyAutoResolve_Journal(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Journal = JournalConfig{
+		Field:     "comments",
+		Templates: JournalTemplates{AlertsResolved: "Resolved", AutoClosed: "Auto-closed"},
+	}
+	config.Workflow.AutoResolve = AutoResolveConfig{State: "6", Delay: time.Minute}
+	scheduler = newActionScheduler()
+
+	data := template.Data{Status: "resolved"}
+	updateParam := Incident{}
+	applyJournal(context.Background(), data, journalAlertsResolved, updateParam)
+	applyAutoResolve(context.Background(), data, Incident{"sys_id": "42"}, updateParam)
+
+	if updateParam["comments"] != "Resolved" {
+		t.Errorf("Unexpected update journal: got %v, want %v", updateParam["comments"], "Resolved")
+	}
+	pending := scheduler.pending()
+	if len(pending) != 1 || pending[0].Params["comments"] != "Auto-closed" {
+		t.Errorf("Unexpected scheduled resolution: %+v", pending)
+	}
+}
+
+func TestJournalConfig_Validate(t *testing.T) {
+	c := JournalConfig{Receivers: map[string]JournalTemplates{"dba": {AlertsResolved: "{{ .Status"}}}
+	if err := c.validate(); err == nil {
+		t.Errorf("Invalid journal template must be rejected")
+	}
+}
+
+func TestSkipDuplicateJournal(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Journal = JournalConfig{
+		HashField: "u_journal_hash",
+		Templates: JournalTemplates{AlertsAdded: "{{ len .Alerts.Firing }} alert(s) firing"},
+	}
+	data := template.Data{Alerts: template.Alerts{{Status: "firing"}}}
+
+	first := Incident{"comments": "update"}
+	applyJournal(context.Background(), data, journalAlertsAdded, first)
+	skipDuplicateJournal(Incident{"number": "INC42"}, first)
+	if first["work_notes"] != "1 alert(s) firing" || first["u_journal_hash"] == nil {
+		t.Fatalf("First journal entry must be written: %v", first)
+	}
+
+	existing := Incident{"number": "INC42", "u_journal_hash": first["u_journal_hash"]}
+	retried := Incident{"comments": "update"}
+	applyJournal(context.Background(), data, journalAlertsAdded, retried)
+	skipDuplicateJournal(existing, retried)
+	if _, ok := retried["work_notes"]; ok {
+		t.Errorf("Duplicate journal entry must be skipped: %v", retried)
+	}
+	if _, ok := retried["u_journal_hash"]; ok {
+		t.Errorf("Hash of a skipped journal entry must not be written: %v", retried)
+	}
+	if retried["comments"] != "update" {
+		t.Errorf("Other fields must be kept: %v", retried)
+	}
+
+	data.Alerts = append(data.Alerts, template.Alert{Status: "firing"})
+	changed := Incident{}
+	applyJournal(context.Background(), data, journalAlertsAdded, changed)
+	skipDuplicateJournal(existing, changed)
+	if changed["work_notes"] != "2 alert(s) firing" {
+		t.Errorf("New journal entry must be written: %v", changed)
+	}
+}
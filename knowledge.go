@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const (
+	defaultRunbookAnnotation = "runbook_url"
+	defaultRunbookLinkField  = "description"
+	knowledgeTable           = "kb_knowledge"
+	knowledgeTaskTable       = "m2m_kb_task"
+)
+
+var (
+	knowledgeNumberRegexp = regexp.MustCompile(`^KB[0-9]+$`)
+	knowledgeSysIDRegexp  = regexp.MustCompile(`^[0-9a-f]{32}$`)
+)
+
+// KnowledgeConfig - Runbook annotations linked to the incident, as ServiceNow knowledge articles or as a link section
+type KnowledgeConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Alert annotation holding the runbook URL, runbook_url by default
+	Annotation string `yaml:"annotation"`
+	// Incident field the section of the runbooks which are not knowledge articles is appended to, description by default
+	LinkField string `yaml:"link_field"`
+	// Receivers (routes) for which runbooks are linked, all when empty
+	Receivers []string `yaml:"receivers"`
+}
+
+// knowledgeArticle references a knowledge article by number or sys_id
+type knowledgeArticle struct {
+	number string
+	sysID  string
+}
+
+func (c KnowledgeConfig) enabledFor(receiver string) bool {
+	if !c.Enabled {
+		return false
+	}
+	if len(c.Receivers) == 0 {
+		return true
+	}
+	for _, allowed := range c.Receivers {
+		if allowed == receiver {
+			return true
+		}
+	}
+	return false
+}
+
+// getRunbookURLs returns the distinct runbook URLs of the firing alerts, sorted
+func getRunbookURLs(data template.Data) []string {
+	annotation := config.Knowledge.Annotation
+	if len(annotation) == 0 {
+		annotation = defaultRunbookAnnotation
+	}
+	found := make(map[string]bool)
+	for _, alert := range data.Alerts.Firing() {
+		if runbook := alert.Annotations[annotation]; len(runbook) > 0 {
+			found[runbook] = true
+		}
+	}
+	if runbook := data.CommonAnnotations[annotation]; len(runbook) > 0 {
+		found[runbook] = true
+	}
+	urls := make([]string, 0, len(found))
+	for runbook := range found {
+		urls = append(urls, runbook)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// parseKnowledgeArticle returns the knowledge article a runbook URL points to, if any:
+// kb_view.do?sysparm_article=KB0010001, kb_knowledge.do?sys_id=<sys_id> or ?sys_kb_id=<sys_id>
+func parseKnowledgeArticle(runbook string) (knowledgeArticle, bool) {
+	parsed, err := url.Parse(runbook)
+	if err != nil {
+		return knowledgeArticle{}, false
+	}
+	query := parsed.Query()
+	if number := query.Get("sysparm_article"); knowledgeNumberRegexp.MatchString(number) {
+		return knowledgeArticle{number: number}, true
+	}
+	if sysID := query.Get("sys_kb_id"); knowledgeSysIDRegexp.MatchString(sysID) {
+		return knowledgeArticle{sysID: sysID}, true
+	}
+	if strings.HasSuffix(parsed.Path, "/"+knowledgeTable+".do") && knowledgeSysIDRegexp.MatchString(query.Get("sys_id")) {
+		return knowledgeArticle{sysID: query.Get("sys_id")}, true
+	}
+	return knowledgeArticle{}, false
+}
+
+// applyRunbookLinks appends the runbooks which are not knowledge articles as a link section of the incident
+func applyRunbookLinks(incident Incident, data template.Data) {
+	if !config.Knowledge.enabledFor(data.Receiver) {
+		return
+	}
+	var section strings.Builder
+	for _, runbook := range getRunbookURLs(data) {
+		if _, ok := parseKnowledgeArticle(runbook); !ok {
+			section.WriteString("- " + runbook + "\n")
+		}
+	}
+	if section.Len() == 0 {
+		return
+	}
+
+	field := config.Knowledge.LinkField
+	if len(field) == 0 {
+		field = defaultRunbookLinkField
+	}
+	text := fmt.Sprint(incident[field])
+	if incident[field] == nil {
+		text = ""
+	}
+	if len(text) > 0 {
+		text += "\n\n"
+	}
+	incident[field] = text + "Runbooks:\n" + strings.TrimSuffix(section.String(), "\n")
+}
+
+// linkKnowledgeArticles links the knowledge articles of the runbooks to the incident, errors are logged but ignored
+func linkKnowledgeArticles(ctx context.Context, data template.Data, incident Incident) {
+	if !config.Knowledge.enabledFor(data.Receiver) || len(incident.GetSysID()) == 0 {
+		return
+	}
+	var articles []knowledgeArticle
+	for _, runbook := range getRunbookURLs(data) {
+		if article, ok := parseKnowledgeArticle(runbook); ok {
+			articles = append(articles, article)
+		}
+	}
+	if len(articles) == 0 {
+		return
+	}
+
+	links, err := serviceNowFor(ctx, data).GetRecords(knowledgeTaskTable, map[string]string{"task": incident.GetSysID()})
+	if err != nil {
+		incidentLog(ctx, data, incident).Errorf("Error getting knowledge articles of incident (%s): %v", incident.GetNumber(), err)
+		return
+	}
+	linked := make(map[string]bool)
+	for _, link := range links {
+		linked[fmt.Sprint(link[knowledgeTable])] = true
+	}
+
+	for _, article := range articles {
+		params := map[string]string{"number": article.number}
+		if len(article.sysID) > 0 {
+			params = map[string]string{"sys_id": article.sysID}
+		}
+		found, err := serviceNowFor(ctx, data).GetRecords(knowledgeTable, params)
+		if err != nil || len(found) == 0 {
+			incidentLog(ctx, data, incident).Warnf("Knowledge article %v of incident (%s) is not found: %v", params, incident.GetNumber(), err)
+			continue
+		}
+		sysID := found[0].GetSysID()
+		if linked[sysID] {
+			continue
+		}
+		if _, err := serviceNowFor(ctx, data).CreateRecord(knowledgeTaskTable, Incident{knowledgeTable: sysID, "task": incident.GetSysID()}); err != nil {
+			incidentLog(ctx, data, incident).Errorf("Error linking knowledge article %s to incident (%s): %v", sysID, incident.GetNumber(), err)
+			continue
+		}
+		linked[sysID] = true
+		incidentLog(ctx, data, incident).Infof("Knowledge article %s linked to incident (%s)", sysID, incident.GetNumber())
+	}
+}
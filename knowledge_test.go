@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParseKnowledgeArticle(t *testing.T) {
+	tests := []struct {
+		runbook string
+		want    knowledgeArticle
+		ok      bool
+	}{
+		{"https://instance.service-now.com/kb_view.do?sysparm_article=KB0010001", knowledgeArticle{number: "KB0010001"}, true},
+		{"https://instance.service-now.com/kb_knowledge.do?sys_id=0123456789abcdef0123456789abcdef", knowledgeArticle{sysID: "0123456789abcdef0123456789abcdef"}, true},
+		{"https://instance.service-now.com/sp?id=kb_article&sys_kb_id=0123456789abcdef0123456789abcdef", knowledgeArticle{sysID: "0123456789abcdef0123456789abcdef"}, true},
+		{"https://instance.service-now.com/incident.do?sys_id=0123456789abcdef0123456789abcdef", knowledgeArticle{}, false},
+		{"https://wiki.example.com/runbooks/high-load", knowledgeArticle{}, false},
+	}
+	for _, test := range tests {
+		got, ok := parseKnowledgeArticle(test.runbook)
+		if got != test.want || ok != test.ok {
+			t.Errorf("Unexpected article of %s: got %+v %v, want %+v %v", test.runbook, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestApplyRunbookLinks(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Knowledge = KnowledgeConfig{Enabled: true, Receivers: []string{"team"}}
+
+	data := template.Data{
+		Receiver: "team",
+		Alerts: template.Alerts{
+			{Status: "firing", Annotations: template.KV{"runbook_url": "https://wiki.example.com/b"}},
+			{Status: "firing", Annotations: template.KV{"runbook_url": "https://wiki.example.com/a"}},
+			{Status: "firing", Annotations: template.KV{"runbook_url": "https://wiki.example.com/a"}},
+			{Status: "firing", Annotations: template.KV{"runbook_url": "https://sn/kb_view.do?sysparm_article=KB0010001"}},
+		},
+	}
+	incident := Incident{"description": "High load"}
+	applyRunbookLinks(incident, data)
+	want := "High load\n\nRunbooks:\n- https://wiki.example.com/a\n- https://wiki.example.com/b"
+	if incident["description"] != want {
+		t.Errorf("Unexpected description: got %q, want %q", incident["description"], want)
+	}
+
+	data.Receiver = "other"
+	incident = Incident{"description": "High load"}
+	applyRunbookLinks(incident, data)
+	if incident["description"] != "High load" {
+		t.Errorf("Runbooks must not be linked for other receivers: %q", incident["description"])
+	}
+}
+
+func TestLinkKnowledgeArticles(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Knowledge = KnowledgeConfig{Enabled: true}
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetRecords", "m2m_kb_task", map[string]string{"task": "42"}).Return([]Incident{{"kb_knowledge": "kb2"}}, nil)
+	snClientMock.On("GetRecords", "kb_knowledge", map[string]string{"number": "KB0010001"}).Return([]Incident{{"sys_id": "kb1"}}, nil)
+	snClientMock.On("GetRecords", "kb_knowledge", map[string]string{"number": "KB0010002"}).Return([]Incident{{"sys_id": "kb2"}}, nil)
+	snClientMock.On("GetRecords", "kb_knowledge", map[string]string{"number": "KB0010003"}).Return([]Incident{}, nil)
+	snClientMock.On("CreateRecord", "m2m_kb_task", mock.Anything).Return(Incident{}, nil)
+
+	data := template.Data{Alerts: template.Alerts{
+		{Status: "firing", Annotations: template.KV{"runbook_url": "https://sn/kb_view.do?sysparm_article=KB0010001"}},
+		{Status: "firing", Annotations: template.KV{"runbook_url": "https://sn/kb_view.do?sysparm_article=KB0010002"}},
+		{Status: "firing", Annotations: template.KV{"runbook_url": "https://sn/kb_view.do?sysparm_article=KB0010003"}},
+		{Status: "firing", Annotations: template.KV{"runbook_url": "https://wiki.example.com/a"}},
+	}}
+	linkKnowledgeArticles(context.Background(), data, Incident{"sys_id": "42", "number": "INC42"})
+
+	snClientMock.AssertNumberOfCalls(t, "CreateRecord", 1)
+	snClientMock.AssertCalled(t, "CreateRecord", "m2m_kb_task", Incident{"kb_knowledge": "kb1", "task": "42"})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	listIncidentsCommand  = kingpin.Command("list-incidents", "List the ServiceNow incidents created by the webhook, i.e. carrying an alert group key, most recent first.")
+	listIncidentsStates   = listIncidentsCommand.Flag("state", "State of the incidents listed, e.g. --state 1. Repeatable. Default: all states").Strings()
+	listIncidentsMaxAge   = listIncidentsCommand.Flag("max-age", "Maximum age of the incidents listed, e.g. 24h. Default: all ages").Duration()
+	listIncidentsInstance = listIncidentsCommand.Flag("instance", "Name of the ServiceNow instance, from service_now_instances. Default: service_now").String()
+	listIncidentsLimit    = listIncidentsCommand.Flag("limit", "Maximum number of incidents listed.").Default("100").Int()
+	listIncidentsOutput   = listIncidentsCommand.Flag("output", "Output format.").Default("table").Enum("table", "json")
+)
+
+// listIncidentsParams returns the Table API parameters of the incidents carrying an alert group key
+func listIncidentsParams(states []string, maxAge time.Duration, limit int) map[string]string {
+	groupKeyField := config.Workflow.IncidentGroupKeyField
+	conditions := []string{groupKeyField + "ISNOTEMPTY"}
+	if len(states) > 0 {
+		conditions = append(conditions, "stateIN"+strings.Join(states, ","))
+	}
+	if maxAge > 0 {
+		conditions = append(conditions, fmt.Sprintf("sys_created_on>=javascript:gs.minutesAgoStart(%d)", int(maxAge.Minutes())))
+	}
+	if source := config.Workflow.Source; source.enabled() {
+		conditions = append(conditions, source.Field+"="+source.Value)
+	}
+	conditions = append(conditions, "ORDERBYDESCsys_created_on")
+	return map[string]string{
+		"sysparm_query":  strings.Join(conditions, "^"),
+		"sysparm_fields": strings.Join([]string{"sys_id", "number", "state", "sys_created_on", "short_description", groupKeyField}, ","),
+		"sysparm_limit":  fmt.Sprint(limit),
+	}
+}
+
+// listIncidents writes the incidents carrying an alert group key of the ServiceNow instance, as a table or as JSON
+func listIncidents(w io.Writer, client ServiceNow, states []string, maxAge time.Duration, limit int, output string) error {
+	found, err := client.GetIncidents(listIncidentsParams(states, maxAge, limit))
+	if err != nil {
+		return err
+	}
+	if output == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(found)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NUMBER\tSTATE\tCREATED\tSHORT DESCRIPTION\tGROUP KEY")
+	for _, incident := range found {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", incident.GetNumber(), incidentField(incident, "state"), incidentField(incident, "sys_created_on"),
+			incidentField(incident, "short_description"), incidentField(incident, config.Workflow.IncidentGroupKeyField))
+	}
+	return tw.Flush()
+}
+
+func incidentField(incident Incident, field string) string {
+	value, ok := incident[field]
+	if !ok || value == nil {
+		return "-"
+	}
+	return orNone(strings.Replace(fmt.Sprint(value), "\n", " ", -1))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListIncidentsParams(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	field := config.Workflow.IncidentGroupKeyField
+
+	params := listIncidentsParams(nil, 0, 100)
+	if want := field + "ISNOTEMPTY^ORDERBYDESCsys_created_on"; params["sysparm_query"] != want {
+		t.Errorf("Unexpected query: got %s, want %s", params["sysparm_query"], want)
+	}
+	if params["sysparm_limit"] != "100" || !strings.HasSuffix(params["sysparm_fields"], ","+field) {
+		t.Errorf("Unexpected params: %v", params)
+	}
+
+	params = listIncidentsParams([]string{"1", "2"}, 2*time.Hour, 10)
+	want := field + "ISNOTEMPTY^stateIN1,2^sys_created_on>=javascript:gs.minutesAgoStart(120)^ORDERBYDESCsys_created_on"
+	if params["sysparm_query"] != want {
+		t.Errorf("Unexpected query: got %s, want %s", params["sysparm_query"], want)
+	}
+}
+
+func TestListIncidents(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	field := config.Workflow.IncidentGroupKeyField
+	found := []Incident{
+		{"sys_id": "42", "number": "INC42", "state": "1", "sys_created_on": "2020-01-01 00:00:00", "short_description": "Disk\nfull", field: "{}:{alertname=\"DiskFull\"}"},
+		{"sys_id": "43", "number": "INC43", "state": "2"},
+	}
+	snClientMock := new(MockedSnClient)
+	snClientMock.On("GetIncidents", listIncidentsParams([]string{"1", "2"}, 0, 10)).Return(found, nil)
+
+	var table bytes.Buffer
+	if err := listIncidents(&table, snClientMock, []string{"1", "2"}, 0, 10, "table"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "NUMBER") {
+		t.Fatalf("Unexpected table: %s", table.String())
+	}
+	if !strings.Contains(lines[1], "Disk full") || !strings.Contains(lines[1], `{}:{alertname="DiskFull"}`) {
+		t.Errorf("Unexpected incident line: %s", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); len(fields) != 5 || fields[0] != "INC43" || fields[4] != "-" {
+		t.Errorf("Unexpected incident line without fields: %s", lines[2])
+	}
+
+	var output bytes.Buffer
+	if err := listIncidents(&output, snClientMock, []string{"1", "2"}, 0, 10, "json"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var decoded []Incident
+	if err := json.Unmarshal(output.Bytes(), &decoded); err != nil || len(decoded) != 2 || decoded[1].GetNumber() != "INC43" {
+		t.Errorf("Unexpected JSON output: %s %v", output.String(), err)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	loadTestCommand  = kingpin.Command("loadtest", "Send synthetic notifications to a running webhook, to size deployments. Target a webhook using a dry-run or mocked ServiceNow.")
+	loadTestURL      = loadTestCommand.Flag("url", "Webhook URL to send notifications to.").Default("http://localhost:9877/webhook").String()
+	loadTestRate     = loadTestCommand.Flag("rate", "Notifications sent per second.").Default("10").Int()
+	loadTestDuration = loadTestCommand.Flag("duration", "Duration of the load test.").Default("1m").Duration()
+	loadTestGroups   = loadTestCommand.Flag("groups", "Number of distinct alert groups notified.").Default("100").Int()
+)
+
+// loadTestReport - Outcome of a load test
+type loadTestReport struct {
+	Requests  int
+	Errors    int
+	Elapsed   time.Duration
+	Latencies []time.Duration
+}
+
+// runLoadTest sends notifications for synthetic alert groups to the url at the given rate, for the given duration
+func runLoadTest(client *http.Client, url string, rate int, duration time.Duration, groups int) loadTestReport {
+	if rate <= 0 {
+		rate = 1
+	}
+	if groups <= 0 {
+		groups = 1
+	}
+
+	var (
+		report loadTestReport
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+	)
+	ticker := time.NewTicker(time.Second / time.Duration(rate))
+	defer ticker.Stop()
+
+	start := time.Now()
+	deadline := start.Add(duration)
+	for i := 0; time.Now().Before(deadline); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			latency, err := sendLoadTestNotification(client, url, syntheticAlertGroup(i, groups))
+			mu.Lock()
+			defer mu.Unlock()
+			report.Requests++
+			if err != nil {
+				report.Errors++
+				return
+			}
+			report.Latencies = append(report.Latencies, latency)
+		}(i)
+		<-ticker.C
+	}
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+	return report
+}
+
+// syntheticAlertGroup returns the i-th notification, cycling over the given number of alert groups
+func syntheticAlertGroup(i int, groups int) template.Data {
+	status := "firing"
+	if (i/groups)%2 == 1 {
+		status = "resolved"
+	}
+	labels := template.KV{
+		"alertname": "LoadTest",
+		"instance":  fmt.Sprintf("loadtest-%d", i%groups),
+	}
+	return template.Data{
+		Receiver:          "loadtest",
+		Status:            status,
+		Alerts:            template.Alerts{{Status: status, Labels: labels, Annotations: template.KV{"summary": "Synthetic load test alert"}, StartsAt: time.Now()}},
+		GroupLabels:       labels,
+		CommonLabels:      labels,
+		CommonAnnotations: template.KV{"summary": "Synthetic load test alert"},
+	}
+}
+
+func sendLoadTestNotification(client *http.Client, url string, data template.Data) (time.Duration, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	response, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+	latency := time.Since(start)
+
+	if response.StatusCode >= 400 {
+		return latency, fmt.Errorf("webhook returned status %d", response.StatusCode)
+	}
+	return latency, nil
+}
+
+// percentile returns the p-th percentile of the latencies
+func (r loadTestReport) percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	latencies := append([]time.Duration(nil), r.Latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	index := int(p/100*float64(len(latencies)) + 0.5)
+	if index < 1 {
+		index = 1
+	}
+	if index > len(latencies) {
+		index = len(latencies)
+	}
+	return latencies[index-1]
+}
+
+func (r loadTestReport) write(w io.Writer) {
+	throughput := 0.0
+	if r.Elapsed > 0 {
+		throughput = float64(r.Requests-r.Errors) / r.Elapsed.Seconds()
+	}
+	fmt.Fprintf(w, "Requests:   %d (%d errors) in %s\n", r.Requests, r.Errors, r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "Throughput: %.2f req/s\n", throughput)
+	fmt.Fprintf(w, "Latency:    p50=%s p90=%s p99=%s\n", r.percentile(50), r.percentile(90), r.percentile(99))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunLoadTest(t *testing.T) {
+	var mu sync.Mutex
+	groupKeys := map[string]bool{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := readRequestBody(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		groupKeys[getGroupKey(data)] = true
+	}))
+	defer ts.Close()
+
+	report := runLoadTest(ts.Client(), ts.URL, 50, 200*time.Millisecond, 2)
+	if report.Requests == 0 || report.Errors != 0 {
+		t.Fatalf("Unexpected load test report: %+v", report)
+	}
+	if len(groupKeys) != 2 {
+		t.Errorf("Unexpected number of alert groups: got %v, want %v", len(groupKeys), 2)
+	}
+
+	var out bytes.Buffer
+	report.write(&out)
+	if !strings.Contains(out.String(), "p99=") {
+		t.Errorf("Latency percentiles are missing from the report: %s", out.String())
+	}
+}
+
+func TestLoadTestReportPercentile(t *testing.T) {
+	report := loadTestReport{}
+	for i := 1; i <= 100; i++ {
+		report.Latencies = append(report.Latencies, time.Duration(i)*time.Millisecond)
+	}
+	if p := report.percentile(50); p != 50*time.Millisecond {
+		t.Errorf("Unexpected p50: got %v, want %v", p, 50*time.Millisecond)
+	}
+	if p := report.percentile(99); p != 99*time.Millisecond {
+		t.Errorf("Unexpected p99: got %v, want %v", p, 99*time.Millisecond)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	logLevel  = kingpin.Flag("log.level", "Only log messages with the given severity or above.").Default("info").Enum("debug", "info", "warn", "error")
+	logFormat = kingpin.Flag("log.format", "Output format of log messages.").Default("logfmt").Enum("logfmt", "json")
+
+	log = logrus.New()
+)
+
+// configureLogging sets the level and the output format of the log messages
+func configureLogging(level string, format string) error {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	log.SetLevel(parsed)
+	if format == "json" {
+		log.SetFormatter(&logrus.JSONFormatter{})
+	} else {
+		log.SetFormatter(&logrus.TextFormatter{DisableColors: true, FullTimestamp: true})
+	}
+	log.SetOutput(os.Stderr)
+	return nil
+}
+
+// alertGroupLog returns a logger whose messages carry the group key and the status of the alert group, and the ID
+// of the webhook request it is processed for, if any
+func alertGroupLog(ctx context.Context, data template.Data) *logrus.Entry {
+	return requestLog(ctx).WithFields(logrus.Fields{"group_key": getGroupKey(data), "status": data.Status})
+}
+
+// incidentLog returns a logger whose messages carry the group key and the status of the alert group, and the number
+// and the sys_id of its incident
+func incidentLog(ctx context.Context, data template.Data, incident Incident) *logrus.Entry {
+	return alertGroupLog(ctx, data).WithFields(incidentLogFields(incident))
+}
+
+// groupKeyLog returns a logger whose messages carry the group key and the number and the sys_id of the incident, if
+// any, for processing done without the alert group, e.g. scheduled actions
+func groupKeyLog(groupKey string, incident Incident) *logrus.Entry {
+	fields := incidentLogFields(incident)
+	fields["group_key"] = groupKey
+	return log.WithFields(fields)
+}
+
+func incidentLogFields(incident Incident) logrus.Fields {
+	fields := logrus.Fields{}
+	if number := incident.GetNumber(); len(number) > 0 {
+		fields["incident_number"] = number
+	}
+	if sysID := incident.GetSysID(); len(sysID) > 0 {
+		fields["sys_id"] = sysID
+	}
+	return fields
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/sirupsen/logrus"
+)
+
+func TestConfigureLogging(t *testing.T) {
+	defer configureLogging("info", "logfmt")
+	if err := configureLogging("warn", "json"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if log.GetLevel() != logrus.WarnLevel {
+		t.Errorf("Unexpected level: %v", log.GetLevel())
+	}
+	if _, ok := log.Formatter.(*logrus.JSONFormatter); !ok {
+		t.Errorf("Unexpected formatter: %T", log.Formatter)
+	}
+	if err := configureLogging("verbose", "json"); err == nil {
+		t.Errorf("Unknown level must be invalid")
+	}
+}
+
+func TestIncidentLog_Fields(t *testing.T) {
+	defer configureLogging("info", "logfmt")
+	configureLogging("info", "json")
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "Logging"}}
+	incidentLog(context.Background(), data, Incident{"number": "INC42", "sys_id": "42"}).Infof("Incident updated")
+	groupKeyLog("{}:{alertname=\"Logging\"}", nil).Warnf("Resync requested")
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Unexpected log output: %s", output.String())
+	}
+	entry := map[string]string{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Unexpected JSON log line %s: %v", lines[0], err)
+	}
+	want := map[string]string{"group_key": getGroupKey(data), "status": "firing", "incident_number": "INC42", "sys_id": "42", "msg": "Incident updated", "level": "info"}
+	for field, value := range want {
+		if entry[field] != value {
+			t.Errorf("Unexpected %s field: got %q, want %q", field, entry[field], value)
+		}
+	}
+
+	entry = map[string]string{}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("Unexpected JSON log line %s: %v", lines[1], err)
+	}
+	if _, ok := entry["incident_number"]; ok || entry["group_key"] != `{}:{alertname="Logging"}` {
+		t.Errorf("Unexpected fields without incident: %v", entry)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// Operators of the incident group key field lookup
+const (
+	lookupExact      = "="
+	lookupLike       = "LIKE"
+	lookupStartsWith = "STARTSWITH"
+)
+
+// GroupKeyLookupConfig - Lookup of the incident of an alert group, resilient to group label churn
+type GroupKeyLookupConfig struct {
+	// sysparm_query operator: = (default), LIKE or STARTSWITH
+	Operator string `yaml:"operator"`
+	// Stable subset of the group labels. When set, the group key field is prefixed by a key of these labels,
+	// which is looked up instead of the whole group key.
+	Labels []string `yaml:"labels"`
+}
+
+func (c GroupKeyLookupConfig) validate() error {
+	switch c.Operator {
+	case "", lookupExact:
+		if len(c.Labels) > 0 {
+			return fmt.Errorf("group_key_lookup labels require the LIKE or STARTSWITH operator")
+		}
+	case lookupLike, lookupStartsWith:
+	default:
+		return fmt.Errorf("group_key_lookup operator %q is invalid, must be one of: =, LIKE, STARTSWITH", c.Operator)
+	}
+	return nil
+}
+
+// isQueryValue returns true if the value can be matched in an encoded sysparm_query, which cannot escape the ^
+// separator of its conditions, the = operator nor new lines
+func isQueryValue(value string) bool {
+	return !strings.ContainsAny(value, "^=\r\n")
+}
+
+// getStableKey returns the key of the stable subset of the group labels
+func getStableKey(data template.Data, labels []string) string {
+	stableLabels := template.KV{}
+	for _, label := range labels {
+		stableLabels[label] = data.GroupLabels[label]
+	}
+	hash := md5.Sum([]byte(fmt.Sprintf("%v", stableLabels.SortedPairs())))
+	return fmt.Sprintf("%x", hash)
+}
+
+// getGroupKeyFieldValue returns the value of the incident group key field of the alert group
+func getGroupKeyFieldValue(ctx context.Context, data template.Data) string {
+	labels := config.Workflow.GroupKeyLookup.Labels
+	if len(labels) == 0 {
+		return getCorrelationID(ctx, data)
+	}
+	return getStableKey(data, labels) + ":" + getCorrelationID(ctx, data)
+}
+
+// getGroupKeyLookupParams returns the params finding the incidents of the alert group. It must be called
+// while configLock is held, as onAlertGroup does: locking again would deadlock with a pending reload.
+func getGroupKeyLookupParams(ctx context.Context, data template.Data) map[string]string {
+	lookup := config.Workflow.GroupKeyLookup
+	field := config.Workflow.IncidentGroupKeyField
+	if len(lookup.Operator) == 0 || lookup.Operator == lookupExact {
+		return withSource(config.Workflow.Source, map[string]string{field: getCorrelationID(ctx, data)})
+	}
+
+	value := getCorrelationID(ctx, data)
+	if len(lookup.Labels) > 0 {
+		value = getStableKey(data, lookup.Labels) + ":"
+	}
+	return withSource(config.Workflow.Source, map[string]string{"sysparm_query": field + lookup.Operator + value})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestGetGroupKeyLookupParams(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	data := template.Data{GroupLabels: template.KV{"alertname": "HighLoad", "cluster": "a", "instance": "1"}}
+
+	params := getGroupKeyLookupParams(context.Background(), data)
+	if params["u_prometheus_alertgroup_id"] != getGroupKey(data) || len(params) != 1 {
+		t.Errorf("Unexpected exact lookup params: %v", params)
+	}
+	if value := getGroupKeyFieldValue(context.Background(), data); value != getGroupKey(data) {
+		t.Errorf("Unexpected group key field value: %s", value)
+	}
+
+	config.Workflow.GroupKeyLookup = GroupKeyLookupConfig{Operator: "LIKE"}
+	params = getGroupKeyLookupParams(context.Background(), data)
+	if params["sysparm_query"] != "u_prometheus_alertgroup_idLIKE"+getGroupKey(data) {
+		t.Errorf("Unexpected LIKE lookup params: %v", params)
+	}
+}
+
+func TestGetGroupKeyLookupParams_StableLabels(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.GroupKeyLookup = GroupKeyLookupConfig{Operator: "STARTSWITH", Labels: []string{"alertname", "cluster"}}
+
+	data := template.Data{GroupLabels: template.KV{"alertname": "HighLoad", "cluster": "a", "instance": "1"}}
+	churned := template.Data{GroupLabels: template.KV{"alertname": "HighLoad", "cluster": "a"}}
+
+	value := getGroupKeyFieldValue(context.Background(), data)
+	if !strings.HasSuffix(value, ":"+getGroupKey(data)) {
+		t.Errorf("Group key field must end with the group key: %s", value)
+	}
+	params := getGroupKeyLookupParams(context.Background(), churned)
+	prefix := strings.TrimPrefix(params["sysparm_query"], "u_prometheus_alertgroup_idSTARTSWITH")
+	if !strings.HasPrefix(value, prefix) || !strings.HasSuffix(prefix, ":") {
+		t.Errorf("Lookup of the churned group %v must match the group key field %s", params, value)
+	}
+
+	other := template.Data{GroupLabels: template.KV{"alertname": "HighLoad", "cluster": "b"}}
+	if getGroupKeyLookupParams(context.Background(), other)["sysparm_query"] == params["sysparm_query"] {
+		t.Error("Lookup of a group with other stable labels must differ")
+	}
+}
+
+func TestGroupKeyLookupConfig_Validate(t *testing.T) {
+	tests := []struct {
+		config GroupKeyLookupConfig
+		valid  bool
+	}{
+		{GroupKeyLookupConfig{}, true},
+		{GroupKeyLookupConfig{Operator: "STARTSWITH", Labels: []string{"alertname"}}, true},
+		{GroupKeyLookupConfig{Operator: "CONTAINS"}, false},
+		{GroupKeyLookupConfig{Labels: []string{"alertname"}}, false},
+	}
+	for _, test := range tests {
+		if err := test.config.validate(); (err == nil) != test.valid {
+			t.Errorf("Unexpected validation of %+v: %v", test.config, err)
+		}
+	}
+}
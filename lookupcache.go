@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultLookupCacheMaxAge = time.Hour
+
+// LookupCacheConfig - Persistence of the resolved reference lookups (assignment group validations, directory of groups
+// and users), so that a restart during an incident storm doesn't issue them all again
+type LookupCacheConfig struct {
+	// File where the lookups are persisted, they are kept in memory only when not set
+	File string `yaml:"file"`
+	// Maximum age of the persisted directory used at startup, until its first refresh, 1h by default
+	DirectoryMaxAge time.Duration `yaml:"directory_max_age"`
+}
+
+func (c LookupCacheConfig) directoryMaxAge() time.Duration {
+	if c.DirectoryMaxAge > 0 {
+		return c.DirectoryMaxAge
+	}
+	return defaultLookupCacheMaxAge
+}
+
+// persistedLookups is the content of the lookup cache file
+type persistedLookups struct {
+	AssignmentGroups map[string]persistedGroupValidation `json:"assignment_groups,omitempty"`
+	Directory        *persistedDirectory                 `json:"directory,omitempty"`
+}
+
+type persistedGroupValidation struct {
+	Valid   bool      `json:"valid"`
+	Expires time.Time `json:"expires"`
+}
+
+type persistedDirectory struct {
+	RefreshedAt time.Time         `json:"refreshed_at"`
+	Groups      map[string]string `json:"groups"`
+	Users       map[string]string `json:"users"`
+}
+
+// lookupCacheFile persists the lookup caches
+type lookupCacheFile struct {
+	mu   sync.Mutex
+	path string
+}
+
+var lookupCache = &lookupCacheFile{}
+
+// configure restores the lookups persisted in the file, if set, expired ones being dropped
+func (f *lookupCacheFile) configure(c LookupCacheConfig, restoreDirectory bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.path = c.File
+	if len(c.File) == 0 {
+		return
+	}
+	content, err := ioutil.ReadFile(c.File)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("Error reading lookup cache file: %v", err)
+		}
+		return
+	}
+	lookups := persistedLookups{}
+	if err := json.Unmarshal(content, &lookups); err != nil {
+		log.Errorf("Error parsing lookup cache file: %v", err)
+		return
+	}
+
+	restored := assignmentGroups.restore(lookups.AssignmentGroups)
+	if restoreDirectory && lookups.Directory != nil && now().Sub(lookups.Directory.RefreshedAt) < c.directoryMaxAge() {
+		directory.restore(*lookups.Directory)
+		restored += len(lookups.Directory.Groups) + len(lookups.Directory.Users)
+	}
+	log.Infof("%d lookup(s) restored from %s", restored, c.File)
+}
+
+// persist writes the lookup caches in the file, if any
+func (f *lookupCacheFile) persist() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.path) == 0 {
+		return
+	}
+	content, err := json.Marshal(persistedLookups{
+		AssignmentGroups: assignmentGroups.snapshot(),
+		Directory:        directory.snapshot(),
+	})
+	if err == nil {
+		err = ioutil.WriteFile(f.path+".tmp", content, 0600)
+	}
+	if err == nil {
+		err = os.Rename(f.path+".tmp", f.path)
+	}
+	if err != nil {
+		log.Errorf("Error writing lookup cache file: %v", err)
+	}
+}
+
+// snapshot returns the unexpired group validations
+func (c *groupValidationCache) snapshot() map[string]persistedGroupValidation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make(map[string]persistedGroupValidation, len(c.entries))
+	for key, entry := range c.entries {
+		if now().Before(entry.expires) {
+			entries[key] = persistedGroupValidation{Valid: entry.valid, Expires: entry.expires}
+		}
+	}
+	return entries
+}
+
+// restore adds the unexpired group validations, returning their number
+func (c *groupValidationCache) restore(entries map[string]persistedGroupValidation) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	restored := 0
+	for key, entry := range entries {
+		if now().Before(entry.Expires) {
+			c.entries[key] = groupValidation{valid: entry.Valid, expires: entry.Expires}
+			restored++
+		}
+	}
+	return restored
+}
+
+// snapshot returns the directory, nil if it was never loaded
+func (d *serviceNowDirectory) snapshot() *persistedDirectory {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if !d.loaded {
+		return nil
+	}
+	return &persistedDirectory{RefreshedAt: d.refreshedAt, Groups: d.groups, Users: d.users}
+}
+
+// restore loads a persisted directory, used until the next refresh
+func (d *serviceNowDirectory) restore(persisted persistedDirectory) {
+	groupIDs := make(map[string]bool, len(persisted.Groups))
+	for _, sysID := range persisted.Groups {
+		groupIDs[sysID] = true
+	}
+	userIDs := make(map[string]bool, len(persisted.Users))
+	for _, sysID := range persisted.Users {
+		userIDs[sysID] = true
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.loaded = true
+	d.refreshedAt = persisted.RefreshedAt
+	d.groups, d.groupIDs = persisted.Groups, groupIDs
+	d.users, d.userIDs = persisted.Users, userIDs
+}
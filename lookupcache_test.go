@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLookupCachePersistence(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	current := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+	dir, err := ioutil.TempDir("", "lookups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() {
+		assignmentGroups = &groupValidationCache{entries: make(map[string]groupValidation)}
+		directory = &serviceNowDirectory{}
+		lookupCache = &lookupCacheFile{}
+	}()
+
+	c := LookupCacheConfig{File: filepath.Join(dir, "lookups.json")}
+	assignmentGroups = &groupValidationCache{entries: make(map[string]groupValidation)}
+	directory = &serviceNowDirectory{}
+	lookupCache = &lookupCacheFile{}
+	lookupCache.configure(c, true)
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetRecords", "sys_user_group", mock.MatchedBy(func(params map[string]string) bool {
+		return params["sysparm_query"] == "active=true"
+	})).Return([]Incident{{"sys_id": "g1", "name": "DBA"}}, nil)
+	snClientMock.On("GetRecords", "sys_user", mock.Anything).Return([]Incident{{"sys_id": "u1", "user_name": "jdoe"}}, nil)
+	snClientMock.On("GetRecords", "sys_user_group", mock.Anything).Return([]Incident{{"sys_id": "g2", "active": "true"}}, nil)
+
+	if valid, err := assignmentGroups.isValid("other", "Network"); err != nil || !valid {
+		t.Fatalf("Unexpected validation: %v, %v", valid, err)
+	}
+	if err := directory.refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart restores the lookups without any request
+	assignmentGroups = &groupValidationCache{entries: make(map[string]groupValidation)}
+	directory = &serviceNowDirectory{}
+	lookupCache = &lookupCacheFile{}
+	snClientMock = new(MockedSnClient)
+	serviceNow = snClientMock
+	current = current.Add(time.Minute)
+	lookupCache.configure(c, true)
+
+	if valid, err := assignmentGroups.isValid("other", "Network"); err != nil || !valid {
+		t.Errorf("Unexpected restored validation: %v, %v", valid, err)
+	}
+	if sysID, found, loaded := directory.lookupUser("jdoe"); !loaded || !found || sysID != "u1" {
+		t.Errorf("Unexpected restored user lookup: %s, %v, %v", sysID, found, loaded)
+	}
+	if _, found, _ := directory.lookupGroup("g1"); !found {
+		t.Errorf("Restored groups must be found by sys_id")
+	}
+	snClientMock.AssertNotCalled(t, "GetRecords", mock.Anything, mock.Anything)
+
+	// Expired lookups are dropped
+	assignmentGroups = &groupValidationCache{entries: make(map[string]groupValidation)}
+	directory = &serviceNowDirectory{}
+	current = current.Add(2 * time.Hour)
+	lookupCache.configure(c, true)
+	if len(assignmentGroups.snapshot()) != 0 {
+		t.Errorf("Expired validations must not be restored")
+	}
+	if _, _, loaded := directory.lookupUser("jdoe"); loaded {
+		t.Errorf("A directory older than directory_max_age must not be restored")
+	}
+}
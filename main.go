@@ -2,14 +2,18 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/prometheus/alertmanager/template"
 	"github.com/prometheus/client_golang/prometheus"
@@ -20,19 +24,31 @@ import (
 	"gopkg.in/alecthomas/kingpin.v2"
 	"gopkg.in/yaml.v2"
 
-	"github.com/prometheus/common/log"
-
 	"crypto/md5"
 	tmpltext "text/template"
 )
 
+const (
+	defaultPasswordFileReload = time.Minute
+	defaultSeverityLabel      = "severity"
+)
+
 var (
+	serveCommand         = kingpin.Command("serve", "Run the webhook (default).").Default()
 	configFile           = kingpin.Flag("config.file", "ServiceNow configuration file.").Default("config/servicenow.yml").String()
 	listenAddress        = kingpin.Flag("web.listen-address", "The address to listen on for HTTP requests.").Default(":9877").String()
 	config               Config
 	serviceNow           ServiceNow
+	serviceNowInstances  map[string]ServiceNow
 	noUpdateStates       map[json.Number]bool
 	incidentUpdateFields map[string]bool
+	redactionRules       []redactionRule
+	urlRewriteRules      []urlRewriteRule
+	fieldTransforms      map[string][]fieldTransform
+	fieldRules           map[string][]fieldRule
+	templateFiles        *tmpltext.Template
+	passwordWatcherDone  chan struct{}
+	now                  = time.Now
 
 	webhookRequests = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -49,6 +65,85 @@ var (
 		},
 	)
 
+	webhookUnauthorizedRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_unauthorized_requests_total",
+			Help: "Total number of notifications rejected as unauthorized, by endpoint.",
+		},
+		[]string{"endpoint"},
+	)
+
+	webhookPayloadFormats = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_payload_formats_total",
+			Help: "Total number of payloads received on /webhook, by detected format.",
+		},
+		[]string{"format"},
+	)
+
+	webhookPartiallyDecodedPayloads = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "webhook_partially_decoded_payloads_total",
+			Help: "Total number of payloads processed although some of their alerts could not be fully decoded.",
+		},
+	)
+
+	webhookRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "webhook_request_duration_seconds",
+			Help:    "Duration of the handling of the notifications, by endpoint.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"endpoint"},
+	)
+
+	webhookDeferredUpdates = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "webhook_deferred_updates_total",
+			Help: "Total number of journal-only incident updates deferred to the end of the quiet hours of the ServiceNow instance.",
+		},
+	)
+
+	webhookStreamedPayloads = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "webhook_streamed_payloads_total",
+			Help: "Total number of payloads decoded while they are read, as they exceed the streaming threshold or their size is unknown.",
+		},
+	)
+
+	webhookPayloadBytes = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "webhook_payload_bytes",
+			Help:    "Size of the payloads received, in bytes.",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 8),
+		},
+	)
+
+	webhookPayloadAlerts = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "webhook_payload_alerts",
+			Help:    "Number of alerts per alert group received, by receiver.",
+			Buckets: []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000},
+		},
+		[]string{"receiver"},
+	)
+
+	webhookAlertLabels = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "webhook_alert_labels",
+			Help:    "Number of labels per alert received.",
+			Buckets: []float64{1, 2, 5, 10, 15, 20, 30, 50},
+		},
+	)
+
+	webhookReceivedAlerts = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_received_alerts_total",
+			Help: "Total number of alerts received, by receiver and status (firing, resolved).",
+		},
+		[]string{"receiver", "status"},
+	)
+
 	webhookIncidentValidationError = promauto.NewCounter(
 		prometheus.CounterOpts{
 			Name: "webhook_incident_validation_errors_total",
@@ -63,6 +158,353 @@ var (
 		},
 	)
 
+	webhookAnnotationFieldsRejected = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_annotation_fields_rejected_total",
+			Help: "Total number of incident fields of annotation_fields ignored, by reason.",
+		},
+		[]string{"reason"},
+	)
+
+	webhookConfigReloadSuccess = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "webhook_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt was successful (1) or not (0).",
+		},
+	)
+
+	webhookConfigReloadTime = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "webhook_config_last_reload_success_timestamp_seconds",
+			Help: "Unix/epoch time of the last successful configuration load.",
+		},
+	)
+
+	webhookHookInvocations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_hook_invocations_total",
+			Help: "Total number of extension hook invocations, by hook and result (allow, veto, error).",
+		},
+		[]string{"hook", "result"},
+	)
+
+	webhookShedNotifications = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_shed_notifications_total",
+			Help: "Total number of notifications shed under overload, by kind (update, rejected).",
+		},
+		[]string{"kind"},
+	)
+
+	webhookOwnedIncidentUpdates = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "webhook_owned_incident_updates_total",
+			Help: "Total number of updates of incidents assigned to a user, reduced to a journal entry.",
+		},
+	)
+
+	webhookQueuedNotifications = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "webhook_queued_notifications",
+			Help: "Number of notifications in the persistent queue, waiting to be sent to ServiceNow.",
+		},
+	)
+
+	webhookQueueOldestTimestamp = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "webhook_queue_oldest_timestamp_seconds",
+			Help: "Timestamp of the oldest notification in the persistent queue, 0 when empty.",
+		},
+	)
+
+	webhookQueueDrained = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_queue_drained_total",
+			Help: "Total number of attempts to send queued notifications to ServiceNow, by result (success, retried, dead_lettered).",
+		},
+		[]string{"result"},
+	)
+
+	webhookDirectoryEntries = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "webhook_directory_entries",
+			Help: "Number of active ServiceNow records in the directory, by type (group, user).",
+		},
+		[]string{"type"},
+	)
+
+	webhookDirectoryLastRefresh = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "webhook_directory_last_refresh_timestamp_seconds",
+			Help: "Unix/epoch time of the last successful refresh of the ServiceNow directory.",
+		},
+	)
+
+	webhookDeadlineExceeded = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "webhook_deadline_exceeded_total",
+			Help: "Total number of notifications not processed within the request deadline, completed in background.",
+		},
+	)
+
+	webhookPausedRoutes = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "webhook_paused_routes",
+			Help: "Number of paused routes.",
+		},
+	)
+
+	webhookSpooledNotifications = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "webhook_spooled_notifications",
+			Help: "Number of notifications spooled for paused routes.",
+		},
+	)
+
+	webhookEmptyAlertGroups = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_empty_alert_groups_total",
+			Help: "Total number of notifications without alerts matching their status, by action.",
+		},
+		[]string{"action"},
+	)
+
+	webhookIncidentTaskErrors = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "webhook_incident_task_errors_total",
+			Help: "Total number of errors creating incident tasks.",
+		},
+	)
+
+	webhookJournalDuplicates = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "webhook_journal_duplicates_total",
+			Help: "Total number of duplicate journal entries skipped.",
+		},
+	)
+
+	webhookTemplateVariantActions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_template_variant_actions_total",
+			Help: "Total number of incident actions, by template variant, action and result.",
+		},
+		[]string{"variant", "action", "result"},
+	)
+
+	webhookIncidentRedactions = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "webhook_incident_redactions_total",
+			Help: "Total number of redactions applied to incident fields.",
+		},
+	)
+
+	webhookIncidentActions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_incident_actions_total",
+			Help: "Total number of incident actions sent to ServiceNow, by receiver and assignment group.",
+		},
+		[]string{"action", "result", "receiver", "assignment_group"},
+	)
+
+	webhookIncidentsResolved = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_incidents_resolved_total",
+			Help: "Total number of incidents resolved in ServiceNow, by mode (immediate, scheduled).",
+		},
+		[]string{"mode"},
+	)
+
+	webhookEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_events_total",
+			Help: "Total number of Event Management events sent to ServiceNow, by severity and result.",
+		},
+		[]string{"severity", "result"},
+	)
+
+	webhookPerAlertUpdatesSkipped = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "webhook_per_alert_updates_skipped_total",
+			Help: "Total number of updates skipped for repeated notifications of firing alerts processed per alert.",
+		},
+	)
+
+	webhookUnknownResolved = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_unknown_resolved_total",
+			Help: "Total number of resolved notifications for alert groups without any incident, by action.",
+		},
+		[]string{"action"},
+	)
+
+	webhookLastIncidentCreated = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "webhook_last_incident_created_timestamp_seconds",
+			Help: "Unix/epoch time of the last incident created in ServiceNow, by alert severity.",
+		},
+		[]string{"severity"},
+	)
+
+	webhookAlertTimesNormalized = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_alert_timestamps_normalized_total",
+			Help: "Total number of alert timestamps normalized before rendering, by field.",
+		},
+		[]string{"field"},
+	)
+
+	webhookIncidentCacheRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_incident_cache_requests_total",
+			Help: "Total number of incident cache lookups, by result (hit, miss).",
+		},
+		[]string{"result"},
+	)
+
+	webhookIncidentCacheEvictions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_incident_cache_evictions_total",
+			Help: "Total number of incident cache entries removed, by reason (expired, size, invalidated).",
+		},
+		[]string{"reason"},
+	)
+
+	webhookIncidentCacheEntries = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "webhook_incident_cache_entries",
+			Help: "Number of group keys in the incident cache.",
+		},
+	)
+
+	webhookInflightNotifications = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "webhook_inflight_notifications",
+			Help: "Number of notifications being processed.",
+		},
+	)
+
+	webhookCoalescingWindow = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "webhook_coalescing_window_seconds",
+			Help: "Current window during which notifications wait to be superseded by a newer one of their group.",
+		},
+	)
+
+	webhookNotificationsCoalesced = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "webhook_notifications_coalesced_total",
+			Help: "Total number of notifications skipped as superseded by a newer one of their group.",
+		},
+	)
+
+	webhookShardForwards = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_shard_forwards_total",
+			Help: "Total number of notifications forwarded to the replica owning their group key, by result.",
+		},
+		[]string{"result"},
+	)
+
+	webhookMigrationDivergences = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_migration_divergences_total",
+			Help: "Total number of divergences between the current and the migration target during dual-write, by kind.",
+		},
+		[]string{"kind"},
+	)
+
+	webhookArchiveError = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "webhook_archive_errors_total",
+			Help: "Total number of payload and ServiceNow exchange archiving errors.",
+		},
+	)
+
+	webhookArchiveDropped = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "webhook_archive_dropped_total",
+			Help: "Total number of payloads and ServiceNow exchanges not archived as the archive queue was full.",
+		},
+	)
+
+	webhookShadowEvaluations = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "webhook_shadow_evaluations_total",
+			Help: "Total number of incidents evaluated with the shadow mapping.",
+		},
+	)
+
+	webhookShadowDifferences = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_shadow_differences_total",
+			Help: "Total number of incident fields differing between the active and the shadow mapping.",
+		},
+		[]string{"field"},
+	)
+
+	webhookScheduledActions = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "webhook_scheduled_actions",
+			Help: "Number of pending scheduled incident actions.",
+		},
+		[]string{"action"},
+	)
+
+	webhookScheduledActionNextFire = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "webhook_scheduled_action_next_fire_time_seconds",
+			Help: "Unix/epoch time of the next pending scheduled incident action.",
+		},
+		[]string{"action"},
+	)
+
+	webhookAlertURLsRewritten = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_alert_urls_rewritten_total",
+			Help: "Total number of alert URLs rewritten before rendering, by field.",
+		},
+		[]string{"field"},
+	)
+
+	webhookAlertURLsDropped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_alert_urls_dropped_total",
+			Help: "Total number of invalid alert URLs dropped before rendering, by field.",
+		},
+		[]string{"field"},
+	)
+
+	webhookCreateVerifications = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_create_verifications_total",
+			Help: "Total number of created incidents verified by a follow-up read, by result (ok, missing, mismatch, error).",
+		},
+		[]string{"result"},
+	)
+
+	webhookResolveConfirmations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_resolve_confirmations_total",
+			Help: "Total number of incident resolutions checked by follow-up reads, by result (confirmed, unconfirmed).",
+		},
+		[]string{"result"},
+	)
+
+	webhookStageErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_incident_stage_errors_total",
+			Help: "Total number of ServiceNow failures managing the incident of an alert group, by stage (lookup, create, update) and error class.",
+		},
+		[]string{"stage", "class"},
+	)
+
+	webhookDeadLetters = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "webhook_dead_letters_total",
+			Help: "Total number of payloads dead-lettered after a non retryable ServiceNow error.",
+		},
+	)
+
 	serviceNowRequests = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "servicenow_requests_total",
@@ -78,6 +520,88 @@ var (
 		},
 	)
 
+	serviceNowRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "servicenow_request_duration_seconds",
+			Help:    "Duration of the HTTP requests to ServiceNow instance.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"host", "method"},
+	)
+
+	serviceNowUp = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "servicenow_up",
+			Help: "Whether ServiceNow was reachable and accepted the credentials (1) or not (0) on the last health probe.",
+		},
+	)
+
+	serviceNowProbeDuration = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "servicenow_probe_duration_seconds",
+			Help: "Duration of the last ServiceNow health probe.",
+		},
+	)
+
+	serviceNowRetries = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "servicenow_request_retries_total",
+			Help: "Total number of ServiceNow requests retried after a transient error.",
+		},
+	)
+
+	serviceNowCapability = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "servicenow_capability",
+			Help: "Whether an optional ServiceNow API is available (1) or not (0), as probed at startup.",
+		},
+		[]string{"capability"},
+	)
+
+	serviceNowRequestErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servicenow_request_errors_total",
+			Help: "Total number of failed HTTP requests to ServiceNow instance, by error class and category.",
+		},
+		[]string{"class", "category"},
+	)
+
+	serviceNowAuthFailures = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servicenow_authentication_failures_total",
+			Help: "Total number of requests to ServiceNow rejected for authentication (401) or authorization (403), once the credentials were reloaded.",
+		},
+		[]string{"code"},
+	)
+
+	serviceNowRateLimitLimit = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "servicenow_ratelimit_limit",
+			Help: "Rate limit quota of the ServiceNow user, as returned in the last response headers.",
+		},
+	)
+
+	serviceNowRateLimitRemaining = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "servicenow_ratelimit_remaining",
+			Help: "Remaining rate limit quota of the ServiceNow user, as returned in the last response headers.",
+		},
+	)
+
+	serviceNowRateLimitDelays = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "servicenow_ratelimit_delays_total",
+			Help: "Total number of requests to ServiceNow delayed as the rate limit quota was nearly exhausted.",
+		},
+	)
+
+	serviceNowDisplayValueUpdateFailures = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "servicenow_display_value_update_failures_total",
+			Help: "Total number of created incidents whose display value fields could not be set by the follow-up update.",
+		},
+	)
+
 	serviceNowError = promauto.NewCounter(
 		prometheus.CounterOpts{
 			Name: "servicenow_errors_total",
@@ -88,29 +612,127 @@ var (
 
 // Config - ServiceNow webhook configuration
 type Config struct {
-	ServiceNow      ServiceNowConfig  `yaml:"service_now"`
-	Workflow        WorkflowConfig    `yaml:"workflow"`
-	DefaultIncident map[string]string `yaml:"default_incident"`
+	ServiceNow ServiceNowConfig `yaml:"service_now"`
+	// Additional instances, the alert groups matching none of them are sent to service_now
+	ServiceNowInstances []ServiceNowInstanceConfig    `yaml:"service_now_instances"`
+	Workflow            WorkflowConfig                `yaml:"workflow"`
+	WebhookAuth         WebhookAuthConfig             `yaml:"webhook_auth"`
+	DefaultIncident     map[string]string             `yaml:"default_incident"`
+	TemplateVariants    TemplateVariantsConfig        `yaml:"template_variants"`
+	TemplateSets        TemplateSetsConfig            `yaml:"template_sets"`
+	Profiles            ProfilesConfig                `yaml:"profiles"`
+	TemplateFiles       []string                      `yaml:"template_files"`
+	StatusWords         StatusWordsConfig             `yaml:"status_words"`
+	Redactions          []RedactionConfig             `yaml:"redactions"`
+	URLRewriting        URLRewritingConfig            `yaml:"url_rewriting"`
+	Decoding            DecodingConfig                `yaml:"decoding"`
+	FieldTransforms     map[string][]TransformConfig  `yaml:"field_transforms"`
+	FieldFormats        map[string]string             `yaml:"field_formats"`
+	FieldRules          map[string][]FieldRuleConfig  `yaml:"field_rules"`
+	FieldMappings       map[string]FieldMappingConfig `yaml:"field_mappings"`
+	AnnotationFields    AnnotationFieldsConfig        `yaml:"annotation_fields"`
+	SeverityMapping     SeverityMappingConfig         `yaml:"severity_mapping"`
+	Metrics             MetricsConfig                 `yaml:"metrics"`
+	Archiver            ArchiverConfig                `yaml:"archiver"`
+	Shadow              ShadowConfig                  `yaml:"shadow"`
+	CloudEvents         CloudEventsConfig             `yaml:"cloudevents"`
+	History             HistoryConfig                 `yaml:"history"`
+	Journal             JournalConfig                 `yaml:"journal"`
+	IncidentCache       IncidentCacheConfig           `yaml:"incident_cache"`
+	Ack                 AckConfig                     `yaml:"ack"`
+	Pause               PauseConfig                   `yaml:"pause"`
+	Queue               QueueConfig                   `yaml:"queue"`
+	Reload              ReloadConfig                  `yaml:"reload"`
+	Resync              ResyncConfig                  `yaml:"resync"`
+	IncidentTasks       IncidentTasksConfig           `yaml:"incident_tasks"`
+	Knowledge           KnowledgeConfig               `yaml:"knowledge"`
+	AlertList           AlertListConfig               `yaml:"alert_list"`
+	Directory           DirectoryConfig               `yaml:"directory"`
+	LookupCache         LookupCacheConfig             `yaml:"lookup_cache"`
+	HealthProbe         HealthProbeConfig             `yaml:"health_probe"`
+	Readiness           ReadinessConfig               `yaml:"readiness"`
+	Timeline            TimelineConfig                `yaml:"timeline"`
+	Coalescing          CoalescingConfig              `yaml:"coalescing"`
+	Overload            OverloadConfig                `yaml:"overload"`
+	Sharding            ShardingConfig                `yaml:"sharding"`
+	Inhibitions         []InhibitionConfig            `yaml:"inhibitions"`
+	Hooks               []HookConfig                  `yaml:"hooks"`
+	Migration           MigrationConfig               `yaml:"migration"`
+	EventManagement     EventManagementConfig         `yaml:"event_management"`
 }
 
 // ServiceNowConfig - ServiceNow instance configuration
 type ServiceNowConfig struct {
-	InstanceName string `yaml:"instance_name"`
-	UserName     string `yaml:"user_name"`
-	Password     string `yaml:"password"`
+	InstanceName            string                        `yaml:"instance_name"`
+	UserName                string                        `yaml:"user_name"`
+	UserNameFile            string                        `yaml:"user_name_file"`
+	Password                string                        `yaml:"password"`
+	PasswordFile            string                        `yaml:"password_file"`
+	PasswordFileReload      time.Duration                 `yaml:"password_file_reload_interval"`
+	SkipCapabilityProbe     bool                          `yaml:"skip_capability_probe"`
+	InputDisplayValue       bool                          `yaml:"input_display_value"`
+	DisplayValueFields      []string                      `yaml:"display_value_fields"`
+	RateLimit               RateLimitConfig               `yaml:"rate_limit"`
+	OAuth2                  OAuth2Config                  `yaml:"oauth2"`
+	Retry                   RetryConfig                   `yaml:"retry"`
+	TableAPIParams          TableAPIParamsConfig          `yaml:"table_api_params"`
+	AuthFailureNotification AuthFailureNotificationConfig `yaml:"auth_failure_notification"`
+	Table                   string                        `yaml:"table"`
+	FieldMap                map[string]string             `yaml:"field_map"`
+	ProxyURL                string                        `yaml:"proxy_url"`
+	ProxyAuth               ProxyAuthConfig               `yaml:"proxy_auth"`
+	TLSConfig               TLSConfig                     `yaml:"tls_config"`
+	QuietHours              QuietHoursConfig              `yaml:"quiet_hours"`
 }
 
 // WorkflowConfig - Incident workflow configuration
 type WorkflowConfig struct {
-	IncidentGroupKeyField string        `yaml:"incident_group_key_field"`
-	NoUpdateStates        []json.Number `yaml:"no_update_states"`
-	IncidentUpdateFields  []string      `yaml:"incident_update_fields"`
+	IncidentGroupKeyField       string                        `yaml:"incident_group_key_field"`
+	GroupLabelsField            string                        `yaml:"group_labels_field"`
+	CorrelationID               CorrelationIDConfig           `yaml:"correlation_id"`
+	GroupKeyLookup              GroupKeyLookupConfig          `yaml:"group_key_lookup"`
+	NoUpdateStates              []json.Number                 `yaml:"no_update_states"`
+	IncidentUpdateFields        []string                      `yaml:"incident_update_fields"`
+	ReopenWindow                time.Duration                 `yaml:"reopen_window"`
+	ReopenState                 json.Number                   `yaml:"reopen_state"`
+	ShortDescriptionAnnotations []string                      `yaml:"short_description_annotations"`
+	ProgressTTL                 time.Duration                 `yaml:"progress_ttl"`
+	RequestDeadline             time.Duration                 `yaml:"request_deadline"`
+	ClockSkewTolerance          time.Duration                 `yaml:"clock_skew_tolerance"`
+	AssignmentGroupOverride     AssignmentGroupOverrideConfig `yaml:"assignment_group_override"`
+	ProgressFile                string                        `yaml:"progress_file"`
+	ScheduleFile                string                        `yaml:"schedule_file"`
+	OnHold                      OnHoldConfig                  `yaml:"on_hold"`
+	AutoResolve                 AutoResolveConfig             `yaml:"auto_resolve"`
+	EmptyAlertGroup             EmptyAlertGroupConfig         `yaml:"empty_alert_group"`
+	Ownership                   OwnershipConfig               `yaml:"ownership"`
+	UnknownResolved             UnknownResolvedConfig         `yaml:"unknown_resolved"`
+	AlertDetails                AlertDetailsConfig            `yaml:"alert_details"`
+	Ungrouped                   UngroupedConfig               `yaml:"ungrouped"`
+	CreateVerification          CreateVerificationConfig      `yaml:"create_verification"`
+	Source                      SourceConfig                  `yaml:"source"`
+}
+
+// OnHoldConfig - Incident on hold configuration while alerts are silenced
+type OnHoldConfig struct {
+	SilencedLabel string      `yaml:"silenced_label"`
+	State         json.Number `yaml:"state"`
+	HoldReason    string      `yaml:"hold_reason"`
+	ResumeState   json.Number `yaml:"resume_state"`
+}
+
+// MetricsConfig - Metrics labels configuration
+type MetricsConfig struct {
+	ReceiverAllowlist        []string `yaml:"receiver_allowlist"`
+	AssignmentGroupAllowlist []string `yaml:"assignment_group_allowlist"`
+	SeverityLabel            string   `yaml:"severity_label"`
 }
 
 // JSONResponse is the Webhook http response
 type JSONResponse struct {
-	Status  int
-	Message string
+	Status    int
+	Message   string
+	RequestID string `json:",omitempty"`
 }
 
 func init() {
@@ -123,14 +745,161 @@ func (c Config) validate() error {
 	if len(c.ServiceNow.InstanceName) == 0 {
 		errs.WriteString("instance_name is missing\n")
 	}
-	if len(c.ServiceNow.UserName) == 0 {
-		errs.WriteString("user_name is missing\n")
+	if c.ServiceNow.OAuth2.enabled() {
+		if err := c.ServiceNow.OAuth2.validate(); err != nil {
+			errs.WriteString(err.Error() + "\n")
+		}
+	} else {
+		if len(c.ServiceNow.UserName) == 0 {
+			errs.WriteString("user_name is missing\n")
+		}
+		if len(c.ServiceNow.Password) == 0 && len(c.ServiceNow.PasswordFile) == 0 {
+			errs.WriteString("password is missing\n")
+		}
+	}
+	if err := c.ServiceNow.TableAPIParams.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := validateFieldMap(c.ServiceNow.FieldMap); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := validateProxy(c.ServiceNow.ProxyURL, c.ServiceNow.ProxyAuth); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.ServiceNow.TLSConfig.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.ServiceNow.QuietHours.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if len(c.Workflow.IncidentGroupKeyField) == 0 {
+		errs.WriteString("incident_group_key_field is missing\n")
+	}
+	if c.Workflow.ReopenWindow < 0 {
+		errs.WriteString("reopen_window must not be negative\n")
+	}
+	if c.Workflow.RequestDeadline < 0 {
+		errs.WriteString("request_deadline must not be negative\n")
+	}
+	if len(c.Workflow.OnHold.SilencedLabel) > 0 && len(c.Workflow.OnHold.State) == 0 {
+		errs.WriteString("on_hold state is missing\n")
+	}
+	if len(c.Workflow.AutoResolve.State) == 0 && (c.Workflow.AutoResolve.Delay != 0 || len(c.Workflow.AutoResolve.Fields) > 0) {
+		errs.WriteString("auto_resolve state is missing\n")
+	}
+	if c.Workflow.AutoResolve.Delay < 0 {
+		errs.WriteString("auto_resolve delay must not be negative\n")
+	}
+	if err := c.Workflow.AutoResolve.Confirmation.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	for _, r := range c.Redactions {
+		if _, err := regexp.Compile(r.Regex); err != nil {
+			errs.WriteString(fmt.Sprintf("redaction regex %q is invalid: %v\n", r.Regex, err))
+		}
+	}
+	if _, err := compileURLRewriteRules(c.URLRewriting.Rules); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if _, err := compileFieldTransforms(c.FieldTransforms); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if _, err := compileFieldRules(c.FieldRules); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := validateFieldMappings(c.FieldMappings); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.AnnotationFields.validate(c.Workflow); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := validateFieldFormats(c.FieldFormats); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.EventManagement.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.Archiver.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.Journal.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.IncidentCache.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.Coalescing.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.Sharding.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.Workflow.CorrelationID.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.Workflow.GroupKeyLookup.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.Workflow.Source.validate(c.Workflow.IncidentGroupKeyField); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.Workflow.EmptyAlertGroup.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.Workflow.Ownership.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.Workflow.UnknownResolved.validate(c.Workflow.AutoResolve); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.Workflow.AlertDetails.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.Workflow.Ungrouped.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := validateServiceNowInstances(c.ServiceNowInstances); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.Profiles.validate(c.ServiceNowInstances); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.IncidentTasks.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	for _, hook := range c.Hooks {
+		if err := hook.validate(); err != nil {
+			errs.WriteString(err.Error() + "\n")
+		}
+	}
+	if err := c.SeverityMapping.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.TemplateSets.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
 	}
-	if len(c.ServiceNow.Password) == 0 {
-		errs.WriteString("password is missing\n")
+	if err := c.TemplateVariants.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
 	}
-	if len(c.Workflow.IncidentGroupKeyField) == 0 {
-		errs.WriteString("incident_group_key_field is missing\n")
+	if c.Directory.RefreshInterval < 0 {
+		errs.WriteString("directory refresh_interval must not be negative\n")
+	}
+	if c.HealthProbe.Interval < 0 || c.HealthProbe.Timeout < 0 {
+		errs.WriteString("health_probe interval and timeout must not be negative\n")
+	}
+	if err := c.WebhookAuth.BasicAuth.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if err := c.Overload.validate(); err != nil {
+		errs.WriteString(err.Error() + "\n")
+	}
+	if c.AlertList.MaxRendered < 0 {
+		errs.WriteString("alert_list max_rendered must not be negative\n")
+	}
+	for i, rule := range c.Inhibitions {
+		if len(rule.SourceMatch) == 0 || len(rule.TargetMatch) == 0 {
+			errs.WriteString(fmt.Sprintf("inhibition %d source_match and target_match must not be empty\n", i))
+		}
 	}
 
 	if errs.Len() > 0 {
@@ -140,19 +909,84 @@ func (c Config) validate() error {
 }
 
 func webhook(w http.ResponseWriter, r *http.Request) {
+	timer := prometheus.NewTimer(webhookRequestDuration.WithLabelValues("/webhook"))
+	defer timer.ObserveDuration()
+	// Processing may continue in background after the response, the context only carries the request ID
+	ctx := withRequestID(context.Background(), handleRequestID(w, r))
+	cfg := currentConfig()
+	if !authorizeWebhook(w, r, cfg.WebhookAuth, "/webhook") {
+		return
+	}
+	forwarded, err := isForwarded(r, cfg.Sharding)
+	if err != nil {
+		requestLog(ctx).Warnf("Unauthorized forwarded notification from %s: %v", r.RemoteAddr, err)
+		webhookUnauthorizedRequests.WithLabelValues("/webhook").Inc()
+		sendJSONResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
 
 	data, err := readRequestBody(r)
 	if err != nil {
-		log.Errorf("Error reading request body : %v", err)
+		requestLog(ctx).Errorf("Error reading request body : %v", err)
 		sendJSONResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if isDryRunRequest(r) {
+		processDryRun(ctx, w, data)
+		return
+	}
+	if !forwarded && forwardToShardOwner(ctx, w, r, cfg.Sharding, data) {
+		return
+	}
+
+	processAlertGroup(ctx, w, cfg, data)
+}
+
+// processAlertGroup manages the incident of a decoded alert group with the configuration snapshot
+// of the request, and sends the webhook response
+func processAlertGroup(ctx context.Context, w http.ResponseWriter, cfg Config, data template.Data) {
+	observePayloadComposition(data)
+	lastPayloads.set(data)
+	archivePayload(data)
+
+	if rejectOverload(ctx, w, cfg.Overload, data) {
+		return
+	}
+
+	if pauses.spool(data) {
+		alertGroupLog(ctx, data).Infof("Route %s is paused, notification of alert group key: %s is spooled", data.Receiver, getGroupKey(data))
+		sendJSONResponse(w, http.StatusAccepted, "Spooled, route is paused")
+		return
+	}
+
+	if enqueueAlertGroup(ctx, w, data) {
+		return
+	}
+
+	superseded, done := coalescer.arrive(getGroupKey(data))
+	if superseded {
+		done()
+		alertGroupLog(ctx, data).Infof("Notification of alert group key: %s is superseded by a newer one, skipping", getGroupKey(data))
+		sendJSONResponse(w, http.StatusOK, "Superseded by a newer notification")
+		return
+	}
 
-	err = onAlertGroup(data)
+	completed, err := processWithDeadline(ctx, data, cfg.Workflow.RequestDeadline, cfg.ServiceNow.Retry, done)
+	if !completed {
+		sendJSONResponse(w, http.StatusAccepted, "Accepted, processing continues in background")
+		return
+	}
 
+	if err != nil && !isRetryableError(err) {
+		// Alertmanager does not retry client errors, the payload is dead-lettered
+		alertGroupLog(ctx, data).Errorf("Non retryable error managing incident from alert, payload is dead-lettered : %v", err)
+		deadLetterPayload(data)
+		sendJSONResponse(w, errorStatus(err), err.Error())
+		return
+	}
 	if err != nil {
-		log.Errorf("Error managing incident from alert : %v", err)
-		sendJSONResponse(w, http.StatusInternalServerError, err.Error())
+		alertGroupLog(ctx, data).Errorf("Error managing incident from alert : %v", err)
+		sendJSONResponse(w, errorStatus(err), err.Error())
 		return
 	}
 
@@ -161,11 +995,13 @@ func webhook(w http.ResponseWriter, r *http.Request) {
 }
 
 func homepage(w http.ResponseWriter, r *http.Request) {
+	prefix := html.EscapeString(linkPrefix)
 	w.Write([]byte(`<html>
 	<head><title>alertmanager-webhook-servicenow</title></head>
 	<body>
 	<h1>alertmanager-webhook-servicenow</h1>
-	<p><a href="/metrics">Metrics</a></p>
+	<p><a href="` + prefix + `/ui">Operator UI</a></p>
+	<p><a href="` + prefix + `/metrics">Metrics</a></p>
 	</body>
 	</html>`))
 }
@@ -173,40 +1009,152 @@ func homepage(w http.ResponseWriter, r *http.Request) {
 // Starts the following http handler:
 // - basic home page on /
 // - Alertmanager webhook entry point on /webhook
+// - optional group key resync admin endpoint on /-/resync
+// - liveness and readiness endpoints on /-/healthy and /-/ready
+// - optional configuration reload endpoint on /-/reload, and reload on SIGHUP
+// - optional CloudEvents entry point on /cloudevents
+// - group key history on /api/v1/groups/{key}/history
+// - optional route pause endpoints on /api/v1/pause and /api/v1/resume
+// - operator UI on /ui, and its live processing status on /api/v1/status
 // - health metrics on /metrics
+// All of them under the --web.route-prefix path, if any.
 func main() {
 	kingpin.Version(version.Print("alertmanager-webhook-servicenow"))
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+	command := kingpin.Parse()
+	if err := configureLogging(*logLevel, *logFormat); err != nil {
+		log.Fatal(err)
+	}
+	if command == loadTestCommand.FullCommand() {
+		report := runLoadTest(&http.Client{Timeout: 30 * time.Second}, *loadTestURL, *loadTestRate, *loadTestDuration, *loadTestGroups)
+		report.write(os.Stdout)
+		return
+	}
+
+	if command == checkConfigCommand.FullCommand() {
+		if err := checkConfig(*configFile); err != nil {
+			fmt.Fprintf(os.Stderr, "%s is invalid:\n%v\n", *configFile, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s is valid\n", *configFile)
+		return
+	}
 
 	_, err := loadConfig(*configFile)
 	if err != nil {
 		log.Fatalf("Error loading config file: %v", err)
 	}
+	if command == describeMappingCommand.FullCommand() {
+		writeMapping(os.Stdout, describeMapping())
+		return
+	}
+	if command == testConfigCommand.FullCommand() {
+		failed, err := runConfigTests(*testConfigFile, os.Stdout)
+		if err != nil {
+			log.Fatalf("Error running config tests: %v", err)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
 
 	_, err = loadSnClient()
 	if err != nil {
 		log.Fatalf("Error loading ServiceNow client: %v", err)
 	}
+	if command == sendTestAlertCommand.FullCommand() {
+		data := testAlertGroup(*sendTestAlertStatus, *sendTestAlertReceiver, *sendTestAlertLabels, *sendTestAlertAnnotations)
+		if err := sendTestAlert(os.Stdout, data); err != nil {
+			log.Fatalf("Error sending test alert: %v", err)
+		}
+		return
+	}
+	if command == listIncidentsCommand.FullCommand() {
+		if _, ok := serviceNowInstances[*listIncidentsInstance]; !ok && len(*listIncidentsInstance) > 0 {
+			log.Fatalf("Unknown ServiceNow instance: %s", *listIncidentsInstance)
+		}
+		if err := listIncidents(os.Stdout, serviceNowByName(*listIncidentsInstance), *listIncidentsStates, *listIncidentsMaxAge, *listIncidentsLimit, *listIncidentsOutput); err != nil {
+			log.Fatalf("Error listing incidents: %v", err)
+		}
+		return
+	}
+	webhookConfigReloadSuccess.Set(1)
+	webhookConfigReloadTime.SetToCurrentTime()
+	// The default instance is probed last, its capabilities are the exposed ones
+	for _, instance := range config.ServiceNowInstances {
+		if prober, ok := serviceNowInstances[instance.Name].(interface{ probeCapabilities() map[string]bool }); ok && !instance.ServiceNow.SkipCapabilityProbe {
+			prober.probeCapabilities()
+		}
+	}
+	if prober, ok := serviceNow.(interface{ probeCapabilities() map[string]bool }); ok && !config.ServiceNow.SkipCapabilityProbe {
+		prober.probeCapabilities()
+	}
+
+	go scheduler.run(time.Second)
+	go queue.run()
+	go watchReloadSignal()
+	if config.Directory.RefreshInterval > 0 {
+		go directory.run(config.Directory.RefreshInterval)
+	}
+	if config.HealthProbe.Interval > 0 {
+		go health.run(config.HealthProbe)
+	}
 
 	log.Info("Starting webhook", version.Info())
 	log.Info("Build context", version.BuildContext())
 
-	http.HandleFunc("/", homepage)
-	http.HandleFunc("/webhook", webhook)
-	http.Handle("/metrics", promhttp.Handler())
+	routePrefix, linkPrefix, err = webPrefixes(*externalURLFlag, *routePrefixFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", homepage)
+	mux.HandleFunc("/webhook", webhook)
+	mux.HandleFunc("/-/healthy", healthy)
+	mux.HandleFunc("/-/ready", ready)
+	if config.Resync.enabled() {
+		mux.HandleFunc("/-/resync", resync)
+	}
+	if config.Reload.enabled() {
+		mux.HandleFunc("/-/reload", reload)
+	}
+	if config.CloudEvents.Enabled {
+		mux.HandleFunc("/cloudevents", cloudEvents)
+	}
+	mux.HandleFunc("/api/v1/groups/", groupHistoryHandler)
+	mux.HandleFunc("/api/v1/scheduled", scheduledActionsHandler)
+	mux.HandleFunc("/api/v1/status", statusHandler)
+	mux.HandleFunc("/ui", ui)
+	if config.Ack.enabled() {
+		mux.HandleFunc("/api/v1/ack", ack)
+	}
+	if config.Pause.enabled() {
+		mux.HandleFunc("/api/v1/pause", pauseRoute)
+		mux.HandleFunc("/api/v1/resume", resumeRoute)
+	}
+	mux.Handle("/metrics", promhttp.Handler())
 
 	log.Infof("listening on: %v", *listenAddress)
-	log.Fatal(http.ListenAndServe(*listenAddress, nil))
+	server := &http.Server{Addr: *listenAddress, Handler: withRoutePrefix(mux, routePrefix)}
+	if err := serve(server, *shutdownTimeout); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func sendJSONResponse(w http.ResponseWriter, status int, message string) {
 	webhookRequests.WithLabelValues(strconv.Itoa(status)).Inc()
 	webhookLastRequest.SetToCurrentTime()
 
+	writeJSONResponse(w, status, message)
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, message string) {
 	data := JSONResponse{
-		Status:  status,
-		Message: message,
+		Status:    status,
+		Message:   message,
+		RequestID: w.Header().Get(requestIDHeader),
 	}
 	bytes, _ := json.Marshal(data)
 
@@ -223,40 +1171,121 @@ func readRequestBody(r *http.Request) (template.Data, error) {
 	// Do not forget to close the body at the end
 	defer r.Body.Close()
 
-	// Extract data from the body in the Data template provided by AlertManager
-	data := template.Data{}
-	err := json.NewDecoder(r.Body).Decode(&data)
+	// Called without configLock held, the streaming threshold is read from a snapshot
+	if r.ContentLength < 0 || r.ContentLength > currentConfig().Decoding.streamingThreshold() {
+		return decodeStream(r.Body)
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return template.Data{}, err
+	}
+
+	return decodeBody(body)
+}
 
-	return data, err
+// decodeBody decodes a payload of any supported format
+func decodeBody(body []byte) (template.Data, error) {
+	// Detect the payload format from its top level fields
+	fields := map[string]json.RawMessage{}
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&fields); err != nil {
+		return template.Data{}, err
+	}
+	format := detectPayloadFormat(fields)
+	webhookPayloadFormats.WithLabelValues(format).Inc()
+	webhookPayloadBytes.Observe(float64(len(body)))
+
+	// Extract data from the body in the Data template provided by AlertManager
+	return decodePayload(format, body)
 }
 
 func loadConfigContent(configData []byte) (Config, error) {
-	config = Config{}
+	// The configuration is parsed and compiled aside, in-flight requests keep using the
+	// current one until the new one is swapped in
+	loaded := Config{}
 	var err error
 
-	err = yaml.Unmarshal([]byte(configData), &config)
+	configData, err = expandEnvVars(configData)
+	if err != nil {
+		return loaded, err
+	}
+	err = yaml.UnmarshalStrict([]byte(configData), &loaded)
 	if err != nil {
-		return config, err
+		return loaded, explainConfigError(err)
 	}
 
-	loadEnvVars(&config)
+	err = loadUserNameFiles(&loaded)
+	if err != nil {
+		return loaded, err
+	}
+	loadEnvVars(&loaded)
 
-	err = config.validate()
+	err = loaded.validate()
 	if err != nil {
-		return config, err
+		return loaded, err
 	}
 
 	// Load internal state from config
-	noUpdateStates = make(map[json.Number]bool, len(config.Workflow.NoUpdateStates))
-	for _, s := range config.Workflow.NoUpdateStates {
-		noUpdateStates[s] = true
+	loadedNoUpdateStates := make(map[json.Number]bool, len(loaded.Workflow.NoUpdateStates))
+	for _, s := range loaded.Workflow.NoUpdateStates {
+		loadedNoUpdateStates[s] = true
 	}
 
 	// Load internal incidents update fields from config
-	incidentUpdateFields = make(map[string]bool, len(config.Workflow.IncidentUpdateFields))
-	for _, f := range config.Workflow.IncidentUpdateFields {
-		incidentUpdateFields[f] = true
+	loadedIncidentUpdateFields := make(map[string]bool, len(loaded.Workflow.IncidentUpdateFields))
+	for _, f := range loaded.Workflow.IncidentUpdateFields {
+		loadedIncidentUpdateFields[f] = true
+	}
+	// Alert details written to work_notes are updated as comments are
+	if loaded.Workflow.AlertDetails.enabled() && loadedIncidentUpdateFields[alertDetailsComments] {
+		loadedIncidentUpdateFields[alertDetailsWorkNotes] = true
+	}
+
+	// Load internal redaction rules from config
+	loadedRedactionRules, err := compileRedactionRules(loaded.Redactions)
+	if err != nil {
+		return loaded, err
+	}
+
+	// Load internal URL rewrite rules from config
+	loadedURLRewriteRules, err := compileURLRewriteRules(loaded.URLRewriting.Rules)
+	if err != nil {
+		return loaded, err
+	}
+
+	// Load internal field transforms from config
+	loadedFieldTransforms, err := compileFieldTransforms(loaded.FieldTransforms)
+	if err != nil {
+		return loaded, err
+	}
+
+	// Load internal field rules from config
+	loadedFieldRules, err := compileFieldRules(loaded.FieldRules)
+	if err != nil {
+		return loaded, err
 	}
+
+	// Load internal shared templates from config
+	loadedTemplateFiles, err := compileTemplateFiles(loaded.TemplateFiles)
+	if err != nil {
+		return loaded, err
+	}
+
+	config = loaded
+	noUpdateStates = loadedNoUpdateStates
+	incidentUpdateFields = loadedIncidentUpdateFields
+	redactionRules = loadedRedactionRules
+	urlRewriteRules = loadedURLRewriteRules
+	fieldTransforms = loadedFieldTransforms
+	fieldRules = loadedFieldRules
+	templateFiles = loadedTemplateFiles
+	archives.configure(newArchiver(config.Archiver))
+	history.configure(config.History)
+	progress.configure(config.Workflow.ProgressTTL, config.Workflow.ProgressFile)
+	scheduler.configure(config.Workflow.ScheduleFile)
+	pauses.configure(config.Pause.StateFile)
+	queue.configure(config.Queue)
+	incidents.configure(config.IncidentCache)
+	lookupCache.configure(config.LookupCache, config.Directory.RefreshInterval > 0)
 	log.Info("ServiceNow config loaded")
 	return config, nil
 }
@@ -267,8 +1296,17 @@ func loadConfig(configFile string) (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
+	configData, err = resolveIncludes(configFile, configData)
+	if err != nil {
+		return Config{}, err
+	}
 
-	return loadConfigContent(configData)
+	loaded, err := loadConfigContent(configData)
+	if err != nil {
+		return loaded, err
+	}
+	loadedConfigData = configData
+	return loaded, nil
 }
 
 func loadEnvVars(c *Config) {
@@ -284,58 +1322,156 @@ func loadEnvVars(c *Config) {
 	if incidentField, ok := os.LookupEnv("SERVICENOW_INCIDENT_GROUP_KEY_FIELD"); ok {
 		(*c).Workflow.IncidentGroupKeyField = incidentField
 	}
+	if shardSelf, ok := os.LookupEnv("SHARDING_SELF"); ok {
+		(*c).Sharding.Self = shardSelf
+	}
 }
 
 func loadSnClient() (ServiceNow, error) {
-	var err error
-	serviceNow, err = NewServiceNowClient(config.ServiceNow.InstanceName, config.ServiceNow.UserName, config.ServiceNow.Password)
+	snClient, err := newServiceNowClientFromConfig(config.ServiceNow)
+	if err != nil {
+		return serviceNow, err
+	}
+	serviceNow = snClient
+	if !config.Migration.Until.IsZero() {
+		migrationClient, err := newMigrationServiceNowClient(config.Migration, config.ServiceNow)
+		if err != nil {
+			return serviceNow, err
+		}
+		log.Infof("Incidents are dual-written to the migration target until %s", config.Migration.Until)
+		serviceNow = newDualWriteServiceNow(snClient, migrationClient)
+	}
+
+	instanceClients, err := loadServiceNowInstances(config.ServiceNowInstances)
 	if err != nil {
 		return serviceNow, err
 	}
+	serviceNowInstances = make(map[string]ServiceNow, len(instanceClients))
+	for name, instanceClient := range instanceClients {
+		serviceNowInstances[name] = instanceClient
+	}
+
+	// Stop watching the password files of the previous clients
+	if passwordWatcherDone != nil {
+		close(passwordWatcherDone)
+		passwordWatcherDone = nil
+	}
+	passwordClients := map[*ServiceNowClient]ServiceNowConfig{snClient: config.ServiceNow}
+	for _, instance := range config.ServiceNowInstances {
+		passwordClients[instanceClients[instance.Name]] = instance.ServiceNow
+	}
+	for client, c := range passwordClients {
+		if len(c.PasswordFile) == 0 {
+			continue
+		}
+		if passwordWatcherDone == nil {
+			passwordWatcherDone = make(chan struct{})
+		}
+		interval := c.PasswordFileReload
+		if interval <= 0 {
+			interval = defaultPasswordFileReload
+		}
+		go client.watchPasswordFile(interval, passwordWatcherDone)
+	}
 	return serviceNow, nil
 }
 
-func onAlertGroup(data template.Data) error {
+// onAlertGroup processes the notification, split per alert when it is processed per alert
+func onAlertGroup(ctx context.Context, data template.Data) error {
+	configLock.RLock()
+	defer configLock.RUnlock()
+
+	if isPerAlert(data) && len(data.Alerts) > 1 {
+		var firstErr error
+		for _, alertData := range splitPerAlert(data) {
+			if err := handleAlertGroup(ctx, alertData); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+	return handleAlertGroup(ctx, data)
+}
 
-	log.Infof("Received alert group: Status=%s, GroupLabels=%v, CommonLabels=%v, CommonAnnotations=%v",
+// handleAlertGroup processes the alert group of a single group key, skipping the steps already completed for its
+// payload, the configuration lock being held
+func handleAlertGroup(ctx context.Context, data template.Data) error {
+	alertGroupLog(ctx, data).Infof("Received alert group: Status=%s, GroupLabels=%v, CommonLabels=%v, CommonAnnotations=%v",
 		data.Status, data.GroupLabels, data.CommonLabels, data.CommonAnnotations)
 
-	getParams := map[string]string{
-		config.Workflow.IncidentGroupKeyField: getGroupKey(data),
+	unlock := progress.lock(getGroupKey(data))
+	defer unlock()
+	if incident, action, ok := progress.completedIncident(data); ok {
+		alertGroupLog(ctx, data).Infof("Alert group key: %s was already processed for this payload (incident %s), skipping its completed steps", getGroupKey(data), incident.GetNumber())
+		if len(action) > 0 {
+			incidentSideEffects(ctx, rewriteAlertURLs(ctx, data), action, incident)
+		}
+		return nil
 	}
+	return manageAlertGroupIncident(ctx, rewriteAlertURLs(ctx, data))
+}
 
-	existingIncidents, err := serviceNow.GetIncidents(getParams)
-	if err != nil {
-		serviceNowError.Inc()
-		return err
+// manageAlertGroupIncident creates or updates the incident of the alert group, or sends its event in event mode,
+// the group key lock being held and the alert URLs rewritten
+func manageAlertGroupIncident(ctx context.Context, data template.Data) error {
+	if config.EventManagement.Enabled {
+		return sendAlertGroupEvent(ctx, data)
+	}
+	existingIncidents, cached := incidents.get(getGroupKey(data))
+	if !cached {
+		var err error
+		existingIncidents, err = serviceNowFor(ctx, data).GetIncidents(getGroupKeyLookupParams(ctx, data))
+		if err != nil {
+			// The incidents are unknown, the alert group must not be processed as having none
+			serviceNowError.Inc()
+			if !isDryRun(ctx) {
+				history.record(getGroupKey(data), data.Status, "lookup", "", err)
+			}
+			return stageError(stageLookup, err)
+		}
+		incidents.set(getGroupKey(data), existingIncidents)
 	}
-	log.Infof("Found %v existing incident(s) for alert group key: %s.", len(existingIncidents), getGroupKey(data))
+	alertGroupLog(ctx, data).Infof("Found %v existing incident(s) for alert group key: %s.", len(existingIncidents), getGroupKey(data))
 
 	updatableIncidents := filterUpdatableIncidents(existingIncidents)
-	log.Infof("Found %v updatable incident(s) for alert group key: %s.", len(updatableIncidents), getGroupKey(data))
+	alertGroupLog(ctx, data).Infof("Found %v updatable incident(s) for alert group key: %s.", len(updatableIncidents), getGroupKey(data))
 
 	var updatableIncident Incident
 	if len(updatableIncidents) > 0 {
 		updatableIncident = updatableIncidents[0]
 
 		if len(updatableIncidents) > 1 {
-			log.Warnf("As multiple updable incidents were found for alert group key: %s, first one will be used: %s", getGroupKey(data), updatableIncident.GetNumber())
+			incidentLog(ctx, data, updatableIncident).Warnf("As multiple updable incidents were found for alert group key: %s, first one will be used: %s", getGroupKey(data), updatableIncident.GetNumber())
+		}
+	}
+
+	if isEmptyAlertGroup(data) {
+		action := config.Workflow.EmptyAlertGroup.action()
+		webhookEmptyAlertGroups.WithLabelValues(action).Inc()
+		if action != emptyGroupProcess {
+			return onEmptyAlertGroup(ctx, data, updatableIncident)
 		}
 	}
 
 	if data.Status == "firing" {
-		return onFiringGroup(data, updatableIncident)
+		if !isDryRun(ctx) && scheduler.cancelAction(getGroupKey(data), actionResolve) {
+			alertGroupLog(ctx, data).Infof("Alert group key: %s is firing again, scheduled actions are cancelled", getGroupKey(data))
+		}
+		return onFiringGroup(ctx, data, updatableIncident, existingIncidents)
 	} else if data.Status == "resolved" {
-		return onResolvedGroup(data, updatableIncident)
+		if len(existingIncidents) == 0 {
+			return onUnknownResolvedGroup(ctx, data)
+		}
+		return onResolvedGroup(ctx, data, updatableIncident)
 	} else {
-		log.Errorf("Unknown alert group status: %s", data.Status)
+		alertGroupLog(ctx, data).Errorf("Unknown alert group status: %s", data.Status)
 	}
 
 	return nil
 }
 
-func onFiringGroup(data template.Data, updatableIncident Incident) error {
-	incidentCreateParam, err := alertGroupToIncident(data)
+func onFiringGroup(ctx context.Context, data template.Data, updatableIncident Incident, existingIncidents []Incident) error {
+	incidentCreateParam, err := alertGroupToIncident(ctx, data)
 	if err != nil {
 		return err
 	}
@@ -343,23 +1479,81 @@ func onFiringGroup(data template.Data, updatableIncident Incident) error {
 	incidentUpdateParam := filterForUpdate(incidentCreateParam)
 
 	if updatableIncident == nil {
-		log.Infof("Found no updatable incident for firing alert group key: %s", getGroupKey(data))
-		if _, err := serviceNow.CreateIncident(incidentCreateParam); err != nil {
-			serviceNowError.Inc()
+		if reopenableIncident := findReopenableIncident(existingIncidents); reopenableIncident != nil {
+			incidentLog(ctx, data, reopenableIncident).Infof("Found incident (%s), with state %s, resolved within reopen window for firing alert group key: %s", reopenableIncident.GetNumber(), reopenableIncident.GetState(), getGroupKey(data))
+			if len(config.Workflow.ReopenState) > 0 {
+				incidentUpdateParam["state"] = config.Workflow.ReopenState.String()
+			}
+			applyJournal(ctx, data, journalAlertsAdded, incidentUpdateParam)
+			skipDuplicateJournal(reopenableIncident, incidentUpdateParam)
+			if vetoed, err := runIncidentHooks(ctx, data, "reopen", reopenableIncident.GetNumber(), incidentUpdateParam); vetoed || err != nil {
+				return err
+			}
+			updatedIncident, err := serviceNowFor(ctx, data).UpdateIncident(incidentUpdateParam, reopenableIncident.GetSysID())
+			cacheIncidentResult(ctx, data, updatedIncident, err)
+			observeIncidentAction(ctx, data, incidentCreateParam, "reopen", reopenableIncident.GetNumber(), err)
+			if err != nil {
+				serviceNowError.Inc()
+				return stageError(stageUpdate, err)
+			}
+			progress.completeIncident(ctx, data, "reopen", reopenableIncident)
+			incidentSideEffects(ctx, data, "reopen", reopenableIncident)
+			return nil
+		}
+
+		alertGroupLog(ctx, data).Infof("Found no updatable incident for firing alert group key: %s", getGroupKey(data))
+		if inhibited, err := inhibitIncident(ctx, data); inhibited {
 			return err
 		}
-	} else {
-		log.Infof("Found updatable incident (%s), with state %s, for firing alert group key: %s", updatableIncident.GetNumber(), updatableIncident.GetState(), getGroupKey(data))
-		if _, err := serviceNow.UpdateIncident(incidentUpdateParam, updatableIncident.GetSysID()); err != nil {
+		applyJournal(ctx, data, journalCreated, incidentCreateParam)
+		if vetoed, err := runIncidentHooks(ctx, data, "create", "", incidentCreateParam); vetoed || err != nil {
+			return err
+		}
+		createdIncident, err := serviceNowFor(ctx, data).CreateIncident(incidentCreateParam)
+		cacheIncidentResult(ctx, data, createdIncident, err)
+		observeIncidentAction(ctx, data, incidentCreateParam, "create", createdIncident.GetNumber(), err)
+		if err != nil {
 			serviceNowError.Inc()
+			return stageError(stageCreate, err)
+		}
+		progress.completeIncident(ctx, data, "create", createdIncident)
+		verifyCreatedIncident(ctx, data, incidentCreateParam, createdIncident)
+		incidentSideEffects(ctx, data, "create", createdIncident)
+	} else {
+		incidentLog(ctx, data, updatableIncident).Infof("Found updatable incident (%s), with state %s, for firing alert group key: %s", updatableIncident.GetNumber(), updatableIncident.GetState(), getGroupKey(data))
+		if shedRepeatUpdate(ctx, data, updatableIncident) || skipPerAlertUpdate(ctx, data, updatableIncident) {
+			return nil
+		}
+		applyOnHold(ctx, data, updatableIncident, incidentUpdateParam)
+		applyJournal(ctx, data, journalAlertsAdded, incidentUpdateParam)
+		restrictOwnedIncidentUpdate(ctx, data, updatableIncident, incidentUpdateParam)
+		skipDuplicateJournal(updatableIncident, incidentUpdateParam)
+		if len(incidentUpdateParam) == 0 {
+			incidentLog(ctx, data, updatableIncident).Infof("Nothing to update in incident (%s) for firing alert group key: %s", updatableIncident.GetNumber(), getGroupKey(data))
+			return nil
+		}
+		if vetoed, err := runIncidentHooks(ctx, data, "update", updatableIncident.GetNumber(), incidentUpdateParam); vetoed || err != nil {
 			return err
 		}
+		if deferJournalUpdate(ctx, data, updatableIncident, incidentUpdateParam) {
+			return nil
+		}
+		updatedIncident, err := serviceNowFor(ctx, data).UpdateIncident(incidentUpdateParam, updatableIncident.GetSysID())
+		cacheIncidentResult(ctx, data, updatedIncident, err)
+		observeIncidentAction(ctx, data, incidentCreateParam, "update", updatableIncident.GetNumber(), err)
+		if err != nil {
+			serviceNowError.Inc()
+			return stageError(stageUpdate, err)
+		}
+		progress.completeIncident(ctx, data, "update", updatableIncident)
+		incidentSideEffects(ctx, data, "update", updatableIncident)
 	}
 	return nil
 }
 
-func onResolvedGroup(data template.Data, updatableIncident Incident) error {
-	incidentCreateParam, err := alertGroupToIncident(data)
+func onResolvedGroup(ctx context.Context, data template.Data, updatableIncident Incident) error {
+	inhibitions.track(ctx, data, updatableIncident)
+	incidentCreateParam, err := alertGroupToIncident(ctx, data)
 	if err != nil {
 		return err
 	}
@@ -367,37 +1561,245 @@ func onResolvedGroup(data template.Data, updatableIncident Incident) error {
 	incidentUpdateParam := filterForUpdate(incidentCreateParam)
 
 	if updatableIncident == nil {
-		log.Infof("Found no updatable incident for resolved alert group key: %s. No incident will be created/updated.", getGroupKey(data))
+		alertGroupLog(ctx, data).Infof("Found no updatable incident for resolved alert group key: %s. No incident will be created/updated.", getGroupKey(data))
 	} else {
-		log.Infof("Found updatable incident (%s), with state %s, for resolved alert group key: %s", updatableIncident.GetNumber(), updatableIncident.GetState(), getGroupKey(data))
-		if _, err := serviceNow.UpdateIncident(incidentUpdateParam, updatableIncident.GetSysID()); err != nil {
-			serviceNowError.Inc()
+		incidentLog(ctx, data, updatableIncident).Infof("Found updatable incident (%s), with state %s, for resolved alert group key: %s", updatableIncident.GetNumber(), updatableIncident.GetState(), getGroupKey(data))
+		applyJournal(ctx, data, journalAlertsResolved, incidentUpdateParam)
+		owned := restrictOwnedIncidentUpdate(ctx, data, updatableIncident, incidentUpdateParam)
+		skipDuplicateJournal(updatableIncident, incidentUpdateParam)
+		takeDeferredJournal(ctx, data, updatableIncident, incidentUpdateParam)
+		if !owned {
+			applyAutoResolve(ctx, data, updatableIncident, incidentUpdateParam)
+		}
+		if len(incidentUpdateParam) == 0 {
+			incidentLog(ctx, data, updatableIncident).Infof("Nothing to update in incident (%s) for resolved alert group key: %s", updatableIncident.GetNumber(), getGroupKey(data))
+			return nil
+		}
+		if vetoed, err := runIncidentHooks(ctx, data, "update", updatableIncident.GetNumber(), incidentUpdateParam); vetoed || err != nil {
 			return err
 		}
+		if deferJournalUpdate(ctx, data, updatableIncident, incidentUpdateParam) {
+			return nil
+		}
+		updatedIncident, err := serviceNowFor(ctx, data).UpdateIncident(incidentUpdateParam, updatableIncident.GetSysID())
+		cacheIncidentResult(ctx, data, updatedIncident, err)
+		observeIncidentAction(ctx, data, incidentCreateParam, "update", updatableIncident.GetNumber(), err)
+		if err != nil {
+			serviceNowError.Inc()
+			return stageError(stageUpdate, err)
+		}
+		action := "update"
+		if resolvesIncident(incidentUpdateParam) {
+			action = "resolve"
+		}
+		progress.completeIncident(ctx, data, action, updatableIncident)
+		incidentSideEffects(ctx, data, action, updatableIncident)
 	}
 	return nil
 }
 
-func alertGroupToIncident(data template.Data) (Incident, error) {
+// incidentSideEffects runs the side effects following the incident write of the action, each one once per payload
+// so that a retried payload only runs the ones left undone
+func incidentSideEffects(ctx context.Context, data template.Data, action string, incident Incident) {
+	if data.Status == "resolved" {
+		if action == "resolve" && !isDryRun(ctx) {
+			progress.runStep(ctx, data, stepResolutionConfirmation, func() {
+				webhookIncidentsResolved.WithLabelValues("immediate").Inc()
+				confirmResolution(serviceNowInstanceName(data), getGroupKey(data), incident)
+			})
+		}
+		progress.runStep(ctx, data, stepTimeline, func() { attachTimeline(ctx, data, incident) })
+		progress.runStep(ctx, data, stepAlertList, func() { attachAlertList(ctx, data, incident) })
+		return
+	}
 
-	incident := Incident{
-		"caller_id":                           config.ServiceNow.UserName,
-		config.Workflow.IncidentGroupKeyField: getGroupKey(data),
+	inhibitions.track(ctx, data, incident)
+	if action == "create" {
+		progress.runStep(ctx, data, stepTimeline, func() { attachTimeline(ctx, data, incident) })
+		progress.runStep(ctx, data, stepAlertList, func() { attachAlertList(ctx, data, incident) })
 	}
+	progress.runStep(ctx, data, stepTasks, func() { createIncidentTasks(ctx, data, incident) })
+	progress.runStep(ctx, data, stepKnowledge, func() { linkKnowledgeArticles(ctx, data, incident) })
+}
 
-	for k, v := range config.DefaultIncident {
-		incident[k] = v
+// resolvesIncident returns true if the update sets the auto resolve state
+func resolvesIncident(incidentUpdateParam Incident) bool {
+	state := config.Workflow.AutoResolve.State
+	return len(state) > 0 && fmt.Sprint(incidentUpdateParam["state"]) == state.String()
+}
+
+// applyOnHold puts the incident on hold when all alerts of the group are
+// silenced, and resumes it when alerts fire unsilenced again
+func applyOnHold(ctx context.Context, data template.Data, incident Incident, incidentUpdateParam Incident) {
+	onHold := config.Workflow.OnHold
+	if len(onHold.SilencedLabel) == 0 {
+		return
+	}
+
+	if allAlertsSilenced(data) {
+		incidentLog(ctx, data, incident).Infof("All alerts are silenced for alert group key: %s, incident (%s) will be put on hold", getGroupKey(data), incident.GetNumber())
+		incidentUpdateParam["state"] = onHold.State.String()
+		if len(onHold.HoldReason) > 0 {
+			incidentUpdateParam["hold_reason"] = onHold.HoldReason
+		}
+	} else if incident.GetState() == onHold.State && len(onHold.ResumeState) > 0 {
+		incidentLog(ctx, data, incident).Infof("Alerts are no longer silenced for alert group key: %s, incident (%s) will be resumed", getGroupKey(data), incident.GetNumber())
+		incidentUpdateParam["state"] = onHold.ResumeState.String()
+	}
+}
+
+// applyAutoResolve resolves the incident along the update, or schedules its
+// resolution after the configured delay
+func applyAutoResolve(ctx context.Context, data template.Data, incident Incident, incidentUpdateParam Incident) {
+	autoResolve := config.Workflow.AutoResolve
+	if len(autoResolve.State) == 0 {
+		return
+	}
+
+	resolveParam := Incident{"state": autoResolve.State.String()}
+	for field, value := range autoResolve.Fields {
+		resolveParam[field] = value
+	}
+	applyIncidentTemplate(ctx, resolveParam, data)
+	applyJournal(ctx, data, journalAutoClosed, resolveParam)
+
+	if autoResolve.Delay <= 0 {
+		incidentLog(ctx, data, incident).Infof("Incident (%s) will be resolved for alert group key: %s", incident.GetNumber(), getGroupKey(data))
+		if autoResolve.ResolveOnly {
+			for field := range incidentUpdateParam {
+				delete(incidentUpdateParam, field)
+			}
+		}
+		for field, value := range resolveParam {
+			incidentUpdateParam[field] = value
+		}
+		return
+	}
+
+	incidentLog(ctx, data, incident).Infof("Incident (%s) resolution is scheduled in %s for alert group key: %s", incident.GetNumber(), autoResolve.Delay, getGroupKey(data))
+	if isDryRun(ctx) {
+		return
 	}
+	scheduler.schedule(scheduledAction{
+		GroupKey:       getGroupKey(data),
+		Action:         actionResolve,
+		IncidentSysID:  incident.GetSysID(),
+		IncidentNumber: incident.GetNumber(),
+		Instance:       serviceNowInstanceName(data),
+		Params:         resolveParam,
+		FireAt:         now().Add(autoResolve.Delay),
+	})
+}
+
+// allAlertsSilenced returns true if every firing alert carries the silenced label set to "true"
+func allAlertsSilenced(data template.Data) bool {
+	firingAlerts := data.Alerts.Firing()
+	if len(firingAlerts) == 0 {
+		return false
+	}
+	for _, alert := range firingAlerts {
+		if alert.Labels[config.Workflow.OnHold.SilencedLabel] != "true" {
+			return false
+		}
+	}
+	return true
+}
+
+// observeIncidentAction records the outcome of an incident action in the group
+// history and in metrics, labelled with allowlisted receiver and assignment
+// group to keep cardinality bounded
+func observeIncidentAction(ctx context.Context, data template.Data, incident Incident, action string, incidentNumber string, err error) {
+	if isDryRun(ctx) {
+		return
+	}
+	history.record(getGroupKey(data), data.Status, action, incidentNumber, err)
+	if err == nil && action == "create" {
+		webhookLastIncidentCreated.WithLabelValues(getSeverity(data)).Set(float64(now().Unix()))
+	}
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	assignmentGroup, _ := incident["assignment_group"].(string)
+
+	webhookTemplateVariantActions.WithLabelValues(getTemplateVariantName(data), action, result).Inc()
+	webhookIncidentActions.WithLabelValues(
+		action,
+		result,
+		allowlistedLabelValue(data.Receiver, config.Metrics.ReceiverAllowlist),
+		allowlistedLabelValue(assignmentGroup, config.Metrics.AssignmentGroupAllowlist),
+	).Inc()
+}
+
+// getSeverity returns the common severity label of the alert group, or "none"
+func getSeverity(data template.Data) string {
+	label := config.Metrics.SeverityLabel
+	if len(label) == 0 {
+		label = defaultSeverityLabel
+	}
+	if severity := data.CommonLabels[label]; len(severity) > 0 {
+		return severity
+	}
+	return "none"
+}
+
+// allowlistedLabelValue returns the value if it is in the allowlist, "other" otherwise
+func allowlistedLabelValue(value string, allowlist []string) string {
+	for _, allowed := range allowlist {
+		if value == allowed {
+			return value
+		}
+	}
+	return "other"
+}
+
+func alertGroupToIncident(ctx context.Context, data template.Data) (Incident, error) {
+	incident := renderIncident(ctx, data, selectDefaultIncident(data))
+	compareShadowIncident(ctx, data, incident)
+	applyAssignmentGroupOverride(ctx, data, incident)
 
-	applyIncidentTemplate(incident, data)
 	err := validateIncident(incident)
 	if err != nil {
 		webhookIncidentValidationError.Inc()
-		log.Error(err)
+		alertGroupLog(ctx, data).Error(err)
 	}
 	return incident, nil
 }
 
+// renderIncident builds the incident fields of an alert group from the given default incident templates
+func renderIncident(ctx context.Context, data template.Data, defaultIncident map[string]string) Incident {
+	incident := Incident{
+		"caller_id":                           config.ServiceNow.UserName,
+		config.Workflow.IncidentGroupKeyField: getGroupKeyFieldValue(ctx, data),
+	}
+	if source := config.Workflow.Source; source.enabled() {
+		incident[source.Field] = source.Value
+	}
+
+	for k, v := range defaultIncident {
+		incident[k] = v
+	}
+
+	applySeverityMapping(incident, data)
+	applyFieldRules(incident, data)
+	applyIncidentTemplate(ctx, incident, data)
+	applyFieldMappings(ctx, incident, data)
+	applyAnnotationFields(ctx, incident, data)
+	applyAlertDetails(ctx, incident, data)
+	applyRunbookLinks(incident, data)
+	if len(config.Workflow.GroupLabelsField) > 0 {
+		incident[config.Workflow.GroupLabelsField] = getGroupLabelsJSON(data)
+	}
+	if shortDescription := selectShortDescription(data); len(shortDescription) > 0 {
+		incident["short_description"] = shortDescription
+	}
+	transformIncident(incident)
+	redactIncident(incident)
+	formatIncident(incident)
+	return incident
+}
+
 func filterForUpdate(incident Incident) Incident {
 	incidentUpdate := Incident{}
 	for field, value := range incident {
@@ -418,12 +1820,73 @@ func filterUpdatableIncidents(incidents []Incident) []Incident {
 	return updatableIncidents
 }
 
+// findReopenableIncident returns the most recently resolved incident that is
+// still within the configured reopen window, or nil if there is none.
+func findReopenableIncident(incidents []Incident) Incident {
+	if config.Workflow.ReopenWindow <= 0 {
+		return nil
+	}
+
+	var reopenableIncident Incident
+	var reopenableResolvedAt time.Time
+	for _, incident := range incidents {
+		resolvedAt, ok := incident.GetResolvedAt()
+		if !ok || now().Sub(resolvedAt) > config.Workflow.ReopenWindow {
+			continue
+		}
+		if reopenableIncident == nil || resolvedAt.After(reopenableResolvedAt) {
+			reopenableIncident = incident
+			reopenableResolvedAt = resolvedAt
+		}
+	}
+	return reopenableIncident
+}
+
+// selectShortDescription returns the value of the first configured annotation
+// found in the common annotations, then in the alerts annotations, falling back
+// to the alertname label. It returns an empty string if no annotation is configured.
+func selectShortDescription(data template.Data) string {
+	if len(config.Workflow.ShortDescriptionAnnotations) == 0 {
+		return ""
+	}
+
+	for _, name := range config.Workflow.ShortDescriptionAnnotations {
+		if value := data.CommonAnnotations[name]; len(value) > 0 {
+			return value
+		}
+		for _, alert := range data.Alerts {
+			if value := alert.Annotations[name]; len(value) > 0 {
+				return value
+			}
+		}
+	}
+
+	if alertName := data.CommonLabels["alertname"]; len(alertName) > 0 {
+		return alertName
+	}
+	return data.GroupLabels["alertname"]
+}
+
 func getGroupKey(data template.Data) string {
+	if isPerAlert(data) && len(data.Alerts) == 1 && len(data.Alerts[0].Fingerprint) > 0 {
+		return data.Alerts[0].Fingerprint
+	}
 	hash := md5.Sum([]byte(fmt.Sprintf("%v", data.GroupLabels.SortedPairs())))
 	return fmt.Sprintf("%x", hash)
 }
 
-func applyIncidentTemplate(incident Incident, data template.Data) {
+// getGroupLabelsJSON returns the group labels as canonical JSON, with sorted keys
+func getGroupLabelsJSON(data template.Data) string {
+	groupLabels := data.GroupLabels
+	if groupLabels == nil {
+		groupLabels = template.KV{}
+	}
+	content, _ := json.Marshal(groupLabels)
+	return string(content)
+}
+
+func applyIncidentTemplate(ctx context.Context, incident Incident, data template.Data) {
+	data = capRenderedAlerts(normalizeAlertTimes(ctx, data))
 	for key, val := range incident {
 		var err error
 		incident[key], err = applyTemplate(key, val.(string), data)
@@ -435,7 +1898,11 @@ func applyIncidentTemplate(incident Incident, data template.Data) {
 }
 
 func applyTemplate(name string, text string, data template.Data) (string, error) {
-	tmpl, err := tmpltext.New(name).Parse(text)
+	tmpl, err := newFieldTemplate(name, data.Receiver)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err = tmpl.Parse(text)
 	if err != nil {
 		return "", err
 	}
@@ -449,9 +1916,22 @@ func applyTemplate(name string, text string, data template.Data) (string, error)
 	return result.String(), nil
 }
 
+// isDisplayValueField returns true if the field is written to ServiceNow as a display value
+func isDisplayValueField(field string) bool {
+	if config.ServiceNow.InputDisplayValue {
+		return true
+	}
+	for _, f := range config.ServiceNow.DisplayValueFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
 func validateIncident(incident Incident) error {
 	var str strings.Builder
-	if impact, ok := incident["impact"]; ok && impact != nil && len(impact.(string)) > 0 {
+	if impact, ok := incident["impact"]; ok && impact != nil && len(impact.(string)) > 0 && !isDisplayValueField("impact") {
 		if _, err := strconv.Atoi(impact.(string)); err != nil {
 			str.WriteString("'impact' field value is ")
 			str.WriteString(impact.(string))
@@ -459,7 +1939,7 @@ func validateIncident(incident Incident) error {
 		}
 	}
 
-	if urgency, ok := incident["urgency"]; ok && urgency != nil && len(urgency.(string)) > 0 {
+	if urgency, ok := incident["urgency"]; ok && urgency != nil && len(urgency.(string)) > 0 && !isDisplayValueField("urgency") {
 		if _, err := strconv.Atoi(urgency.(string)); err != nil {
 			str.WriteString("'urgency' field value is ")
 			str.WriteString(urgency.(string))
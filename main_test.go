@@ -2,15 +2,20 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -28,6 +33,21 @@ func (mock *MockedSnClient) GetIncidents(params map[string]string) ([]Incident,
 	return args.Get(0).([]Incident), args.Error(1)
 }
 
+func (mock *MockedSnClient) GetRecords(table string, params map[string]string) ([]Incident, error) {
+	args := mock.Called(table, params)
+	return args.Get(0).([]Incident), args.Error(1)
+}
+
+func (mock *MockedSnClient) CreateRecord(table string, recordParam Incident) (Incident, error) {
+	args := mock.Called(table, recordParam)
+	return args.Get(0).(Incident), args.Error(1)
+}
+
+func (mock *MockedSnClient) AttachFile(table string, sysID string, fileName string, contentType string, content []byte) error {
+	args := mock.Called(table, sysID, fileName, contentType, content)
+	return args.Error(0)
+}
+
 func (mock *MockedSnClient) UpdateIncident(incidentParam Incident, sysID string) (Incident, error) {
 	args := mock.Called(incidentParam, sysID)
 	return args.Get(0).(Incident), args.Error(1)
@@ -63,6 +83,7 @@ func TestWebhookHandler_Firing_DoNotExists_OK(t *testing.T) {
 
 	// Create a request to pass to the handler
 	req := httptest.NewRequest("GET", "/webhook", bytes.NewReader(data))
+	req.Header.Set(requestIDHeader, "test-request")
 
 	// Create a ResponseRecorder (which satisfies http.ResponseWriter) to record the response
 	rr := httptest.NewRecorder()
@@ -75,7 +96,7 @@ func TestWebhookHandler_Firing_DoNotExists_OK(t *testing.T) {
 		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusOK)
 	}
 
-	want := `{"Status":200,"Message":"Success"}`
+	want := `{"Status":200,"Message":"Success","RequestID":"test-request"}`
 	if rr.Body.String() != want {
 		t.Errorf("Unexpected body: got %v, want %v", rr.Body.String(), want)
 	}
@@ -97,6 +118,7 @@ func TestWebhookHandler_Firing_Exists_Create_OK(t *testing.T) {
 
 	// Create a request to pass to the handler
 	req := httptest.NewRequest("GET", "/webhook", bytes.NewReader(data))
+	req.Header.Set(requestIDHeader, "test-request")
 
 	// Create a ResponseRecorder (which satisfies http.ResponseWriter) to record the response
 	rr := httptest.NewRecorder()
@@ -109,7 +131,7 @@ func TestWebhookHandler_Firing_Exists_Create_OK(t *testing.T) {
 		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusOK)
 	}
 
-	want := `{"Status":200,"Message":"Success"}`
+	want := `{"Status":200,"Message":"Success","RequestID":"test-request"}`
 	if rr.Body.String() != want {
 		t.Errorf("Unexpected body: got %v, want %v", rr.Body.String(), want)
 	}
@@ -139,6 +161,7 @@ func TestWebhookHandler_Firing_Exists_Update_OK(t *testing.T) {
 
 	// Create a request to pass to the handler
 	req := httptest.NewRequest("GET", "/webhook", bytes.NewReader(data))
+	req.Header.Set(requestIDHeader, "test-request")
 
 	// Create a ResponseRecorder (which satisfies http.ResponseWriter) to record the response
 	rr := httptest.NewRecorder()
@@ -151,12 +174,88 @@ func TestWebhookHandler_Firing_Exists_Update_OK(t *testing.T) {
 		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusOK)
 	}
 
-	want := `{"Status":200,"Message":"Success"}`
+	want := `{"Status":200,"Message":"Success","RequestID":"test-request"}`
 	if rr.Body.String() != want {
 		t.Errorf("Unexpected body: got %v, want %v", rr.Body.String(), want)
 	}
 }
 
+func TestWebhookHandler_Firing_Reopen_OK(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.ReopenWindow = time.Hour
+	config.Workflow.ReopenState = "2"
+	incidentUpdateFields = map[string]bool{
+		"comments": true,
+	}
+	now = func() time.Time { return time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{
+		Incident{"state": "6", "number": "INC41", "sys_id": "41", "resolved_at": "2020-01-01 09:00:00"},
+		Incident{"state": "6", "number": "INC42", "sys_id": "42", "resolved_at": "2020-01-01 11:30:00"},
+	}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{}, errors.New("Create should not be called"))
+	snClientMock.On("UpdateIncident", mock.Anything, "42").Run(func(args mock.Arguments) {
+		incident := args.Get(0).(Incident)
+		if incident["state"] != "2" {
+			t.Errorf("Wrong incident state: got %v, want %v", incident["state"], "2")
+		}
+	}).Return(Incident{}, nil)
+
+	// Load a simple example of a body coming from AlertManager
+	data, err := ioutil.ReadFile("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a request to pass to the handler
+	req := httptest.NewRequest("GET", "/webhook", bytes.NewReader(data))
+
+	// Create a ResponseRecorder (which satisfies http.ResponseWriter) to record the response
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(webhook)
+
+	// Test the handler with the request and record the result
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusOK)
+	}
+	snClientMock.AssertCalled(t, "UpdateIncident", mock.Anything, "42")
+}
+
+func TestFindReopenableIncident_OutsideWindow(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.ReopenWindow = time.Hour
+	now = func() time.Time { return time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	incidents := []Incident{
+		Incident{"state": "6", "number": "INC42", "sys_id": "42", "resolved_at": "2020-01-01 10:30:00"},
+		Incident{"state": "7", "number": "INC43", "sys_id": "43"},
+	}
+	if got := findReopenableIncident(incidents); got != nil {
+		t.Errorf("Unexpected reopenable incident: got %v, want nil", got)
+	}
+}
+
+func TestFindReopenableIncident_WithoutResolutionTime(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.ReopenWindow = time.Hour
+	now = func() time.Time { return time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	// The last update time is not used as resolution time
+	incidents := []Incident{
+		Incident{"state": "6", "number": "INC42", "sys_id": "42", "sys_updated_on": "2020-01-01 11:30:00"},
+	}
+	if got := findReopenableIncident(incidents); got != nil {
+		t.Errorf("Unexpected reopenable incident: got %v, want nil", got)
+	}
+}
+
 func TestWebhookHandler_Resolved_DoNotExists_OK(t *testing.T) {
 	loadConfig("config/servicenow_example.yml")
 	snClientMock := new(MockedSnClient)
@@ -173,6 +272,7 @@ func TestWebhookHandler_Resolved_DoNotExists_OK(t *testing.T) {
 
 	// Create a request to pass to the handler
 	req := httptest.NewRequest("GET", "/webhook", bytes.NewReader(data))
+	req.Header.Set(requestIDHeader, "test-request")
 
 	// Create a ResponseRecorder (which satisfies http.ResponseWriter) to record the response
 	rr := httptest.NewRecorder()
@@ -185,7 +285,7 @@ func TestWebhookHandler_Resolved_DoNotExists_OK(t *testing.T) {
 		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusOK)
 	}
 
-	want := `{"Status":200,"Message":"Success"}`
+	want := `{"Status":200,"Message":"Success","RequestID":"test-request"}`
 	if rr.Body.String() != want {
 		t.Errorf("Unexpected body: got %v, want %v", rr.Body.String(), want)
 	}
@@ -207,6 +307,7 @@ func TestWebhookHandler_Resolved_Exists_OK(t *testing.T) {
 
 	// Create a request to pass to the handler
 	req := httptest.NewRequest("GET", "/webhook", bytes.NewReader(data))
+	req.Header.Set(requestIDHeader, "test-request")
 
 	// Create a ResponseRecorder (which satisfies http.ResponseWriter) to record the response
 	rr := httptest.NewRecorder()
@@ -219,7 +320,7 @@ func TestWebhookHandler_Resolved_Exists_OK(t *testing.T) {
 		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusOK)
 	}
 
-	want := `{"Status":200,"Message":"Success"}`
+	want := `{"Status":200,"Message":"Success","RequestID":"test-request"}`
 	if rr.Body.String() != want {
 		t.Errorf("Unexpected body: got %v, want %v", rr.Body.String(), want)
 	}
@@ -230,6 +331,7 @@ func TestWebhookHandler_BadRequest(t *testing.T) {
 
 	// Create a request to pass to the handler
 	req := httptest.NewRequest("GET", "/webhook", nil)
+	req.Header.Set(requestIDHeader, "test-request")
 
 	// Create a ResponseRecorder (which satisfies http.ResponseWriter) to record the response.
 	rr := httptest.NewRecorder()
@@ -242,7 +344,7 @@ func TestWebhookHandler_BadRequest(t *testing.T) {
 		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusBadRequest)
 	}
 
-	want := `{"Status":400,"Message":"EOF"}`
+	want := `{"Status":400,"Message":"EOF","RequestID":"test-request"}`
 	if rr.Body.String() != want {
 		t.Errorf("Unexpected body: got %v, want %v", rr.Body.String(), want)
 	}
@@ -263,6 +365,7 @@ func TestWebhookHandler_InternalServerError(t *testing.T) {
 
 	// Create a request to pass to the handler
 	req := httptest.NewRequest("GET", "/webhook", bytes.NewReader(data))
+	req.Header.Set(requestIDHeader, "test-request")
 
 	// Create a ResponseRecorder (which satisfies http.ResponseWriter) to record the response
 	rr := httptest.NewRecorder()
@@ -271,17 +374,46 @@ func TestWebhookHandler_InternalServerError(t *testing.T) {
 	// Test the handler with the request and record the result
 	handler.ServeHTTP(rr, req)
 
-	if status := rr.Code; status != http.StatusInternalServerError {
-		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusInternalServerError)
+	// A failed creation is reported as a bad gateway, Alertmanager retrying it
+	if status := rr.Code; status != http.StatusBadGateway {
+		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusBadGateway)
 	}
 
 	// Check the response body
-	want := `{"Status":500,"Message":"Error"}`
+	want := `{"Status":502,"Message":"incident create failed: Error","RequestID":"test-request"}`
 	if rr.Body.String() != want {
 		t.Errorf("Unexpected body: got %v, want %v", rr.Body.String(), want)
 	}
 }
 
+func TestWebhookHandler_DeadLetter(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{}, &serviceNowHTTPError{statusCode: http.StatusBadRequest})
+
+	data, err := ioutil.ReadFile("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+	req := httptest.NewRequest("GET", "/webhook", bytes.NewReader(data))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(webhook).ServeHTTP(rr, req)
+
+	// Client errors must not be retried by Alertmanager
+	if status := rr.Code; status != http.StatusUnprocessableEntity {
+		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusUnprocessableEntity)
+	}
+	// Without archiver, the dead-lettered payload is logged to be replayed
+	if !strings.Contains(output.String(), "Dead-lettered payload of alert group key") || !strings.Contains(output.String(), "receiver") {
+		t.Errorf("Dead-lettered payload must be logged, got: %s", output.String())
+	}
+}
+
 func TestApplyTemplate_emptyText(t *testing.T) {
 	data := template.Data{}
 	text := ""
@@ -323,7 +455,7 @@ func TestApplyIncidentTemplate_Range(t *testing.T) {
 	incident := Incident{
 		"description": "{{ range $key, $val := .CommonAnnotations}}{{ $key }}:{{ $val }} {{end}}",
 	}
-	applyIncidentTemplate(incident, data)
+	applyIncidentTemplate(context.Background(), incident, data)
 
 	got := incident["description"]
 	want := "error:a warning:b "
@@ -470,3 +602,162 @@ func Test_validateIncident(t *testing.T) {
 		})
 	}
 }
+
+func Test_selectShortDescription(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.ShortDescriptionAnnotations = []string{"summary", "message"}
+
+	tests := []struct {
+		name string
+		data template.Data
+		want string
+	}{
+		{
+			name: "common_summary",
+			data: template.Data{
+				CommonAnnotations: template.KV{"summary": "common summary", "message": "common message"},
+				Alerts:            template.Alerts{{Annotations: template.KV{"summary": "alert summary"}}},
+			},
+			want: "common summary",
+		},
+		{
+			name: "alert_summary",
+			data: template.Data{
+				CommonAnnotations: template.KV{"message": "common message"},
+				Alerts:            template.Alerts{{Annotations: template.KV{"summary": "alert summary"}}},
+			},
+			want: "alert summary",
+		},
+		{
+			name: "message",
+			data: template.Data{
+				CommonAnnotations: template.KV{"message": "common message"},
+			},
+			want: "common message",
+		},
+		{
+			name: "alertname",
+			data: template.Data{
+				CommonLabels: template.KV{"alertname": "TestAlert"},
+			},
+			want: "TestAlert",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectShortDescription(tt.data); got != tt.want {
+				t.Errorf("selectShortDescription() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_renderIncident_GroupLabelsField(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.GroupLabelsField = "u_group_labels"
+
+	data := template.Data{GroupLabels: template.KV{"service": "db", "alertname": "{{ test }}"}}
+	incident := renderIncident(context.Background(), data, config.DefaultIncident)
+
+	want := `{"alertname":"{{ test }}","service":"db"}`
+	if incident["u_group_labels"] != want {
+		t.Errorf("Unexpected group labels field: got %v, want %v", incident["u_group_labels"], want)
+	}
+	if incident[config.Workflow.IncidentGroupKeyField] != getGroupKey(data) {
+		t.Errorf("Group key hash must still be set")
+	}
+}
+
+func Test_getSeverity(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	data := template.Data{CommonLabels: template.KV{"severity": "critical", "priority": "P1"}}
+	if severity := getSeverity(data); severity != "critical" {
+		t.Errorf("Unexpected severity: got %v, want %v", severity, "critical")
+	}
+
+	config.Metrics.SeverityLabel = "priority"
+	if severity := getSeverity(data); severity != "P1" {
+		t.Errorf("Unexpected severity: got %v, want %v", severity, "P1")
+	}
+	if severity := getSeverity(template.Data{}); severity != "none" {
+		t.Errorf("Unexpected severity: got %v, want %v", severity, "none")
+	}
+}
+
+func Test_observeIncidentAction_LastIncidentCreated(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	now = func() time.Time { return time.Unix(1577880000, 0) }
+	defer func() { now = time.Now }()
+
+	observeIncidentAction(context.Background(), template.Data{CommonLabels: template.KV{"severity": "sev1"}}, Incident{}, "create", "INC42", nil)
+
+	if got := testutil.ToFloat64(webhookLastIncidentCreated.WithLabelValues("sev1")); got != 1577880000 {
+		t.Errorf("Unexpected last incident created timestamp: got %v, want %v", got, 1577880000)
+	}
+}
+
+func Test_allowlistedLabelValue(t *testing.T) {
+	allowlist := []string{"admins", "dba"}
+	if got := allowlistedLabelValue("dba", allowlist); got != "dba" {
+		t.Errorf("allowlistedLabelValue() = %v, want %v", got, "dba")
+	}
+	if got := allowlistedLabelValue("netops", allowlist); got != "other" {
+		t.Errorf("allowlistedLabelValue() = %v, want %v", got, "other")
+	}
+	if got := allowlistedLabelValue("dba", nil); got != "other" {
+		t.Errorf("allowlistedLabelValue() = %v, want %v", got, "other")
+	}
+}
+
+func Test_applyOnHold(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.OnHold = OnHoldConfig{
+		SilencedLabel: "silenced",
+		State:         "3",
+		HoldReason:    "1",
+		ResumeState:   "2",
+	}
+
+	silenced := template.Data{Alerts: template.Alerts{
+		{Status: "firing", Labels: template.KV{"silenced": "true"}},
+	}}
+	unsilenced := template.Data{Alerts: template.Alerts{
+		{Status: "firing", Labels: template.KV{"silenced": "true"}},
+		{Status: "firing", Labels: template.KV{}},
+	}}
+
+	tests := []struct {
+		name     string
+		data     template.Data
+		incident Incident
+		want     Incident
+	}{
+		{
+			name:     "hold",
+			data:     silenced,
+			incident: Incident{"state": "2", "number": "INC42"},
+			want:     Incident{"state": "3", "hold_reason": "1"},
+		},
+		{
+			name:     "resume",
+			data:     unsilenced,
+			incident: Incident{"state": "3", "number": "INC42"},
+			want:     Incident{"state": "2"},
+		},
+		{
+			name:     "unchanged",
+			data:     unsilenced,
+			incident: Incident{"state": "2", "number": "INC42"},
+			want:     Incident{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Incident{}
+			applyOnHold(context.Background(), tt.data, tt.incident, got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyOnHold(context.Background()) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template/parse"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var describeMappingCommand = kingpin.Command("describe-mapping", "Print which alert fields feed which ServiceNow incident fields, through which mapping steps and transforms.")
+
+// fieldMapping describes how an incident field is rendered from the alert group
+type fieldMapping struct {
+	Field string
+	// Alert group data referenced, e.g. .CommonLabels.severity
+	Sources map[string]bool
+	// Mapping steps, in the order they are applied
+	Steps      []string
+	Transforms []string
+}
+
+// describeMapping returns the mapping of each incident field of the loaded configuration, sorted by field
+func describeMapping() []*fieldMapping {
+	mappings := make(map[string]*fieldMapping)
+	mapping := func(field string) *fieldMapping {
+		if _, ok := mappings[field]; !ok {
+			mappings[field] = &fieldMapping{Field: field, Sources: make(map[string]bool)}
+		}
+		return mappings[field]
+	}
+	step := func(field string, name string, sources ...string) {
+		m := mapping(field)
+		m.Steps = append(m.Steps, name)
+		for _, source := range sources {
+			m.Sources[source] = true
+		}
+	}
+	templateStep := func(field string, name string, text string) {
+		step(field, name, templateSources(field, text)...)
+	}
+
+	// Steps follow the order of renderIncident and alertGroupToIncident
+	step("caller_id", "service_now.user_name")
+	step(config.Workflow.IncidentGroupKeyField, "group key", ".GroupLabels")
+	if source := config.Workflow.Source; source.enabled() {
+		step(source.Field, "source")
+	}
+	for field, text := range config.DefaultIncident {
+		templateStep(field, "default_incident", text)
+	}
+	for _, value := range config.Profiles.values() {
+		for field, text := range config.Profiles.Profiles[value].DefaultIncident {
+			templateStep(field, "profiles "+value, text)
+			mapping(field).Sources[".CommonLabels."+config.Profiles.Label] = true
+		}
+	}
+	for _, name := range sortedKeys(config.TemplateSets.Sets) {
+		for field, text := range config.TemplateSets.Sets[name].DefaultIncident {
+			templateStep(field, "template_sets "+name, text)
+		}
+	}
+	for _, variant := range config.TemplateVariants.Variants {
+		for field, text := range variant.DefaultIncident {
+			templateStep(field, "template_variants "+variant.Name, text)
+		}
+	}
+	if len(config.TemplateVariants.Field) > 0 {
+		step(config.TemplateVariants.Field, "template_variants name", ".GroupLabels")
+	}
+	severityLabel := ".CommonLabels." + config.SeverityMapping.label()
+	for _, level := range config.SeverityMapping.Levels {
+		for field, text := range level.Fields {
+			templateStep(field, "severity_mapping "+level.Value, text)
+			mapping(field).Sources[severityLabel] = true
+		}
+	}
+	for field, rules := range fieldRules {
+		var labels []string
+		for _, rule := range rules {
+			for _, matcher := range rule.matchers {
+				labels = append(labels, ".CommonLabels."+matcher.name)
+			}
+		}
+		step(field, fmt.Sprintf("field_rules (%d)", len(rules)), labels...)
+	}
+	for field, m := range config.FieldMappings {
+		switch {
+		case len(m.Label) > 0:
+			step(field, "field_mappings", ".CommonLabels."+m.Label)
+		case len(m.Annotation) > 0:
+			step(field, "field_mappings", ".CommonAnnotations."+m.Annotation)
+		default:
+			templateStep(field, "field_mappings", m.Template)
+		}
+	}
+	if len(config.AnnotationFields.Annotations) > 0 {
+		var sources []string
+		for _, annotation := range config.AnnotationFields.Annotations {
+			sources = append(sources, ".CommonAnnotations."+annotation)
+		}
+		for _, field := range config.AnnotationFields.AllowedFields {
+			step(field, "annotation_fields", sources...)
+		}
+	}
+	if details := config.Workflow.AlertDetails; details.enabled() {
+		var sources []string
+		if len(details.Annotation) > 0 {
+			sources = append(sources, ".CommonAnnotations."+details.Annotation)
+		}
+		step(alertDetailsComments, "alert_details", sources...)
+		step(alertDetailsWorkNotes, "alert_details", sources...)
+	}
+	if config.Knowledge.Enabled {
+		field := config.Knowledge.LinkField
+		if len(field) == 0 {
+			field = defaultRunbookLinkField
+		}
+		annotation := config.Knowledge.Annotation
+		if len(annotation) == 0 {
+			annotation = defaultRunbookAnnotation
+		}
+		step(field, "knowledge runbook links", ".Alerts.Annotations."+annotation)
+	}
+	if len(config.Workflow.GroupLabelsField) > 0 {
+		step(config.Workflow.GroupLabelsField, "group_labels_field", ".GroupLabels")
+	}
+	if len(config.Workflow.ShortDescriptionAnnotations) > 0 {
+		sources := []string{".CommonLabels.alertname"}
+		for _, annotation := range config.Workflow.ShortDescriptionAnnotations {
+			sources = append(sources, ".CommonAnnotations."+annotation, ".Alerts.Annotations."+annotation)
+		}
+		step("short_description", "short_description_annotations", sources...)
+	}
+	for field, transforms := range config.FieldTransforms {
+		for _, transform := range transforms {
+			mapping(field).Transforms = append(mapping(field).Transforms, describeTransform(transform))
+		}
+	}
+	for field, format := range config.FieldFormats {
+		mapping(field).Transforms = append(mapping(field).Transforms, "format("+format+")")
+	}
+	if label := config.Workflow.AssignmentGroupOverride.Label; len(label) > 0 {
+		step("assignment_group", "assignment_group_override", ".CommonLabels."+label, ".CommonAnnotations."+label)
+	}
+
+	fields := make([]*fieldMapping, 0, len(mappings))
+	for _, m := range mappings {
+		fields = append(fields, m)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
+}
+
+// describeTransform returns a short description of a field transform
+func describeTransform(t TransformConfig) string {
+	switch t.Type {
+	case "truncate":
+		return fmt.Sprintf("truncate(%d)", t.Length)
+	case "regex_replace":
+		return fmt.Sprintf("regex_replace(%q)", t.Regex)
+	case "prefix", "suffix":
+		return fmt.Sprintf("%s(%q)", t.Type, t.Value)
+	case "map":
+		return fmt.Sprintf("map(%d values)", len(t.Values))
+	}
+	return t.Type
+}
+
+// templateSources returns the alert group data referenced by a field template, sorted
+func templateSources(name string, text string) []string {
+	tmpl, err := newFieldTemplate(name, "")
+	if err == nil {
+		tmpl, err = tmpl.Parse(text)
+	}
+	if err != nil {
+		return nil
+	}
+	sources := make(map[string]bool)
+	visited := make(map[string]bool)
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			args := n.Args
+			if indexSource(n, sources) {
+				args = args[3:]
+			}
+			for _, arg := range args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			sources["."+strings.Join(n.Ident, ".")] = true
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+			// Shared templates of template_files are described along the field
+			if included := tmpl.Lookup(n.Name); included != nil && !visited[n.Name] {
+				visited[n.Name] = true
+				walk(included.Tree.Root)
+			}
+		}
+	}
+	walk(tmpl.Tree.Root)
+
+	names := make([]string, 0, len(sources))
+	for source := range sources {
+		names = append(names, source)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// indexSource adds the key read with index, e.g. index .CommonLabels "team", returning false if the command
+// does not index a field with a constant key
+func indexSource(cmd *parse.CommandNode, sources map[string]bool) bool {
+	if len(cmd.Args) < 3 {
+		return false
+	}
+	if ident, ok := cmd.Args[0].(*parse.IdentifierNode); !ok || ident.Ident != "index" {
+		return false
+	}
+	field, ok := cmd.Args[1].(*parse.FieldNode)
+	if !ok {
+		return false
+	}
+	key, ok := cmd.Args[2].(*parse.StringNode)
+	if !ok {
+		return false
+	}
+	sources["."+strings.Join(field.Ident, ".")+"."+key.Text] = true
+	return true
+}
+
+// sortedKeys returns the sorted names of the template sets
+func sortedKeys(sets map[string]TemplateSetConfig) []string {
+	names := make([]string, 0, len(sets))
+	for name := range sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeMapping writes the field mappings as a table
+func writeMapping(w io.Writer, mappings []*fieldMapping) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tALERT DATA\tMAPPING\tTRANSFORMS")
+	for _, m := range mappings {
+		sources := make([]string, 0, len(m.Sources))
+		for source := range m.Sources {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m.Field, orNone(strings.Join(sources, ", ")), orNone(strings.Join(m.Steps, " > ")), orNone(strings.Join(m.Transforms, " > ")))
+	}
+	tw.Flush()
+	if len(redactionRules) > 0 {
+		fmt.Fprintf(w, "\n%d redaction rule(s) applied to all fields.\n", len(redactionRules))
+	}
+}
+
+func orNone(value string) string {
+	if len(value) == 0 {
+		return "-"
+	}
+	return value
+}
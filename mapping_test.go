@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDescribeMapping(t *testing.T) {
+	configFile := `
+service_now:
+ instance_name: "instance"
+ user_name: "SA"
+ password: "SA!"
+workflow:
+ incident_group_key_field: "u_other_reference_1"
+default_incident:
+ short_description: "{{ .CommonLabels.alertname }} on {{ index .CommonLabels \"instance\" }}"
+ description: "{{ range .Alerts }}{{ .Annotations.summary }}{{ end }}"
+ impact: "2"
+field_rules:
+ assignment_group:
+  - if: 'team="db"'
+    value: "DBA"
+field_transforms:
+ short_description:
+  - type: trim
+  - type: truncate
+    length: 80
+severity_mapping:
+ levels:
+  - value: critical
+    fields:
+     urgency: "1"
+`
+	defer loadConfig("config/servicenow_example.yml")
+	if _, err := loadConfigContent([]byte(configFile)); err != nil {
+		t.Fatal(err)
+	}
+
+	mappings := make(map[string]*fieldMapping)
+	for _, m := range describeMapping() {
+		mappings[m.Field] = m
+	}
+	tests := []struct {
+		field      string
+		sources    []string
+		steps      []string
+		transforms []string
+	}{
+		{"short_description", []string{".CommonLabels.alertname", ".CommonLabels.instance"}, []string{"default_incident"}, []string{"trim", "truncate(80)"}},
+		{"description", []string{".Alerts", ".Annotations.summary"}, []string{"default_incident"}, nil},
+		{"impact", nil, []string{"default_incident"}, nil},
+		{"assignment_group", []string{".CommonLabels.team"}, []string{"field_rules (1)"}, nil},
+		{"urgency", []string{".CommonLabels.severity"}, []string{"severity_mapping critical"}, nil},
+		{"u_other_reference_1", []string{".GroupLabels"}, []string{"group key"}, nil},
+	}
+	for _, test := range tests {
+		m, ok := mappings[test.field]
+		if !ok {
+			t.Errorf("Missing mapping of field %s", test.field)
+			continue
+		}
+		if len(m.Sources) != len(test.sources) {
+			t.Errorf("Unexpected sources of field %s: got %v, want %v", test.field, m.Sources, test.sources)
+		}
+		for _, source := range test.sources {
+			if !m.Sources[source] {
+				t.Errorf("Missing source %s of field %s, got %v", source, test.field, m.Sources)
+			}
+		}
+		if strings.Join(m.Steps, ",") != strings.Join(test.steps, ",") {
+			t.Errorf("Unexpected steps of field %s: got %v, want %v", test.field, m.Steps, test.steps)
+		}
+		if strings.Join(m.Transforms, ",") != strings.Join(test.transforms, ",") {
+			t.Errorf("Unexpected transforms of field %s: got %v, want %v", test.field, m.Transforms, test.transforms)
+		}
+	}
+
+	var out bytes.Buffer
+	writeMapping(&out, describeMapping())
+	if !strings.Contains(out.String(), "short_description") || !strings.HasPrefix(out.String(), "FIELD") {
+		t.Errorf("Unexpected mapping table:\n%s", out.String())
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MigrationConfig - Dual-write of incidents to a new target (table and/or instance) until a given
+// time, comparing its results with the current target to de-risk a migration
+type MigrationConfig struct {
+	// Instance of the new target, the current instance is used if instance_name is not set
+	ServiceNow ServiceNowConfig `yaml:"service_now"`
+	// Incident table of the new target
+	Table string `yaml:"table"`
+	// End of the dual-write period, dual-write is disabled when not set
+	Until time.Time `yaml:"until"`
+}
+
+// enabled returns true during the dual-write period
+func (c MigrationConfig) enabled() bool {
+	return !c.Until.IsZero() && now().Before(c.Until)
+}
+
+// dualWriteServiceNow writes incidents to the current target, and mirrors lookups, creations and updates
+// on the new target. The new target never affects the results, its divergences are logged and counted.
+type dualWriteServiceNow struct {
+	primary   ServiceNow
+	secondary ServiceNow
+	// Shared by the request scoped copies of the client
+	*dualWriteState
+}
+
+// dualWriteState matches the incidents of both targets
+type dualWriteState struct {
+	mu sync.Mutex
+	// Group key field value of the primary incidents, by sys_id
+	groupKeys map[string]string
+	// Secondary incidents, by group key field value
+	secondaryIncidents map[string][]Incident
+}
+
+func newDualWriteServiceNow(primary ServiceNow, secondary ServiceNow) *dualWriteServiceNow {
+	return &dualWriteServiceNow{
+		primary:   primary,
+		secondary: secondary,
+		dualWriteState: &dualWriteState{
+			groupKeys:          make(map[string]string),
+			secondaryIncidents: make(map[string][]Incident),
+		},
+	}
+}
+
+// withContext returns the client sending the requests of both targets with the request ID of the context
+func (d *dualWriteServiceNow) withContext(ctx context.Context) ServiceNow {
+	return &dualWriteServiceNow{
+		primary:        withRequestScope(ctx, d.primary),
+		secondary:      withRequestScope(ctx, d.secondary),
+		dualWriteState: d.dualWriteState,
+	}
+}
+
+// newMigrationServiceNowClient creates the client of the new target
+func newMigrationServiceNowClient(c MigrationConfig, current ServiceNowConfig) (*ServiceNowClient, error) {
+	snConfig := c.ServiceNow
+	if len(snConfig.InstanceName) == 0 {
+		snConfig = current
+	}
+	snClient, err := newServiceNowClientFromConfig(snConfig)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Table) > 0 {
+		snClient.incidentTable = c.Table
+	}
+	return snClient, nil
+}
+
+// divergence logs and counts a difference between the current and the new target
+func (d *dualWriteServiceNow) divergence(kind string, format string, args ...interface{}) {
+	webhookMigrationDivergences.WithLabelValues(kind).Inc()
+	log.Warnf("Migration divergence (%s): %s", kind, fmt.Sprintf(format, args...))
+}
+
+// mirror returns true if the new target must be written
+func (d *dualWriteServiceNow) mirror() bool {
+	return config.Migration.enabled()
+}
+
+// CreateIncident creates the incident on both targets
+func (d *dualWriteServiceNow) CreateIncident(incidentParam Incident) (Incident, error) {
+	incident, err := d.primary.CreateIncident(incidentParam)
+	if !d.mirror() {
+		return incident, err
+	}
+
+	secondaryIncident, secondaryErr := d.secondary.CreateIncident(incidentParam)
+	d.compareResults("create", incidentParam, incident, err, secondaryIncident, secondaryErr)
+	if err == nil && secondaryErr == nil {
+		groupKey, _ := incidentParam[config.Workflow.IncidentGroupKeyField].(string)
+		d.mu.Lock()
+		d.groupKeys[incident.GetSysID()] = groupKey
+		d.secondaryIncidents[groupKey] = append(d.secondaryIncidents[groupKey], secondaryIncident)
+		d.mu.Unlock()
+	}
+	return incident, err
+}
+
+// GetIncidents looks up the incidents on both targets
+func (d *dualWriteServiceNow) GetIncidents(params map[string]string) ([]Incident, error) {
+	incidents, err := d.primary.GetIncidents(params)
+	groupKey, ok := params[config.Workflow.IncidentGroupKeyField]
+	if !d.mirror() || !ok {
+		return incidents, err
+	}
+
+	secondaryIncidents, secondaryErr := d.secondary.GetIncidents(params)
+	if (err == nil) != (secondaryErr == nil) {
+		d.divergence("lookup_result", "group key field %s: current error %v, new error %v", groupKey, err, secondaryErr)
+	} else if err == nil && len(filterUpdatableIncidents(incidents)) != len(filterUpdatableIncidents(secondaryIncidents)) {
+		d.divergence("lookup_updatable", "group key field %s: %d updatable current incident(s), %d updatable new incident(s)",
+			groupKey, len(filterUpdatableIncidents(incidents)), len(filterUpdatableIncidents(secondaryIncidents)))
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, incident := range incidents {
+		d.groupKeys[incident.GetSysID()] = groupKey
+	}
+	if secondaryErr == nil {
+		d.secondaryIncidents[groupKey] = secondaryIncidents
+	}
+	return incidents, err
+}
+
+// GetRecords looks up records on the current target only
+func (d *dualWriteServiceNow) GetRecords(table string, params map[string]string) ([]Incident, error) {
+	return d.primary.GetRecords(table, params)
+}
+
+// CreateRecord creates the record on the current target only
+func (d *dualWriteServiceNow) CreateRecord(table string, recordParam Incident) (Incident, error) {
+	return d.primary.CreateRecord(table, recordParam)
+}
+
+// UpdateIncident updates the incident on both targets, the new target incident being the updatable
+// one found or created for the same group key
+func (d *dualWriteServiceNow) UpdateIncident(incidentParam Incident, sysID string) (Incident, error) {
+	incident, err := d.primary.UpdateIncident(incidentParam, sysID)
+	if !d.mirror() {
+		return incident, err
+	}
+
+	d.mu.Lock()
+	groupKey, ok := d.groupKeys[sysID]
+	var secondarySysID string
+	if ok {
+		if updatable := filterUpdatableIncidents(d.secondaryIncidents[groupKey]); len(updatable) > 0 {
+			secondarySysID = updatable[0].GetSysID()
+		} else if incidents := d.secondaryIncidents[groupKey]; len(incidents) > 0 {
+			secondarySysID = incidents[len(incidents)-1].GetSysID()
+		}
+	}
+	d.mu.Unlock()
+	if len(secondarySysID) == 0 {
+		d.divergence("missing_incident", "no new incident matches current incident %s", sysID)
+		return incident, err
+	}
+
+	secondaryIncident, secondaryErr := d.secondary.UpdateIncident(incidentParam, secondarySysID)
+	d.compareResults("update", incidentParam, incident, err, secondaryIncident, secondaryErr)
+	return incident, err
+}
+
+// AttachFile attaches the file on the current target only
+func (d *dualWriteServiceNow) AttachFile(table string, sysID string, fileName string, contentType string, content []byte) error {
+	return d.primary.AttachFile(table, sysID, fileName, contentType, content)
+}
+
+// probeCapabilities probes the current target
+func (d *dualWriteServiceNow) probeCapabilities() map[string]bool {
+	if prober, ok := d.primary.(interface{ probeCapabilities() map[string]bool }); ok {
+		return prober.probeCapabilities()
+	}
+	return nil
+}
+
+// compareResults compares the outcome and the written fields of an action on both targets
+func (d *dualWriteServiceNow) compareResults(action string, incidentParam Incident, incident Incident, err error, secondaryIncident Incident, secondaryErr error) {
+	if (err == nil) != (secondaryErr == nil) {
+		d.divergence(action+"_result", "current error %v, new error %v", err, secondaryErr)
+		return
+	}
+	if err != nil {
+		return
+	}
+	for field := range incidentParam {
+		value, ok := incident[field]
+		secondaryValue, secondaryOk := secondaryIncident[field]
+		if ok && secondaryOk && fmt.Sprint(value) != fmt.Sprint(secondaryValue) {
+			d.divergence(action+"_field", "field %s: current %v, new %v", field, value, secondaryValue)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDualWriteServiceNow(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Migration.Until = time.Now().Add(time.Hour)
+	groupKeyField := config.Workflow.IncidentGroupKeyField
+
+	primary := new(MockedSnClient)
+	secondary := new(MockedSnClient)
+	primary.On("GetIncidents", mock.Anything).Return([]Incident{{"sys_id": "p1", "state": "1"}}, nil)
+	secondary.On("GetIncidents", mock.Anything).Return([]Incident{{"sys_id": "s1", "state": "1"}}, nil)
+	primary.On("UpdateIncident", mock.Anything, "p1").Return(Incident{"sys_id": "p1", "comments": "a"}, nil)
+	secondary.On("UpdateIncident", mock.Anything, "s1").Return(Incident{"sys_id": "s1", "comments": "b"}, nil)
+	primary.On("CreateIncident", mock.Anything).Return(Incident{"sys_id": "p2"}, nil)
+	secondary.On("CreateIncident", mock.Anything).Return(Incident{}, errors.New("invalid table"))
+
+	d := newDualWriteServiceNow(primary, secondary)
+	before := testutil.ToFloat64(webhookMigrationDivergences.WithLabelValues("update_field"))
+
+	incidents, err := d.GetIncidents(map[string]string{groupKeyField: "key"})
+	if err != nil || len(incidents) != 1 || incidents[0].GetSysID() != "p1" {
+		t.Fatalf("Current target incidents must be returned, got %v, %v", incidents, err)
+	}
+	incident, err := d.UpdateIncident(Incident{"comments": "a"}, "p1")
+	if err != nil || incident.GetSysID() != "p1" {
+		t.Errorf("Current target update must be returned, got %v, %v", incident, err)
+	}
+	secondary.AssertCalled(t, "UpdateIncident", Incident{"comments": "a"}, "s1")
+	if got := testutil.ToFloat64(webhookMigrationDivergences.WithLabelValues("update_field")); got != before+1 {
+		t.Errorf("Field divergence must be counted")
+	}
+
+	// New target errors do not affect the result
+	if _, err := d.CreateIncident(Incident{groupKeyField: "other"}); err != nil {
+		t.Errorf("New target error must not be returned, got %v", err)
+	}
+
+	// No dual-write after the migration period
+	config.Migration.Until = time.Now().Add(-time.Hour)
+	d.CreateIncident(Incident{groupKeyField: "other"})
+	secondary.AssertNumberOfCalls(t, "CreateIncident", 1)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	oauth2TokenURL           = "https://%s.service-now.com/oauth_token.do"
+	oauth2ClientCredentials  = "client_credentials"
+	oauth2RefreshToken       = "refresh_token"
+	oauth2TokenExpiryLeeway  = 30 * time.Second
+	defaultOAuth2TokenExpiry = 30 * time.Minute
+)
+
+// OAuth2Config - OAuth2 authentication to ServiceNow, used instead of basic authentication when client_id is set
+type OAuth2Config struct {
+	// Token endpoint, https://<instance_name>.service-now.com/oauth_token.do by default
+	TokenURL         string `yaml:"token_url"`
+	ClientID         string `yaml:"client_id"`
+	ClientSecret     string `yaml:"client_secret"`
+	ClientSecretFile string `yaml:"client_secret_file"`
+	// client_credentials (default) or refresh_token
+	GrantType        string `yaml:"grant_type"`
+	RefreshToken     string `yaml:"refresh_token"`
+	RefreshTokenFile string `yaml:"refresh_token_file"`
+}
+
+// enabled returns true if a client ID is configured
+func (c OAuth2Config) enabled() bool {
+	return len(c.ClientID) > 0
+}
+
+func (c OAuth2Config) validate() error {
+	var errs strings.Builder
+	if len(c.ClientSecret) == 0 && len(c.ClientSecretFile) == 0 {
+		errs.WriteString("oauth2 client_secret is missing\n")
+	}
+	switch c.GrantType {
+	case "", oauth2ClientCredentials:
+	case oauth2RefreshToken:
+		if len(c.RefreshToken) == 0 && len(c.RefreshTokenFile) == 0 {
+			errs.WriteString("oauth2 refresh_token is missing\n")
+		}
+	default:
+		errs.WriteString(fmt.Sprintf("oauth2 grant_type %q is invalid, must be one of: client_credentials, refresh_token\n", c.GrantType))
+	}
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(errs.String(), "\n"))
+	}
+	return nil
+}
+
+// oauth2TokenSource gets access tokens from the token endpoint and caches them until they expire
+type oauth2TokenSource struct {
+	mu           sync.Mutex
+	config       OAuth2Config
+	tokenURL     string
+	client       *http.Client
+	accessToken  string
+	refreshToken string
+	expiry       time.Time
+}
+
+// oauth2TokenResponse is the response of the token endpoint
+type oauth2TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Error        string `json:"error"`
+}
+
+func newOAuth2TokenSource(c OAuth2Config, instanceName string) *oauth2TokenSource {
+	tokenURL := c.TokenURL
+	if len(tokenURL) == 0 {
+		tokenURL = fmt.Sprintf(oauth2TokenURL, instanceName)
+	}
+	return &oauth2TokenSource{config: c, tokenURL: tokenURL, client: http.DefaultClient}
+}
+
+// token returns a valid access token, requesting a new one if needed
+func (s *oauth2TokenSource) token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.accessToken) > 0 && now().Add(oauth2TokenExpiryLeeway).Before(s.expiry) {
+		return s.accessToken, nil
+	}
+
+	form, err := s.grantForm()
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.client.PostForm(s.tokenURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	tokenResponse := oauth2TokenResponse{}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil || resp.StatusCode != http.StatusOK || len(tokenResponse.AccessToken) == 0 {
+		return "", fmt.Errorf("ServiceNow OAuth2 token request failed with HTTP code %v %s", resp.StatusCode, tokenResponse.Error)
+	}
+
+	expiresIn := time.Duration(tokenResponse.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = defaultOAuth2TokenExpiry
+	}
+	s.accessToken = tokenResponse.AccessToken
+	s.expiry = now().Add(expiresIn)
+	if len(tokenResponse.RefreshToken) > 0 {
+		s.refreshToken = tokenResponse.RefreshToken
+	}
+	log.Infof("ServiceNow OAuth2 access token obtained, expiring in %s", expiresIn)
+	return s.accessToken, nil
+}
+
+// grantForm returns the form of the token request of the configured grant
+func (s *oauth2TokenSource) grantForm() (url.Values, error) {
+	clientSecret := s.config.ClientSecret
+	if len(s.config.ClientSecretFile) > 0 {
+		var err error
+		if clientSecret, err = readSecretFile(s.config.ClientSecretFile); err != nil {
+			return nil, err
+		}
+	}
+	form := url.Values{
+		"grant_type":    {oauth2ClientCredentials},
+		"client_id":     {s.config.ClientID},
+		"client_secret": {clientSecret},
+	}
+	if s.config.GrantType != oauth2RefreshToken {
+		return form, nil
+	}
+
+	// A refresh token returned by the token endpoint replaces the configured one
+	refreshToken := s.refreshToken
+	if len(refreshToken) == 0 {
+		refreshToken = s.config.RefreshToken
+		if len(s.config.RefreshTokenFile) > 0 {
+			var err error
+			if refreshToken, err = readSecretFile(s.config.RefreshTokenFile); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(refreshToken) == 0 {
+		return nil, errors.New("ServiceNow OAuth2 refresh token is missing")
+	}
+	form.Set("grant_type", oauth2RefreshToken)
+	form.Set("refresh_token", refreshToken)
+	return form, nil
+}
+
+// invalidate drops the cached access token, returning true if there was one
+func (s *oauth2TokenSource) invalidate() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invalidated := len(s.accessToken) > 0
+	s.accessToken = ""
+	return invalidated
+}
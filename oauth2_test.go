@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOAuth2ServiceNowClient_RefreshOn401(t *testing.T) {
+	var tokenRequests int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("client_id") != "my-client" || r.Form.Get("client_secret") != "my-secret" {
+			t.Errorf("Unexpected token request form: %v", r.Form)
+		}
+		if atomic.AddInt32(&tokenRequests, 1) == 1 {
+			if r.Form.Get("refresh_token") != "initial-refresh" {
+				t.Errorf("Unexpected refresh token; got: %v, want: initial-refresh", r.Form.Get("refresh_token"))
+			}
+			w.Write([]byte(`{"access_token":"token-1","refresh_token":"rotated-refresh","expires_in":1800}`))
+			return
+		}
+		if r.Form.Get("refresh_token") != "rotated-refresh" {
+			t.Errorf("Unexpected refresh token; got: %v, want: rotated-refresh", r.Form.Get("refresh_token"))
+		}
+		w.Write([]byte(`{"access_token":"token-2","expires_in":1800}`))
+	}))
+	defer tokenServer.Close()
+
+	var authHeaders []string
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"result":[]}`))
+	}))
+	defer apiServer.Close()
+
+	snClient, err := newServiceNowClientFromConfig(ServiceNowConfig{
+		InstanceName: "instance",
+		OAuth2: OAuth2Config{
+			TokenURL:     tokenServer.URL,
+			ClientID:     "my-client",
+			ClientSecret: "my-secret",
+			GrantType:    "refresh_token",
+			RefreshToken: "initial-refresh",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	snClient.baseURL = apiServer.URL
+
+	if _, err := snClient.GetIncidents(nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectedHeaders := []string{"Bearer token-1", "Bearer token-2"}
+	if !reflect.DeepEqual(authHeaders, expectedHeaders) {
+		t.Errorf("Unexpected Authorization headers; got: %v, want: %v", authHeaders, expectedHeaders)
+	}
+
+	// The access token is cached
+	if _, err := snClient.GetIncidents(nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := atomic.LoadInt32(&tokenRequests); n != 2 {
+		t.Errorf("Unexpected token requests; got: %v, want: 2", n)
+	}
+}
+
+func TestOAuth2ServiceNowClient_TokenError(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"access_denied"}`))
+	}))
+	defer tokenServer.Close()
+
+	snClient, err := newServiceNowClientFromConfig(ServiceNowConfig{
+		InstanceName: "instance",
+		OAuth2:       OAuth2Config{TokenURL: tokenServer.URL, ClientID: "my-client", ClientSecret: "bad"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err = snClient.GetIncidents(nil)
+	expected := "ServiceNow OAuth2 token request failed with HTTP code 401 access_denied"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Unexpected error; got: %v, want: %v", err, expected)
+	}
+}
+
+func TestOAuth2Config_Validate(t *testing.T) {
+	if err := (OAuth2Config{ClientID: "id", ClientSecret: "secret"}).validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	err := OAuth2Config{ClientID: "id", GrantType: "refresh_token"}.validate()
+	expected := "oauth2 client_secret is missing\noauth2 refresh_token is missing"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Unexpected error; got: %v, want: %v", err, expected)
+	}
+	err = OAuth2Config{ClientID: "id", ClientSecret: "secret", GrantType: "password"}.validate()
+	expected = `oauth2 grant_type "password" is invalid, must be one of: client_credentials, refresh_token`
+	if err == nil || err.Error() != expected {
+		t.Errorf("Unexpected error; got: %v, want: %v", err, expected)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const defaultOverloadRetryAfter = 30 * time.Second
+
+// OverloadConfig - Degraded behavior under sustained overload, measured as the depth of the notification backlog:
+// the notifications waiting in the queue or in the spool of a paused route, and the in-flight ones
+type OverloadConfig struct {
+	// Backlog depth above which repeat updates of firing incidents (journal entries, refreshed fields) are shed,
+	// creations and resolutions are still processed. Disabled when not set.
+	HighWaterMark int `yaml:"high_water_mark"`
+	// Backlog depth at which new notifications are rejected with 503, for Alertmanager to retry them later.
+	// Disabled when not set.
+	MaxInflight int `yaml:"max_inflight"`
+	// Retry-After of rejected notifications, 30s by default
+	RetryAfter time.Duration `yaml:"retry_after"`
+}
+
+func (c OverloadConfig) validate() error {
+	if c.HighWaterMark < 0 || c.MaxInflight < 0 || c.RetryAfter < 0 {
+		return fmt.Errorf("overload high_water_mark, max_inflight and retry_after must not be negative")
+	}
+	return nil
+}
+
+// load returns the number of in-flight notifications
+func (c *notificationCoalescer) load() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inflight
+}
+
+// backlogDepth returns the number of notifications queued, spooled or in flight
+func backlogDepth() int {
+	return queue.length() + pauses.spooled() + coalescer.load()
+}
+
+// rejectOverload answers 503 when the backlog depth reaches max_inflight, and returns true if the notification
+// is rejected
+func rejectOverload(ctx context.Context, w http.ResponseWriter, c OverloadConfig, data template.Data) bool {
+	maxInflight := c.MaxInflight
+	if maxInflight <= 0 || backlogDepth() < maxInflight {
+		return false
+	}
+	retryAfter := c.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = defaultOverloadRetryAfter
+	}
+	webhookShedNotifications.WithLabelValues("rejected").Inc()
+	alertGroupLog(ctx, data).Warnf("Notification of alert group key: %s is rejected, %d notifications are queued, spooled or in flight", getGroupKey(data), maxInflight)
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	sendJSONResponse(w, http.StatusServiceUnavailable, "Overloaded, retry later")
+	return true
+}
+
+// shedRepeatUpdate returns true if the update of the firing incident must be shed as the backlog depth exceeds
+// the high-water mark
+func shedRepeatUpdate(ctx context.Context, data template.Data, incident Incident) bool {
+	highWaterMark := config.Overload.HighWaterMark
+	if highWaterMark <= 0 || isDryRun(ctx) || backlogDepth() <= highWaterMark {
+		return false
+	}
+	webhookShedNotifications.WithLabelValues("update").Inc()
+	incidentLog(ctx, data, incident).Warnf("Update of incident (%s) for alert group key: %s is shed under overload", incident.GetNumber(), getGroupKey(data))
+	history.record(getGroupKey(data), data.Status, "shed", incident.GetNumber(), nil)
+	return true
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+// setInflight sets the number of in-flight notifications, and returns the function restoring it
+func setInflight(n int) func() {
+	coalescer.mu.Lock()
+	previous := coalescer.inflight
+	coalescer.inflight = n
+	coalescer.mu.Unlock()
+	return func() {
+		coalescer.mu.Lock()
+		coalescer.inflight = previous
+		coalescer.mu.Unlock()
+	}
+}
+
+// fillQueue queues n notifications of distinct group keys, and returns the function restoring an empty queue
+func fillQueue(t *testing.T, n int) func() {
+	dir, err := ioutil.TempDir("", "overload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue = newNotificationQueue()
+	queue.configure(QueueConfig{Directory: dir})
+	for i := 0; i < n; i++ {
+		if err := queue.enqueue(template.Data{Status: "firing", GroupLabels: template.KV{"alertname": fmt.Sprintf("queued-%d", i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		queue = newNotificationQueue()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestOnAlertGroup_ShedRepeatUpdate(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Overload = OverloadConfig{HighWaterMark: 5}
+	defer setInflight(6)()
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{{"state": "1", "number": "INC42", "sys_id": "42"}}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{"number": "INC43", "sys_id": "43"}, nil)
+
+	firing := template.Data{Status: "firing", Alerts: template.Alerts{{Status: "firing"}}, GroupLabels: template.KV{"alertname": "overload-shed"}}
+	if err := onAlertGroup(context.Background(), firing); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertNotCalled(t, "UpdateIncident", mock.Anything, mock.Anything)
+
+	// Resolutions are still processed
+	snClientMock.On("UpdateIncident", mock.Anything, "42").Return(Incident{}, nil)
+	resolved := template.Data{Status: "resolved", Alerts: template.Alerts{{Status: "resolved"}}, GroupLabels: template.KV{"alertname": "overload-shed"}}
+	if err := onAlertGroup(context.Background(), resolved); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertNumberOfCalls(t, "UpdateIncident", 1)
+}
+
+func TestProcessAlertGroup_RejectOverload(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Overload = OverloadConfig{MaxInflight: 10}
+	defer setInflight(10)()
+
+	rr := httptest.NewRecorder()
+	processAlertGroup(context.Background(), rr, config, template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "overload-reject"}})
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") != "30" {
+		t.Errorf("Unexpected response: got %v with Retry-After %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+}
+
+func TestOnAlertGroup_ShedRepeatUpdate_QueuedBacklog(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Overload = OverloadConfig{HighWaterMark: 5}
+	defer setInflight(0)()
+	defer fillQueue(t, 6)()
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{{"state": "1", "number": "INC42", "sys_id": "42"}}, nil)
+
+	firing := template.Data{Status: "firing", Alerts: template.Alerts{{Status: "firing"}}, GroupLabels: template.KV{"alertname": "overload-queued"}}
+	if err := onAlertGroup(context.Background(), firing); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertNotCalled(t, "UpdateIncident", mock.Anything, mock.Anything)
+}
+
+func TestProcessAlertGroup_RejectOverload_QueuedBacklog(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Overload = OverloadConfig{MaxInflight: 10}
+	defer setInflight(4)()
+	defer fillQueue(t, 6)()
+
+	rr := httptest.NewRecorder()
+	processAlertGroup(context.Background(), rr, config, template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "overload-reject"}})
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Notification must be rejected on the queued and in-flight backlog: got %v", rr.Code)
+	}
+	if queue.length() != 6 {
+		t.Errorf("Rejected notification must not be queued, got %d queued", queue.length())
+	}
+}
+
+func TestProcessAlertGroup_RejectOverload_SpooledBacklog(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Overload = OverloadConfig{MaxInflight: 3}
+	defer setInflight(0)()
+	pauses = newRoutePauses()
+	defer func() { pauses = newRoutePauses() }()
+	pauses.pause("paused")
+	for i := 0; i < 3; i++ {
+		pauses.spool(template.Data{Receiver: "paused", GroupLabels: template.KV{"alertname": fmt.Sprintf("spooled-%d", i)}})
+	}
+
+	rr := httptest.NewRecorder()
+	processAlertGroup(context.Background(), rr, config, template.Data{Status: "firing", Receiver: "other", GroupLabels: template.KV{"alertname": "overload-reject"}})
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Notification must be rejected on the spooled backlog: got %v", rr.Code)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	tmpltext "text/template"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const defaultOwnershipWorkNote = "Alertmanager notification received ({{ .Status }}): {{ len .Alerts.Firing }} firing and {{ len .Alerts.Resolved }} resolved alert(s)."
+
+// OwnershipConfig - Incidents owned by an operator once assigned to a user, only journal entries being written to them
+type OwnershipConfig struct {
+	// Field of the incident holding its assignee, e.g. assigned_to, ownership is not tracked when not set
+	Field string `yaml:"field"`
+	// Journal entry written on updates of an owned incident when no journal template applies
+	WorkNote string `yaml:"work_note"`
+}
+
+func (c OwnershipConfig) validate() error {
+	if _, err := tmpltext.New("work_note").Funcs(templateFuncs("")).Parse(c.WorkNote); err != nil {
+		return fmt.Errorf("ownership work_note template is invalid: %v", err)
+	}
+	return nil
+}
+
+// incidentOwner returns the assignee of the incident, as a plain value or a reference
+func incidentOwner(incident Incident, field string) string {
+	switch value := incident[field].(type) {
+	case string:
+		return value
+	case map[string]interface{}:
+		if owner, ok := value["display_value"].(string); ok && len(owner) > 0 {
+			return owner
+		}
+		if owner, ok := value["value"].(string); ok {
+			return owner
+		}
+	}
+	return ""
+}
+
+// restrictOwnedIncidentUpdate reduces the update of an incident assigned to a user to its journal entry, the
+// operator owning its state and fields. Returns true if the incident is owned.
+func restrictOwnedIncidentUpdate(ctx context.Context, data template.Data, incident Incident, incidentUpdateParam Incident) bool {
+	ownership := config.Workflow.Ownership
+	if len(ownership.Field) == 0 {
+		return false
+	}
+	owner := incidentOwner(incident, ownership.Field)
+	if len(owner) == 0 {
+		return false
+	}
+
+	field := config.Journal.Field
+	if len(field) == 0 {
+		field = defaultJournalField
+	}
+	kept := map[string]bool{field: true, config.Journal.HashField: true}
+	for name := range incidentUpdateParam {
+		if !kept[name] {
+			delete(incidentUpdateParam, name)
+		}
+	}
+	if incidentUpdateParam[field] == nil {
+		text := ownership.WorkNote
+		if len(text) == 0 {
+			text = defaultOwnershipWorkNote
+		}
+		note, err := applyTemplate("work_note", text, data)
+		if err != nil {
+			webhookIncidentTemplateError.Inc()
+			log.Errorf("Error parsing ownership work note template, error:%v", err)
+		} else {
+			incidentUpdateParam[field] = note
+		}
+	}
+
+	webhookOwnedIncidentUpdates.Inc()
+	incidentLog(ctx, data, incident).Infof("Incident (%s) is assigned to %s, only a journal entry is written for alert group key: %s", incident.GetNumber(), owner, getGroupKey(data))
+	return true
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIncidentOwner(t *testing.T) {
+	tests := []struct {
+		incident Incident
+		want     string
+	}{
+		{Incident{}, ""},
+		{Incident{"assigned_to": ""}, ""},
+		{Incident{"assigned_to": "6816f79cc0a8016401c5a33be04be441"}, "6816f79cc0a8016401c5a33be04be441"},
+		{Incident{"assigned_to": map[string]interface{}{"link": "https://instance/api/now/table/sys_user/42", "value": "42"}}, "42"},
+		{Incident{"assigned_to": map[string]interface{}{"display_value": "Jane Doe", "value": "42"}}, "Jane Doe"},
+	}
+	for _, test := range tests {
+		if got := incidentOwner(test.incident, "assigned_to"); got != test.want {
+			t.Errorf("Unexpected owner for %v: got %q, want %q", test.incident, got, test.want)
+		}
+	}
+}
+
+func TestOnAlertGroup_OwnedIncident(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.Ownership = OwnershipConfig{Field: "assigned_to", WorkNote: "Still firing for {{ .Receiver }}"}
+	config.Journal = JournalConfig{}
+	config.Workflow.AutoResolve = AutoResolveConfig{State: "6"}
+
+	for _, status := range []string{"firing", "resolved"} {
+		snClientMock := new(MockedSnClient)
+		serviceNow = snClientMock
+		snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{{"state": "2", "number": "INC42", "sys_id": "42", "assigned_to": "jdoe"}}, nil)
+		snClientMock.On("UpdateIncident", Incident{"work_notes": "Still firing for team"}, "42").Return(Incident{}, nil)
+
+		data := template.Data{
+			Status:      status,
+			Receiver:    "team",
+			GroupLabels: template.KV{"alertname": "owned-" + status},
+			Alerts:      template.Alerts{{Status: status}},
+		}
+		if err := onAlertGroup(context.Background(), data); err != nil {
+			t.Fatal(err)
+		}
+		snClientMock.AssertNumberOfCalls(t, "UpdateIncident", 1)
+		snClientMock.AssertNotCalled(t, "CreateIncident", mock.Anything)
+	}
+}
+
+func TestOnAlertGroup_UnownedIncident(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.Ownership = OwnershipConfig{Field: "assigned_to"}
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{{"state": "2", "number": "INC42", "sys_id": "42", "assigned_to": ""}}, nil)
+	snClientMock.On("UpdateIncident", mock.MatchedBy(func(incident Incident) bool {
+		return incident["comments"] != nil
+	}), "42").Return(Incident{}, nil)
+
+	data := template.Data{
+		Status:      "firing",
+		GroupLabels: template.KV{"alertname": "unowned"},
+		Alerts:      template.Alerts{{Status: "firing"}},
+	}
+	if err := onAlertGroup(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertNumberOfCalls(t, "UpdateIncident", 1)
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// PauseConfig - Route pause endpoints, enabled when a bearer token is set
+type PauseConfig struct {
+	BearerToken     string `yaml:"bearer_token"`
+	BearerTokenFile string `yaml:"bearer_token_file"`
+	// File where the paused routes and their spooled notifications are persisted, so they survive restarts
+	StateFile string `yaml:"state_file"`
+}
+
+// enabled returns true if a bearer token is configured
+func (c PauseConfig) enabled() bool {
+	return len(c.BearerToken) > 0 || len(c.BearerTokenFile) > 0
+}
+
+// pausedRoute holds the notifications received while a route (receiver) is paused, the latest one per group key
+type pausedRoute struct {
+	Receiver string          `json:"receiver"`
+	Since    time.Time       `json:"since"`
+	Spool    []template.Data `json:"spool"`
+}
+
+// routePauses keeps the paused routes
+type routePauses struct {
+	mu     sync.Mutex
+	path   string
+	routes map[string]*pausedRoute
+}
+
+var pauses = newRoutePauses()
+
+func newRoutePauses() *routePauses {
+	return &routePauses{routes: make(map[string]*pausedRoute)}
+}
+
+// configure loads the persisted paused routes from path, if set
+func (p *routePauses) configure(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.path = path
+	if len(path) == 0 {
+		return
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("Error reading pause state file: %v", err)
+		}
+		return
+	}
+	routes := make(map[string]*pausedRoute)
+	if err := json.Unmarshal(content, &routes); err != nil {
+		log.Errorf("Error parsing pause state file: %v", err)
+		return
+	}
+	p.routes = routes
+	p.updateMetrics()
+}
+
+// pause starts spooling the notifications of the receiver, returning false if it was already paused
+func (p *routePauses) pause(receiver string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.routes[receiver]; ok {
+		return false
+	}
+	p.routes[receiver] = &pausedRoute{Receiver: receiver, Since: now()}
+	p.updateMetrics()
+	p.persist()
+	return true
+}
+
+// resume stops spooling the notifications of the receiver and returns the spooled ones
+func (p *routePauses) resume(receiver string) ([]template.Data, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	route, ok := p.routes[receiver]
+	if !ok {
+		return nil, false
+	}
+	delete(p.routes, receiver)
+	p.updateMetrics()
+	p.persist()
+	return route.Spool, true
+}
+
+// spool stores the notification if its receiver is paused, replacing the previous one of its group key
+func (p *routePauses) spool(data template.Data) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	route, ok := p.routes[data.Receiver]
+	if !ok {
+		return false
+	}
+	for i, spooled := range route.Spool {
+		if getGroupKey(spooled) == getGroupKey(data) {
+			route.Spool = append(route.Spool[:i], route.Spool[i+1:]...)
+			break
+		}
+	}
+	route.Spool = append(route.Spool, data)
+	p.updateMetrics()
+	p.persist()
+	return true
+}
+
+// spooled returns the number of spooled notifications of all the paused routes
+func (p *routePauses) spooled() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	spooled := 0
+	for _, route := range p.routes {
+		spooled += len(route.Spool)
+	}
+	return spooled
+}
+
+// list returns the paused routes sorted by receiver
+func (p *routePauses) list() []pausedRoute {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	routes := make([]pausedRoute, 0, len(p.routes))
+	for _, route := range p.routes {
+		routes = append(routes, *route)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Receiver < routes[j].Receiver })
+	return routes
+}
+
+// updateMetrics refreshes the paused routes gauges, must be called with the lock held
+func (p *routePauses) updateMetrics() {
+	spooled := 0
+	for _, route := range p.routes {
+		spooled += len(route.Spool)
+	}
+	webhookPausedRoutes.Set(float64(len(p.routes)))
+	webhookSpooledNotifications.Set(float64(spooled))
+}
+
+// persist writes the paused routes in the state file, if any, must be called with the lock held
+func (p *routePauses) persist() {
+	if len(p.path) == 0 {
+		return
+	}
+	content, err := json.Marshal(p.routes)
+	if err == nil {
+		err = ioutil.WriteFile(p.path+".tmp", content, 0600)
+	}
+	if err == nil {
+		err = os.Rename(p.path+".tmp", p.path)
+	}
+	if err != nil {
+		log.Errorf("Error writing pause state file: %v", err)
+	}
+}
+
+// pauseRoute pauses the route of the receiver query parameter on POST, and lists the paused routes on GET
+func pauseRoute(w http.ResponseWriter, r *http.Request) {
+	auth := currentConfig().Pause
+	if err := authorizeBearerToken(r, auth.BearerToken, auth.BearerTokenFile); err != nil {
+		log.Warnf("Unauthorized pause request: %v", err)
+		writeJSONResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pauses.list())
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSONResponse(w, http.StatusMethodNotAllowed, "Only GET and POST methods are allowed")
+		return
+	}
+
+	receiver := r.URL.Query().Get("receiver")
+	if len(receiver) == 0 {
+		writeJSONResponse(w, http.StatusBadRequest, "receiver parameter is missing")
+		return
+	}
+	if !pauses.pause(receiver) {
+		writeJSONResponse(w, http.StatusConflict, fmt.Sprintf("Route %s is already paused", receiver))
+		return
+	}
+	log.Infof("Route %s is paused, its notifications are spooled", receiver)
+	writeJSONResponse(w, http.StatusOK, fmt.Sprintf("Route %s paused", receiver))
+}
+
+// resumeRoute resumes the route of the receiver query parameter, and sends its spooled notifications through the
+// queue, or processes them in background
+func resumeRoute(w http.ResponseWriter, r *http.Request) {
+	auth := currentConfig().Pause
+	if err := authorizeBearerToken(r, auth.BearerToken, auth.BearerTokenFile); err != nil {
+		log.Warnf("Unauthorized resume request: %v", err)
+		writeJSONResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSONResponse(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	receiver := r.URL.Query().Get("receiver")
+	if len(receiver) == 0 {
+		writeJSONResponse(w, http.StatusBadRequest, "receiver parameter is missing")
+		return
+	}
+	spool, ok := pauses.resume(receiver)
+	if !ok {
+		writeJSONResponse(w, http.StatusNotFound, fmt.Sprintf("Route %s is not paused", receiver))
+		return
+	}
+
+	ctx := withRequestID(context.Background(), handleRequestID(w, r))
+	log.Infof("Route %s is resumed, %d spooled notification(s) are processed in background", receiver, len(spool))
+	retry := currentConfig().ServiceNow.Retry
+	for _, data := range spool {
+		resumeSpooled(ctx, data, retry)
+	}
+	writeJSONResponse(w, http.StatusAccepted, fmt.Sprintf("Route %s resumed, %d spooled notification(s) processed in background", receiver, len(spool)))
+}
+
+// resumeSpooled queues the spooled notification when the queue is enabled, and processes it in background
+// otherwise. As Alertmanager will not send it again, retryable errors are retried and the payload is only
+// dead-lettered once they are exhausted or on a non retryable error.
+func resumeSpooled(ctx context.Context, data template.Data, retry RetryConfig) {
+	if queue.enabled() {
+		err := queue.enqueue(data)
+		if err == nil {
+			alertGroupLog(ctx, data).Infof("Spooled notification of alert group key: %s is queued", getGroupKey(data))
+			return
+		}
+		alertGroupLog(ctx, data).Errorf("Error queuing spooled notification of alert group key: %s, processing it in background : %v", getGroupKey(data), err)
+	}
+	go func() {
+		superseded, done := coalescer.arrive(getGroupKey(data))
+		defer done()
+		if superseded {
+			alertGroupLog(ctx, data).Infof("Spooled notification of alert group key: %s is superseded by a newer one, skipping", getGroupKey(data))
+			return
+		}
+		completeInBackground(ctx, data, onAlertGroup(ctx, data), retry)
+	}()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPauseResume(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	dir, err := ioutil.TempDir("", "pause")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config.Pause = PauseConfig{BearerToken: "secret", StateFile: filepath.Join(dir, "pause.json")}
+	pauses = newRoutePauses()
+	pauses.configure(config.Pause.StateFile)
+	defer func() { pauses = newRoutePauses() }()
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	created := make(chan struct{})
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{"sys_id": "42", "number": "INC42"}, nil).Run(func(mock.Arguments) {
+		close(created)
+	})
+
+	req := httptest.NewRequest("POST", "/api/v1/pause?receiver=admins", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(pauseRoute).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Wrong pause status code: got %v, want %v", rr.Code, http.StatusOK)
+	}
+
+	payload, err := ioutil.ReadFile("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(webhook).ServeHTTP(rr, httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload)))
+		if rr.Code != http.StatusAccepted {
+			t.Errorf("Wrong webhook status code of a paused route: got %v, want %v", rr.Code, http.StatusAccepted)
+		}
+	}
+	snClientMock.AssertNotCalled(t, "GetIncidents", mock.Anything)
+
+	// The paused route and its spool survive a restart
+	pauses = newRoutePauses()
+	pauses.configure(config.Pause.StateFile)
+	routes := pauses.list()
+	if len(routes) != 1 || routes[0].Receiver != "admins" || len(routes[0].Spool) != 1 {
+		t.Fatalf("Unexpected paused routes: %+v", routes)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/resume?receiver=admins", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(resumeRoute).ServeHTTP(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("Wrong resume status code: got %v, want %v", rr.Code, http.StatusAccepted)
+	}
+	select {
+	case <-created:
+	case <-time.After(time.Second):
+		t.Fatal("Spooled notification must be processed in background")
+	}
+	for inflight := 1; inflight > 0; time.Sleep(time.Millisecond) {
+		coalescer.mu.Lock()
+		inflight = coalescer.inflight
+		coalescer.mu.Unlock()
+	}
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+	if len(pauses.list()) != 0 {
+		t.Errorf("Route must be resumed: %+v", pauses.list())
+	}
+}
+
+func TestPauseRoute_Errors(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Pause = PauseConfig{BearerToken: "secret"}
+	pauses = newRoutePauses()
+	defer func() { pauses = newRoutePauses() }()
+
+	tests := []struct {
+		handler http.HandlerFunc
+		url     string
+		token   string
+		want    int
+	}{
+		{pauseRoute, "/api/v1/pause?receiver=admins", "wrong", http.StatusUnauthorized},
+		{pauseRoute, "/api/v1/pause", "secret", http.StatusBadRequest},
+		{pauseRoute, "/api/v1/pause?receiver=admins", "secret", http.StatusOK},
+		{pauseRoute, "/api/v1/pause?receiver=admins", "secret", http.StatusConflict},
+		{resumeRoute, "/api/v1/resume?receiver=other", "secret", http.StatusNotFound},
+		{resumeRoute, "/api/v1/resume?receiver=admins", "secret", http.StatusAccepted},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", test.url, nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		rr := httptest.NewRecorder()
+		test.handler.ServeHTTP(rr, req)
+		if rr.Code != test.want {
+			t.Errorf("Wrong status code for %s: got %v, want %v", test.url, rr.Code, test.want)
+		}
+	}
+}
+
+func TestResumeRoute_Queued(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	dir, err := ioutil.TempDir("", "pause")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config.Pause = PauseConfig{BearerToken: "secret"}
+	pauses = newRoutePauses()
+	defer func() { pauses = newRoutePauses() }()
+	queue = newNotificationQueue()
+	queue.configure(QueueConfig{Directory: dir})
+	defer func() { queue = newNotificationQueue() }()
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	deadLetters := testutil.ToFloat64(webhookDeadLetters)
+
+	pauses.pause("admins")
+	for _, alertname := range []string{"spooled-1", "spooled-2"} {
+		pauses.spool(template.Data{Status: "firing", Receiver: "admins", GroupLabels: template.KV{"alertname": alertname}})
+	}
+	req := httptest.NewRequest("POST", "/api/v1/resume?receiver=admins", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(resumeRoute).ServeHTTP(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("Wrong resume status code: got %v, want %v", rr.Code, http.StatusAccepted)
+	}
+
+	// Spooled notifications are queued, not processed by the request nor dead-lettered
+	if queue.length() != 2 {
+		t.Errorf("Spooled notifications must be queued, got %d queued", queue.length())
+	}
+	snClientMock.AssertNotCalled(t, "GetIncidents", mock.Anything)
+	if got := testutil.ToFloat64(webhookDeadLetters); got != deadLetters {
+		t.Errorf("Spooled notifications must not be dead-lettered: got %v dead letters, want %v", got, deadLetters)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// ProfileConfig - Defaults of the alert groups of an environment
+type ProfileConfig struct {
+	// Fields overriding the default_incident ones, e.g. impact, urgency and assignment_group
+	DefaultIncident map[string]string `yaml:"default_incident"`
+	// Name of the service_now_instances entry the incidents are sent to, instead of the one matching the alert group
+	Instance string `yaml:"instance"`
+}
+
+// ProfilesConfig - Profiles selected by the value of a common label of the alert group, e.g. prod, staging and dev,
+// so that one receiver serves all environments
+type ProfilesConfig struct {
+	Label string `yaml:"label"`
+	// Profile used when the label is missing or its value has no profile, none by default
+	Default  string                   `yaml:"default"`
+	Profiles map[string]ProfileConfig `yaml:"profiles"`
+}
+
+func (c ProfilesConfig) validate(instances []ServiceNowInstanceConfig) error {
+	var errs strings.Builder
+	if len(c.Profiles) > 0 && len(c.Label) == 0 {
+		errs.WriteString("profiles label is missing\n")
+	}
+	if _, ok := c.Profiles[c.Default]; len(c.Default) > 0 && !ok {
+		errs.WriteString(fmt.Sprintf("profiles default profile %q is not defined\n", c.Default))
+	}
+
+	names := make(map[string]bool, len(instances))
+	for _, instance := range instances {
+		names[instance.Name] = true
+	}
+	for _, value := range c.values() {
+		if instance := c.Profiles[value].Instance; len(instance) > 0 && !names[instance] {
+			errs.WriteString(fmt.Sprintf("profile %q instance %q is not defined in service_now_instances\n", value, instance))
+		}
+	}
+
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(errs.String(), "\n"))
+	}
+	return nil
+}
+
+// values returns the label values having a profile, sorted
+func (c ProfilesConfig) values() []string {
+	values := make([]string, 0, len(c.Profiles))
+	for value := range c.Profiles {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// selectProfile returns the profile of the environment label of the alert group, or nil
+func selectProfile(data template.Data) *ProfileConfig {
+	if len(config.Profiles.Profiles) == 0 {
+		return nil
+	}
+	if profile, ok := config.Profiles.Profiles[data.CommonLabels[config.Profiles.Label]]; ok {
+		return &profile
+	}
+	if profile, ok := config.Profiles.Profiles[config.Profiles.Default]; ok {
+		return &profile
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestSelectDefaultIncident_Profiles(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() {
+		config.Profiles = ProfilesConfig{}
+		config.ServiceNowInstances = nil
+	}()
+	config.ServiceNowInstances = []ServiceNowInstanceConfig{{Name: "prod-instance", Match: map[string]string{"team": "payments"}}}
+	config.Profiles = ProfilesConfig{
+		Label:   "environment",
+		Default: "dev",
+		Profiles: map[string]ProfileConfig{
+			"prod": {DefaultIncident: map[string]string{"impact": "1", "urgency": "1"}, Instance: "prod-instance"},
+			"dev":  {DefaultIncident: map[string]string{"impact": "3"}},
+		},
+	}
+
+	tests := []struct {
+		labels   template.KV
+		impact   string
+		instance string
+	}{
+		{template.KV{"environment": "prod"}, "1", "prod-instance"},
+		{template.KV{"environment": "staging"}, "3", ""},
+		{template.KV{}, "3", ""},
+		{template.KV{"environment": "dev", "team": "payments"}, "3", "prod-instance"},
+	}
+	for _, test := range tests {
+		data := template.Data{CommonLabels: test.labels}
+		if got := selectDefaultIncident(data)["impact"]; got != test.impact {
+			t.Errorf("Unexpected impact of %v: got %v, want %v", test.labels, got, test.impact)
+		}
+		if got := serviceNowInstanceName(data); got != test.instance {
+			t.Errorf("Unexpected instance of %v: got %q, want %q", test.labels, got, test.instance)
+		}
+	}
+	if got := selectDefaultIncident(template.Data{CommonLabels: template.KV{"environment": "prod"}})["short_description"]; got != config.DefaultIncident["short_description"] {
+		t.Errorf("Fields not in the profile must keep the default_incident value: got %v", got)
+	}
+
+	config.Profiles.Default = ""
+	if got, want := selectDefaultIncident(template.Data{})["impact"], config.DefaultIncident["impact"]; got != want {
+		t.Errorf("Unexpected impact without profile: got %v, want %v", got, want)
+	}
+}
+
+func TestProfilesConfig_Validate(t *testing.T) {
+	instances := []ServiceNowInstanceConfig{{Name: "prod"}}
+	tests := []struct {
+		config ProfilesConfig
+		valid  bool
+	}{
+		{ProfilesConfig{}, true},
+		{ProfilesConfig{Label: "env", Default: "dev", Profiles: map[string]ProfileConfig{"dev": {}, "prod": {Instance: "prod"}}}, true},
+		{ProfilesConfig{Profiles: map[string]ProfileConfig{"dev": {}}}, false},
+		{ProfilesConfig{Label: "env", Default: "test", Profiles: map[string]ProfileConfig{"dev": {}}}, false},
+		{ProfilesConfig{Label: "env", Profiles: map[string]ProfileConfig{"prod": {Instance: "staging"}}}, false},
+	}
+	for _, test := range tests {
+		if err := test.config.validate(instances); (err == nil) != test.valid {
+			t.Errorf("Unexpected validation of %+v: %v", test.config, err)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// Steps of the processing of a payload which must not be redone when the same payload is retried
+const (
+	// Incident written, or event sent, with the incident number as result
+	stepIncident = "incident"
+	// sys_id and action of the written incident, used to resume its side effects
+	stepIncidentSysID  = "incident_sys_id"
+	stepIncidentAction = "incident_action"
+	// Side effects following the incident write
+	stepResolutionConfirmation = "resolution_confirmation"
+	stepTimeline               = "timeline"
+	stepAlertList              = "alert_list"
+	stepTasks                  = "tasks"
+	stepKnowledge              = "knowledge"
+)
+
+// progressEntry is the processing progress of a payload
+type progressEntry struct {
+	GroupKey  string            `json:"group_key"`
+	Steps     map[string]string `json:"steps"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// progressStore keeps the completed steps of recently processed payloads, so that a payload retried
+// by Alertmanager after a timeout resumes from its incomplete step instead of redoing completed ones
+type progressStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	path    string
+	entries map[string]*progressEntry
+	locks   map[string]*groupLock
+}
+
+type groupLock struct {
+	sync.Mutex
+	refs int
+}
+
+var progress = newProgressStore()
+
+func newProgressStore() *progressStore {
+	return &progressStore{
+		entries: make(map[string]*progressEntry),
+		locks:   make(map[string]*groupLock),
+	}
+}
+
+// configure enables progress tracking when ttl is positive, loading persisted entries from path if set
+func (p *progressStore) configure(ttl time.Duration, path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ttl = ttl
+	p.path = path
+	if ttl <= 0 || len(path) == 0 {
+		return
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("Error reading progress file: %v", err)
+		}
+		return
+	}
+	entries := make(map[string]*progressEntry)
+	if err := json.Unmarshal(content, &entries); err != nil {
+		log.Errorf("Error parsing progress file: %v", err)
+		return
+	}
+	for hash, entry := range entries {
+		p.entries[hash] = entry
+	}
+}
+
+// lock serializes the processing of a group key, and returns the function releasing it
+func (p *progressStore) lock(groupKey string) func() {
+	p.mu.Lock()
+	l, ok := p.locks[groupKey]
+	if !ok {
+		l = &groupLock{}
+		p.locks[groupKey] = l
+	}
+	l.refs++
+	p.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		p.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(p.locks, groupKey)
+		}
+		p.mu.Unlock()
+	}
+}
+
+// completed returns the result of the step if it was already completed for this payload
+func (p *progressStore) completed(data template.Data, step string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ttl <= 0 {
+		return "", false
+	}
+	entry, ok := p.entries[payloadHash(data)]
+	if !ok || now().Sub(entry.UpdatedAt) > p.ttl {
+		return "", false
+	}
+	result, ok := entry.Steps[step]
+	return result, ok
+}
+
+// complete records the step of this payload as completed with its result
+func (p *progressStore) complete(data template.Data, step string, result string) {
+	p.completeSteps(data, map[string]string{step: result})
+}
+
+// completeSteps records the steps of this payload as completed with their results
+func (p *progressStore) completeSteps(data template.Data, results map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ttl <= 0 {
+		return
+	}
+
+	hash := payloadHash(data)
+	entry, ok := p.entries[hash]
+	if !ok {
+		entry = &progressEntry{GroupKey: getGroupKey(data), Steps: make(map[string]string)}
+		p.entries[hash] = entry
+	}
+	for step, result := range results {
+		entry.Steps[step] = result
+	}
+	entry.UpdatedAt = now()
+
+	p.expire()
+	p.persist()
+}
+
+// completeIncident records the incident write of this payload as completed, with the written incident and the
+// action resuming its side effects
+func (p *progressStore) completeIncident(ctx context.Context, data template.Data, action string, incident Incident) {
+	if isDryRun(ctx) {
+		return
+	}
+	p.completeSteps(data, map[string]string{
+		stepIncident:       incident.GetNumber(),
+		stepIncidentSysID:  incident.GetSysID(),
+		stepIncidentAction: action,
+	})
+}
+
+// completedIncident returns the incident written for this payload and its action, if the write was completed
+func (p *progressStore) completedIncident(data template.Data) (Incident, string, bool) {
+	number, ok := p.completed(data, stepIncident)
+	if !ok {
+		return nil, "", false
+	}
+	sysID, _ := p.completed(data, stepIncidentSysID)
+	action, _ := p.completed(data, stepIncidentAction)
+	return Incident{"number": number, "sys_id": sysID}, action, true
+}
+
+// runStep runs the side effect of this payload unless it was already completed, and records it as completed
+func (p *progressStore) runStep(ctx context.Context, data template.Data, step string, sideEffect func()) {
+	if isDryRun(ctx) {
+		sideEffect()
+		return
+	}
+	if _, ok := p.completed(data, step); ok {
+		return
+	}
+	sideEffect()
+	p.complete(data, step, "")
+}
+
+// clear forgets the progress of all payloads of the group key
+func (p *progressStore) clear(groupKey string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for hash, entry := range p.entries {
+		if entry.GroupKey == groupKey {
+			delete(p.entries, hash)
+		}
+	}
+	p.persist()
+}
+
+// expire removes the entries older than the TTL, must be called with the lock held
+func (p *progressStore) expire() {
+	for hash, entry := range p.entries {
+		if now().Sub(entry.UpdatedAt) > p.ttl {
+			delete(p.entries, hash)
+		}
+	}
+}
+
+// persist writes the entries in the progress file, if any, must be called with the lock held
+func (p *progressStore) persist() {
+	if len(p.path) == 0 {
+		return
+	}
+	content, err := json.Marshal(p.entries)
+	if err == nil {
+		err = ioutil.WriteFile(p.path+".tmp", content, 0600)
+	}
+	if err == nil {
+		err = os.Rename(p.path+".tmp", p.path)
+	}
+	if err != nil {
+		log.Errorf("Error writing progress file: %v", err)
+	}
+}
+
+// payloadHash returns a hash identifying the payload content
+func payloadHash(data template.Data) string {
+	content, _ := json.Marshal(data)
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
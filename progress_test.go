@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOnAlertGroup_RetriedPayload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	loadConfig("config/servicenow_example.yml")
+	progress.configure(time.Hour, filepath.Join(dir, "progress.json"))
+	defer progress.configure(0, "")
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{"number": "INC42", "sys_id": "42"}, nil)
+
+	data := template.Data{
+		Status:      "firing",
+		GroupLabels: template.KV{"alertname": "RetriedAlert"},
+	}
+	for i := 0; i < 2; i++ {
+		if err := onAlertGroup(context.Background(), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+
+	// Progress must survive a restart through the progress file
+	progress = newProgressStore()
+	progress.configure(time.Hour, filepath.Join(dir, "progress.json"))
+	if number, ok := progress.completed(data, stepIncident); !ok || number != "INC42" {
+		t.Errorf("Unexpected progress: got %v %v, want INC42 true", number, ok)
+	}
+
+	progress.clear(getGroupKey(data))
+	if _, ok := progress.completed(data, stepIncident); ok {
+		t.Errorf("Progress should be cleared")
+	}
+}
+
+func TestOnAlertGroup_RetriedAfterCreate(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	progress.configure(time.Hour, "")
+	defer progress.configure(0, "")
+	config.Timeline = TimelineConfig{Enabled: true}
+	config.AlertList = AlertListConfig{MaxRendered: 1}
+	defer func() { config.Timeline = TimelineConfig{}; config.AlertList = AlertListConfig{} }()
+	incidents = newIncidentCache()
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{"number": "INC42", "sys_id": "42"}, nil)
+	// The processing dies once the incident is created, while attaching the timeline
+	snClientMock.On("AttachFile", "incident", "42", defaultTimelineFileName, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		panic("processing interrupted")
+	}).Once()
+	snClientMock.On("AttachFile", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	data := template.Data{
+		Status:      "firing",
+		GroupLabels: template.KV{"alertname": "RetriedAfterCreate"},
+		Alerts:      template.Alerts{{Status: "firing"}, {Status: "firing"}},
+	}
+	func() {
+		defer func() { recover() }()
+		onAlertGroup(context.Background(), data)
+	}()
+	for i := 0; i < 2; i++ {
+		if err := onAlertGroup(context.Background(), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The retries resume from the timeline, without creating the incident again
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+	snClientMock.AssertNumberOfCalls(t, "AttachFile", 3)
+	if _, ok := progress.completed(data, stepAlertList); !ok {
+		t.Errorf("Alert list step should be completed")
+	}
+}
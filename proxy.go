@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ProxyAuthConfig - Basic authentication to the HTTP proxy of the requests to ServiceNow, be it set by proxy_url or by
+// the proxy environment variables
+type ProxyAuthConfig struct {
+	UserName     string `yaml:"user_name"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+// validateProxy checks the proxy URL and authentication of a ServiceNow configuration
+func validateProxy(proxyURL string, auth ProxyAuthConfig) error {
+	if len(proxyURL) > 0 {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return fmt.Errorf("proxy_url %q is invalid: %v", proxyURL, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return fmt.Errorf("proxy_url %q is invalid: an http(s) scheme and a host are required", proxyURL)
+		}
+	}
+	if len(auth.UserName) == 0 && (len(auth.Password) > 0 || len(auth.PasswordFile) > 0) {
+		return fmt.Errorf("proxy_auth user_name is missing")
+	}
+	return nil
+}
+
+// newServiceNowHTTPClient returns the HTTP client of the requests to ServiceNow, with the configured TLS options, and
+// through the configured proxy or the one of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+func newServiceNowHTTPClient(c ServiceNowConfig) (*http.Client, error) {
+	if len(c.ProxyURL) == 0 && len(c.ProxyAuth.UserName) == 0 && !c.TLSConfig.enabled() {
+		return http.DefaultClient, nil
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if len(c.ProxyURL) > 0 {
+		proxyURL, err := url.Parse(c.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+	if len(c.ProxyAuth.UserName) > 0 {
+		password := c.ProxyAuth.Password
+		if len(c.ProxyAuth.PasswordFile) > 0 {
+			var err error
+			if password, err = readSecretFile(c.ProxyAuth.PasswordFile); err != nil {
+				return nil, err
+			}
+		}
+		proxy = withProxyAuth(proxy, url.UserPassword(c.ProxyAuth.UserName, password))
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	if c.TLSConfig.enabled() {
+		tlsConfig, err := c.TLSConfig.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// withProxyAuth sets the user info to the proxy URLs without any
+func withProxyAuth(proxy func(*http.Request) (*url.URL, error), user *url.Userinfo) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		proxyURL, err := proxy(req)
+		if err != nil || proxyURL == nil || proxyURL.User != nil {
+			return proxyURL, err
+		}
+		authenticated := *proxyURL
+		authenticated.User = user
+		return &authenticated, nil
+	}
+}
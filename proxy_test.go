@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServiceNowClient_Proxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		if got, want := r.Header.Get("Proxy-Authorization"), "Basic "+base64.StdEncoding.EncodeToString([]byte("proxy-user:proxy-pass")); got != want {
+			t.Errorf("Unexpected proxy authorization: got %v, want %v", got, want)
+		}
+		w.Write([]byte(`{"result":[]}`))
+	}))
+	defer proxy.Close()
+
+	snClient, err := newServiceNowClientFromConfig(ServiceNowConfig{
+		InstanceName: "instance",
+		UserName:     "user",
+		Password:     "pass",
+		ProxyURL:     proxy.URL,
+		ProxyAuth:    ProxyAuthConfig{UserName: "proxy-user", Password: "proxy-pass"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	snClient.baseURL = "http://instance.servicenow.invalid"
+
+	if _, err := snClient.GetIncidents(map[string]string{"sysparm_query": "number=INC42"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(proxied) != 1 || proxied[0][:len(snClient.baseURL)] != snClient.baseURL {
+		t.Errorf("Unexpected proxied requests: %v", proxied)
+	}
+}
+
+func TestServiceNowClient_NoProxy(t *testing.T) {
+	client, err := newServiceNowHTTPClient(ServiceNowConfig{})
+	if err != nil || client != http.DefaultClient {
+		t.Errorf("Client without proxy settings must be the default one, honoring the proxy environment variables: %v", err)
+	}
+}
+
+func TestValidateProxy(t *testing.T) {
+	tests := []struct {
+		proxyURL string
+		auth     ProxyAuthConfig
+		valid    bool
+	}{
+		{"", ProxyAuthConfig{}, true},
+		{"http://proxy:3128", ProxyAuthConfig{UserName: "user", Password: "pass"}, true},
+		{"", ProxyAuthConfig{UserName: "user", PasswordFile: "/secrets/proxy"}, true},
+		{"proxy:3128", ProxyAuthConfig{}, false},
+		{"socks5://proxy:1080", ProxyAuthConfig{}, false},
+		{"http://proxy:3128", ProxyAuthConfig{Password: "pass"}, false},
+	}
+	for _, test := range tests {
+		if err := validateProxy(test.proxyURL, test.auth); (err == nil) != test.valid {
+			t.Errorf("Unexpected validation of %q %+v: %v", test.proxyURL, test.auth, err)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const defaultQueueRetryInterval = 30 * time.Second
+
+// QueueConfig - Persistent queue of notifications, acknowledged immediately and sent to ServiceNow asynchronously,
+// so they survive ServiceNow outages longer than the Alertmanager retries
+type QueueConfig struct {
+	// Directory holding the queued notifications, one file each, the queue is disabled when not set
+	Directory string `yaml:"directory"`
+	// Interval between attempts to drain the queue while ServiceNow fails, 30s by default
+	RetryInterval time.Duration `yaml:"retry_interval"`
+	// Age after which a queued notification failing to be sent is dead-lettered, kept until sent when not set
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+func (c QueueConfig) retryInterval() time.Duration {
+	if c.RetryInterval > 0 {
+		return c.RetryInterval
+	}
+	return defaultQueueRetryInterval
+}
+
+// queuedNotification is the content of a queue file
+type queuedNotification struct {
+	QueuedAt time.Time     `json:"queued_at"`
+	Attempts int           `json:"attempts"`
+	Data     template.Data `json:"data"`
+}
+
+// queueEntry is a queued notification and the name of its file
+type queueEntry struct {
+	name         string
+	notification queuedNotification
+}
+
+// notificationQueue keeps the queued notifications, the latest one per group key, in arrival order
+type notificationQueue struct {
+	mu      sync.Mutex
+	config  QueueConfig
+	entries []*queueEntry
+	seq     int
+	wake    chan struct{}
+}
+
+var queue = newNotificationQueue()
+
+func newNotificationQueue() *notificationQueue {
+	return &notificationQueue{wake: make(chan struct{}, 1)}
+}
+
+// enabled returns true if a queue directory is configured
+func (q *notificationQueue) enabled() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.config.Directory) > 0
+}
+
+// configure loads the notifications queued in the directory, if set. The queued notifications are kept when
+// the directory is unchanged, e.g. on a configuration reload while the queue drains.
+func (q *notificationQueue) configure(c QueueConfig) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	directory := q.config.Directory
+	q.config = c
+	defer q.updateMetrics()
+	if c.Directory == directory {
+		return
+	}
+	q.entries = nil
+	if len(c.Directory) == 0 {
+		return
+	}
+	if err := os.MkdirAll(c.Directory, 0700); err != nil {
+		log.Errorf("Error creating queue directory: %v", err)
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(c.Directory, "*.json"))
+	if err != nil {
+		log.Errorf("Error listing queue directory: %v", err)
+		return
+	}
+	// File names start with the enqueue time, sorting them restores the arrival order
+	sort.Strings(files)
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		entry := &queueEntry{name: filepath.Base(file)}
+		if err == nil {
+			err = json.Unmarshal(content, &entry.notification)
+		}
+		if err != nil {
+			log.Errorf("Error reading queued notification %s: %v", file, err)
+			continue
+		}
+		q.replace(entry)
+	}
+	if len(q.entries) > 0 {
+		log.Infof("%d queued notification(s) loaded from %s", len(q.entries), c.Directory)
+	}
+}
+
+// enqueue persists the notification, replacing the queued one of its group key, and wakes the queue up
+func (q *notificationQueue) enqueue(data template.Data) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.seq++
+	entry := &queueEntry{
+		name:         fmt.Sprintf("%020d-%06d.json", now().UnixNano(), q.seq%1000000),
+		notification: queuedNotification{QueuedAt: now(), Data: data},
+	}
+	if err := q.write(entry); err != nil {
+		return err
+	}
+	q.replace(entry)
+	q.updateMetrics()
+	q.wakeUp()
+	return nil
+}
+
+// wakeUp makes the queue drain without waiting for the retry interval
+func (q *notificationQueue) wakeUp() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// length returns the number of queued notifications
+func (q *notificationQueue) length() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// replace appends the entry, removing the queued entry of the same group key, must be called with the lock held
+func (q *notificationQueue) replace(entry *queueEntry) {
+	for i, queued := range q.entries {
+		if getGroupKey(queued.notification.Data) == getGroupKey(entry.notification.Data) {
+			q.entries = append(q.entries[:i], q.entries[i+1:]...)
+			q.removeFile(queued)
+			break
+		}
+	}
+	q.entries = append(q.entries, entry)
+}
+
+// next returns the oldest queued entry
+func (q *notificationQueue) next() *queueEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.entries) == 0 {
+		return nil
+	}
+	return q.entries[0]
+}
+
+// done removes the entry from the queue, unless it has already been replaced by a newer notification
+func (q *notificationQueue) done(entry *queueEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, queued := range q.entries {
+		if queued.name == entry.name {
+			q.entries = append(q.entries[:i], q.entries[i+1:]...)
+			q.removeFile(entry)
+			break
+		}
+	}
+	q.updateMetrics()
+}
+
+// failed records a failed attempt of the entry, persisting its attempts count
+func (q *notificationQueue) failed(entry *queueEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	entry.notification.Attempts++
+	for _, queued := range q.entries {
+		if queued.name == entry.name {
+			queued.notification.Attempts = entry.notification.Attempts
+			if err := q.write(queued); err != nil {
+				log.Errorf("Error writing queued notification: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// drain sends the queued notifications in arrival order, stopping at the first retryable error
+func (q *notificationQueue) drain() {
+	for entry := q.next(); entry != nil; entry = q.next() {
+		ctx := context.Background()
+		data := entry.notification.Data
+		err := onAlertGroup(ctx, data)
+		if err == nil {
+			webhookQueueDrained.WithLabelValues("success").Inc()
+			q.done(entry)
+			continue
+		}
+
+		maxAge := q.maxAge()
+		if !isRetryableError(err) || (maxAge > 0 && now().Sub(entry.notification.QueuedAt) > maxAge) {
+			alertGroupLog(ctx, data).Errorf("Error managing incident from queued alert, payload is dead-lettered : %v", err)
+			webhookQueueDrained.WithLabelValues("dead_lettered").Inc()
+			deadLetterPayload(data)
+			q.done(entry)
+			continue
+		}
+
+		alertGroupLog(ctx, data).Errorf("Error managing incident from queued alert of group key: %s, retrying later : %v", getGroupKey(data), err)
+		webhookQueueDrained.WithLabelValues("retried").Inc()
+		q.failed(entry)
+		return
+	}
+}
+
+func (q *notificationQueue) maxAge() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.config.MaxAge
+}
+
+// run drains the queue when a notification is queued, and at every retry interval
+func (q *notificationQueue) run() {
+	for {
+		q.drain()
+		q.mu.Lock()
+		interval := q.config.retryInterval()
+		q.mu.Unlock()
+		select {
+		case <-q.wake:
+		case <-time.After(interval):
+		}
+	}
+}
+
+// write persists the entry in the queue directory, must be called with the lock held
+func (q *notificationQueue) write(entry *queueEntry) error {
+	content, err := json.Marshal(entry.notification)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(q.config.Directory, entry.name)
+	if err := ioutil.WriteFile(path+".tmp", content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// removeFile deletes the file of the entry, must be called with the lock held
+func (q *notificationQueue) removeFile(entry *queueEntry) {
+	if len(q.config.Directory) == 0 {
+		return
+	}
+	if err := os.Remove(filepath.Join(q.config.Directory, entry.name)); err != nil && !os.IsNotExist(err) {
+		log.Errorf("Error removing queued notification: %v", err)
+	}
+}
+
+// updateMetrics refreshes the queue gauges, must be called with the lock held
+func (q *notificationQueue) updateMetrics() {
+	webhookQueuedNotifications.Set(float64(len(q.entries)))
+	if len(q.entries) == 0 {
+		webhookQueueOldestTimestamp.Set(0)
+		return
+	}
+	webhookQueueOldestTimestamp.Set(float64(q.entries[0].notification.QueuedAt.Unix()))
+}
+
+// enqueueAlertGroup queues the notification and acknowledges it, returning false if the queue is disabled
+func enqueueAlertGroup(ctx context.Context, w http.ResponseWriter, data template.Data) bool {
+	if !queue.enabled() {
+		return false
+	}
+	if err := queue.enqueue(data); err != nil {
+		// Alertmanager retries the notification
+		alertGroupLog(ctx, data).Errorf("Error queuing notification of alert group key: %s : %v", getGroupKey(data), err)
+		sendJSONResponse(w, http.StatusInternalServerError, "Error queuing notification: "+err.Error())
+		return true
+	}
+	alertGroupLog(ctx, data).Infof("Notification of alert group key: %s is queued", getGroupKey(data))
+	sendJSONResponse(w, http.StatusAccepted, "Queued")
+	return true
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestQueue(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config.Queue = QueueConfig{Directory: dir}
+	queue = newNotificationQueue()
+	queue.configure(config.Queue)
+	defer func() { queue = newNotificationQueue() }()
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+
+	payload, err := ioutil.ReadFile("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webhook).ServeHTTP(rr, httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload)))
+		if rr.Code != http.StatusAccepted {
+			t.Errorf("Wrong webhook status code of a queued notification: got %v, want %v", rr.Code, http.StatusAccepted)
+		}
+	}
+	snClientMock.AssertNotCalled(t, "GetIncidents", mock.Anything)
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("The latest notification of the group key must be queued only, got %d files", len(files))
+	}
+
+	// Queued notifications survive a restart, and are kept while ServiceNow is unavailable
+	queue = newNotificationQueue()
+	queue.configure(config.Queue)
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, &serviceNowHTTPError{statusCode: http.StatusServiceUnavailable}).Once()
+	queue.drain()
+	entry := queue.next()
+	if entry == nil || entry.notification.Attempts != 1 {
+		t.Fatalf("The notification must be kept in the queue after a retryable error, got %+v", entry)
+	}
+
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{"sys_id": "42", "number": "INC42"}, nil)
+	queue.drain()
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+	if entry := queue.next(); entry != nil {
+		t.Errorf("The queue must be empty once drained, got %+v", entry)
+	}
+	files, _ = filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 0 {
+		t.Errorf("Queue files must be removed once drained, got %v", files)
+	}
+}
+
+func TestQueueDeadLetter(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	queue = newNotificationQueue()
+	queue.configure(QueueConfig{Directory: dir})
+	defer func() { queue = newNotificationQueue() }()
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, &serviceNowHTTPError{statusCode: http.StatusBadRequest})
+
+	payload, err := ioutil.ReadFile("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := readRequestBody(httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.enqueue(data); err != nil {
+		t.Fatal(err)
+	}
+	queue.drain()
+	if entry := queue.next(); entry != nil {
+		t.Errorf("A notification failing with a client error must be dead-lettered, got %+v", entry)
+	}
+}
+
+func TestQueue_ReloadDuringDrain(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	queue = newNotificationQueue()
+	queue.configure(QueueConfig{Directory: dir})
+	defer func() { queue = newNotificationQueue() }()
+
+	payload, err := ioutil.ReadFile("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := readRequestBody(httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.enqueue(data); err != nil {
+		t.Fatal(err)
+	}
+
+	// The configuration is reloaded while the queued notification is sent
+	reload := func(mock.Arguments) { queue.configure(QueueConfig{Directory: dir, RetryInterval: time.Minute}) }
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, &serviceNowHTTPError{statusCode: http.StatusServiceUnavailable}).Run(reload).Once()
+	queue.drain()
+	reloaded := newNotificationQueue()
+	reloaded.configure(QueueConfig{Directory: dir})
+	if entry := reloaded.next(); entry == nil || entry.notification.Attempts != 1 {
+		t.Fatalf("The failed attempt must be persisted, got %+v", entry)
+	}
+
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil).Run(reload)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{"sys_id": "42", "number": "INC42"}, nil)
+	queue.drain()
+	queue.drain()
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+	if queue.length() != 0 {
+		t.Errorf("The sent notification must be removed from the queue, %d queued", queue.length())
+	}
+}
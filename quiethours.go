@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const quietHoursTimeLayout = "15:04"
+
+// QuietHoursConfig - Windows of the ServiceNow instance, e.g. maintenance or backups, during which journal-only updates
+// are deferred until the window ends. Creations and other updates are sent immediately.
+type QuietHoursConfig struct {
+	Windows []QuietWindowConfig `yaml:"windows"`
+	// Time zone of the windows, e.g. Europe/Paris, UTC by default
+	TimeZone string `yaml:"time_zone"`
+}
+
+// QuietWindowConfig - Daily window, on some days of the week only if set
+type QuietWindowConfig struct {
+	// Days the window starts on, e.g. saturday, every day when not set
+	Days []string `yaml:"days"`
+	// Start and end times (HH:MM), the window ending the next day if end is not after start
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+func (c QuietHoursConfig) validate() error {
+	if _, err := c.location(); err != nil {
+		return fmt.Errorf("quiet_hours time_zone is invalid: %v", err)
+	}
+	for i, window := range c.Windows {
+		for _, value := range []string{window.Start, window.End} {
+			if _, err := time.Parse(quietHoursTimeLayout, value); err != nil {
+				return fmt.Errorf("quiet_hours windows[%d] time %q is invalid, must be HH:MM", i, value)
+			}
+		}
+		for _, day := range window.Days {
+			if _, ok := parseWeekday(day); !ok {
+				return fmt.Errorf("quiet_hours windows[%d] day %q is invalid", i, day)
+			}
+		}
+	}
+	return nil
+}
+
+func (c QuietHoursConfig) location() (*time.Location, error) {
+	if len(c.TimeZone) == 0 {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(c.TimeZone)
+}
+
+func parseWeekday(day string) (time.Weekday, bool) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(day, weekday.String()) {
+			return weekday, true
+		}
+	}
+	return time.Sunday, false
+}
+
+// end returns the end of the window containing the time, false if the time is out of any window
+func (c QuietHoursConfig) end(t time.Time) (time.Time, bool) {
+	location, err := c.location()
+	if err != nil {
+		return time.Time{}, false
+	}
+	t = t.In(location)
+	for _, window := range c.Windows {
+		// The window may have started the day before
+		for _, day := range []time.Time{t.AddDate(0, 0, -1), t} {
+			start, end, ok := window.on(day, location)
+			if ok && !t.Before(start) && t.Before(end) {
+				return end, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// on returns the start and end of the window starting on the day, false if it does not start on this day
+func (w QuietWindowConfig) on(day time.Time, location *time.Location) (time.Time, time.Time, bool) {
+	if len(w.Days) > 0 {
+		found := false
+		for _, name := range w.Days {
+			if weekday, _ := parseWeekday(name); weekday == day.Weekday() {
+				found = true
+			}
+		}
+		if !found {
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	startTime, _ := time.Parse(quietHoursTimeLayout, w.Start)
+	endTime, _ := time.Parse(quietHoursTimeLayout, w.End)
+	start := time.Date(day.Year(), day.Month(), day.Day(), startTime.Hour(), startTime.Minute(), 0, 0, location)
+	end := time.Date(day.Year(), day.Month(), day.Day(), endTime.Hour(), endTime.Minute(), 0, 0, location)
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, true
+}
+
+// isJournalOnly returns true if the update writes journal entries only
+func isJournalOnly(incidentUpdateParam Incident) bool {
+	for field := range incidentUpdateParam {
+		if !isJournalField(field) {
+			return false
+		}
+	}
+	return len(incidentUpdateParam) > 0
+}
+
+// takeDeferredJournal merges the journal entries deferred for the incident, if any, into the update and cancels
+// their deferred update
+func takeDeferredJournal(ctx context.Context, data template.Data, incident Incident, incidentUpdateParam Incident) {
+	if isDryRun(ctx) {
+		return
+	}
+	pending, ok := scheduler.get(getGroupKey(data))
+	if !ok || pending.Action != actionJournal || pending.IncidentSysID != incident.GetSysID() {
+		return
+	}
+	mergeJournal(pending.Params, incidentUpdateParam)
+	scheduler.cancelAction(getGroupKey(data), actionJournal)
+}
+
+// deferJournalUpdate schedules a journal-only update of the incident at the end of the quiet hours window of its
+// instance, along with the journal entries already deferred, and returns true. Other updates, and updates out of
+// quiet hours, are sent immediately with the journal entries deferred.
+func deferJournalUpdate(ctx context.Context, data template.Data, incident Incident, incidentUpdateParam Incident) bool {
+	takeDeferredJournal(ctx, data, incident, incidentUpdateParam)
+	if isDryRun(ctx) || !isJournalOnly(incidentUpdateParam) {
+		return false
+	}
+	end, quiet := serviceNowConfigByName(serviceNowInstanceName(data)).QuietHours.end(now())
+	if !quiet {
+		return false
+	}
+	groupKey := getGroupKey(data)
+	// Other pending actions, e.g. a scheduled resolution, are not replaced
+	if _, ok := scheduler.get(groupKey); ok {
+		return false
+	}
+
+	scheduler.schedule(scheduledAction{
+		GroupKey:       groupKey,
+		Action:         actionJournal,
+		Status:         data.Status,
+		IncidentSysID:  incident.GetSysID(),
+		IncidentNumber: incident.GetNumber(),
+		Instance:       serviceNowInstanceName(data),
+		Params:         incidentUpdateParam,
+		FireAt:         end,
+	})
+	webhookDeferredUpdates.Inc()
+	incidentLog(ctx, data, incident).Infof("Journal update of incident (%s) for alert group key: %s is deferred to the end of quiet hours at %s", incident.GetNumber(), groupKey, end)
+	history.record(groupKey, data.Status, "deferred", incident.GetNumber(), nil)
+	return true
+}
+
+// mergeJournal prepends the earlier journal entries to the ones of the update
+func mergeJournal(earlier Incident, incidentUpdateParam Incident) {
+	for field, value := range earlier {
+		if current, ok := incidentUpdateParam[field]; ok {
+			incidentUpdateParam[field] = fmt.Sprintf("%v\n\n%v", value, current)
+		} else {
+			incidentUpdateParam[field] = value
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestQuietHoursConfig_End(t *testing.T) {
+	quietHours := QuietHoursConfig{Windows: []QuietWindowConfig{
+		{Start: "02:00", End: "03:00"},
+		{Days: []string{"Saturday"}, Start: "22:00", End: "06:00"},
+	}}
+	// 2020-01-04 is a Saturday
+	tests := []struct {
+		time    time.Time
+		want    time.Time
+		wantOk  bool
+		comment string
+	}{
+		{time.Date(2020, 1, 1, 2, 30, 0, 0, time.UTC), time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC), true, "daily window"},
+		{time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC), time.Time{}, false, "daily window end"},
+		{time.Date(2020, 1, 4, 23, 0, 0, 0, time.UTC), time.Date(2020, 1, 5, 6, 0, 0, 0, time.UTC), true, "saturday window"},
+		{time.Date(2020, 1, 5, 5, 0, 0, 0, time.UTC), time.Date(2020, 1, 5, 6, 0, 0, 0, time.UTC), true, "saturday window, next day"},
+		{time.Date(2020, 1, 5, 23, 0, 0, 0, time.UTC), time.Time{}, false, "sunday"},
+	}
+	for _, test := range tests {
+		got, ok := quietHours.end(test.time)
+		if ok != test.wantOk || !got.Equal(test.want) {
+			t.Errorf("Unexpected end of %s: got %v %v, want %v %v", test.comment, got, ok, test.want, test.wantOk)
+		}
+	}
+
+	quietHours = QuietHoursConfig{TimeZone: "Etc/GMT-2", Windows: []QuietWindowConfig{{Start: "02:00", End: "03:00"}}}
+	if _, ok := quietHours.end(time.Date(2020, 1, 1, 0, 30, 0, 0, time.UTC)); !ok {
+		t.Errorf("Window must be in the configured time zone")
+	}
+}
+
+func TestQuietHoursConfig_Validate(t *testing.T) {
+	valid := QuietHoursConfig{Windows: []QuietWindowConfig{{Days: []string{"sunday"}, Start: "22:00", End: "01:00"}}}
+	if err := valid.validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for _, invalid := range []QuietHoursConfig{
+		{TimeZone: "Nowhere/Nothing"},
+		{Windows: []QuietWindowConfig{{Start: "22h", End: "01:00"}}},
+		{Windows: []QuietWindowConfig{{Start: "22:00"}}},
+		{Windows: []QuietWindowConfig{{Days: []string{"weekend"}, Start: "22:00", End: "01:00"}}},
+	} {
+		if err := invalid.validate(); err == nil {
+			t.Errorf("Quiet hours %+v must be invalid", invalid)
+		}
+	}
+}
+
+func TestDeferJournalUpdate(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	scheduler = newActionScheduler()
+	now = func() time.Time { return time.Date(2020, 1, 1, 2, 30, 0, 0, time.UTC) }
+	defer func() {
+		config.ServiceNow.QuietHours = QuietHoursConfig{}
+		scheduler = newActionScheduler()
+		now = time.Now
+	}()
+	config.ServiceNow.QuietHours = QuietHoursConfig{Windows: []QuietWindowConfig{{Start: "02:00", End: "03:00"}}}
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "QuietHours"}}
+	incident := Incident{"sys_id": "42", "number": "INC42"}
+
+	if deferJournalUpdate(context.Background(), data, incident, Incident{"comments": "first", "urgency": "1"}) {
+		t.Errorf("Update of other fields than the journal must not be deferred")
+	}
+	if !deferJournalUpdate(context.Background(), data, incident, Incident{"comments": "first"}) {
+		t.Fatalf("Journal-only update must be deferred")
+	}
+	if !deferJournalUpdate(context.Background(), data, incident, Incident{"comments": "second", "work_notes": "note"}) {
+		t.Fatalf("Journal-only update must be deferred")
+	}
+	action, ok := scheduler.get(getGroupKey(data))
+	if !ok || action.Action != actionJournal || !action.FireAt.Equal(time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Unexpected deferred update: %+v", action)
+	}
+	if action.Params["comments"] != "first\n\nsecond" || action.Params["work_notes"] != "note" {
+		t.Errorf("Unexpected merged journal: %+v", action.Params)
+	}
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("UpdateIncident", Incident{"comments": "first\n\nsecond", "work_notes": "note"}, "42").Return(Incident{}, nil)
+	scheduler.fireDue()
+	snClientMock.AssertNumberOfCalls(t, "UpdateIncident", 0)
+	now = func() time.Time { return time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC) }
+	scheduler.fireDue()
+	snClientMock.AssertNumberOfCalls(t, "UpdateIncident", 1)
+	if _, ok := scheduler.get(getGroupKey(data)); ok {
+		t.Errorf("Deferred update must be removed once sent")
+	}
+}
+
+func TestDeferJournalUpdate_Flushed(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	scheduler = newActionScheduler()
+	now = func() time.Time { return time.Date(2020, 1, 1, 2, 30, 0, 0, time.UTC) }
+	defer func() {
+		config.ServiceNow.QuietHours = QuietHoursConfig{}
+		scheduler = newActionScheduler()
+		now = time.Now
+	}()
+	config.ServiceNow.QuietHours = QuietHoursConfig{Windows: []QuietWindowConfig{{Start: "02:00", End: "03:00"}}}
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "QuietHours"}}
+	incident := Incident{"sys_id": "42", "number": "INC42"}
+	deferJournalUpdate(context.Background(), data, incident, Incident{"comments": "first"})
+
+	// Firing again does not cancel the deferred journal, but urgent updates send it immediately
+	incidents = newIncidentCache()
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{"sys_id": "43", "number": "INC43"}, nil)
+	if err := manageAlertGroupIncident(context.Background(), data); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := scheduler.get(getGroupKey(data)); !ok {
+		t.Fatalf("Deferred journal must be kept when the alert group fires again")
+	}
+
+	update := Incident{"comments": "second", "state": "2"}
+	if deferJournalUpdate(context.Background(), data, incident, update) {
+		t.Fatalf("Update of other fields than the journal must not be deferred")
+	}
+	if update["comments"] != "first\n\nsecond" {
+		t.Errorf("Unexpected update with the deferred journal: %+v", update)
+	}
+	if _, ok := scheduler.get(getGroupKey(data)); ok {
+		t.Errorf("Deferred journal must be removed once sent with an update")
+	}
+
+	now = func() time.Time { return time.Date(2020, 1, 1, 4, 0, 0, 0, time.UTC) }
+	if deferJournalUpdate(context.Background(), data, incident, Incident{"comments": "third"}) {
+		t.Errorf("Update out of quiet hours must not be deferred")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultRateLimitMaxDelay = 5 * time.Second
+
+// Rate limit response headers, sent by ServiceNow when rate limit rules apply to the user
+const (
+	rateLimitLimitHeader     = "X-RateLimit-Limit"
+	rateLimitRemainingHeader = "X-RateLimit-Remaining"
+	rateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// RateLimitConfig - Proactive slow down of requests when the ServiceNow rate limit quota is nearly exhausted
+type RateLimitConfig struct {
+	// Remaining quota under which requests are spread until the quota reset, disabled when not set
+	MinRemaining int `yaml:"min_remaining"`
+	// Maximum delay added before a request
+	MaxDelay time.Duration `yaml:"max_delay"`
+}
+
+// rateLimitState is the last rate limit quota returned by ServiceNow
+type rateLimitState struct {
+	known     bool
+	remaining int
+	reset     time.Time
+}
+
+// observeRateLimit keeps the rate limit quota returned in the response headers, if any
+func (snClient *ServiceNowClient) observeRateLimit(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get(rateLimitRemainingHeader))
+	if err != nil {
+		return
+	}
+	state := rateLimitState{known: true, remaining: remaining}
+	if reset, err := strconv.ParseInt(resp.Header.Get(rateLimitResetHeader), 10, 64); err == nil {
+		state.reset = time.Unix(reset, 0)
+	}
+	if limit, err := strconv.Atoi(resp.Header.Get(rateLimitLimitHeader)); err == nil {
+		serviceNowRateLimitLimit.Set(float64(limit))
+	}
+	serviceNowRateLimitRemaining.Set(float64(remaining))
+
+	snClient.mu.Lock()
+	defer snClient.mu.Unlock()
+	snClient.rateLimitState = state
+}
+
+// rateLimitDelay returns the delay spreading the remaining quota until its reset, when
+// it is under the configured minimum, so that requests slow down before hitting 429s
+func (snClient *ServiceNowClient) rateLimitDelay() time.Duration {
+	snClient.mu.RLock()
+	defer snClient.mu.RUnlock()
+	state := snClient.rateLimitState
+	if snClient.rateLimit.MinRemaining <= 0 || !state.known || state.remaining >= snClient.rateLimit.MinRemaining {
+		return 0
+	}
+
+	untilReset := state.reset.Sub(now())
+	if untilReset <= 0 {
+		return 0
+	}
+	delay := untilReset / time.Duration(state.remaining+1)
+
+	maxDelay := snClient.rateLimit.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultRateLimitMaxDelay
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// waitRateLimit slows down the next request if the rate limit quota is nearly exhausted
+func (snClient *ServiceNowClient) waitRateLimit() {
+	if delay := snClient.rateLimitDelay(); delay > 0 {
+		log.Warnf("ServiceNow rate limit quota is nearly exhausted, delaying request by %s", delay)
+		serviceNowRateLimitDelays.Inc()
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimitDelay(t *testing.T) {
+	current := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	snClient, err := NewServiceNowClient("instancename", "username", "password")
+	if err != nil {
+		t.Fatalf("Error occured on NewServiceNowClient: %s", err)
+	}
+	observe := func(remaining int) {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set(rateLimitLimitHeader, "100")
+		resp.Header.Set(rateLimitRemainingHeader, strconv.Itoa(remaining))
+		resp.Header.Set(rateLimitResetHeader, strconv.FormatInt(current.Add(10*time.Second).Unix(), 10))
+		snClient.observeRateLimit(resp)
+	}
+
+	observe(4)
+	if delay := snClient.rateLimitDelay(); delay != 0 {
+		t.Errorf("Requests must not be delayed when disabled, got %v", delay)
+	}
+
+	snClient.rateLimit = RateLimitConfig{MinRemaining: 10, MaxDelay: 3 * time.Second}
+	tests := map[int]time.Duration{
+		50: 0,
+		4:  2 * time.Second,
+		0:  3 * time.Second,
+	}
+	for remaining, want := range tests {
+		observe(remaining)
+		if delay := snClient.rateLimitDelay(); delay != want {
+			t.Errorf("Unexpected delay for %d remaining requests: got %v, want %v", remaining, delay, want)
+		}
+	}
+}
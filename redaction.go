@@ -0,0 +1,55 @@
+package main
+
+import (
+	"regexp"
+)
+
+const defaultRedactionReplacement = "[REDACTED]"
+
+// RedactionConfig - Rule masking sensitive content in rendered incident fields
+type RedactionConfig struct {
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+}
+
+type redactionRule struct {
+	regexp      *regexp.Regexp
+	replacement string
+}
+
+// compileRedactionRules compiles the configured redaction rules
+func compileRedactionRules(redactions []RedactionConfig) ([]redactionRule, error) {
+	rules := make([]redactionRule, 0, len(redactions))
+	for _, r := range redactions {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return nil, err
+		}
+
+		replacement := r.Replacement
+		if len(replacement) == 0 {
+			replacement = defaultRedactionReplacement
+		}
+		rules = append(rules, redactionRule{regexp: re, replacement: replacement})
+	}
+	return rules, nil
+}
+
+// redactIncident applies all redaction rules to the string fields of the incident
+func redactIncident(incident Incident) {
+	for key, val := range incident {
+		text, ok := val.(string)
+		if !ok {
+			continue
+		}
+		for _, rule := range redactionRules {
+			matches := len(rule.regexp.FindAllStringIndex(text, -1))
+			if matches == 0 {
+				continue
+			}
+			text = rule.regexp.ReplaceAllString(text, rule.replacement)
+			webhookIncidentRedactions.Add(float64(matches))
+		}
+		incident[key] = text
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRedactIncident(t *testing.T) {
+	var err error
+	redactionRules, err = compileRedactionRules([]RedactionConfig{
+		{Regex: `password=\S+`, Replacement: "password=***"},
+		{Regex: `token [a-f0-9]+`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { redactionRules = nil }()
+
+	incident := Incident{
+		"description": "login failed with password=hunter2 and token deadbeef",
+		"impact":      nil,
+	}
+	redactIncident(incident)
+
+	want := "login failed with password=*** and [REDACTED]"
+	if incident["description"] != want {
+		t.Errorf("Unexpected result: got %v, want %v", incident["description"], want)
+	}
+	if incident["impact"] != nil {
+		t.Errorf("Unexpected result: got %v, want nil", incident["impact"])
+	}
+}
+
+func TestLoadConfigContent_InvalidRedaction(t *testing.T) {
+	configFile := `
+service_now:
+ instance_name: "instance"
+ user_name: "SA"
+ password: "SA!"
+workflow:
+ incident_group_key_field: "u_other_reference_1"
+redactions:
+ - regex: "("
+`
+	_, err := loadConfigContent([]byte(configFile))
+	if err == nil {
+		t.Errorf("Should have an error on invalid redaction regex")
+	}
+}
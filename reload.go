@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// ReloadConfig - Configuration reload endpoint (/-/reload), enabled when a bearer token is set
+type ReloadConfig struct {
+	BearerToken     string `yaml:"bearer_token"`
+	BearerTokenFile string `yaml:"bearer_token_file"`
+}
+
+// enabled returns true if a bearer token is configured
+func (c ReloadConfig) enabled() bool {
+	return len(c.BearerToken) > 0 || len(c.BearerTokenFile) > 0
+}
+
+var (
+	// configLock is held for reading while an alert group is processed, and for writing while the
+	// configuration is reloaded, so that in-flight notifications complete with the configuration they started with
+	configLock sync.RWMutex
+	// loadedConfigData is the content of the last successfully loaded configuration
+	loadedConfigData []byte
+)
+
+// currentConfig returns a snapshot of the configuration, so that a request is handled with one
+// configuration even if it is reloaded meanwhile
+func currentConfig() Config {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	return config
+}
+
+// reloadConfig loads the configuration file and rebuilds the ServiceNow clients. On error, the
+// previous configuration and clients are kept.
+func reloadConfig() error {
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	previousConfigData := loadedConfigData
+	previousServiceNow, previousInstances := serviceNow, serviceNowInstances
+	restore := func() {
+		if _, err := loadConfigContent(previousConfigData); err != nil {
+			log.Errorf("Error restoring the previous configuration: %v", err)
+		}
+		loadedConfigData = previousConfigData
+		serviceNow, serviceNowInstances = previousServiceNow, previousInstances
+	}
+
+	_, err := loadConfig(*configFile)
+	if err == nil {
+		_, err = loadSnClient()
+	}
+	if err != nil {
+		restore()
+		webhookConfigReloadSuccess.Set(0)
+		log.Errorf("Error reloading the configuration, the previous one is kept: %v", err)
+		return err
+	}
+
+	webhookConfigReloadSuccess.Set(1)
+	webhookConfigReloadTime.SetToCurrentTime()
+	log.Info("Configuration reloaded")
+	return nil
+}
+
+// reload reloads the configuration on POST /-/reload
+func reload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONResponse(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+	auth := currentConfig().Reload
+	if err := authorizeBearerToken(r, auth.BearerToken, auth.BearerTokenFile); err != nil {
+		log.Warnf("Unauthorized reload request: %v", err)
+		writeJSONResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := reloadConfig(); err != nil {
+		writeJSONResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, "Configuration reloaded")
+}
+
+// watchReloadSignal reloads the configuration on SIGHUP
+func watchReloadSignal() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		log.Info("SIGHUP received, reloading the configuration")
+		reloadConfig()
+	}
+}
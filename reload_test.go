@@ -0,0 +1,127 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestReloadConfig(t *testing.T) {
+	defer loadConfig("config/servicenow_example.yml")
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	content, err := ioutil.ReadFile("config/servicenow_example.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "servicenow.yml")
+	previousConfigFile := *configFile
+	*configFile = path
+	defer func() { *configFile = previousConfigFile }()
+
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	instanceName := config.ServiceNow.InstanceName
+
+	// A valid configuration is applied
+	updated := strings.Replace(string(content), "instance_name: \""+instanceName+"\"", "instance_name: \"reloaded\"", 1)
+	if err := ioutil.WriteFile(path, []byte(updated), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if config.ServiceNow.InstanceName != "reloaded" || testutil.ToFloat64(webhookConfigReloadSuccess) != 1 {
+		t.Errorf("Configuration is not reloaded: instance_name %s", config.ServiceNow.InstanceName)
+	}
+	if snClient, ok := serviceNow.(*ServiceNowClient); !ok || !strings.Contains(snClient.baseURL, "reloaded") {
+		t.Errorf("ServiceNow client is not rebuilt: %+v", serviceNow)
+	}
+
+	// An invalid configuration is rejected, the previous one is kept
+	if err := ioutil.WriteFile(path, []byte("service_now:\n  instance_name: \"\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadConfig(); err == nil {
+		t.Errorf("Invalid configuration must not be reloaded")
+	}
+	if config.ServiceNow.InstanceName != "reloaded" || testutil.ToFloat64(webhookConfigReloadSuccess) != 0 {
+		t.Errorf("Previous configuration is not kept: instance_name %s", config.ServiceNow.InstanceName)
+	}
+}
+
+func TestReloadHandler_Unauthorized(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Reload = ReloadConfig{BearerToken: "secret"}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/-/reload", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	http.HandlerFunc(reload).ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Wrong status code: got %v, want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestReloadConfig_ConcurrentWebhook(t *testing.T) {
+	defer loadConfig("config/servicenow_example.yml")
+	dir, err := ioutil.TempDir("", "reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	content, err := ioutil.ReadFile("config/servicenow_example.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content = append(content, []byte("\nwebhook_auth:\n  bearer_token: \"secret\"\n")...)
+	path := filepath.Join(dir, "servicenow.yml")
+	previousConfigFile := *configFile
+	*configFile = path
+	defer func() { *configFile = previousConfigFile }()
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unauthenticated notifications are rejected while the configuration is reloaded
+	stop := make(chan struct{})
+	reloaded := make(chan struct{})
+	go func() {
+		defer close(reloaded)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				reloadConfig()
+			}
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/webhook", strings.NewReader("{}"))
+		http.HandlerFunc(webhook).ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Wrong status code during reload: got %v, want %v", rr.Code, http.StatusUnauthorized)
+			break
+		}
+	}
+	close(stop)
+	<-reloaded
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// requestIDHeader holds the correlation ID of a webhook request, accepted from the caller, returned in the response
+// and sent on the ServiceNow requests done for it
+const requestIDHeader = "X-Request-ID"
+
+// validRequestID restricts the accepted request IDs to what can be logged and sent as is
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// handleRequestID returns the ID of the webhook request, from its X-Request-ID header if valid, generated otherwise,
+// and sets it in the response header
+func handleRequestID(w http.ResponseWriter, r *http.Request) string {
+	id := r.Header.Get(requestIDHeader)
+	if !validRequestID.MatchString(id) {
+		id = newRequestID()
+	}
+	w.Header().Set(requestIDHeader, id)
+	return id
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// withRequestID returns a context carrying the request ID
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestIDFrom returns the request ID of the context, empty if none
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestLog returns a logger whose messages carry the request ID of the context, if any
+func requestLog(ctx context.Context) *logrus.Entry {
+	if id := requestIDFrom(ctx); len(id) > 0 {
+		return log.WithField("request_id", id)
+	}
+	return logrus.NewEntry(log)
+}
+
+// requestScoper is implemented by the ServiceNow clients able to send their requests with the request ID of a context
+type requestScoper interface {
+	withContext(ctx context.Context) ServiceNow
+}
+
+// withRequestScope returns the client sending its requests with the request ID of the context, if it supports it
+func withRequestScope(ctx context.Context, client ServiceNow) ServiceNow {
+	if scoper, ok := client.(requestScoper); ok {
+		return scoper.withContext(ctx)
+	}
+	return client
+}
+
+// requestScopedSnClient sends the requests of a ServiceNow client with the ID of the webhook request they are done for
+type requestScopedSnClient struct {
+	client *ServiceNowClient
+	ctx    context.Context
+}
+
+// withContext returns the client sending its requests with the request ID of the context
+func (snClient *ServiceNowClient) withContext(ctx context.Context) ServiceNow {
+	return &requestScopedSnClient{client: snClient, ctx: ctx}
+}
+
+func (c *requestScopedSnClient) withContext(ctx context.Context) ServiceNow {
+	return &requestScopedSnClient{client: c.client, ctx: ctx}
+}
+
+func (c *requestScopedSnClient) CreateIncident(incidentParam Incident) (Incident, error) {
+	return c.client.createIncidentContext(c.ctx, incidentParam)
+}
+
+func (c *requestScopedSnClient) GetIncidents(params map[string]string) ([]Incident, error) {
+	return c.client.getIncidentsContext(c.ctx, params)
+}
+
+func (c *requestScopedSnClient) GetRecords(table string, params map[string]string) ([]Incident, error) {
+	return c.client.getRecordsContext(c.ctx, table, params)
+}
+
+func (c *requestScopedSnClient) CreateRecord(table string, recordParam Incident) (Incident, error) {
+	return c.client.createRecordContext(c.ctx, table, recordParam)
+}
+
+func (c *requestScopedSnClient) UpdateIncident(incidentParam Incident, sysID string) (Incident, error) {
+	return c.client.updateIncidentContext(c.ctx, incidentParam, sysID)
+}
+
+func (c *requestScopedSnClient) AttachFile(table string, sysID string, fileName string, contentType string, content []byte) error {
+	return c.client.attachFileContext(c.ctx, table, sysID, fileName, contentType, content)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestHandleRequestID(t *testing.T) {
+	req := httptest.NewRequest("POST", "/webhook", nil)
+	req.Header.Set(requestIDHeader, "am-42")
+	rr := httptest.NewRecorder()
+	if id := handleRequestID(rr, req); id != "am-42" || rr.Header().Get(requestIDHeader) != "am-42" {
+		t.Errorf("Caller request ID must be kept: got %s, header %s", id, rr.Header().Get(requestIDHeader))
+	}
+
+	for _, header := range []string{"", "bad id\n", strings.Repeat("a", 129)} {
+		req := httptest.NewRequest("POST", "/webhook", nil)
+		req.Header.Set(requestIDHeader, header)
+		rr := httptest.NewRecorder()
+		id := handleRequestID(rr, req)
+		if id == header || len(id) != 32 || rr.Header().Get(requestIDHeader) != id {
+			t.Errorf("Request ID must be generated for header %q: got %s", header, id)
+		}
+	}
+}
+
+func TestRequestID_Context(t *testing.T) {
+	if id := requestIDFrom(context.Background()); len(id) > 0 {
+		t.Errorf("Context without request ID must have none: got %s", id)
+	}
+	if id := requestIDFrom(withRequestID(context.Background(), "am-42")); id != "am-42" {
+		t.Errorf("Unexpected request ID: got %s, want am-42", id)
+	}
+}
+
+func TestRequestID_PropagatedToServiceNow(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	var received []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(requestIDHeader))
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.Write([]byte(`{"result":{"sys_id":"42","number":"INC42"}}`))
+			return
+		}
+		w.Write([]byte(`{"result":[]}`))
+	}))
+	defer ts.Close()
+
+	newClient := func() *ServiceNowClient {
+		snClient, err := NewServiceNowClient("instancename", "username", "password")
+		if err != nil {
+			t.Fatalf("Error occured on NewServiceNowClient: %s", err)
+		}
+		snClient.baseURL = ts.URL
+		return snClient
+	}
+	snClient := newClient()
+	ctx := withRequestID(context.Background(), "am-42")
+
+	if _, err := withRequestScope(context.Background(), snClient).GetIncidents(map[string]string{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := withRequestScope(ctx, snClient).GetIncidents(map[string]string{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(received) != 2 || received[0] != "" || received[1] != "am-42" {
+		t.Errorf("Unexpected request ID headers sent to ServiceNow: %q", received)
+	}
+
+	// Both targets of a dual-write, and the reads of a dry run, carry the request ID
+	received = nil
+	config.Migration.Until = time.Now().Add(time.Hour)
+	defer func() { config.Migration = MigrationConfig{} }()
+	if _, err := withRequestScope(ctx, newDualWriteServiceNow(snClient, newClient())).CreateIncident(Incident{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := withRequestScope(ctx, &dryRunSnClient{ServiceNow: snClient, ctx: context.Background()}).GetIncidents(map[string]string{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(received) != 3 || received[0] != "am-42" || received[1] != "am-42" || received[2] != "am-42" {
+		t.Errorf("Unexpected request ID headers sent by dual-write and dry run clients: %q", received)
+	}
+}
+
+func TestRequestID_Logged(t *testing.T) {
+	defer configureLogging("info", "logfmt")
+	configureLogging("info", "json")
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	data := template.Data{Status: "firing", GroupLabels: template.KV{"alertname": "RequestID"}}
+	ctx := withRequestID(context.Background(), "am-42")
+	alertGroupLog(ctx, data).Infof("Received alert group")
+	incidentLog(ctx, data, Incident{"number": "INC42"}).Infof("Incident updated")
+
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		entry := map[string]string{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Unexpected JSON log line %s: %v", line, err)
+		}
+		if entry["request_id"] != "am-42" {
+			t.Errorf("Log line must carry the request ID: %s", line)
+		}
+	}
+}
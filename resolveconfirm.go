@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const defaultResolveConfirmationInterval = 30 * time.Second
+
+// ResolveConfirmationConfig - Follow-up reads checking that resolved incidents actually reached the resolved state,
+// business rules may reject the transition silently
+type ResolveConfirmationConfig struct {
+	// Number of reads, the confirmation is disabled when not set
+	Attempts int `yaml:"attempts"`
+	// Interval before each read, 30s by default
+	Interval time.Duration `yaml:"interval"`
+}
+
+func (c ResolveConfirmationConfig) validate() error {
+	if c.Attempts < 0 || c.Interval < 0 {
+		return fmt.Errorf("auto_resolve confirmation attempts and interval must not be negative")
+	}
+	return nil
+}
+
+func (c ResolveConfirmationConfig) interval() time.Duration {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return defaultResolveConfirmationInterval
+}
+
+// confirmResolution starts confirming the resolution of the incident in the background, if configured.
+// It must be called while configLock is held, the confirmation using the configuration at that time.
+func confirmResolution(instance string, groupKey string, incident Incident) {
+	confirmation := config.Workflow.AutoResolve.Confirmation
+	if confirmation.Attempts <= 0 {
+		return
+	}
+	go awaitResolution(serviceNowByName(instance), config.Workflow.Source, groupKey, incident, confirmation,
+		config.Workflow.AutoResolve.State, noUpdateStates)
+}
+
+// awaitResolution reads the resolved incident until it is in the resolved (or a closed) state, and flags the
+// group key in its history if it never is. It blocks until confirmed or the attempts are exhausted.
+func awaitResolution(client ServiceNow, source SourceConfig, groupKey string, incident Incident, confirmation ResolveConfirmationConfig,
+	state json.Number, closedStates map[json.Number]bool) {
+	var current Incident
+	var err error
+	for attempt := 1; attempt <= confirmation.Attempts; attempt++ {
+		time.Sleep(confirmation.interval())
+		var found []Incident
+		found, err = client.GetIncidents(withSource(source, map[string]string{
+			"sysparm_query":  "sys_id=" + incident.GetSysID(),
+			"sysparm_fields": "sys_id,number,state",
+		}))
+		if err != nil {
+			serviceNowError.Inc()
+			groupKeyLog(groupKey, incident).Errorf("Error confirming the resolution of incident (%s), attempt %d: %v", incident.GetNumber(), attempt, err)
+			continue
+		}
+		if len(found) == 0 {
+			err = fmt.Errorf("incident not found")
+			continue
+		}
+		current = found[0]
+		if current.GetState() == state || closedStates[current.GetState()] {
+			webhookResolveConfirmations.WithLabelValues("confirmed").Inc()
+			groupKeyLog(groupKey, incident).Infof("Resolution of incident (%s) confirmed for alert group key: %s", incident.GetNumber(), groupKey)
+			return
+		}
+	}
+
+	if current != nil {
+		err = fmt.Errorf("incident state is %s instead of %s after %d attempts", current.GetState(), state, confirmation.Attempts)
+	}
+	webhookResolveConfirmations.WithLabelValues("unconfirmed").Inc()
+	groupKeyLog(groupKey, incident).Warnf("Resolution of incident (%s) for alert group key: %s is not confirmed: %v", incident.GetNumber(), groupKey, err)
+	history.record(groupKey, "resolved", "resolve_unconfirmed", incident.GetNumber(), err)
+}
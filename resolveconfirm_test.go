@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestResolveConfirmationConfig_Validate(t *testing.T) {
+	if err := (ResolveConfirmationConfig{Attempts: 3, Interval: time.Second}).validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := (ResolveConfirmationConfig{Attempts: -1}).validate(); err == nil {
+		t.Errorf("Negative attempts must be invalid")
+	}
+	if got := (ResolveConfirmationConfig{}).interval(); got != defaultResolveConfirmationInterval {
+		t.Errorf("Unexpected default interval: got %v, want %v", got, defaultResolveConfirmationInterval)
+	}
+}
+
+func TestAwaitResolution_Confirmed(t *testing.T) {
+	history = newGroupHistory(10)
+	snClientMock := new(MockedSnClient)
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{{"sys_id": "42", "number": "INC42", "state": "2"}}, nil).Once()
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{{"sys_id": "42", "number": "INC42", "state": "6"}}, nil).Once()
+
+	confirmed := testutil.ToFloat64(webhookResolveConfirmations.WithLabelValues("confirmed"))
+	awaitResolution(snClientMock, SourceConfig{}, "group", Incident{"sys_id": "42", "number": "INC42"},
+		ResolveConfirmationConfig{Attempts: 3, Interval: time.Millisecond}, "6", map[json.Number]bool{})
+
+	snClientMock.AssertNumberOfCalls(t, "GetIncidents", 2)
+	snClientMock.AssertCalled(t, "GetIncidents", map[string]string{"sysparm_query": "sys_id=42", "sysparm_fields": "sys_id,number,state"})
+	if got := testutil.ToFloat64(webhookResolveConfirmations.WithLabelValues("confirmed")); got != confirmed+1 {
+		t.Errorf("Unexpected confirmations: got %v, want %v", got, confirmed+1)
+	}
+	if _, ok := history.get("group"); ok {
+		t.Errorf("Confirmed resolution must not be recorded")
+	}
+}
+
+func TestAwaitResolution_Unconfirmed(t *testing.T) {
+	history = newGroupHistory(10)
+	snClientMock := new(MockedSnClient)
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{{"sys_id": "42", "number": "INC42", "state": "2"}}, nil)
+
+	unconfirmed := testutil.ToFloat64(webhookResolveConfirmations.WithLabelValues("unconfirmed"))
+	awaitResolution(snClientMock, SourceConfig{}, "group", Incident{"sys_id": "42", "number": "INC42"},
+		ResolveConfirmationConfig{Attempts: 2, Interval: time.Millisecond}, "6", map[json.Number]bool{"7": true})
+
+	snClientMock.AssertNumberOfCalls(t, "GetIncidents", 2)
+	if got := testutil.ToFloat64(webhookResolveConfirmations.WithLabelValues("unconfirmed")); got != unconfirmed+1 {
+		t.Errorf("Unexpected unconfirmed resolutions: got %v, want %v", got, unconfirmed+1)
+	}
+	entries, ok := history.get("group")
+	if !ok || len(entries) != 1 || entries[0].Action != "resolve_unconfirmed" || entries[0].Incident != "INC42" || len(entries[0].Error) == 0 {
+		t.Errorf("Unexpected history: %+v", entries)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRetryInitialBackoff = 500 * time.Millisecond
+	defaultRetryMaxBackoff     = 10 * time.Second
+)
+
+// RetryConfig - Retry of ServiceNow requests failing with a transient error (429, 502, 503, 504, or a network
+// error on a read or an update), with exponential backoff
+type RetryConfig struct {
+	// Maximum number of attempts of a request, requests are not retried when not set
+	MaxAttempts int `yaml:"max_attempts"`
+	// Backoff before the first retry, doubled on each retry, 500ms by default
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	// Maximum backoff, also capping the Retry-After returned by ServiceNow, 10s by default
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+}
+
+func (c RetryConfig) maxBackoff() time.Duration {
+	if c.MaxBackoff > 0 {
+		return c.MaxBackoff
+	}
+	return defaultRetryMaxBackoff
+}
+
+// backoff returns the delay before the retry following the attempt: the Retry-After returned by ServiceNow
+// if any, or an exponential backoff with jitter, capped by the maximum backoff
+func (c RetryConfig) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		if retryAfter > c.maxBackoff() {
+			return c.maxBackoff()
+		}
+		return retryAfter
+	}
+	backoff := c.InitialBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryInitialBackoff
+	}
+	for i := 1; i < attempt && backoff < c.maxBackoff(); i++ {
+		backoff *= 2
+	}
+	if backoff > c.maxBackoff() {
+		backoff = c.maxBackoff()
+	}
+	// Jitter between half and the full backoff, spreading the retries of concurrent notifications
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// isTransientError returns true if the request failed with an error which may not happen again. Network errors
+// of creations are not retried, as the record may have been created.
+func isTransientError(req *http.Request, err error) bool {
+	var httpErr *serviceNowHTTPError
+	if !errors.As(err, &httpErr) {
+		return req.Method != http.MethodPost
+	}
+	switch httpErr.statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter returns the delay of a Retry-After header, in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if len(value) == 0 {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Sub(now())
+	}
+	return 0
+}
+
+// doRequest sends the request, retrying it on transient errors as configured
+func (snClient *ServiceNowClient) doRequest(req *http.Request) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		body, err := snClient.doRequestOnce(req)
+		if err == nil || attempt >= snClient.retry.MaxAttempts || !isTransientError(req, err) {
+			return body, err
+		}
+
+		var retryAfter time.Duration
+		var httpErr *serviceNowHTTPError
+		if errors.As(err, &httpErr) {
+			retryAfter = httpErr.retryAfter
+		}
+		delay := snClient.retry.backoff(attempt, retryAfter)
+		requestLog(req.Context()).Warnf("ServiceNow %s request failed (attempt %d/%d), retrying in %s: %v", req.Method, attempt, snClient.retry.MaxAttempts, delay, err)
+		serviceNowRetries.Inc()
+		time.Sleep(delay)
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
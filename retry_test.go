@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDoRequestRetry(t *testing.T) {
+	var attempts int
+	var bodies []string
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if attempts < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"result":{"number":"INC0001"}}`)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer ts.Close()
+
+	snClient, err := NewServiceNowClient("instancename", "username", "password")
+	if err != nil {
+		t.Fatalf("Error occured on NewServiceNowClient: %s", err)
+	}
+	snClient.baseURL = ts.URL
+
+	if _, err := snClient.CreateIncident(Incident{"short_description": "test"}); err == nil {
+		t.Errorf("Requests must not be retried when disabled")
+	}
+	if attempts != 1 {
+		t.Errorf("Unexpected number of attempts when disabled: got %d, want 1", attempts)
+	}
+
+	attempts = 0
+	bodies = nil
+	snClient.retry = RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+	incident, err := snClient.CreateIncident(Incident{"short_description": "test"})
+	if err != nil {
+		t.Fatalf("Error occured on CreateIncident: %s", err)
+	}
+	if incident.GetNumber() != "INC0001" {
+		t.Errorf("Unexpected incident: %v", incident)
+	}
+	if attempts != 3 {
+		t.Errorf("Unexpected number of attempts: got %d, want 3", attempts)
+	}
+	for _, body := range bodies {
+		if body != bodies[0] || len(body) == 0 {
+			t.Errorf("Request body must be sent again on retries, got %q", bodies)
+			break
+		}
+	}
+}
+
+func TestDoRequestNoRetryOnClientError(t *testing.T) {
+	var attempts int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	snClient, err := NewServiceNowClient("instancename", "username", "password")
+	if err != nil {
+		t.Fatalf("Error occured on NewServiceNowClient: %s", err)
+	}
+	snClient.baseURL = ts.URL
+	snClient.retry = RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	if _, err := snClient.GetIncidents(map[string]string{"number": "INC0001"}); err == nil {
+		t.Errorf("Expected an error")
+	}
+	if attempts != 1 {
+		t.Errorf("Client errors must not be retried: got %d attempts", attempts)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	c := RetryConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		attempt    int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{1, 0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{3, 0, 200 * time.Millisecond, 400 * time.Millisecond},
+		{10, 0, 500 * time.Millisecond, time.Second},
+		{1, 300 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond},
+		{1, time.Minute, time.Second, time.Second},
+	}
+	for _, test := range tests {
+		if backoff := c.backoff(test.attempt, test.retryAfter); backoff < test.min || backoff > test.max {
+			t.Errorf("Unexpected backoff for attempt %d and Retry-After %v: got %v, want between %v and %v", test.attempt, test.retryAfter, backoff, test.min, test.max)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	current := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	tests := map[string]time.Duration{
+		"":                              0,
+		"5":                             5 * time.Second,
+		"Wed, 01 Jan 2020 12:00:30 GMT": 30 * time.Second,
+		"invalid":                       0,
+	}
+	for value, want := range tests {
+		if got := parseRetryAfter(value); got != want {
+			t.Errorf("Unexpected Retry-After delay for %q: got %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// FieldRuleConfig - Conditional incident field value, the value of the first rule matching the
+// alert group is used. A rule without condition sets the default value.
+type FieldRuleConfig struct {
+	// Comma separated label matchers, e.g.: team="db", severity=~"critical|major"
+	If      string  `yaml:"if"`
+	Value   string  `yaml:"value"`
+	Default *string `yaml:"default"`
+}
+
+type labelMatcher struct {
+	name     string
+	value    string
+	negative bool
+	regex    *regexp.Regexp
+}
+
+type fieldRule struct {
+	matchers []labelMatcher
+	value    string
+}
+
+var labelMatcherRegexp = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*("(?:[^"\\]|\\.)*")\s*$`)
+
+// compileFieldRules compiles the conditional values of each field
+func compileFieldRules(fieldRules map[string][]FieldRuleConfig) (map[string][]fieldRule, error) {
+	compiled := make(map[string][]fieldRule, len(fieldRules))
+	for field, rules := range fieldRules {
+		for i, r := range rules {
+			rule, err := r.compile()
+			if err != nil {
+				return nil, fmt.Errorf("field_rules %s[%d]: %v", field, i, err)
+			}
+			compiled[field] = append(compiled[field], rule)
+		}
+	}
+	return compiled, nil
+}
+
+func (r FieldRuleConfig) compile() (fieldRule, error) {
+	if r.Default != nil {
+		if len(r.If) > 0 {
+			return fieldRule{}, fmt.Errorf("default rule must not have a condition")
+		}
+		return fieldRule{value: *r.Default}, nil
+	}
+	if len(r.If) == 0 {
+		return fieldRule{}, fmt.Errorf("rule must have a condition or be a default")
+	}
+
+	rule := fieldRule{value: r.Value}
+	for _, condition := range splitMatchers(r.If) {
+		matcher, err := parseLabelMatcher(condition)
+		if err != nil {
+			return fieldRule{}, err
+		}
+		rule.matchers = append(rule.matchers, matcher)
+	}
+	return rule, nil
+}
+
+// splitMatchers splits comma separated matchers, ignoring commas in quoted values
+func splitMatchers(conditions string) []string {
+	var matchers []string
+	var current strings.Builder
+	quoted, escaped := false, false
+	for _, c := range conditions {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			matchers = append(matchers, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteRune(c)
+	}
+	return append(matchers, current.String())
+}
+
+func parseLabelMatcher(condition string) (labelMatcher, error) {
+	parts := labelMatcherRegexp.FindStringSubmatch(condition)
+	if parts == nil {
+		return labelMatcher{}, fmt.Errorf("invalid condition %q, expected label=\"value\", label!=\"value\", label=~\"regex\" or label!~\"regex\"", strings.TrimSpace(condition))
+	}
+	value, err := strconv.Unquote(parts[3])
+	if err != nil {
+		return labelMatcher{}, err
+	}
+
+	matcher := labelMatcher{name: parts[1], value: value, negative: strings.HasPrefix(parts[2], "!")}
+	if strings.HasSuffix(parts[2], "~") {
+		if matcher.regex, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+			return labelMatcher{}, err
+		}
+	}
+	return matcher, nil
+}
+
+func (m labelMatcher) matches(labels template.KV) bool {
+	value := labels[m.name]
+	var matched bool
+	if m.regex != nil {
+		matched = m.regex.MatchString(value)
+	} else {
+		matched = value == m.value
+	}
+	return matched != m.negative
+}
+
+// applyFieldRules sets the fields having a rule matching the common labels of the alert group
+func applyFieldRules(incident Incident, data template.Data) {
+	for field, rules := range fieldRules {
+		for _, rule := range rules {
+			matched := true
+			for _, matcher := range rule.matchers {
+				if !matcher.matches(data.CommonLabels) {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				incident[field] = rule.value
+				break
+			}
+		}
+	}
+}
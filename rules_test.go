@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestApplyFieldRules(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	rulesConfig := map[string][]FieldRuleConfig{}
+	err := yaml.UnmarshalStrict([]byte(`
+category:
+  - if: 'team="db"'
+    value: Database
+  - if: 'team=~"net.*", severity!="info"'
+    value: Network
+  - default: Infrastructure
+urgency:
+  - if: 'severity="critical"'
+    value: "1"
+`), &rulesConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fieldRules, err = compileFieldRules(rulesConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		labels   template.KV
+		category string
+		urgency  interface{}
+	}{
+		{template.KV{"team": "db", "severity": "critical"}, "Database", "1"},
+		{template.KV{"team": "network", "severity": "warning"}, "Network", "2"},
+		{template.KV{"team": "network", "severity": "info"}, "Infrastructure", "2"},
+		{template.KV{}, "Infrastructure", "2"},
+	}
+	for _, test := range tests {
+		incident := Incident{"category": "default", "urgency": "2"}
+		applyFieldRules(incident, template.Data{CommonLabels: test.labels})
+		if incident["category"] != test.category || incident["urgency"] != test.urgency {
+			t.Errorf("Unexpected fields for labels %v: got %v, %v, want %v, %v", test.labels, incident["category"], incident["urgency"], test.category, test.urgency)
+		}
+	}
+}
+
+func TestCompileFieldRules_Invalid(t *testing.T) {
+	defaultValue := "x"
+	invalid := []FieldRuleConfig{
+		{Value: "missing condition"},
+		{If: `team="db"`, Default: &defaultValue},
+		{If: `team==db`, Value: "x"},
+		{If: `team=~"("`, Value: "x"},
+	}
+	for _, rule := range invalid {
+		if _, err := compileFieldRules(map[string][]FieldRuleConfig{"category": {rule}}); err == nil {
+			t.Errorf("Rule %+v must be invalid", rule)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Actions which can be scheduled on an incident
+const (
+	actionResolve = "resolve"
+	// Update of the journal entries deferred during quiet hours
+	actionJournal = "journal"
+)
+
+// AutoResolveConfig - Incident resolution when its alert group is resolved
+type AutoResolveConfig struct {
+	State json.Number `yaml:"state"`
+	// Grace period before resolving, cancelled if the alert group fires again
+	Delay        time.Duration             `yaml:"delay"`
+	Fields       map[string]string         `yaml:"fields"`
+	Confirmation ResolveConfirmationConfig `yaml:"confirmation"`
+	// Send the resolution fields only, instead of the update fields, when resolving without delay
+	ResolveOnly bool `yaml:"resolve_only"`
+}
+
+// scheduledAction is an incident action delayed until its fire time
+type scheduledAction struct {
+	GroupKey string `json:"group_key"`
+	Action   string `json:"action"`
+	// Status of the alert group which scheduled the action, resolved when not set
+	Status         string `json:"status,omitempty"`
+	IncidentSysID  string `json:"incident_sys_id"`
+	IncidentNumber string `json:"incident_number"`
+	// Name of the ServiceNow instance of the incident, empty for the default instance
+	Instance string    `json:"instance,omitempty"`
+	Params   Incident  `json:"params"`
+	FireAt   time.Time `json:"fire_at"`
+}
+
+// actionScheduler keeps the pending actions, at most one per group key
+type actionScheduler struct {
+	mu      sync.Mutex
+	path    string
+	actions map[string]scheduledAction
+}
+
+var scheduler = newActionScheduler()
+
+func newActionScheduler() *actionScheduler {
+	return &actionScheduler{actions: make(map[string]scheduledAction)}
+}
+
+// configure loads the persisted pending actions from path, if set
+func (s *actionScheduler) configure(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	if len(path) == 0 {
+		return
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("Error reading schedule file: %v", err)
+		}
+		return
+	}
+	actions := make(map[string]scheduledAction)
+	if err := json.Unmarshal(content, &actions); err != nil {
+		log.Errorf("Error parsing schedule file: %v", err)
+		return
+	}
+	for groupKey, action := range actions {
+		s.actions[groupKey] = action
+	}
+	s.updateMetrics()
+}
+
+// schedule replaces the pending action of the group key
+func (s *actionScheduler) schedule(action scheduledAction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions[action.GroupKey] = action
+	s.updateMetrics()
+	s.persist()
+}
+
+// cancel removes the pending action of the group key, returning true if there was one
+func (s *actionScheduler) cancel(groupKey string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.actions[groupKey]; !ok {
+		return false
+	}
+	delete(s.actions, groupKey)
+	s.updateMetrics()
+	s.persist()
+	return true
+}
+
+// get returns the pending action of the group key
+func (s *actionScheduler) get(groupKey string) (scheduledAction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	action, ok := s.actions[groupKey]
+	return action, ok
+}
+
+// cancelAction removes the pending action of the group key if it is of the kind, returning true if there was one
+func (s *actionScheduler) cancelAction(groupKey string, kind string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if action, ok := s.actions[groupKey]; !ok || action.Action != kind {
+		return false
+	}
+	delete(s.actions, groupKey)
+	s.updateMetrics()
+	s.persist()
+	return true
+}
+
+// pending returns the pending actions sorted by fire time
+func (s *actionScheduler) pending() []scheduledAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	actions := make([]scheduledAction, 0, len(s.actions))
+	for _, action := range s.actions {
+		actions = append(actions, action)
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i].FireAt.Before(actions[j].FireAt) })
+	return actions
+}
+
+// fireDue executes the actions whose fire time is reached. Failed actions are retried on next call.
+func (s *actionScheduler) fireDue() {
+	for _, action := range s.pending() {
+		if now().Before(action.FireAt) {
+			return
+		}
+		s.fire(action)
+	}
+}
+
+func (s *actionScheduler) fire(action scheduledAction) {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	unlock := progress.lock(action.GroupKey)
+	defer unlock()
+
+	// The action may have been cancelled or replaced while waiting for the lock
+	s.mu.Lock()
+	current, ok := s.actions[action.GroupKey]
+	s.mu.Unlock()
+	if !ok || !current.FireAt.Equal(action.FireAt) {
+		return
+	}
+
+	logger := groupKeyLog(action.GroupKey, Incident{"sys_id": action.IncidentSysID, "number": action.IncidentNumber})
+	logger.Infof("Firing scheduled %s of incident (%s) for alert group key: %s", action.Action, action.IncidentNumber, action.GroupKey)
+	_, err := serviceNowByName(action.Instance).UpdateIncident(action.Params, action.IncidentSysID)
+	status := action.Status
+	if len(status) == 0 {
+		status = "resolved"
+	}
+	history.record(action.GroupKey, status, action.Action, action.IncidentNumber, err)
+	incidents.invalidate(action.GroupKey)
+	if err != nil {
+		serviceNowError.Inc()
+		logger.Errorf("Error firing scheduled %s of incident (%s): %v", action.Action, action.IncidentNumber, err)
+		return
+	}
+	if action.Action == actionResolve {
+		webhookIncidentsResolved.WithLabelValues("scheduled").Inc()
+		confirmResolution(action.Instance, action.GroupKey, Incident{"sys_id": action.IncidentSysID, "number": action.IncidentNumber})
+	}
+	s.cancel(action.GroupKey)
+}
+
+// run fires the due actions at every interval
+func (s *actionScheduler) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.fireDue()
+	}
+}
+
+// updateMetrics refreshes the scheduled actions gauges, must be called with the lock held
+func (s *actionScheduler) updateMetrics() {
+	webhookScheduledActions.Reset()
+	webhookScheduledActionNextFire.Reset()
+	nextFire := make(map[string]time.Time)
+	for _, action := range s.actions {
+		webhookScheduledActions.WithLabelValues(action.Action).Inc()
+		if next, ok := nextFire[action.Action]; !ok || action.FireAt.Before(next) {
+			nextFire[action.Action] = action.FireAt
+		}
+	}
+	for action, fireAt := range nextFire {
+		webhookScheduledActionNextFire.WithLabelValues(action).Set(float64(fireAt.Unix()))
+	}
+}
+
+// persist writes the pending actions in the schedule file, if any, must be called with the lock held
+func (s *actionScheduler) persist() {
+	if len(s.path) == 0 {
+		return
+	}
+	content, err := json.Marshal(s.actions)
+	if err == nil {
+		err = ioutil.WriteFile(s.path+".tmp", content, 0600)
+	}
+	if err == nil {
+		err = os.Rename(s.path+".tmp", s.path)
+	}
+	if err != nil {
+		log.Errorf("Error writing schedule file: %v", err)
+	}
+}
+
+// scheduledActionsHandler serves the pending scheduled actions on /api/v1/scheduled
+func scheduledActionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scheduler.pending())
+}
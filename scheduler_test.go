@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestApplyAutoResolve_Delayed(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.AutoResolve = AutoResolveConfig{State: "6", Delay: 5 * time.Minute, Fields: map[string]string{"close_notes": "{{ .Status }}"}}
+	scheduler = newActionScheduler()
+	now = func() time.Time { return time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	data := template.Data{Status: "resolved", GroupLabels: template.KV{"alertname": "test"}}
+	updateParam := Incident{}
+	applyAutoResolve(context.Background(), data, Incident{"sys_id": "42", "number": "INC42"}, updateParam)
+	if _, ok := updateParam["state"]; ok {
+		t.Errorf("State must not be updated before the delay")
+	}
+
+	pending := scheduler.pending()
+	if len(pending) != 1 || pending[0].Params["close_notes"] != "resolved" || !pending[0].FireAt.Equal(now().Add(5*time.Minute)) {
+		t.Fatalf("Unexpected scheduled actions: %+v", pending)
+	}
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("UpdateIncident", mock.Anything, "42").Return(Incident{}, nil)
+
+	scheduler.fireDue()
+	snClientMock.AssertNotCalled(t, "UpdateIncident", mock.Anything, mock.Anything)
+
+	now = func() time.Time { return time.Date(2020, 1, 1, 12, 5, 0, 0, time.UTC) }
+	resolved := testutil.ToFloat64(webhookIncidentsResolved.WithLabelValues("scheduled"))
+	scheduler.fireDue()
+	snClientMock.AssertCalled(t, "UpdateIncident", Incident{"state": "6", "close_notes": "resolved"}, "42")
+	if got := testutil.ToFloat64(webhookIncidentsResolved.WithLabelValues("scheduled")); got != resolved+1 {
+		t.Errorf("Unexpected scheduled resolutions: got %v, want %v", got, resolved+1)
+	}
+	if len(scheduler.pending()) != 0 {
+		t.Errorf("Fired action must no longer be pending")
+	}
+}
+
+func TestApplyAutoResolve_Immediate(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.AutoResolve = AutoResolveConfig{State: "6"}
+	scheduler = newActionScheduler()
+
+	updateParam := Incident{}
+	applyAutoResolve(context.Background(), template.Data{Status: "resolved"}, Incident{"sys_id": "42"}, updateParam)
+	if updateParam["state"] != "6" {
+		t.Errorf("Unexpected state: got %v, want %v", updateParam["state"], "6")
+	}
+	if !resolvesIncident(updateParam) {
+		t.Errorf("Update must resolve the incident")
+	}
+	if len(scheduler.pending()) != 0 {
+		t.Errorf("No action must be scheduled without delay")
+	}
+}
+
+func TestApplyAutoResolve_ResolveOnly(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Workflow.AutoResolve = AutoResolveConfig{State: "6", Fields: map[string]string{"close_code": "Solved"}, ResolveOnly: true}
+	scheduler = newActionScheduler()
+
+	updateParam := Incident{"comments": "Alert group resolved", "impact": "2"}
+	applyAutoResolve(context.Background(), template.Data{Status: "resolved"}, Incident{"sys_id": "42"}, updateParam)
+	want := Incident{"state": "6", "close_code": "Solved"}
+	if len(updateParam) != len(want) || updateParam["state"] != "6" || updateParam["close_code"] != "Solved" {
+		t.Errorf("Unexpected update: got %v, want %v", updateParam, want)
+	}
+}
+
+func TestActionScheduler_Persist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scheduler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "schedule.json")
+
+	s := newActionScheduler()
+	s.configure(path)
+	s.schedule(scheduledAction{GroupKey: "a", Action: actionResolve, IncidentSysID: "42", FireAt: time.Now()})
+	s.schedule(scheduledAction{GroupKey: "b", Action: actionResolve, IncidentSysID: "43", FireAt: time.Now()})
+	if !s.cancel("b") {
+		t.Errorf("Pending action must be cancelled")
+	}
+
+	restarted := newActionScheduler()
+	restarted.configure(path)
+	pending := restarted.pending()
+	if len(pending) != 1 || pending[0].GroupKey != "a" {
+		t.Errorf("Unexpected reloaded actions: %+v", pending)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -10,14 +11,18 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
-
-	"github.com/prometheus/common/log"
+	"sync"
+	"time"
 )
 
 const (
-	serviceNowBaseURL   = "https://https://dev96535.service-now.com"
+	serviceNowBaseURL   = "https://%s.service-now.com"
 	tableAPI            = "%s/api/now/v2/table/%s"
+	attachmentAPI       = "%s/api/now/attachment"
+	batchAPI            = "%s/api/now/v1/batch"
 	hibernatingInstance = "Hibernating Instance"
+	// ServiceNow Table API returns date/time fields in UTC with this layout
+	serviceNowTimeLayout = "2006-01-02 15:04:05"
 )
 
 // Incident is a model of the ServiceNow incident table
@@ -25,12 +30,14 @@ type Incident map[string]interface{}
 
 // GetSysID returns the sys_id of the incident
 func (i Incident) GetSysID() string {
-	return i["sys_id"].(string)
+	sysID, _ := i["sys_id"].(string)
+	return sysID
 }
 
 // GetNumber returns the number of the incident
 func (i Incident) GetNumber() string {
-	return i["number"].(string)
+	number, _ := i["number"].(string)
+	return number
 }
 
 // GetState returns the state of the incident
@@ -38,6 +45,23 @@ func (i Incident) GetState() json.Number {
 	return json.Number(i["state"].(string))
 }
 
+// GetResolvedAt returns the time the incident was resolved (or closed), and false
+// if none of these fields can be parsed. The last update time is no resolution
+// time, as any later journal entry or field change would move it.
+func (i Incident) GetResolvedAt() (time.Time, bool) {
+	for _, field := range []string{"resolved_at", "closed_at"} {
+		value, ok := i[field].(string)
+		if !ok || len(value) == 0 {
+			continue
+		}
+		t, err := time.Parse(serviceNowTimeLayout, value)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
 // IncidentResponse is a model of an API response contaning one incident
 type IncidentResponse map[string]interface{}
 
@@ -64,16 +88,43 @@ func (ir IncidentsResponse) GetResults() []Incident {
 type ServiceNow interface {
 	CreateIncident(incidentParam Incident) (Incident, error)
 	GetIncidents(params map[string]string) ([]Incident, error)
+	GetRecords(table string, params map[string]string) ([]Incident, error)
+	CreateRecord(table string, recordParam Incident) (Incident, error)
 	UpdateIncident(incidentParam Incident, sysID string) (Incident, error)
+	AttachFile(table string, sysID string, fileName string, contentType string, content []byte) error
 }
 
 // ServiceNowClient is the interface to a ServiceNow instance
 type ServiceNowClient struct {
-	baseURL    string
-	authHeader string
-	client     *http.Client
+	baseURL            string
+	authHeader         string
+	client             *http.Client
+	inputDisplayValue  bool
+	displayValueFields map[string]bool
+	userName           string
+	passwordFile       string
+	capabilities       map[string]bool
+	rateLimit          RateLimitConfig
+	incidentTable      string
+	fieldMap           map[string]string
+	reverseFieldMap    map[string]string
+	rateLimitState     rateLimitState
+	oauth2             *oauth2TokenSource
+	retry              RetryConfig
+	tableAPIParams     TableAPIParamsConfig
+	// Notification of rejected credentials, and the time it was last sent
+	authFailureNotification     AuthFailureNotificationConfig
+	lastAuthFailureNotification time.Time
+	mu                          sync.RWMutex
 }
 
+// Optional ServiceNow APIs probed at startup
+const (
+	capabilityTable      = "table"
+	capabilityAttachment = "attachment"
+	capabilityBatch      = "batch"
+)
+
 // NewServiceNowClient will create a new ServiceNow client
 func NewServiceNowClient(instanceName string, userName string, password string) (*ServiceNowClient, error) {
 	if instanceName == "" {
@@ -90,71 +141,285 @@ func NewServiceNowClient(instanceName string, userName string, password string)
 
 	return &ServiceNowClient{
 		baseURL:    fmt.Sprintf(serviceNowBaseURL, instanceName),
-		authHeader: fmt.Sprintf("Basic %s", base64.URLEncoding.EncodeToString([]byte(userName+":"+password))),
+		authHeader: basicAuthHeader(userName, password),
 		client:     http.DefaultClient,
+		userName:   userName,
 	}, nil
 }
 
+func basicAuthHeader(userName string, password string) string {
+	return fmt.Sprintf("Basic %s", base64.URLEncoding.EncodeToString([]byte(userName+":"+password)))
+}
+
+// newServiceNowClientFromConfig will create a new ServiceNow client with all options from the given configuration
+func newServiceNowClientFromConfig(c ServiceNowConfig) (*ServiceNowClient, error) {
+	if c.OAuth2.enabled() {
+		return newOAuth2ServiceNowClient(c)
+	}
+
+	password := c.Password
+	if len(c.PasswordFile) > 0 {
+		var err error
+		if password, err = readSecretFile(c.PasswordFile); err != nil {
+			return nil, err
+		}
+	}
+
+	snClient, err := NewServiceNowClient(c.InstanceName, c.UserName, password)
+	if err != nil {
+		return nil, err
+	}
+	if snClient.client, err = newServiceNowHTTPClient(c); err != nil {
+		return nil, err
+	}
+	applyServiceNowClientOptions(snClient, c)
+	return snClient, nil
+}
+
+// newOAuth2ServiceNowClient will create a new ServiceNow client authenticated with OAuth2
+func newOAuth2ServiceNowClient(c ServiceNowConfig) (*ServiceNowClient, error) {
+	if c.InstanceName == "" {
+		return nil, errors.New("Missing instanceName")
+	}
+	client, err := newServiceNowHTTPClient(c)
+	if err != nil {
+		return nil, err
+	}
+	snClient := &ServiceNowClient{
+		baseURL:  fmt.Sprintf(serviceNowBaseURL, c.InstanceName),
+		client:   client,
+		userName: c.UserName,
+		oauth2:   newOAuth2TokenSource(c.OAuth2, c.InstanceName),
+	}
+	snClient.oauth2.client = client
+	applyServiceNowClientOptions(snClient, c)
+	return snClient, nil
+}
+
+// applyServiceNowClientOptions sets the options of the configuration to the client
+func applyServiceNowClientOptions(snClient *ServiceNowClient, c ServiceNowConfig) {
+	snClient.passwordFile = c.PasswordFile
+	snClient.rateLimit = c.RateLimit
+	snClient.retry = c.Retry
+	snClient.authFailureNotification = c.AuthFailureNotification
+	snClient.tableAPIParams = c.TableAPIParams
+	snClient.incidentTable = c.Table
+	snClient.setFieldMap(c.FieldMap)
+
+	snClient.inputDisplayValue = c.InputDisplayValue
+	snClient.displayValueFields = make(map[string]bool, len(c.DisplayValueFields))
+	for _, f := range c.DisplayValueFields {
+		snClient.displayValueFields[f] = true
+	}
+}
+
+// readSecretFile returns the content of a secret file, without trailing new lines
+func readSecretFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// getAuthHeader returns the current Authorization header value
+func (snClient *ServiceNowClient) getAuthHeader() string {
+	snClient.mu.RLock()
+	defer snClient.mu.RUnlock()
+	return snClient.authHeader
+}
+
+// reloadPassword re-reads the password file and returns true if the password changed
+func (snClient *ServiceNowClient) reloadPassword() bool {
+	if len(snClient.passwordFile) == 0 {
+		return false
+	}
+
+	password, err := readSecretFile(snClient.passwordFile)
+	if err != nil {
+		log.Errorf("Error reading ServiceNow password file. %s", err)
+		return false
+	}
+
+	authHeader := basicAuthHeader(snClient.userName, password)
+	snClient.mu.Lock()
+	defer snClient.mu.Unlock()
+	if authHeader == snClient.authHeader || len(password) == 0 {
+		return false
+	}
+	snClient.authHeader = authHeader
+	log.Info("ServiceNow password file changed, credentials reloaded")
+	return true
+}
+
+// reloadCredentials drops the OAuth2 access token, or re-reads the password file, and returns true if the request can be retried
+func (snClient *ServiceNowClient) reloadCredentials() bool {
+	if snClient.oauth2 != nil {
+		return snClient.oauth2.invalidate()
+	}
+	return snClient.reloadPassword()
+}
+
+// watchPasswordFile periodically reloads the password file until done is closed
+func (snClient *ServiceNowClient) watchPasswordFile(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			snClient.reloadPassword()
+		case <-done:
+			return
+		}
+	}
+}
+
 // Create a table item in ServiceNow from a post body
-func (snClient *ServiceNowClient) create(table string, body []byte) ([]byte, error) {
+func (snClient *ServiceNowClient) create(ctx context.Context, table string, body []byte, params map[string]string) ([]byte, error) {
 	url := fmt.Sprintf(tableAPI, snClient.baseURL, table)
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
 	if err != nil {
-		log.Errorf("Error creating the request. %s", err)
+		requestLog(ctx).Errorf("Error creating the request. %s", err)
 		return nil, err
 	}
+	req = req.WithContext(ctx)
+	setQueryParams(req, withTableAPIParams(snClient.tableAPIParams.Create, params))
 
 	return snClient.doRequest(req)
 }
 
 // get a table item from ServiceNow using a map of arguments
-func (snClient *ServiceNowClient) get(table string, params map[string]string) ([]byte, error) {
+func (snClient *ServiceNowClient) get(ctx context.Context, table string, params map[string]string) ([]byte, error) {
 	url := fmt.Sprintf(tableAPI, snClient.baseURL, table)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Errorf("Error creating the request. %s", err)
+		requestLog(ctx).Errorf("Error creating the request. %s", err)
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 
-	q := req.URL.Query()
-	for key, val := range params {
-		q.Add(key, val)
-	}
-	req.URL.RawQuery = q.Encode()
+	setQueryParams(req, withTableAPIParams(snClient.tableAPIParams.Get, params))
 
 	return snClient.doRequest(req)
 }
 
 // update a table item in ServiceNow from a post body and a sys_id
-func (snClient *ServiceNowClient) update(table string, body []byte, sysID string) ([]byte, error) {
+func (snClient *ServiceNowClient) update(ctx context.Context, table string, body []byte, sysID string, params map[string]string) ([]byte, error) {
 	url := fmt.Sprintf(tableAPI+"/%s", snClient.baseURL, table, sysID)
 	req, err := http.NewRequest("PUT", url, bytes.NewBuffer(body))
 	if err != nil {
-		log.Errorf("Error creating the request. %s", err)
+		requestLog(ctx).Errorf("Error creating the request. %s", err)
 		return nil, err
 	}
+	req = req.WithContext(ctx)
+	setQueryParams(req, withTableAPIParams(snClient.tableAPIParams.Update, params))
 
 	return snClient.doRequest(req)
 }
 
-// doRequest will do the given ServiceNow request and return response as byte array
-func (snClient *ServiceNowClient) doRequest(req *http.Request) ([]byte, error) {
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", snClient.authHeader)
-	resp, err := snClient.client.Do(req)
+// AttachFile attaches a file to a table item in ServiceNow
+func (snClient *ServiceNowClient) AttachFile(table string, sysID string, fileName string, contentType string, content []byte) error {
+	return snClient.attachFileContext(context.Background(), table, sysID, fileName, contentType, content)
+}
+
+func (snClient *ServiceNowClient) attachFileContext(ctx context.Context, table string, sysID string, fileName string, contentType string, content []byte) error {
+	if !snClient.hasCapability(capabilityAttachment) {
+		return errors.New("ServiceNow attachment API is not available")
+	}
 
+	url := fmt.Sprintf(attachmentAPI+"/file", snClient.baseURL)
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(content))
+	if err != nil {
+		requestLog(ctx).Errorf("Error creating the request. %s", err)
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	setQueryParams(req, map[string]string{
+		"table_name":   table,
+		"table_sys_id": sysID,
+		"file_name":    fileName,
+	})
+
+	_, err = snClient.doRequest(req)
+	return err
+}
+
+// getIncidentTable returns the table holding the incidents, incident by default
+func (snClient *ServiceNowClient) getIncidentTable() string {
+	if len(snClient.incidentTable) == 0 {
+		return "incident"
+	}
+	return snClient.incidentTable
+}
+
+// setQueryParams adds the given params to the request URL query
+func setQueryParams(req *http.Request, params map[string]string) {
+	q := req.URL.Query()
+	for key, val := range params {
+		q.Add(key, val)
+	}
+	req.URL.RawQuery = q.Encode()
+}
+
+// writeParams returns the Table API params of a create or update request
+func writeParams(inputDisplayValue bool) map[string]string {
+	return map[string]string{
+		"sysparm_input_display_value": strconv.FormatBool(inputDisplayValue),
+	}
+}
+
+// splitDisplayValueFields splits the incident between fields written as values and fields written as display values
+func (snClient *ServiceNowClient) splitDisplayValueFields(incidentParam Incident) (Incident, Incident) {
+	if snClient.inputDisplayValue || len(snClient.displayValueFields) == 0 {
+		return incidentParam, Incident{}
+	}
+
+	valueParam := Incident{}
+	displayValueParam := Incident{}
+	for field, value := range incidentParam {
+		if snClient.displayValueFields[field] {
+			displayValueParam[field] = value
+		} else {
+			valueParam[field] = value
+		}
+	}
+	return valueParam, displayValueParam
+}
+
+// doRequestOnce will do the given ServiceNow request and return response as byte array
+func (snClient *ServiceNowClient) doRequestOnce(req *http.Request) ([]byte, error) {
+	resp, err := snClient.send(req)
 	if err != nil {
-		log.Errorf("Error sending the request. %s", err)
 		return nil, err
 	}
 
-	serviceNowRequests.WithLabelValues(req.URL.Host, req.Method, strconv.Itoa(resp.StatusCode)).Inc()
-	serviceNowLastRequest.SetToCurrentTime()
+	// On authentication failure, the password may have been rotated or the access token revoked:
+	// reload them once and retry
+	if isAuthenticationFailure(resp.StatusCode) && snClient.reloadCredentials() {
+		resp.Body.Close()
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		if resp, err = snClient.send(req); err != nil {
+			return nil, err
+		}
+	}
 
 	if resp.StatusCode >= 400 {
-		errorMsg := fmt.Sprintf("ServiceNow returned the HTTP error code: %v", resp.StatusCode)
-		log.Error(errorMsg)
-		return nil, errors.New(errorMsg)
+		errorBody, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		archiveServiceNowExchange(req, resp.StatusCode, errorBody)
+		err := &serviceNowHTTPError{statusCode: resp.StatusCode, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		err.message, err.category = classifyServiceNowErrorBody(resp.StatusCode, errorBody)
+		serviceNowRequestErrors.WithLabelValues(serviceNowErrorClass(err), err.category).Inc()
+		requestLog(req.Context()).Errorf("%s (%s): %s", err, err.category, err.message)
+		if isAuthenticationFailure(resp.StatusCode) {
+			snClient.onAuthenticationFailure(err)
+		}
+		return nil, err
 	}
 
 	defer resp.Body.Close()
@@ -164,8 +429,10 @@ func (snClient *ServiceNowClient) doRequest(req *http.Request) ([]byte, error) {
 		log.Errorf("Error reading the body. %s", err)
 		return nil, err
 	}
+	archiveServiceNowExchange(req, resp.StatusCode, responseBody)
 
 	if !json.Valid(responseBody) {
+		serviceNowRequestErrors.WithLabelValues(errorClassUnavailable, errorCategoryUnknown).Inc()
 		if strings.Contains(string(responseBody), hibernatingInstance) {
 			return nil, errors.New("ServiceNow is in sleeping mode and is unavailable (Hibernating Instance)")
 		}
@@ -175,80 +442,344 @@ func (snClient *ServiceNowClient) doRequest(req *http.Request) ([]byte, error) {
 	return responseBody, nil
 }
 
+// Classes of ServiceNow errors
+const (
+	errorClassClient         = "client"
+	errorClassAuthentication = "authentication"
+	errorClassThrottled      = "throttled"
+	errorClassServer         = "server"
+	errorClassUnavailable    = "unavailable"
+)
+
+// serviceNowHTTPError is returned when ServiceNow answers with an HTTP error code
+type serviceNowHTTPError struct {
+	statusCode int
+	message    string
+	category   string
+	retryAfter time.Duration
+}
+
+// Bounded categories of ServiceNow error messages
+const (
+	errorCategoryACLDenied             = "acl_denied"
+	errorCategoryInvalidReference      = "invalid_reference"
+	errorCategoryMandatoryFieldMissing = "mandatory_field_missing"
+	errorCategoryUnknown               = "unknown"
+)
+
+// classifyServiceNowErrorBody returns the message of a ServiceNow error response
+// ({"error": {"message": "...", "detail": "..."}}) and its category
+func classifyServiceNowErrorBody(statusCode int, body []byte) (string, string) {
+	response := struct {
+		Error struct {
+			Message string `json:"message"`
+			Detail  string `json:"detail"`
+		} `json:"error"`
+	}{}
+	json.Unmarshal(body, &response)
+	message := strings.TrimSpace(response.Error.Message + " " + response.Error.Detail)
+
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "mandatory"):
+		return message, errorCategoryMandatoryFieldMissing
+	case strings.Contains(lower, "invalid reference") || strings.Contains(lower, "reference") && strings.Contains(lower, "not found"):
+		return message, errorCategoryInvalidReference
+	case statusCode == http.StatusForbidden || strings.Contains(lower, "acl") || strings.Contains(lower, "insufficient rights") || strings.Contains(lower, "not authorized"):
+		return message, errorCategoryACLDenied
+	}
+	return message, errorCategoryUnknown
+}
+
+func (e *serviceNowHTTPError) Error() string {
+	return fmt.Sprintf("ServiceNow returned the HTTP error code: %v", e.statusCode)
+}
+
+// serviceNowErrorClass returns the class of a ServiceNow error: client errors (4xx except 401 and 429),
+// rejected credentials (401), throttling (429), server errors (5xx), or unavailability for any other error
+func serviceNowErrorClass(err error) string {
+	var httpErr *serviceNowHTTPError
+	if !errors.As(err, &httpErr) {
+		return errorClassUnavailable
+	}
+	switch {
+	case httpErr.statusCode == http.StatusUnauthorized:
+		return errorClassAuthentication
+	case httpErr.statusCode == http.StatusTooManyRequests:
+		return errorClassThrottled
+	case httpErr.statusCode >= 500:
+		return errorClassServer
+	default:
+		return errorClassClient
+	}
+}
+
+// isRetryableError returns false for client errors, which would fail again when retried
+func isRetryableError(err error) bool {
+	return serviceNowErrorClass(err) != errorClassClient
+}
+
+// send will send the given ServiceNow request with the current credentials
+func (snClient *ServiceNowClient) send(req *http.Request) (*http.Response, error) {
+	if len(req.Header.Get("Content-Type")) == 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	authHeader := snClient.getAuthHeader()
+	if snClient.oauth2 != nil {
+		token, err := snClient.oauth2.token()
+		if err != nil {
+			log.Errorf("Error getting the OAuth2 access token. %s", err)
+			serviceNowRequestErrors.WithLabelValues(errorClassUnavailable, errorCategoryUnknown).Inc()
+			return nil, err
+		}
+		authHeader = "Bearer " + token
+	}
+	req.Header.Set("Authorization", authHeader)
+	if id := requestIDFrom(req.Context()); len(id) > 0 {
+		req.Header.Set(requestIDHeader, id)
+	}
+	snClient.waitRateLimit()
+	start := time.Now()
+	resp, err := snClient.client.Do(req)
+	serviceNowRequestDuration.WithLabelValues(req.URL.Host, req.Method).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		log.Errorf("Error sending the request. %s", err)
+		serviceNowRequestErrors.WithLabelValues(errorClassUnavailable, errorCategoryUnknown).Inc()
+		return nil, err
+	}
+
+	serviceNowRequests.WithLabelValues(req.URL.Host, req.Method, strconv.Itoa(resp.StatusCode)).Inc()
+	snClient.observeRateLimit(resp)
+	serviceNowLastRequest.SetToCurrentTime()
+	return resp, nil
+}
+
+// probeCapabilities checks which ServiceNow APIs are available with the configured
+// credentials, logs the capability matrix and exposes it as metrics
+func (snClient *ServiceNowClient) probeCapabilities() map[string]bool {
+	probes := map[string]struct {
+		url       string
+		available func(statusCode int) bool
+	}{
+		capabilityTable: {
+			url:       fmt.Sprintf(tableAPI, snClient.baseURL, snClient.getIncidentTable()) + "?sysparm_limit=1",
+			available: func(statusCode int) bool { return statusCode < 300 },
+		},
+		capabilityAttachment: {
+			url:       fmt.Sprintf(attachmentAPI, snClient.baseURL) + "?sysparm_limit=1",
+			available: func(statusCode int) bool { return statusCode < 300 },
+		},
+		// The batch API only accepts POST, a GET answering anything but 404 or an authorization error means it exists
+		capabilityBatch: {
+			url: fmt.Sprintf(batchAPI, snClient.baseURL),
+			available: func(statusCode int) bool {
+				return statusCode != http.StatusNotFound && statusCode != http.StatusUnauthorized && statusCode != http.StatusForbidden
+			},
+		},
+	}
+
+	capabilities := make(map[string]bool, len(probes))
+	for capability, probe := range probes {
+		capabilities[capability] = false
+		req, err := http.NewRequest("GET", probe.url, nil)
+		if err != nil {
+			log.Errorf("Error creating the %s capability probe request. %s", capability, err)
+			continue
+		}
+		resp, err := snClient.send(req)
+		if err != nil {
+			log.Warnf("ServiceNow %s capability could not be probed: %s", capability, err)
+			continue
+		}
+		resp.Body.Close()
+		capabilities[capability] = probe.available(resp.StatusCode)
+	}
+
+	for capability, available := range capabilities {
+		log.Infof("ServiceNow capability %s available: %v", capability, available)
+		if available {
+			serviceNowCapability.WithLabelValues(capability).Set(1)
+		} else {
+			serviceNowCapability.WithLabelValues(capability).Set(0)
+		}
+	}
+
+	snClient.mu.Lock()
+	defer snClient.mu.Unlock()
+	snClient.capabilities = capabilities
+	return capabilities
+}
+
+// hasCapability returns false only if the capability was probed as unavailable
+func (snClient *ServiceNowClient) hasCapability(capability string) bool {
+	snClient.mu.RLock()
+	defer snClient.mu.RUnlock()
+	available, probed := snClient.capabilities[capability]
+	return !probed || available
+}
+
 // CreateIncident will create an incident in ServiceNow from a given Incident, and return the created incident
 func (snClient *ServiceNowClient) CreateIncident(incidentParam Incident) (Incident, error) {
-	log.Info("Create a ServiceNow incident")
+	return snClient.createIncidentContext(context.Background(), incidentParam)
+}
 
-	postBody, err := json.Marshal(incidentParam)
+func (snClient *ServiceNowClient) createIncidentContext(ctx context.Context, incidentParam Incident) (Incident, error) {
+	requestLog(ctx).Info("Create a ServiceNow incident")
+
+	valueParam, displayValueParam := snClient.splitDisplayValueFields(incidentParam)
+	valueParam, displayValueParam = snClient.toTableFields(valueParam), snClient.toTableFields(displayValueParam)
+
+	postBody, err := json.Marshal(valueParam)
 	if err != nil {
-		log.Errorf("Error while marshalling the incident. %s", err)
+		requestLog(ctx).Errorf("Error while marshalling the incident. %s", err)
 		return nil, err
 	}
 
-	response, err := snClient.create("incident", postBody)
+	response, err := snClient.create(ctx, snClient.getIncidentTable(), postBody, writeParams(snClient.inputDisplayValue))
 	if err != nil {
-		log.Errorf("Error while creating the incident. %s", err)
+		requestLog(ctx).Errorf("Error while creating the incident. %s", err)
 		return nil, err
 	}
 
 	incidentResponse := IncidentResponse{}
 	err = json.Unmarshal(response, &incidentResponse)
 	if err != nil {
-		log.Errorf("Error while unmarshalling the incident. %s", err)
+		requestLog(ctx).Errorf("Error while unmarshalling the incident. %s", err)
 		return nil, err
 	}
 
-	createdIncident := incidentResponse.GetResult()
-	log.Infof("Incident %s created", createdIncident.GetNumber())
+	createdIncident := snClient.fromTableFields(incidentResponse.GetResult())
+	requestLog(ctx).WithFields(incidentLogFields(createdIncident)).Infof("Incident %s created", createdIncident.GetNumber())
+
+	if len(displayValueParam) > 0 {
+		updatedIncident, err := snClient.updateIncident(ctx, displayValueParam, createdIncident.GetSysID(), true)
+		if err != nil {
+			// The incident exists, failing would create it again when the notification is retried
+			serviceNowDisplayValueUpdateFailures.Inc()
+			requestLog(ctx).WithFields(incidentLogFields(createdIncident)).Errorf("Error while updating the display value fields of the created incident %s, they are not set. %s", createdIncident.GetNumber(), err)
+			return createdIncident, nil
+		}
+		return updatedIncident, nil
+	}
 
 	return createdIncident, nil
 }
 
 // GetIncidents will retrieve an incident from ServiceNow
 func (snClient *ServiceNowClient) GetIncidents(params map[string]string) ([]Incident, error) {
-	log.Infof("Get ServiceNow incidents with params: %v", params)
-	response, err := snClient.get("incident", params)
+	return snClient.getIncidentsContext(context.Background(), params)
+}
+
+func (snClient *ServiceNowClient) getIncidentsContext(ctx context.Context, params map[string]string) ([]Incident, error) {
+	requestLog(ctx).Infof("Get ServiceNow incidents with params: %v", params)
+	records, err := snClient.getRecordsContext(ctx, snClient.getIncidentTable(), snClient.toTableParams(params))
+	if err != nil {
+		return nil, err
+	}
+	for i, record := range records {
+		records[i] = snClient.fromTableFields(record)
+	}
+	return records, nil
+}
+
+// GetRecords will retrieve records of any table from ServiceNow
+func (snClient *ServiceNowClient) GetRecords(table string, params map[string]string) ([]Incident, error) {
+	return snClient.getRecordsContext(context.Background(), table, params)
+}
+
+func (snClient *ServiceNowClient) getRecordsContext(ctx context.Context, table string, params map[string]string) ([]Incident, error) {
+	response, err := snClient.get(ctx, table, params)
+
+	if err != nil {
+		requestLog(ctx).Errorf("Error while getting the %s records. %s", table, err)
+		return nil, err
+	}
+
+	recordsResponse := IncidentsResponse{}
+	err = json.Unmarshal(response, &recordsResponse)
+	if err != nil {
+		requestLog(ctx).Errorf("Error while unmarshalling the %s records. %s", table, err)
+		return nil, err
+	}
 
+	return recordsResponse.GetResults(), nil
+}
+
+// CreateRecord will create a record of any table in ServiceNow from a given Incident, and return the created record
+func (snClient *ServiceNowClient) CreateRecord(table string, recordParam Incident) (Incident, error) {
+	return snClient.createRecordContext(context.Background(), table, recordParam)
+}
+
+func (snClient *ServiceNowClient) createRecordContext(ctx context.Context, table string, recordParam Incident) (Incident, error) {
+	postBody, err := json.Marshal(recordParam)
 	if err != nil {
-		log.Errorf("Error while getting the incident. %s", err)
+		requestLog(ctx).Errorf("Error while marshalling the %s record. %s", table, err)
 		return nil, err
 	}
 
-	incidentsResponse := IncidentsResponse{}
-	err = json.Unmarshal(response, &incidentsResponse)
+	response, err := snClient.create(ctx, table, postBody, writeParams(snClient.inputDisplayValue))
 	if err != nil {
-		log.Errorf("Error while unmarshalling the incident. %s", err)
+		requestLog(ctx).Errorf("Error while creating the %s record. %s", table, err)
 		return nil, err
 	}
 
-	return incidentsResponse.GetResults(), nil
+	recordResponse := IncidentResponse{}
+	err = json.Unmarshal(response, &recordResponse)
+	if err != nil {
+		requestLog(ctx).Errorf("Error while unmarshalling the %s record. %s", table, err)
+		return nil, err
+	}
+
+	return recordResponse.GetResult(), nil
 }
 
 // UpdateIncident will update an incident in ServiceNow from a given Incident, and return the updated incident
 func (snClient *ServiceNowClient) UpdateIncident(incidentParam Incident, sysID string) (Incident, error) {
-	log.Infof("Update %v field(s) of ServiceNow incident with id : %s", len(incidentParam), sysID)
+	return snClient.updateIncidentContext(context.Background(), incidentParam, sysID)
+}
+
+func (snClient *ServiceNowClient) updateIncidentContext(ctx context.Context, incidentParam Incident, sysID string) (Incident, error) {
+	valueParam, displayValueParam := snClient.splitDisplayValueFields(incidentParam)
+	valueParam, displayValueParam = snClient.toTableFields(valueParam), snClient.toTableFields(displayValueParam)
+
+	if len(displayValueParam) == 0 {
+		return snClient.updateIncident(ctx, valueParam, sysID, snClient.inputDisplayValue)
+	}
+
+	if len(valueParam) > 0 {
+		if _, err := snClient.updateIncident(ctx, valueParam, sysID, false); err != nil {
+			return nil, err
+		}
+	}
+	return snClient.updateIncident(ctx, displayValueParam, sysID, true)
+}
+
+// updateIncident will do a single incident update request, with fields written as values or display values
+func (snClient *ServiceNowClient) updateIncident(ctx context.Context, incidentParam Incident, sysID string, inputDisplayValue bool) (Incident, error) {
+	requestLog(ctx).WithField("sys_id", sysID).Infof("Update %v field(s) of ServiceNow incident with id : %s", len(incidentParam), sysID)
 
 	postBody, err := json.Marshal(incidentParam)
 	if err != nil {
-		log.Errorf("Error while marshalling the incident. %s", err)
+		requestLog(ctx).Errorf("Error while marshalling the incident. %s", err)
 		return nil, err
 	}
 
-	response, err := snClient.update("incident", postBody, sysID)
+	response, err := snClient.update(ctx, snClient.getIncidentTable(), postBody, sysID, writeParams(inputDisplayValue))
 	if err != nil {
-		log.Errorf("Error while updating the incident. %s", err)
+		requestLog(ctx).Errorf("Error while updating the incident. %s", err)
 		return nil, err
 	}
 
 	incidentResponse := IncidentResponse{}
 	err = json.Unmarshal(response, &incidentResponse)
 	if err != nil {
-		log.Errorf("Error while unmarshalling the incident. %s", err)
+		requestLog(ctx).Errorf("Error while unmarshalling the incident. %s", err)
 		return nil, err
 	}
 
-	updatedIncident := incidentResponse.GetResult()
-	log.Infof("Incident %s updated", updatedIncident.GetNumber())
+	updatedIncident := snClient.fromTableFields(incidentResponse.GetResult())
+	requestLog(ctx).WithFields(incidentLogFields(updatedIncident)).Infof("Incident %s updated", updatedIncident.GetNumber())
 
 	return updatedIncident, nil
 }
@@ -2,12 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"reflect"
 	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 var basicIncidentParam = Incident{
@@ -312,3 +316,259 @@ func TestUpdateIncident_CreateRequestError(t *testing.T) {
 		t.Errorf("Expected an error, got none")
 	}
 }
+
+func TestCreateIncident_DisplayValueFields(t *testing.T) {
+	// Load a simple example of a response coming from ServiceNow
+	incidentTest, err := ioutil.ReadFile("test/incident_response.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests []string
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.Query().Get("sysparm_input_display_value"), body))
+		fmt.Fprint(w, string(incidentTest))
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer ts.Close()
+
+	snClient, err := newServiceNowClientFromConfig(ServiceNowConfig{
+		InstanceName:       "instancename",
+		UserName:           "username",
+		Password:           "password",
+		DisplayValueFields: []string{"impact"},
+	})
+	if err != nil {
+		t.Errorf("Error occured on newServiceNowClientFromConfig: %s", err)
+	}
+	snClient.baseURL = ts.URL
+
+	_, err = snClient.CreateIncident(Incident{"short_description": "desc", "impact": "1 - High"})
+	if err != nil {
+		t.Errorf("Error occured on CreateIncident: %s", err)
+	}
+
+	want := []string{
+		`POST false {"short_description":"desc"}`,
+		`PUT true {"impact":"1 - High"}`,
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("Unexpected requests; got: %v, want: %v", requests, want)
+	}
+}
+
+func TestDoRequest_PasswordRotation(t *testing.T) {
+	passwordFile, err := ioutil.TempFile("", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(passwordFile.Name())
+	ioutil.WriteFile(passwordFile.Name(), []byte("old\n"), 0600)
+
+	// Load a simple example of a response coming from ServiceNow
+	incidentTest, err := ioutil.ReadFile("test/incident_response.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		if _, password, _ := r.BasicAuth(); password != "new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, string(incidentTest))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer ts.Close()
+
+	snClient, err := newServiceNowClientFromConfig(ServiceNowConfig{
+		InstanceName: "instancename",
+		UserName:     "username",
+		PasswordFile: passwordFile.Name(),
+	})
+	if err != nil {
+		t.Fatalf("Error occured on newServiceNowClientFromConfig: %s", err)
+	}
+	snClient.baseURL = ts.URL
+
+	// Rotate the password after the client creation
+	ioutil.WriteFile(passwordFile.Name(), []byte("new\n"), 0600)
+
+	if _, err = snClient.CreateIncident(basicIncidentParam); err != nil {
+		t.Errorf("Error occured on CreateIncident: %s", err)
+	}
+}
+
+func TestProbeCapabilities(t *testing.T) {
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/now/v2/table/incident":
+			fmt.Fprint(w, `{"result":[]}`)
+		case "/api/now/v1/batch":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}
+	ts := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer ts.Close()
+
+	snClient, err := NewServiceNowClient("instancename", "username", "password")
+	if err != nil {
+		t.Fatalf("Error occured on NewServiceNowClient: %s", err)
+	}
+	snClient.baseURL = ts.URL
+
+	if !snClient.hasCapability(capabilityAttachment) {
+		t.Errorf("Capabilities should be available before probing")
+	}
+
+	got := snClient.probeCapabilities()
+	want := map[string]bool{
+		capabilityTable:      true,
+		capabilityAttachment: false,
+		capabilityBatch:      true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected capabilities; got: %v, want: %v", got, want)
+	}
+	if snClient.hasCapability(capabilityAttachment) {
+		t.Errorf("Attachment capability should not be available")
+	}
+}
+
+func TestServiceNowErrorClass(t *testing.T) {
+	tests := []struct {
+		err       error
+		class     string
+		retryable bool
+	}{
+		{&serviceNowHTTPError{statusCode: http.StatusBadRequest}, errorClassClient, false},
+		{&serviceNowHTTPError{statusCode: http.StatusForbidden}, errorClassClient, false},
+		{&serviceNowHTTPError{statusCode: http.StatusUnauthorized}, errorClassAuthentication, true},
+		{&serviceNowHTTPError{statusCode: http.StatusTooManyRequests}, errorClassThrottled, true},
+		{&serviceNowHTTPError{statusCode: http.StatusServiceUnavailable}, errorClassServer, true},
+		{errors.New("connection refused"), errorClassUnavailable, true},
+	}
+	for _, test := range tests {
+		if class := serviceNowErrorClass(test.err); class != test.class {
+			t.Errorf("Unexpected class for %v; got: %v, want: %v", test.err, class, test.class)
+		}
+		if retryable := isRetryableError(test.err); retryable != test.retryable {
+			t.Errorf("Unexpected retryability for %v; got: %v, want: %v", test.err, retryable, test.retryable)
+		}
+	}
+}
+
+func TestAttachFile_OK(t *testing.T) {
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/now/attachment/file" {
+			t.Errorf("Unexpected path: %s", r.URL.Path)
+		}
+		if r.Header.Get("Content-Type") != "image/svg+xml" {
+			t.Errorf("Unexpected content type: %s", r.Header.Get("Content-Type"))
+		}
+		if got := r.URL.Query().Get("table_sys_id"); got != "42" {
+			t.Errorf("Unexpected table_sys_id: %s", got)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"result": {}}`))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer ts.Close()
+
+	snClient, err := NewServiceNowClient("instancename", "username", "password")
+	if err != nil {
+		t.Fatalf("Error occured on NewServiceNowClient: %s", err)
+	}
+	snClient.baseURL = ts.URL
+
+	if err := snClient.AttachFile("incident", "42", "timeline.svg", "image/svg+xml", []byte("<svg/>")); err != nil {
+		t.Errorf("Error occured on AttachFile: %s", err)
+	}
+}
+
+func TestCreateRecord_OK(t *testing.T) {
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/now/v2/table/incident_task" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"result": {"sys_id": "1", "number": "TASK1"}}`))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer ts.Close()
+
+	snClient, err := NewServiceNowClient("instancename", "username", "password")
+	if err != nil {
+		t.Fatalf("Error occured on NewServiceNowClient: %s", err)
+	}
+	snClient.baseURL = ts.URL
+
+	task, err := snClient.CreateRecord("incident_task", Incident{"incident": "42"})
+	if err != nil {
+		t.Errorf("Error occured on CreateRecord: %s", err)
+	}
+	if task.GetNumber() != "TASK1" {
+		t.Errorf("Unexpected record: %v", task)
+	}
+}
+
+func TestClassifyServiceNowErrorBody(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		body       string
+		category   string
+	}{
+		{http.StatusForbidden, `{"error": {"message": "Operation Failed", "detail": "ACL Exception Insert Failed due to security constraints"}, "status": "failure"}`, errorCategoryACLDenied},
+		{http.StatusBadRequest, `{"error": {"message": "Invalid reference", "detail": "assignment_group"}}`, errorCategoryInvalidReference},
+		{http.StatusForbidden, `{"error": {"message": "Operation Failed", "detail": "Data Policy Exception: The following fields are mandatory: Category"}}`, errorCategoryMandatoryFieldMissing},
+		{http.StatusInternalServerError, `not json`, errorCategoryUnknown},
+	}
+	for _, test := range tests {
+		if _, category := classifyServiceNowErrorBody(test.statusCode, []byte(test.body)); category != test.category {
+			t.Errorf("Unexpected category for %s; got: %v, want: %v", test.body, category, test.category)
+		}
+	}
+}
+
+func TestCreateIncident_DisplayValueFieldsUpdateFailure(t *testing.T) {
+	incidentTest, err := ioutil.ReadFile("test/incident_response.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testHandler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, string(incidentTest))
+	}
+	ts := httptest.NewServer(http.HandlerFunc(testHandler))
+	defer ts.Close()
+
+	snClient, err := newServiceNowClientFromConfig(ServiceNowConfig{
+		InstanceName:       "instancename",
+		UserName:           "username",
+		Password:           "password",
+		DisplayValueFields: []string{"impact"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snClient.baseURL = ts.URL
+	failures := testutil.ToFloat64(serviceNowDisplayValueUpdateFailures)
+
+	// The created incident is returned, so that a retried notification does not create it again
+	incident, err := snClient.CreateIncident(Incident{"short_description": "desc", "impact": "1 - High"})
+	if err != nil {
+		t.Errorf("Created incident must be returned despite the update failure, got: %v", err)
+	}
+	if len(incident.GetSysID()) == 0 {
+		t.Errorf("Unexpected incident: %v", incident)
+	}
+	if got := testutil.ToFloat64(serviceNowDisplayValueUpdateFailures); got != failures+1 {
+		t.Errorf("Update failure must be counted: got %v, want %v", got, failures+1)
+	}
+}
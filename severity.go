@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// SeverityLevelConfig - Incident fields of a severity
+type SeverityLevelConfig struct {
+	Value string `yaml:"value"`
+	// Fields overriding the default_incident ones, e.g.: impact, urgency, priority
+	Fields map[string]string `yaml:"fields"`
+}
+
+// SeverityMappingConfig - Incident fields set from the severity of the alert group
+type SeverityMappingConfig struct {
+	// Alert label holding the severity, severity by default
+	Label string `yaml:"label"`
+	// Severities ordered from the most to the least severe
+	Levels []SeverityLevelConfig `yaml:"levels"`
+}
+
+func (c SeverityMappingConfig) label() string {
+	if len(c.Label) > 0 {
+		return c.Label
+	}
+	return defaultSeverityLabel
+}
+
+func (c SeverityMappingConfig) validate() error {
+	var errs strings.Builder
+	values := make(map[string]bool)
+	for i, level := range c.Levels {
+		if len(level.Value) == 0 {
+			errs.WriteString(fmt.Sprintf("severity_mapping level %d value is missing\n", i))
+		} else if values[level.Value] {
+			errs.WriteString(fmt.Sprintf("severity_mapping level %q is defined more than once\n", level.Value))
+		}
+		values[level.Value] = true
+	}
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(errs.String(), "\n"))
+	}
+	return nil
+}
+
+// selectSeverityLevel returns the level of the common severity of the alert group, or of the most
+// severe of its firing alerts when they have different severities, nil if no level matches
+func selectSeverityLevel(data template.Data) *SeverityLevelConfig {
+	mapping := config.SeverityMapping
+	if len(mapping.Levels) == 0 {
+		return nil
+	}
+	severities := make(map[string]bool)
+	if severity, ok := data.CommonLabels[mapping.label()]; ok {
+		severities[severity] = true
+	} else {
+		for _, alert := range data.Alerts.Firing() {
+			severities[alert.Labels[mapping.label()]] = true
+		}
+	}
+	for i, level := range mapping.Levels {
+		if severities[level.Value] {
+			return &mapping.Levels[i]
+		}
+	}
+	return nil
+}
+
+// applySeverityMapping sets the incident fields of the severity of the alert group
+func applySeverityMapping(incident Incident, data template.Data) {
+	level := selectSeverityLevel(data)
+	if level == nil {
+		return
+	}
+	for field, value := range level.Fields {
+		incident[field] = value
+	}
+}
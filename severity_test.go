@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestApplySeverityMapping(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.SeverityMapping = SeverityMappingConfig{
+		Levels: []SeverityLevelConfig{
+			{Value: "critical", Fields: map[string]string{"impact": "1", "urgency": "1"}},
+			{Value: "warning", Fields: map[string]string{"impact": "3", "urgency": "3"}},
+		},
+	}
+
+	tests := []struct {
+		data   template.Data
+		impact interface{}
+	}{
+		{template.Data{CommonLabels: template.KV{"severity": "critical"}}, "1"},
+		{template.Data{CommonLabels: template.KV{"severity": "warning"}}, "3"},
+		{template.Data{CommonLabels: template.KV{"severity": "info"}}, "2"},
+		{template.Data{Alerts: template.Alerts{
+			{Status: "firing", Labels: template.KV{"severity": "warning"}},
+			{Status: "firing", Labels: template.KV{"severity": "critical"}},
+		}}, "1"},
+		{template.Data{Alerts: template.Alerts{
+			{Status: "firing", Labels: template.KV{"severity": "warning"}},
+			{Status: "resolved", Labels: template.KV{"severity": "critical"}},
+		}}, "3"},
+	}
+	for _, test := range tests {
+		incident := Incident{"impact": "2"}
+		applySeverityMapping(incident, test.data)
+		if incident["impact"] != test.impact {
+			t.Errorf("Unexpected impact of %+v: got %v, want %v", test.data, incident["impact"], test.impact)
+		}
+	}
+}
+
+func TestSeverityMappingConfig_Validate(t *testing.T) {
+	c := SeverityMappingConfig{Levels: []SeverityLevelConfig{{Value: "critical"}, {}, {Value: "critical"}}}
+	err := c.validate()
+	expected := "severity_mapping level 1 value is missing\nseverity_mapping level \"critical\" is defined more than once"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Unexpected error; got: %v, want: %v", err, expected)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// ShadowConfig - Mapping evaluated alongside the active one, without affecting incidents
+type ShadowConfig struct {
+	DefaultIncident map[string]string `yaml:"default_incident"`
+}
+
+// compareShadowIncident renders the incident with the shadow mapping, if any,
+// and logs and counts the fields differing from the active incident
+func compareShadowIncident(ctx context.Context, data template.Data, incident Incident) {
+	if len(config.Shadow.DefaultIncident) == 0 {
+		return
+	}
+
+	webhookShadowEvaluations.Inc()
+	shadowIncident := renderIncident(ctx, data, config.Shadow.DefaultIncident)
+	for _, field := range diffIncidentFields(incident, shadowIncident) {
+		webhookShadowDifferences.WithLabelValues(field).Inc()
+		alertGroupLog(ctx, data).Infof("Shadow mapping difference for alert group key: %s, field %s: active=%q shadow=%q", getGroupKey(data), field, incident[field], shadowIncident[field])
+	}
+}
+
+// diffIncidentFields returns the sorted names of the fields differing between two incidents
+func diffIncidentFields(a Incident, b Incident) []string {
+	var fields []string
+	for field, value := range a {
+		if otherValue, ok := b[field]; !ok || otherValue != value {
+			fields = append(fields, field)
+		}
+	}
+	for field := range b {
+		if _, ok := a[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
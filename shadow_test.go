@@ -0,0 +1,19 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_diffIncidentFields(t *testing.T) {
+	active := Incident{"short_description": "a", "description": "same", "impact": "1"}
+	shadow := Incident{"short_description": "b", "description": "same", "urgency": "2"}
+
+	want := []string{"impact", "short_description", "urgency"}
+	if got := diffIncidentFields(active, shadow); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffIncidentFields() = %v, want %v", got, want)
+	}
+	if got := diffIncidentFields(active, active); got != nil {
+		t.Errorf("diffIncidentFields() = %v, want nil", got)
+	}
+}
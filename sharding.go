@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const (
+	shardForwardedHeader = "X-Webhook-Forwarded-By"
+	shardSignatureHeader = "X-Webhook-Forwarded-Signature"
+)
+
+// ShardingConfig - Sharding of group keys across webhook replicas, so that each group key
+// is only managed by one replica. Notifications received by another replica are forwarded.
+type ShardingConfig struct {
+	// Base URLs of all the replicas (e.g.: http://webhook-0.webhook:9877)
+	Replicas []string `yaml:"replicas"`
+	// Base URL of this replica, among the replicas
+	Self string `yaml:"self"`
+	// Secret shared by the replicas, signing the forwarded notifications
+	Secret string `yaml:"secret"`
+	// File containing the shared secret, read on each use so it can be rotated, used instead of secret
+	SecretFile string `yaml:"secret_file"`
+}
+
+func (c ShardingConfig) validate() error {
+	if len(c.Replicas) == 0 {
+		return nil
+	}
+	if len(c.Secret) == 0 && len(c.SecretFile) == 0 {
+		return errors.New("sharding secret or secret_file is required to authenticate the forwarded notifications")
+	}
+	for _, replica := range c.Replicas {
+		if replica == c.Self {
+			return nil
+		}
+	}
+	return fmt.Errorf("sharding self %q is not one of the replicas", c.Self)
+}
+
+var shardClient = &http.Client{Timeout: 30 * time.Second}
+
+// shardOwner returns the replica owning the group key, using rendezvous hashing so that
+// adding or removing a replica only moves the group keys of this replica
+func shardOwner(c ShardingConfig, groupKey string) string {
+	return shardOwners(c, groupKey)[0]
+}
+
+// shardOwners returns the replicas by decreasing rendezvous score of the group key, the
+// owner first and then the replicas taking over when the previous ones are unavailable
+func shardOwners(c ShardingConfig, groupKey string) []string {
+	scores := make(map[string]uint64, len(c.Replicas))
+	for _, replica := range c.Replicas {
+		hash := fnv.New64a()
+		hash.Write([]byte(replica))
+		hash.Write([]byte(groupKey))
+		scores[replica] = mix64(hash.Sum64())
+	}
+	owners := append([]string(nil), c.Replicas...)
+	sort.SliceStable(owners, func(i, j int) bool { return scores[owners[i]] > scores[owners[j]] })
+	return owners
+}
+
+// shardSignature returns the HMAC-SHA256 of the forwarded body with the shared secret
+func shardSignature(c ShardingConfig, body []byte) (string, error) {
+	secret := c.Secret
+	if len(c.SecretFile) > 0 {
+		var err error
+		if secret, err = readSecretFile(c.SecretFile); err != nil {
+			return "", err
+		}
+	}
+	if len(secret) == 0 {
+		return "", errors.New("no sharding secret is configured")
+	}
+	return hex.EncodeToString(hmacSHA256([]byte(secret), string(body))), nil
+}
+
+// isForwarded returns true if the request was forwarded by one of the replicas, and an error if it claims
+// to be but its signature does not verify. The body is read to be verified, and restored for decoding.
+func isForwarded(r *http.Request, c ShardingConfig) (bool, error) {
+	forwardedBy := r.Header.Get(shardForwardedHeader)
+	if len(c.Replicas) == 0 || len(forwardedBy) == 0 {
+		return false, nil
+	}
+	known := false
+	for _, replica := range c.Replicas {
+		known = known || forwardedBy == replica
+	}
+	if !known {
+		return false, fmt.Errorf("unknown replica %q", forwardedBy)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return false, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	expected, err := shardSignature(c, body)
+	if err != nil {
+		return false, err
+	}
+	if !hmac.Equal([]byte(r.Header.Get(shardSignatureHeader)), []byte(expected)) {
+		return false, fmt.Errorf("invalid signature of replica %q", forwardedBy)
+	}
+	return true, nil
+}
+
+// mix64 spreads the bits of a hash, as FNV scores of similar inputs are correlated
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// forwardToShardOwner forwards the alert group to the replica owning its group key, if it is
+// not this replica, and sends back its response. When the owner is unavailable, the next replica
+// by rendezvous score takes over. It returns false if the alert group must be processed locally.
+func forwardToShardOwner(ctx context.Context, w http.ResponseWriter, r *http.Request, c ShardingConfig, data template.Data) bool {
+	if len(c.Replicas) == 0 {
+		return false
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		sendJSONResponse(w, http.StatusInternalServerError, err.Error())
+		return true
+	}
+
+	for _, owner := range shardOwners(c, getGroupKey(data)) {
+		if owner == c.Self {
+			return false
+		}
+		alertGroupLog(ctx, data).Infof("Forwarding alert group key: %s to replica %s", getGroupKey(data), owner)
+		resp, err := forwardToReplica(r, c, owner, body, w.Header().Get(requestIDHeader))
+		if err != nil {
+			webhookShardForwards.WithLabelValues("failure").Inc()
+			alertGroupLog(ctx, data).Errorf("Error forwarding alert group key: %s to replica %s, trying the next one: %v", getGroupKey(data), owner, err)
+			continue
+		}
+		defer resp.Body.Close()
+		webhookShardForwards.WithLabelValues("success").Inc()
+
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return true
+	}
+	return false
+}
+
+// forwardToReplica sends the alert group to the webhook endpoint of the replica
+func forwardToReplica(r *http.Request, c ShardingConfig, replica string, body []byte, requestID string) (*http.Response, error) {
+	signature, err := shardSignature(c, body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", strings.TrimSuffix(replica, "/")+routePrefix+"/webhook", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(shardForwardedHeader, c.Self)
+	req.Header.Set(shardSignatureHeader, signature)
+	req.Header.Set(requestIDHeader, requestID)
+	if authorization := r.Header.Get("Authorization"); len(authorization) > 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+	return shardClient.Do(req)
+}
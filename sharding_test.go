@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestShardOwner(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.Sharding.Replicas = []string{"http://webhook-0", "http://webhook-1", "http://webhook-2"}
+
+	owners := map[string]string{}
+	counts := map[string]int{}
+	for i := 0; i < 300; i++ {
+		groupKey := fmt.Sprintf("group-%d", i)
+		owners[groupKey] = shardOwner(config.Sharding, groupKey)
+		counts[owners[groupKey]]++
+	}
+	for _, replica := range config.Sharding.Replicas {
+		if counts[replica] == 0 {
+			t.Errorf("Replica %s owns no group key", replica)
+		}
+	}
+
+	// Removing a replica only moves its own group keys
+	config.Sharding.Replicas = []string{"http://webhook-0", "http://webhook-1"}
+	for groupKey, owner := range owners {
+		if owner != "http://webhook-2" && shardOwner(config.Sharding, groupKey) != owner {
+			t.Errorf("Group key %s moved from %s to %s", groupKey, owner, shardOwner(config.Sharding, groupKey))
+		}
+	}
+}
+
+func TestWebhookHandler_ShardForward(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{}, nil)
+
+	// The owner replica processes forwarded notifications locally, replicas share the route prefix
+	routePrefix = "/servicenow"
+	defer func() { routePrefix = "" }()
+	owner := httptest.NewServer(withRoutePrefix(http.HandlerFunc(webhook), routePrefix))
+	defer owner.Close()
+
+	data, err := ioutil.ReadFile("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := decodeBody(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Find a replica name which is not the owner of the group key
+	config.Sharding.Secret = "secret"
+	config.Sharding.Replicas = []string{owner.URL}
+	for i := 0; len(config.Sharding.Replicas) == 1; i++ {
+		self := fmt.Sprintf("http://webhook-%d", i)
+		config.Sharding.Replicas = []string{owner.URL, self}
+		if shardOwner(config.Sharding, getGroupKey(payload)) != owner.URL {
+			config.Sharding.Replicas = []string{owner.URL}
+		} else {
+			config.Sharding.Self = self
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(webhook).ServeHTTP(rr, httptest.NewRequest("POST", "/webhook", bytes.NewReader(data)))
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusOK)
+	}
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+}
+
+// shardPayload returns the test payload, and sets the sharding replicas so that the first replica owns its group
+// key and this replica comes next
+func shardPayload(t *testing.T, first string) []byte {
+	data, err := ioutil.ReadFile("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := decodeBody(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; ; i++ {
+		self := fmt.Sprintf("http://webhook-%d", i)
+		config.Sharding = ShardingConfig{Replicas: []string{first, self}, Self: self, Secret: "secret"}
+		if shardOwner(config.Sharding, getGroupKey(payload)) == first {
+			return data
+		}
+	}
+}
+
+func TestWebhookHandler_ShardFailover(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{}, nil)
+
+	// The owner is unreachable, this replica takes over
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	data := shardPayload(t, unreachable.URL)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(webhook).ServeHTTP(rr, httptest.NewRequest("POST", "/webhook", bytes.NewReader(data)))
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusOK)
+	}
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+}
+
+func TestWebhookHandler_ShardForwardedByUnknownReplica(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+
+	forwarded := 0
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded++
+		sendJSONResponse(w, http.StatusOK, "Success")
+	}))
+	defer owner.Close()
+	data := shardPayload(t, owner.URL)
+
+	// A notification claiming to be forwarded by an unknown replica is rejected
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(data))
+	req.Header.Set(shardForwardedHeader, "http://attacker")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(webhook).ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized || forwarded != 0 {
+		t.Errorf("Notification must be rejected: got %v, forwarded %d times", rr.Code, forwarded)
+	}
+	snClientMock.AssertNotCalled(t, "GetIncidents", mock.Anything)
+}
+
+func TestWebhookHandler_ShardForwardedSignature(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{}, nil)
+
+	forwarded := 0
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded++
+		sendJSONResponse(w, http.StatusOK, "Success")
+	}))
+	defer owner.Close()
+	data := shardPayload(t, owner.URL)
+	signature, err := shardSignature(config.Sharding, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A notification spoofing a replica without the shared secret is rejected
+	req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(data))
+	req.Header.Set(shardForwardedHeader, owner.URL)
+	req.Header.Set(shardSignatureHeader, "0123456789abcdef")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(webhook).ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Wrong status code of an invalid signature: got %v, want %v", rr.Code, http.StatusUnauthorized)
+	}
+	snClientMock.AssertNotCalled(t, "GetIncidents", mock.Anything)
+
+	// A notification signed by a replica is processed locally, even though this replica is not the owner
+	req = httptest.NewRequest("POST", "/webhook", bytes.NewReader(data))
+	req.Header.Set(shardForwardedHeader, owner.URL)
+	req.Header.Set(shardSignatureHeader, signature)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(webhook).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || forwarded != 0 {
+		t.Errorf("Signed notification must be processed locally: got %v, forwarded %d times", rr.Code, forwarded)
+	}
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+}
+
+func TestShardingConfig_ValidateSecret(t *testing.T) {
+	c := ShardingConfig{Replicas: []string{"http://webhook-0", "http://webhook-1"}, Self: "http://webhook-0"}
+	if err := c.validate(); err == nil {
+		t.Error("Sharding without secret must be invalid")
+	}
+	c.Secret = "secret"
+	if err := c.validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	shutdownTimeout = kingpin.Flag("web.shutdown-timeout", "Maximum time to complete the in-flight notifications and drain the queue on SIGTERM or SIGINT.").Default("30s").Duration()
+)
+
+// serve runs the server until it fails, or until SIGTERM or SIGINT is received and the server is shut down
+func serve(server *http.Server, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() { errs <- server.ListenAndServe() }()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-signals:
+		log.Infof("%s received, shutting down", sig)
+	}
+	return shutdown(server, timeout)
+}
+
+// shutdown stops accepting requests, then waits within the timeout for the in-flight requests, the notifications
+// still processed in background, the queued notifications, the scheduled actions being fired and the queued
+// archives. Only a failure to shut the server down is returned, the work left when the timeout is reached is logged.
+func shutdown(server *http.Server, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("in-flight requests are not completed: %v", err)
+	}
+	if !waitUntil(ctx, func() bool { return coalescer.load() == 0 }) {
+		log.Warnf("%d notification(s) processed in background are not completed", coalescer.load())
+	}
+	if queue.enabled() {
+		queue.wakeUp()
+		if !waitUntil(ctx, func() bool { return queue.length() == 0 }) {
+			log.Warnf("%d queued notification(s) are not sent, they are kept in the queue directory", queue.length())
+		}
+	}
+
+	// Scheduled actions being fired hold the configuration lock
+	locked := make(chan struct{})
+	go func() {
+		configLock.Lock()
+		configLock.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-ctx.Done():
+		log.Warn("Scheduled actions being fired are not completed, they are kept in the schedule file")
+	}
+	if !waitUntil(ctx, func() bool { return archives.length() == 0 }) {
+		log.Warnf("%d payload(s) and ServiceNow exchange(s) are not archived", archives.length())
+	}
+	log.Info("Shutdown completed")
+	return nil
+}
+
+// waitUntil polls the condition until it is true, returning false if the context is done first
+func waitUntil(ctx context.Context, condition func() bool) bool {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for !condition() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdown_WaitsInflightRequests(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})}
+	go server.Serve(listener)
+
+	responses := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		responses <- err
+	}()
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- shutdown(server, 5*time.Second) }()
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown must wait for the in-flight request, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Unexpected shutdown error: %v", err)
+	}
+	if err := <-responses; err != nil {
+		t.Errorf("In-flight request must complete: %v", err)
+	}
+	if _, err := net.DialTimeout("tcp", listener.Addr().String(), time.Second); err == nil {
+		t.Errorf("New connections must be refused once shut down")
+	}
+}
+
+func TestShutdown_Timeout(t *testing.T) {
+	_, done := coalescer.arrive("shutdown")
+	start := time.Now()
+	if err := shutdown(&http.Server{}, 100*time.Millisecond); err != nil {
+		t.Errorf("Work left at the timeout must be logged only, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown must not wait beyond the timeout, took %s", elapsed)
+	}
+	done()
+	if err := shutdown(&http.Server{}, time.Second); err != nil {
+		t.Errorf("Unexpected shutdown error: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+)
+
+// SourceConfig - Identifier of the webhook deployment written in the incidents it creates and required by its
+// lookups, so that several deployments against one ServiceNow instance never update each other's incidents
+type SourceConfig struct {
+	// Incident field holding the identifier, e.g. u_monitoring_source
+	Field string `yaml:"field"`
+	// Identifier of the deployment, e.g. am-bridge/prod-eu
+	Value string `yaml:"value"`
+}
+
+func (c SourceConfig) enabled() bool {
+	return len(c.Field) > 0
+}
+
+func (c SourceConfig) validate(groupKeyField string) error {
+	if len(c.Field) == 0 && len(c.Value) == 0 {
+		return nil
+	}
+	if len(c.Field) == 0 || len(c.Value) == 0 {
+		return fmt.Errorf("source field and value must be set together")
+	}
+	if c.Field == groupKeyField {
+		return fmt.Errorf("source field must not be the incident_group_key_field")
+	}
+	if !isQueryValue(c.Field) || !isQueryValue(c.Value) {
+		return fmt.Errorf("source field and value must not contain ^, = nor new lines")
+	}
+	return nil
+}
+
+// withSource restricts the lookup params to the incidents of the source, if configured
+func withSource(source SourceConfig, params map[string]string) map[string]string {
+	if !source.enabled() {
+		return params
+	}
+	if query, ok := params["sysparm_query"]; ok {
+		params["sysparm_query"] = query + "^" + source.Field + "=" + source.Value
+	} else {
+		params[source.Field] = source.Value
+	}
+	return params
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestSourceConfig_Validate(t *testing.T) {
+	tests := []struct {
+		source SourceConfig
+		valid  bool
+	}{
+		{SourceConfig{}, true},
+		{SourceConfig{Field: "u_monitoring_source", Value: "am-bridge/prod-eu"}, true},
+		{SourceConfig{Field: "u_monitoring_source"}, false},
+		{SourceConfig{Value: "am-bridge/prod-eu"}, false},
+		{SourceConfig{Field: "u_group_key", Value: "am-bridge/prod-eu"}, false},
+		{SourceConfig{Field: "u_monitoring_source", Value: "prod^active=false"}, false},
+		{SourceConfig{Field: "u_monitoring_source", Value: "prod\n"}, false},
+	}
+	for _, test := range tests {
+		if err := test.source.validate("u_group_key"); (err == nil) != test.valid {
+			t.Errorf("Unexpected validation of %+v: %v", test.source, err)
+		}
+	}
+}
+
+func TestSource_LookupAndIncident(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { config.Workflow.Source = SourceConfig{} }()
+	config.Workflow.Source = SourceConfig{Field: "u_monitoring_source", Value: "am-bridge/prod-eu"}
+	data := template.Data{GroupLabels: template.KV{"alertname": "HighLoad"}}
+
+	params := getGroupKeyLookupParams(context.Background(), data)
+	if params["u_prometheus_alertgroup_id"] != getGroupKey(data) || params["u_monitoring_source"] != "am-bridge/prod-eu" {
+		t.Errorf("Unexpected exact lookup params: %v", params)
+	}
+
+	config.Workflow.GroupKeyLookup = GroupKeyLookupConfig{Operator: "LIKE"}
+	params = getGroupKeyLookupParams(context.Background(), data)
+	if want := "u_prometheus_alertgroup_idLIKE" + getGroupKey(data) + "^u_monitoring_source=am-bridge/prod-eu"; params["sysparm_query"] != want {
+		t.Errorf("Unexpected LIKE lookup params: got %v, want %s", params, want)
+	}
+
+	incident := renderIncident(context.Background(), data, config.DefaultIncident)
+	if incident["u_monitoring_source"] != "am-bridge/prod-eu" {
+		t.Errorf("Incident must carry the source: %v", incident)
+	}
+}
+
+func TestSource_AckLookup(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { config.Workflow.Source = SourceConfig{} }()
+	config.Workflow.Source = SourceConfig{Field: "u_monitoring_source", Value: "am-bridge/prod-eu"}
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", map[string]string{"number": "INC42", "u_monitoring_source": "am-bridge/prod-eu"}).Return([]Incident{}, nil)
+
+	if _, err := findAckIncident(ackRequest{IncidentNumber: "INC42"}); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertExpectations(t)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Stages of the incident management of an alert group which can fail on ServiceNow
+const (
+	stageLookup = "lookup"
+	stageCreate = "create"
+	stageUpdate = "update"
+)
+
+// incidentStageError is returned when ServiceNow fails at a stage of the incident management of an alert group
+type incidentStageError struct {
+	stage string
+	err   error
+}
+
+// stageError returns the error of the stage, nil if there is none
+func stageError(stage string, err error) error {
+	if err == nil {
+		return nil
+	}
+	webhookStageErrors.WithLabelValues(stage, serviceNowErrorClass(err)).Inc()
+	return &incidentStageError{stage: stage, err: err}
+}
+
+func (e *incidentStageError) Error() string {
+	return fmt.Sprintf("incident %s failed: %v", e.stage, e.err)
+}
+
+func (e *incidentStageError) Unwrap() error {
+	return e.err
+}
+
+// serviceNowCause returns the ServiceNow error of an incident stage error, the error itself otherwise
+func serviceNowCause(err error) error {
+	var stageErr *incidentStageError
+	if errors.As(err, &stageErr) {
+		return stageErr.err
+	}
+	return err
+}
+
+// errorStatus returns the webhook response status of an alert group processing error: 422 for non retryable
+// errors, 503 when the incidents could not be looked up (nothing was written), 502 when the incident could not be
+// created or updated, and 500 for errors which are not ServiceNow ones, e.g. templating errors
+func errorStatus(err error) int {
+	if !isRetryableError(err) {
+		return http.StatusUnprocessableEntity
+	}
+	var stageErr *incidentStageError
+	if !errors.As(err, &stageErr) {
+		return http.StatusInternalServerError
+	}
+	if stageErr.stage == stageLookup {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("template error"), http.StatusInternalServerError},
+		{&incidentStageError{stage: stageLookup, err: errors.New("timeout")}, http.StatusServiceUnavailable},
+		{&incidentStageError{stage: stageCreate, err: &serviceNowHTTPError{statusCode: http.StatusInternalServerError}}, http.StatusBadGateway},
+		{&incidentStageError{stage: stageUpdate, err: &serviceNowHTTPError{statusCode: http.StatusTooManyRequests}}, http.StatusBadGateway},
+		{&incidentStageError{stage: stageUpdate, err: &serviceNowHTTPError{statusCode: http.StatusBadRequest}}, http.StatusUnprocessableEntity},
+	}
+	for _, test := range tests {
+		if got := errorStatus(test.err); got != test.want {
+			t.Errorf("Unexpected status of %v: got %d, want %d", test.err, got, test.want)
+		}
+	}
+}
+
+func TestStageError(t *testing.T) {
+	if stageError(stageCreate, nil) != nil {
+		t.Errorf("No error must stay nil")
+	}
+	cause := &serviceNowHTTPError{statusCode: http.StatusUnauthorized}
+	err := stageError(stageCreate, cause)
+	if serviceNowCause(err) != cause || serviceNowErrorClass(err) != errorClassAuthentication {
+		t.Errorf("Stage error must keep the ServiceNow error: %v", err)
+	}
+
+	// The ServiceNow error is found through wrapping errors
+	wrapped := fmt.Errorf("processing failed: %w", err)
+	if serviceNowCause(wrapped) != cause || serviceNowErrorClass(wrapped) != errorClassAuthentication || errorStatus(wrapped) != http.StatusBadGateway {
+		t.Errorf("Wrapped stage error must keep the ServiceNow error: %v", wrapped)
+	}
+	if !isTransientError(httptest.NewRequest("GET", "/", nil), fmt.Errorf("lookup: %w", &serviceNowHTTPError{statusCode: http.StatusServiceUnavailable})) {
+		t.Errorf("Wrapped transient ServiceNow error must be retried")
+	}
+}
+
+func TestWebhookHandler_LookupError(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident(nil), errors.New("connection refused"))
+
+	data, err := ioutil.ReadFile("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/webhook", bytes.NewReader(data))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(webhook).ServeHTTP(rr, req)
+
+	// A failed lookup must not be processed as an alert group without incident
+	if status := rr.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("Wrong status code: got %v, want %v", status, http.StatusServiceUnavailable)
+	}
+	snClientMock.AssertNotCalled(t, "CreateIncident", mock.Anything)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	tmpltext "text/template"
+)
+
+// Default colors of the statuses in HTML mode
+var defaultStatusColors = map[string]string{
+	"firing":   "#cc0000",
+	"resolved": "#008800",
+}
+
+// StatusWordsConfig - Display words of the alert statuses (firing, resolved) in rendered text, used by the statusWord template function
+type StatusWordsConfig struct {
+	// Display word by status, e.g.: firing: OUTAGE. The status itself is used when not set.
+	Words     map[string]string `yaml:"words"`
+	Uppercase bool              `yaml:"uppercase"`
+	// Render the word as HTML colored by status, for journal fields (work_notes, comments) rendering [code] blocks
+	HTML   bool              `yaml:"html"`
+	Colors map[string]string `yaml:"colors"`
+	// Display words by receiver, used instead of the default ones
+	Receivers map[string]StatusWordsConfig `yaml:"receivers"`
+}
+
+// forReceiver returns the display words of the receiver, or the default ones
+func (c StatusWordsConfig) forReceiver(receiver string) StatusWordsConfig {
+	if receiverConfig, ok := c.Receivers[receiver]; ok {
+		return receiverConfig
+	}
+	return c
+}
+
+// word returns the display word of the status
+func (c StatusWordsConfig) word(status string) string {
+	word, ok := c.Words[status]
+	if !ok {
+		word = status
+	}
+	if c.Uppercase {
+		word = strings.ToUpper(word)
+	}
+	if !c.HTML {
+		return word
+	}
+
+	color, ok := c.Colors[status]
+	if !ok {
+		color = defaultStatusColors[status]
+	}
+	if len(color) == 0 {
+		return fmt.Sprintf("[code]<b>%s</b>[/code]", html.EscapeString(word))
+	}
+	return fmt.Sprintf(`[code]<b style="color:%s">%s</b>[/code]`, html.EscapeString(color), html.EscapeString(word))
+}
+
+// templateFuncs returns the functions available to the templates rendered for the receiver
+func templateFuncs(receiver string) tmpltext.FuncMap {
+	return tmpltext.FuncMap{
+		"statusWord": func(status string) string {
+			return config.StatusWords.forReceiver(receiver).word(status)
+		},
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestStatusWordsConfig_Word(t *testing.T) {
+	tests := []struct {
+		config StatusWordsConfig
+		status string
+		word   string
+	}{
+		{StatusWordsConfig{}, "firing", "firing"},
+		{StatusWordsConfig{Words: map[string]string{"firing": "Outage"}}, "firing", "Outage"},
+		{StatusWordsConfig{Words: map[string]string{"firing": "Outage"}, Uppercase: true}, "firing", "OUTAGE"},
+		{StatusWordsConfig{Uppercase: true}, "resolved", "RESOLVED"},
+		{StatusWordsConfig{HTML: true}, "firing", `[code]<b style="color:#cc0000">firing</b>[/code]`},
+		{StatusWordsConfig{HTML: true, Colors: map[string]string{"resolved": "blue"}}, "resolved", `[code]<b style="color:blue">resolved</b>[/code]`},
+		{StatusWordsConfig{HTML: true, Words: map[string]string{"unknown": "<?>"}}, "unknown", "[code]<b>&lt;?&gt;</b>[/code]"},
+	}
+	for _, test := range tests {
+		if word := test.config.word(test.status); word != test.word {
+			t.Errorf("Unexpected word of %s: got %q, want %q", test.status, word, test.word)
+		}
+	}
+}
+
+func TestApplyTemplate_StatusWord(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { config.StatusWords = StatusWordsConfig{} }()
+	config.StatusWords = StatusWordsConfig{
+		Words: map[string]string{"firing": "OUTAGE", "resolved": "RECOVERED"},
+		Receivers: map[string]StatusWordsConfig{
+			"team": {Words: map[string]string{"firing": "SEV1"}},
+		},
+	}
+
+	text := `{{ statusWord .Status }}{{ range .Alerts }} {{ statusWord .Status }}{{ end }}`
+	data := template.Data{Status: "firing", Alerts: template.Alerts{{Status: "firing"}, {Status: "resolved"}}}
+	result, err := applyTemplate("short_description", text, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "OUTAGE OUTAGE RECOVERED" {
+		t.Errorf("Unexpected rendering: %s", result)
+	}
+
+	data.Receiver = "team"
+	result, err = applyTemplate("short_description", text, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "SEV1 SEV1 resolved" {
+		t.Errorf("Unexpected rendering of receiver team: %s", result)
+	}
+}
+
+func TestCompileTemplateFiles_StatusWord(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() {
+		config.StatusWords = StatusWordsConfig{}
+		templateFiles = nil
+	}()
+	dir, err := ioutil.TempDir("", "statuswords")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	content := `{{ define "status" }}Status: {{ statusWord .Status }}{{ end }}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "status.tmpl"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	templateFiles, err = compileTemplateFiles([]string{filepath.Join(dir, "*.tmpl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.StatusWords = StatusWordsConfig{Receivers: map[string]StatusWordsConfig{"team": {Uppercase: true}}}
+	result, err := applyTemplate("short_description", `{{ template "status" . }}`, template.Data{Receiver: "team", Status: "resolved"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "Status: RESOLVED" {
+		t.Errorf("Unexpected rendering: %s", result)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const defaultStreamingThreshold = 1 << 20
+
+// DecodingConfig - Decoding of the received payloads
+type DecodingConfig struct {
+	// Size in bytes above which payloads are decoded while they are read, alert by alert, instead of being read
+	// in full first, 1MiB by default. Payloads of unknown size are always streamed.
+	StreamingThreshold int64 `yaml:"streaming_threshold"`
+}
+
+func (c DecodingConfig) streamingThreshold() int64 {
+	if c.StreamingThreshold > 0 {
+		return c.StreamingThreshold
+	}
+	return defaultStreamingThreshold
+}
+
+// countingReader counts the bytes read
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// decodeStream decodes a payload of any supported format while it is read, so that neither the raw payload nor
+// the raw alerts are held in memory along with the decoded ones
+func decodeStream(r io.Reader) (template.Data, error) {
+	counter := &countingReader{reader: r}
+	decoder := json.NewDecoder(counter)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return template.Data{}, err
+	}
+
+	// Top level fields other than the alerts are small, they are decoded once all are read
+	fields := map[string]json.RawMessage{}
+	var alerts template.Alerts
+	partial := false
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return template.Data{}, err
+		}
+		name, _ := token.(string)
+		if name != "alerts" {
+			var value json.RawMessage
+			if err := decoder.Decode(&value); err != nil {
+				return template.Data{}, err
+			}
+			fields[name] = value
+			continue
+		}
+
+		fields[name] = json.RawMessage("[]")
+		if alerts, partial, err = decodeAlertStream(decoder); err != nil {
+			return template.Data{}, err
+		}
+	}
+	if err := expectDelim(decoder, '}'); err != nil {
+		return template.Data{}, err
+	}
+
+	format := detectPayloadFormat(fields)
+	webhookPayloadFormats.WithLabelValues(format).Inc()
+	webhookPayloadBytes.Observe(float64(counter.count))
+	webhookStreamedPayloads.Inc()
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return template.Data{}, err
+	}
+	if format != formatAlertmanager {
+		return decodePayload(format, body)
+	}
+	data := template.Data{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return template.Data{}, err
+	}
+	data.Alerts = alerts
+	if partial {
+		webhookPartiallyDecodedPayloads.Inc()
+	}
+	return data, nil
+}
+
+// decodeAlertStream decodes the alerts array alert by alert, tolerating malformed alert fields as the full decoding
+// does, and returns true if some alerts are partially decoded
+func decodeAlertStream(decoder *json.Decoder) (template.Alerts, bool, error) {
+	token, err := decoder.Token()
+	if err != nil || token == nil {
+		return nil, false, err
+	}
+	if token != json.Delim('[') {
+		return nil, false, fmt.Errorf("invalid payload: alerts are %v instead of an array", token)
+	}
+	alerts := template.Alerts{}
+	partial := false
+	for i := 0; decoder.More(); i++ {
+		var rawAlert json.RawMessage
+		if err := decoder.Decode(&rawAlert); err != nil {
+			return nil, false, err
+		}
+		alert := template.Alert{}
+		if err := json.Unmarshal(rawAlert, &alert); err != nil {
+			partial = true
+			var ok bool
+			if alert, ok = decodeAlertTolerantly(i, rawAlert); !ok {
+				continue
+			}
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, partial, expectDelim(decoder, ']')
+}
+
+// expectDelim reads the next token, which must be the delimiter
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("invalid payload: %v found instead of %v", token, delim)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDecodeStream_SameAsFullDecoding(t *testing.T) {
+	now = func() time.Time { return time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+	payloads := map[string][]byte{
+		"grafana_legacy": []byte(`{"ruleName": "Disk full", "state": "alerting", "evalMatches": [{"value": 95, "metric": "disk_used"}]}`),
+		"partial": []byte(`{"receiver": "team", "status": "firing", "commonLabels": {"alertname": "Disk full"}, "alerts": [
+			{"status": "firing", "labels": {"instance": "server01"}, "startsAt": "2020-01-01T00:00:00Z"},
+			{"status": "firing", "labels": {"instance": "server02"}, "startsAt": "yesterday"},
+			"not an alert"
+		]}`),
+		"null_alerts": []byte(`{"receiver": "team", "status": "firing", "alerts": null}`),
+	}
+	for _, file := range []string{"test/alertmanager_firing.json", "test/alertmanager_resolved.json"} {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payloads[file] = content
+	}
+
+	for name, payload := range payloads {
+		want, err := decodeBody(payload)
+		if err != nil {
+			t.Fatalf("Unexpected error decoding %s: %v", name, err)
+		}
+		got, err := decodeStream(bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("Unexpected error streaming %s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Unexpected streamed %s: got %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestDecodeStream_MalformedPayload(t *testing.T) {
+	for _, payload := range []string{`{"status": 1, "alerts": []}`, `{"alerts": {}}`, `{"alerts": [`, `[]`} {
+		if _, err := decodeStream(strings.NewReader(payload)); err == nil {
+			t.Errorf("Malformed payload %s must not be decoded", payload)
+		}
+	}
+}
+
+func TestReadRequestBody_Streaming(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { config.Decoding = DecodingConfig{} }()
+	payload, err := ioutil.ReadFile("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := decodeBody(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Decoding = DecodingConfig{StreamingThreshold: 16}
+	for _, contentLength := range []int64{int64(len(payload)), -1} {
+		req := httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))
+		req.ContentLength = contentLength
+		streamed := testutil.ToFloat64(webhookStreamedPayloads)
+		got, err := readRequestBody(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Unexpected streamed data: got %+v, want %+v", got, want)
+		}
+		if testutil.ToFloat64(webhookStreamedPayloads) != streamed+1 {
+			t.Errorf("Payload of content length %d must be streamed", contentLength)
+		}
+	}
+}
+
+func TestReadRequestBody_ConcurrentReload(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { config.Decoding = DecodingConfig{} }()
+	payload, err := ioutil.ReadFile("test/alertmanager_firing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The configuration is swapped under configLock while the request is read, as by a reload
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			configLock.Lock()
+			config.Decoding = DecodingConfig{StreamingThreshold: int64(i)}
+			configLock.Unlock()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := readRequestBody(httptest.NewRequest("POST", "/webhook", bytes.NewReader(payload))); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	<-done
+}
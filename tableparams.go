@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Table API parameters set by the client, which cannot be configured
+var managedTableAPIParams = map[string]string{
+	"sysparm_input_display_value": "input_display_value",
+}
+
+// TableAPIParamsConfig - Additional Table API query parameters by operation, e.g. sysparm_suppress_auto_sys_field or
+// sysparm_no_count, reducing ServiceNow processing time on instances with heavy business rules
+type TableAPIParamsConfig struct {
+	Create map[string]string `yaml:"create"`
+	Update map[string]string `yaml:"update"`
+	Get    map[string]string `yaml:"get"`
+}
+
+func (c TableAPIParamsConfig) validate() error {
+	var errs strings.Builder
+	operations := map[string]map[string]string{"create": c.Create, "update": c.Update, "get": c.Get}
+	for _, operation := range []string{"create", "update", "get"} {
+		names := make([]string, 0, len(operations[operation]))
+		for name := range operations[operation] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if !strings.HasPrefix(name, "sysparm_") {
+				errs.WriteString(fmt.Sprintf("table_api_params %s parameter %s is invalid, must start with sysparm_\n", operation, name))
+			} else if option, ok := managedTableAPIParams[name]; ok {
+				errs.WriteString(fmt.Sprintf("table_api_params %s parameter %s is set by the webhook, use %s instead\n", operation, name, option))
+			}
+		}
+	}
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(errs.String(), "\n"))
+	}
+	return nil
+}
+
+// withTableAPIParams returns the request params completed with the configured ones, request params taking precedence
+func withTableAPIParams(configured map[string]string, params map[string]string) map[string]string {
+	if len(configured) == 0 {
+		return params
+	}
+	merged := make(map[string]string, len(configured)+len(params))
+	for name, value := range configured {
+		merged[name] = value
+	}
+	for name, value := range params {
+		merged[name] = value
+	}
+	return merged
+}
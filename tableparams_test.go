@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestTableAPIParamsValidate(t *testing.T) {
+	valid := TableAPIParamsConfig{Get: map[string]string{"sysparm_no_count": "true"}}
+	if err := valid.validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	invalid := TableAPIParamsConfig{
+		Create: map[string]string{"sysparm_input_display_value": "true"},
+		Update: map[string]string{"no_count": "true"},
+	}
+	err := invalid.validate()
+	want := "table_api_params create parameter sysparm_input_display_value is set by the webhook, use input_display_value instead\n" +
+		"table_api_params update parameter no_count is invalid, must start with sysparm_"
+	if err == nil || err.Error() != want {
+		t.Errorf("Unexpected error: got %v, want %v", err, want)
+	}
+}
+
+func TestTableAPIParams(t *testing.T) {
+	queries := make(map[string]url.Values)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries[r.Method] = r.URL.Query()
+		if r.Method == http.MethodGet {
+			fmt.Fprint(w, `{"result":[]}`)
+			return
+		}
+		fmt.Fprint(w, `{"result":{"number":"INC0001","sys_id":"42"}}`)
+	}))
+	defer ts.Close()
+
+	snClient, err := NewServiceNowClient("instancename", "username", "password")
+	if err != nil {
+		t.Fatalf("Error occured on NewServiceNowClient: %s", err)
+	}
+	snClient.baseURL = ts.URL
+	snClient.tableAPIParams = TableAPIParamsConfig{
+		Create: map[string]string{"sysparm_suppress_auto_sys_field": "true"},
+		Update: map[string]string{"sysparm_suppress_auto_sys_field": "true"},
+		Get:    map[string]string{"sysparm_no_count": "true", "sysparm_limit": "10"},
+	}
+
+	if _, err := snClient.CreateIncident(Incident{"short_description": "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snClient.UpdateIncident(Incident{"comments": "test"}, "42"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snClient.GetIncidents(map[string]string{"sysparm_limit": "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		if got := queries[method].Get("sysparm_suppress_auto_sys_field"); got != "true" {
+			t.Errorf("Missing configured parameter of %s request, got query %v", method, queries[method])
+		}
+		if got := queries[method].Get("sysparm_input_display_value"); got != "false" {
+			t.Errorf("Webhook parameters must be kept in %s request, got query %v", method, queries[method])
+		}
+	}
+	if got := queries[http.MethodGet].Get("sysparm_no_count"); got != "true" {
+		t.Errorf("Missing configured parameter of get request, got query %v", queries[http.MethodGet])
+	}
+	if got := queries[http.MethodGet].Get("sysparm_limit"); got != "1" {
+		t.Errorf("Request parameters must take precedence over the configured ones, got sysparm_limit %s", got)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	tmpltext "text/template"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const (
+	defaultIncidentTaskTable          = "incident_task"
+	defaultIncidentTaskComponentField = "short_description"
+)
+
+// IncidentTasksConfig - Child incident tasks created under the incident for each affected component
+type IncidentTasksConfig struct {
+	// Alert label holding the component, incident tasks are disabled when not set
+	Label string `yaml:"label"`
+	Table string `yaml:"table"`
+	// Field of the task holding the component, used to find the existing tasks of the incident
+	ComponentField         string            `yaml:"component_field"`
+	AssignmentGroups       map[string]string `yaml:"assignment_groups"`
+	DefaultAssignmentGroup string            `yaml:"default_assignment_group"`
+	Fields                 map[string]string `yaml:"fields"`
+}
+
+func (c IncidentTasksConfig) validate() error {
+	var errs strings.Builder
+	for field, text := range c.Fields {
+		if _, err := tmpltext.New(field).Funcs(templateFuncs("")).Parse(text); err != nil {
+			errs.WriteString(fmt.Sprintf("incident_tasks field %s template is invalid: %v\n", field, err))
+		}
+	}
+	if len(c.Label) == 0 && (len(c.AssignmentGroups) > 0 || len(c.Fields) > 0) {
+		errs.WriteString("incident_tasks label is missing\n")
+	}
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(errs.String(), "\n"))
+	}
+	return nil
+}
+
+func (c IncidentTasksConfig) table() string {
+	if len(c.Table) == 0 {
+		return defaultIncidentTaskTable
+	}
+	return c.Table
+}
+
+func (c IncidentTasksConfig) componentField() string {
+	if len(c.ComponentField) == 0 {
+		return defaultIncidentTaskComponentField
+	}
+	return c.ComponentField
+}
+
+// componentAlerts returns the firing alerts of the group by component
+func componentAlerts(data template.Data, label string) map[string]template.Alerts {
+	components := make(map[string]template.Alerts)
+	for _, alert := range data.Alerts.Firing() {
+		if component := alert.Labels[label]; len(component) > 0 {
+			components[component] = append(components[component], alert)
+		}
+	}
+	return components
+}
+
+// createIncidentTasks creates an incident task for each component of the firing alerts without one yet,
+// errors are logged but ignored
+func createIncidentTasks(ctx context.Context, data template.Data, incident Incident) {
+	tasks := config.IncidentTasks
+	if len(tasks.Label) == 0 || len(incident.GetSysID()) == 0 {
+		return
+	}
+	components := componentAlerts(data, tasks.Label)
+	if len(components) == 0 {
+		return
+	}
+
+	existingTasks, err := serviceNowFor(ctx, data).GetRecords(tasks.table(), map[string]string{"incident": incident.GetSysID()})
+	if err != nil {
+		webhookIncidentTaskErrors.Inc()
+		incidentLog(ctx, data, incident).Errorf("Error getting incident tasks of incident (%s) for alert group key: %s, %v", incident.GetNumber(), getGroupKey(data), err)
+		return
+	}
+	for _, task := range existingTasks {
+		delete(components, fmt.Sprint(task[tasks.componentField()]))
+	}
+
+	names := make([]string, 0, len(components))
+	for component := range components {
+		names = append(names, component)
+	}
+	sort.Strings(names)
+
+	for _, component := range names {
+		taskParam, err := renderIncidentTask(data, component, components[component])
+		if err == nil {
+			taskParam["incident"] = incident.GetSysID()
+			_, err = serviceNowFor(ctx, data).CreateRecord(tasks.table(), taskParam)
+		}
+		if err != nil {
+			webhookIncidentTaskErrors.Inc()
+			incidentLog(ctx, data, incident).Errorf("Error creating incident task of component %s for incident (%s), %v", component, incident.GetNumber(), err)
+			continue
+		}
+		incidentLog(ctx, data, incident).Infof("Incident task of component %s created for incident (%s)", component, incident.GetNumber())
+	}
+}
+
+// renderIncidentTask renders the fields of the task of the component, templates being applied to the alerts of the component
+func renderIncidentTask(data template.Data, component string, alerts template.Alerts) (Incident, error) {
+	tasks := config.IncidentTasks
+	componentData := data
+	componentData.Alerts = alerts
+	componentData.CommonLabels = template.KV{}
+	for name, value := range data.CommonLabels {
+		componentData.CommonLabels[name] = value
+	}
+	componentData.CommonLabels[tasks.Label] = component
+
+	taskParam := Incident{}
+	for field, text := range tasks.Fields {
+		value, err := applyTemplate(field, text, componentData)
+		if err != nil {
+			webhookIncidentTemplateError.Inc()
+			return nil, err
+		}
+		taskParam[field] = value
+	}
+
+	group := tasks.AssignmentGroups[component]
+	if len(group) == 0 {
+		group = tasks.DefaultAssignmentGroup
+	}
+	if len(group) > 0 {
+		taskParam["assignment_group"] = group
+	}
+	taskParam[tasks.componentField()] = component
+	return taskParam, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateIncidentTasks(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.IncidentTasks = IncidentTasksConfig{
+		Label:                  "component",
+		AssignmentGroups:       map[string]string{"database": "dba"},
+		DefaultAssignmentGroup: "ops",
+		Fields:                 map[string]string{"description": "{{ len .Alerts }} alert(s) on {{ .CommonLabels.component }}"},
+	}
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetRecords", "incident_task", map[string]string{"incident": "42"}).Return([]Incident{{"short_description": "network"}}, nil)
+	snClientMock.On("CreateRecord", "incident_task", mock.Anything).Return(Incident{"sys_id": "1"}, nil)
+
+	data := template.Data{
+		Status: "firing",
+		Alerts: template.Alerts{
+			{Status: "firing", Labels: template.KV{"component": "database"}},
+			{Status: "firing", Labels: template.KV{"component": "database"}},
+			{Status: "firing", Labels: template.KV{"component": "storage"}},
+			{Status: "firing", Labels: template.KV{"component": "network"}},
+			{Status: "resolved", Labels: template.KV{"component": "dns"}},
+			{Status: "firing"},
+		},
+	}
+	createIncidentTasks(context.Background(), data, Incident{"sys_id": "42", "number": "INC42"})
+
+	snClientMock.AssertNumberOfCalls(t, "CreateRecord", 2)
+	snClientMock.AssertCalled(t, "CreateRecord", "incident_task", Incident{
+		"incident":          "42",
+		"short_description": "database",
+		"assignment_group":  "dba",
+		"description":       "2 alert(s) on database",
+	})
+	snClientMock.AssertCalled(t, "CreateRecord", "incident_task", Incident{
+		"incident":          "42",
+		"short_description": "storage",
+		"assignment_group":  "ops",
+		"description":       "1 alert(s) on storage",
+	})
+}
+
+func TestCreateIncidentTasks_Errors(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.IncidentTasks = IncidentTasksConfig{Label: "component"}
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetRecords", mock.Anything, mock.Anything).Return([]Incident{}, errors.New("lookup failed"))
+
+	data := template.Data{Alerts: template.Alerts{{Status: "firing", Labels: template.KV{"component": "database"}}}}
+	createIncidentTasks(context.Background(), data, Incident{"sys_id": "42"})
+	snClientMock.AssertNotCalled(t, "CreateRecord", mock.Anything, mock.Anything)
+
+	// Tasks are disabled without label
+	config.IncidentTasks = IncidentTasksConfig{}
+	snClientMock = new(MockedSnClient)
+	serviceNow = snClientMock
+	createIncidentTasks(context.Background(), data, Incident{"sys_id": "42"})
+	snClientMock.AssertNotCalled(t, "GetRecords", mock.Anything, mock.Anything)
+}
+
+func TestIncidentTasksConfig_Validate(t *testing.T) {
+	if err := (IncidentTasksConfig{Label: "component", Fields: map[string]string{"description": "{{ .Alerts"}}).validate(); err == nil {
+		t.Error("Invalid field template must be rejected")
+	}
+	if err := (IncidentTasksConfig{AssignmentGroups: map[string]string{"database": "dba"}}).validate(); err == nil {
+		t.Error("Missing label must be rejected")
+	}
+}
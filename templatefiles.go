@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	tmpltext "text/template"
+)
+
+// compileTemplateFiles parses the Go templates defined in the files matching the patterns, to be
+// used by name ({{ template "name" . }}) from any incident field template
+func compileTemplateFiles(patterns []string) (*tmpltext.Template, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	templates := tmpltext.New("").Funcs(templateFuncs(""))
+	for _, pattern := range patterns {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("template_files pattern %q is invalid: %v", pattern, err)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("template_files pattern %q matches no file", pattern)
+		}
+		if _, err := templates.ParseFiles(files...); err != nil {
+			return nil, fmt.Errorf("template_files are invalid: %v", err)
+		}
+	}
+	return templates, nil
+}
+
+// newFieldTemplate returns a new template named after the field, sharing the templates of the template files
+// and the template functions of the receiver
+func newFieldTemplate(name string, receiver string) (*tmpltext.Template, error) {
+	if templateFiles == nil {
+		return tmpltext.New(name).Funcs(templateFuncs(receiver)), nil
+	}
+	shared, err := templateFiles.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return shared.Funcs(templateFuncs(receiver)).New(name), nil
+}
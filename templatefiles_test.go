@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestCompileTemplateFiles(t *testing.T) {
+	defer loadConfig("config/servicenow_example.yml")
+	dir, err := ioutil.TempDir("", "templatefiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	content := `{{ define "incident.description" }}{{ range .Alerts }}- {{ .Annotations.summary }}
+{{ end }}{{ end }}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "incident.tmpl"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	templateFiles, err = compileTemplateFiles([]string{filepath.Join(dir, "*.tmpl")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := template.Data{Alerts: template.Alerts{
+		{Annotations: template.KV{"summary": "High load"}},
+		{Annotations: template.KV{"summary": "Disk full"}},
+	}}
+	got, err := applyTemplate("description", `{{ template "incident.description" . }}`, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "- High load\n- Disk full\n"; got != want {
+		t.Errorf("Unexpected rendered template: got %q, want %q", got, want)
+	}
+
+	if _, err := compileTemplateFiles([]string{filepath.Join(dir, "*.missing")}); err == nil {
+		t.Error("Pattern matching no file must be rejected")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "invalid.tmpl"), []byte(`{{ define "x" }}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := compileTemplateFiles([]string{filepath.Join(dir, "*.tmpl")}); err == nil {
+		t.Error("Invalid template file must be rejected")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	tmpltext "text/template"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// TemplateSetConfig - Named set of incident and journal templates, e.g. in the working language or format of a service desk
+type TemplateSetConfig struct {
+	// Fields overriding the default_incident ones
+	DefaultIncident map[string]string `yaml:"default_incident"`
+	// Journal templates used instead of the journal ones
+	Journal JournalTemplates `yaml:"journal"`
+}
+
+// TemplateSetsConfig - Template sets, selected by receiver
+type TemplateSetsConfig struct {
+	Sets map[string]TemplateSetConfig `yaml:"sets"`
+	// Template set name by receiver
+	Receivers map[string]string `yaml:"receivers"`
+}
+
+func (c TemplateSetsConfig) validate() error {
+	var errs strings.Builder
+	for receiver, name := range c.Receivers {
+		if _, ok := c.Sets[name]; !ok {
+			errs.WriteString(fmt.Sprintf("template set %q of receiver %q is not defined\n", name, receiver))
+		}
+	}
+	for name, set := range c.Sets {
+		for _, event := range []string{journalCreated, journalAlertsAdded, journalAlertsResolved, journalAutoClosed} {
+			if _, err := tmpltext.New(event).Funcs(templateFuncs("")).Parse(set.Journal.get(event)); err != nil {
+				errs.WriteString(fmt.Sprintf("template set %q journal %s template is invalid: %v\n", name, event, err))
+			}
+		}
+	}
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(errs.String(), "\n"))
+	}
+	return nil
+}
+
+// selectTemplateSet returns the template set of the receiver of the alert group, or nil
+func selectTemplateSet(data template.Data) *TemplateSetConfig {
+	name, ok := config.TemplateSets.Receivers[data.Receiver]
+	if !ok {
+		return nil
+	}
+	set, ok := config.TemplateSets.Sets[name]
+	if !ok {
+		return nil
+	}
+	return &set
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestTemplateSets(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.TemplateSets = TemplateSetsConfig{
+		Sets: map[string]TemplateSetConfig{
+			"french-brief": {
+				DefaultIncident: map[string]string{"short_description": "{{ .CommonLabels.alertname }} en cours"},
+				Journal:         JournalTemplates{Created: "Incident créé", AlertsAdded: "Alertes ajoutées"},
+			},
+		},
+		Receivers: map[string]string{"paris": "french-brief"},
+	}
+	config.Journal = JournalConfig{
+		Templates: JournalTemplates{Created: "Incident created"},
+		Receivers: map[string]JournalTemplates{"paris": {AlertsAdded: "Alerts added to {{ .Receiver }}"}},
+	}
+	defer func() { config.Journal = JournalConfig{} }()
+
+	data := template.Data{Receiver: "paris", CommonLabels: template.KV{"alertname": "DiskFull"}}
+	defaultIncident := selectDefaultIncident(data)
+	if defaultIncident["short_description"] != "{{ .CommonLabels.alertname }} en cours" {
+		t.Errorf("Unexpected template set default incident: %v", defaultIncident)
+	}
+	if defaultIncident["assignment_group"] != config.DefaultIncident["assignment_group"] {
+		t.Errorf("Fields not overridden by the template set must be kept: %v", defaultIncident)
+	}
+
+	tests := []struct {
+		receiver string
+		event    string
+		want     string
+	}{
+		{"paris", journalCreated, "Incident créé"},
+		{"paris", journalAlertsAdded, "Alerts added to paris"},
+		{"london", journalCreated, "Incident created"},
+	}
+	for _, test := range tests {
+		incident := Incident{}
+		applyJournal(context.Background(), template.Data{Receiver: test.receiver}, test.event, incident)
+		if incident["work_notes"] != test.want {
+			t.Errorf("Unexpected %s journal of receiver %s: got %v, want %v", test.event, test.receiver, incident["work_notes"], test.want)
+		}
+	}
+}
+
+func TestTemplateSetsConfig_Validate(t *testing.T) {
+	c := TemplateSetsConfig{
+		Sets:      map[string]TemplateSetConfig{"brief": {Journal: JournalTemplates{Created: "{{ .Receiver"}}},
+		Receivers: map[string]string{"team": "missing"},
+	}
+	err := c.validate()
+	expected := `template set "missing" of receiver "team" is not defined` + "\n" +
+		`template set "brief" journal created template is invalid: template: created:1: unclosed action`
+	if err == nil || err.Error() != expected {
+		t.Errorf("Unexpected error; got: %v, want: %v", err, expected)
+	}
+}
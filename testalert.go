@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const testAlertName = "ServiceNowWebhookTest"
+
+var (
+	sendTestAlertCommand     = kingpin.Command("send-test-alert", "Run a synthetic alert group through the full pipeline against the configured ServiceNow instance, to verify incident creation end to end.")
+	sendTestAlertStatus      = sendTestAlertCommand.Flag("status", "Status of the test alert.").Default("firing").Enum("firing", "resolved")
+	sendTestAlertReceiver    = sendTestAlertCommand.Flag("receiver", "Receiver of the test alert group.").Default("send-test-alert").String()
+	sendTestAlertLabels      = sendTestAlertCommand.Flag("label", "Label of the test alert, overriding the default ones (e.g. --label severity=critical). Repeatable.").StringMap()
+	sendTestAlertAnnotations = sendTestAlertCommand.Flag("annotation", "Annotation of the test alert, overriding the default ones. Repeatable.").StringMap()
+)
+
+// testAlertGroup returns an alert group of a single synthetic alert, all of its labels being group labels
+func testAlertGroup(status string, receiver string, labels map[string]string, annotations map[string]string) template.Data {
+	alertLabels := template.KV{"alertname": testAlertName, "severity": "info"}
+	for name, value := range labels {
+		alertLabels[name] = value
+	}
+	alertAnnotations := template.KV{"summary": "Test alert sent by alertmanager-webhook-servicenow send-test-alert"}
+	for name, value := range annotations {
+		alertAnnotations[name] = value
+	}
+
+	alert := template.Alert{Status: status, Labels: alertLabels, Annotations: alertAnnotations, StartsAt: now()}
+	if status == "resolved" {
+		alert.EndsAt = now()
+	}
+	return template.Data{
+		Receiver:          receiver,
+		Status:            status,
+		Alerts:            template.Alerts{alert},
+		GroupLabels:       alertLabels,
+		CommonLabels:      alertLabels,
+		CommonAnnotations: alertAnnotations,
+	}
+}
+
+// sendTestAlert processes the alert group as the webhook does, and writes the resulting action and incident
+func sendTestAlert(w io.Writer, data template.Data) error {
+	start := now()
+	err := onAlertGroup(context.Background(), data)
+	fmt.Fprintf(w, "Alert group key: %s\n", getGroupKey(data))
+	if entries, ok := history.get(getGroupKey(data)); ok {
+		for _, entry := range entries {
+			if entry.Time.Before(start) {
+				continue
+			}
+			fmt.Fprintf(w, "%s: %s", entry.Time.Format(time.RFC3339), entry.Action)
+			if len(entry.Incident) > 0 {
+				fmt.Fprintf(w, " incident %s", entry.Incident)
+			}
+			if len(entry.Error) > 0 {
+				fmt.Fprintf(w, " error: %s", entry.Error)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	return err
+}
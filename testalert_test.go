@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTestAlertGroup(t *testing.T) {
+	data := testAlertGroup("resolved", "ops", map[string]string{"severity": "critical", "team": "payments"}, map[string]string{"runbook": "https://runbooks/test"})
+	if data.Receiver != "ops" || data.Status != "resolved" || len(data.Alerts) != 1 || data.Alerts[0].EndsAt.IsZero() {
+		t.Fatalf("Unexpected test alert group: %+v", data)
+	}
+	want := map[string]string{"alertname": testAlertName, "severity": "critical", "team": "payments"}
+	for name, value := range want {
+		if data.CommonLabels[name] != value || data.GroupLabels[name] != value {
+			t.Errorf("Unexpected label %s: got %v, want %v", name, data.CommonLabels[name], value)
+		}
+	}
+	if data.CommonAnnotations["runbook"] != "https://runbooks/test" || len(data.CommonAnnotations["summary"]) == 0 {
+		t.Errorf("Unexpected annotations: %v", data.CommonAnnotations)
+	}
+}
+
+func TestSendTestAlert(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	incidents = newIncidentCache()
+	history = newGroupHistory(10)
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{"sys_id": "42", "number": "INC42"}, nil)
+
+	var out bytes.Buffer
+	data := testAlertGroup("firing", "send-test-alert", nil, nil)
+	if err := sendTestAlert(&out, data); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+	if !strings.Contains(out.String(), "Alert group key: "+getGroupKey(data)) || !strings.Contains(out.String(), "create incident INC42") {
+		t.Errorf("Unexpected output: %s", out.String())
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const (
+	defaultTimelineFileName = "alert-timeline.svg"
+	timelineMaxAlerts       = 50
+	timelineWidth           = 800
+	timelineLabelWidth      = 250
+	timelineRowHeight       = 24
+)
+
+// TimelineConfig - SVG timeline of the alerts of the group attached to the incident
+type TimelineConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	FileName string `yaml:"file_name"`
+}
+
+// attachTimeline attaches the timeline of the alert group to the incident, errors are logged but ignored
+func attachTimeline(ctx context.Context, data template.Data, incident Incident) {
+	if !config.Timeline.Enabled || len(incident.GetSysID()) == 0 {
+		return
+	}
+	fileName := config.Timeline.FileName
+	if len(fileName) == 0 {
+		fileName = defaultTimelineFileName
+	}
+
+	err := serviceNowFor(ctx, data).AttachFile("incident", incident.GetSysID(), fileName, "image/svg+xml", renderTimelineSVG(data))
+	if err != nil {
+		incidentLog(ctx, data, incident).Errorf("Error attaching timeline to incident (%s) for alert group key: %s, %v", incident.GetNumber(), getGroupKey(data), err)
+	}
+}
+
+// renderTimelineSVG renders the start and end times of the alerts of the group,
+// firing alerts lasting until now, as an SVG image
+func renderTimelineSVG(data template.Data) []byte {
+	alerts := data.Alerts
+	if len(alerts) > timelineMaxAlerts {
+		alerts = alerts[:timelineMaxAlerts]
+	}
+
+	current := now()
+	from, to := current, current
+	for _, alert := range alerts {
+		if !alert.StartsAt.IsZero() && alert.StartsAt.Before(from) {
+			from = alert.StartsAt
+		}
+		if end := timelineEnd(alert, current); end.After(to) {
+			to = end
+		}
+	}
+	span := to.Sub(from)
+	if span <= 0 {
+		span = time.Second
+	}
+	x := func(t time.Time) int {
+		return timelineLabelWidth + int(float64(timelineWidth-timelineLabelWidth-10)*float64(t.Sub(from))/float64(span))
+	}
+
+	height := (len(alerts) + 2) * timelineRowHeight
+	var svg bytes.Buffer
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", timelineWidth, height)
+	fmt.Fprintf(&svg, `<text x="%d" y="16">%s</text>`+"\n", timelineLabelWidth, escapeXML(from.UTC().Format(time.RFC3339)))
+	fmt.Fprintf(&svg, `<text x="%d" y="16" text-anchor="end">%s</text>`+"\n", timelineWidth-10, escapeXML(to.UTC().Format(time.RFC3339)))
+	for i, alert := range alerts {
+		y := (i + 1) * timelineRowHeight
+		start := alert.StartsAt
+		if start.IsZero() {
+			start = from
+		}
+		color := "#d9534f"
+		if alert.Status == "resolved" {
+			color = "#5cb85c"
+		}
+		width := x(timelineEnd(alert, current)) - x(start)
+		if width < 2 {
+			width = 2
+		}
+		fmt.Fprintf(&svg, `<text x="0" y="%d">%s</text>`+"\n", y+16, escapeXML(timelineAlertName(data, alert)))
+		fmt.Fprintf(&svg, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", x(start), y+4, width, timelineRowHeight-8, color)
+	}
+	if len(data.Alerts) > len(alerts) {
+		fmt.Fprintf(&svg, `<text x="0" y="%d">%d more alert(s)</text>`+"\n", height-8, len(data.Alerts)-len(alerts))
+	}
+	svg.WriteString("</svg>\n")
+	return svg.Bytes()
+}
+
+// timelineEnd returns the end time of a resolved alert, now otherwise
+func timelineEnd(alert template.Alert, current time.Time) time.Time {
+	if alert.Status == "resolved" && !alert.EndsAt.IsZero() {
+		return alert.EndsAt
+	}
+	return current
+}
+
+// timelineAlertName returns the labels distinguishing the alert in its group, or its alertname
+func timelineAlertName(data template.Data, alert template.Alert) string {
+	var pairs []string
+	for _, pair := range alert.Labels.SortedPairs() {
+		if _, ok := data.CommonLabels[pair.Name]; !ok {
+			pairs = append(pairs, pair.Name+"="+pair.Value)
+		}
+	}
+	if len(pairs) == 0 {
+		return alert.Labels["alertname"]
+	}
+	name := strings.Join(pairs, ", ")
+	if len(name) > 40 {
+		name = name[:37] + "..."
+	}
+	return name
+}
+
+func escapeXML(s string) string {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(s))
+	return escaped.String()
+}
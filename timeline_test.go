@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRenderTimelineSVG(t *testing.T) {
+	current := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	data := template.Data{
+		CommonLabels: template.KV{"alertname": "HighLoad"},
+		Alerts: template.Alerts{
+			{Status: "firing", Labels: template.KV{"alertname": "HighLoad", "instance": "a<b"}, StartsAt: current.Add(-time.Hour)},
+			{Status: "resolved", Labels: template.KV{"alertname": "HighLoad", "instance": "c"}, StartsAt: current.Add(-2 * time.Hour), EndsAt: current.Add(-time.Hour)},
+		},
+	}
+	svg := renderTimelineSVG(data)
+
+	// The SVG must be well formed XML
+	decoder := xml.NewDecoder(bytes.NewReader(svg))
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if err.Error() != "EOF" {
+				t.Fatalf("Invalid SVG: %v\n%s", err, svg)
+			}
+			break
+		}
+	}
+	for _, want := range []string{"instance=a&lt;b", "instance=c", "2020-01-01T10:00:00Z", `fill="#5cb85c"`, `fill="#d9534f"`} {
+		if !strings.Contains(string(svg), want) {
+			t.Errorf("Missing %s in SVG:\n%s", want, svg)
+		}
+	}
+}
+
+func TestAttachTimeline(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("AttachFile", "incident", "42", defaultTimelineFileName, "image/svg+xml", mock.Anything).Return(nil)
+
+	attachTimeline(context.Background(), template.Data{}, Incident{"sys_id": "42"})
+	snClientMock.AssertNotCalled(t, "AttachFile", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	config.Timeline.Enabled = true
+	attachTimeline(context.Background(), template.Data{}, Incident{"sys_id": "42"})
+	snClientMock.AssertExpectations(t)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// normalizeAlertTimes returns a copy of the alert group with alert timestamps
+// made writable to ServiceNow time fields, when clock_skew_tolerance is set:
+//   - StartsAt in the future beyond the tolerance is set to now
+//   - EndsAt of a resolved alert which is zero or in the future beyond the tolerance is set to now
+//   - EndsAt of a resolved alert before its StartsAt is set to StartsAt
+func normalizeAlertTimes(ctx context.Context, data template.Data) template.Data {
+	tolerance := config.Workflow.ClockSkewTolerance
+	if tolerance <= 0 {
+		return data
+	}
+
+	current := now()
+	alerts := make(template.Alerts, len(data.Alerts))
+	for i, alert := range data.Alerts {
+		if alert.StartsAt.After(current.Add(tolerance)) {
+			logNormalizedTime(ctx, data, "StartsAt", alert.StartsAt, current)
+			alert.StartsAt = current
+		}
+		if alert.Status == "resolved" {
+			if alert.EndsAt.IsZero() || alert.EndsAt.After(current.Add(tolerance)) {
+				logNormalizedTime(ctx, data, "EndsAt", alert.EndsAt, current)
+				alert.EndsAt = current
+			}
+			if alert.EndsAt.Before(alert.StartsAt) {
+				logNormalizedTime(ctx, data, "EndsAt", alert.EndsAt, alert.StartsAt)
+				alert.EndsAt = alert.StartsAt
+			}
+		}
+		alerts[i] = alert
+	}
+	data.Alerts = alerts
+	return data
+}
+
+func logNormalizedTime(ctx context.Context, data template.Data, field string, from time.Time, to time.Time) {
+	webhookAlertTimesNormalized.WithLabelValues(field).Inc()
+	alertGroupLog(ctx, data).Warnf("Alert %s %s normalized to %s for alert group key: %s", field, from.Format(time.RFC3339), to.Format(time.RFC3339), getGroupKey(data))
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestNormalizeAlertTimes(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	current := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	data := template.Data{Alerts: template.Alerts{
+		{Status: "firing", StartsAt: current.Add(time.Hour)},
+		{Status: "firing", StartsAt: current.Add(30 * time.Second)},
+		{Status: "resolved", StartsAt: current.Add(-time.Hour)},
+		{Status: "resolved", StartsAt: current.Add(-time.Hour), EndsAt: current.Add(-2 * time.Hour)},
+	}}
+
+	if got := normalizeAlertTimes(context.Background(), data); !got.Alerts[0].StartsAt.Equal(current.Add(time.Hour)) {
+		t.Errorf("Timestamps must not be normalized without clock_skew_tolerance")
+	}
+
+	config.Workflow.ClockSkewTolerance = time.Minute
+	got := normalizeAlertTimes(context.Background(), data)
+	want := []struct{ startsAt, endsAt time.Time }{
+		{current, time.Time{}},
+		{current.Add(30 * time.Second), time.Time{}},
+		{current.Add(-time.Hour), current},
+		{current.Add(-time.Hour), current.Add(-time.Hour)},
+	}
+	for i, w := range want {
+		if !got.Alerts[i].StartsAt.Equal(w.startsAt) || !got.Alerts[i].EndsAt.Equal(w.endsAt) {
+			t.Errorf("Unexpected alert %d timestamps: got %v - %v, want %v - %v", i, got.Alerts[i].StartsAt, got.Alerts[i].EndsAt, w.startsAt, w.endsAt)
+		}
+	}
+	if !data.Alerts[0].StartsAt.Equal(current.Add(time.Hour)) {
+		t.Errorf("Original alert group must not be modified")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSConfig - TLS options of the connections to ServiceNow, e.g. to instances fronted by internal proxies with
+// private CAs or requiring client certificates
+type TLSConfig struct {
+	// CA certificates (PEM) verifying the server certificate, instead of the system ones
+	CAFile string `yaml:"ca_file"`
+	// Client certificate and key (PEM), both required
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// Server name verified in the server certificate, the host of the request by default
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+func (c TLSConfig) enabled() bool {
+	return c != TLSConfig{}
+}
+
+func (c TLSConfig) validate() error {
+	if (len(c.CertFile) == 0) != (len(c.KeyFile) == 0) {
+		return fmt.Errorf("tls_config cert_file and key_file must be set together")
+	}
+	return nil
+}
+
+// tlsConfig returns the TLS configuration, loading the CA and client certificates
+func (c TLSConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if len(c.CAFile) > 0 {
+		ca, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read tls_config ca_file: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("tls_config ca_file %s holds no PEM certificate", c.CAFile)
+		}
+	}
+	if len(c.CertFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load tls_config client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCertificate writes a self-signed client certificate and its key to the directory
+func writeClientCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "webhook"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600)
+	return certFile, keyFile
+}
+
+func TestServiceNowClient_TLSConfig(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) != 1 || r.TLS.PeerCertificates[0].Subject.CommonName != "webhook" {
+			t.Errorf("Unexpected client certificates: %v", r.TLS.PeerCertificates)
+		}
+		w.Write([]byte(`{"result":[]}`))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "tlsconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600)
+	certFile, keyFile := writeClientCertificate(t, dir)
+
+	c := ServiceNowConfig{InstanceName: "instance", UserName: "user", Password: "pass"}
+	snClient, err := newServiceNowClientFromConfig(c)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	snClient.baseURL = ts.URL
+	if _, err := snClient.GetIncidents(map[string]string{}); err == nil {
+		t.Errorf("Server certificate signed by a private CA must not be trusted by default")
+	}
+
+	c.TLSConfig = TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "example.com"}
+	if snClient, err = newServiceNowClientFromConfig(c); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	snClient.baseURL = ts.URL
+	if _, err := snClient.GetIncidents(map[string]string{}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	c.TLSConfig = TLSConfig{CAFile: filepath.Join(dir, "missing.pem")}
+	if _, err := newServiceNowClientFromConfig(c); err == nil {
+		t.Errorf("Missing CA file must fail the client creation")
+	}
+}
+
+func TestTLSConfig_Validate(t *testing.T) {
+	if err := (TLSConfig{CertFile: "client.pem", KeyFile: "client-key.pem"}).validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := (TLSConfig{CertFile: "client.pem"}).validate(); err == nil {
+		t.Errorf("Client certificate without key must be invalid")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TransformConfig - Transformation applied to a rendered incident field value
+type TransformConfig struct {
+	// One of: trim, truncate, regex_replace, map, prefix, suffix
+	Type        string            `yaml:"type"`
+	Length      int               `yaml:"length"`
+	Regex       string            `yaml:"regex"`
+	Replacement string            `yaml:"replacement"`
+	Values      map[string]string `yaml:"values"`
+	Default     *string           `yaml:"default"`
+	Value       string            `yaml:"value"`
+}
+
+type fieldTransform func(string) string
+
+// compileFieldTransforms compiles the transformation pipelines of each field
+func compileFieldTransforms(fieldTransforms map[string][]TransformConfig) (map[string][]fieldTransform, error) {
+	pipelines := make(map[string][]fieldTransform, len(fieldTransforms))
+	for field, transforms := range fieldTransforms {
+		for i, t := range transforms {
+			transform, err := t.compile()
+			if err != nil {
+				return nil, fmt.Errorf("field_transforms %s[%d]: %v", field, i, err)
+			}
+			pipelines[field] = append(pipelines[field], transform)
+		}
+	}
+	return pipelines, nil
+}
+
+func (t TransformConfig) compile() (fieldTransform, error) {
+	switch t.Type {
+	case "trim":
+		return strings.TrimSpace, nil
+	case "truncate":
+		if t.Length <= 0 {
+			return nil, fmt.Errorf("truncate length must be positive")
+		}
+		return func(value string) string {
+			runes := []rune(value)
+			if len(runes) <= t.Length {
+				return value
+			}
+			return string(runes[:t.Length])
+		}, nil
+	case "regex_replace":
+		re, err := regexp.Compile(t.Regex)
+		if err != nil {
+			return nil, err
+		}
+		return func(value string) string {
+			return re.ReplaceAllString(value, t.Replacement)
+		}, nil
+	case "map":
+		return func(value string) string {
+			if mapped, ok := t.Values[value]; ok {
+				return mapped
+			}
+			if t.Default != nil {
+				return *t.Default
+			}
+			return value
+		}, nil
+	case "prefix":
+		return func(value string) string {
+			return t.Value + value
+		}, nil
+	case "suffix":
+		return func(value string) string {
+			return value + t.Value
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown transform type %q", t.Type)
+}
+
+// transformIncident applies the transformation pipelines to the incident fields
+func transformIncident(incident Incident) {
+	for field, pipeline := range fieldTransforms {
+		value, ok := incident[field].(string)
+		if !ok {
+			continue
+		}
+		for _, transform := range pipeline {
+			value = transform(value)
+		}
+		incident[field] = value
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestTransformIncident(t *testing.T) {
+	unknown := "3"
+	var err error
+	fieldTransforms, err = compileFieldTransforms(map[string][]TransformConfig{
+		"short_description": {
+			{Type: "trim"},
+			{Type: "regex_replace", Regex: `\s+`, Replacement: " "},
+			{Type: "prefix", Value: "[AM] "},
+			{Type: "truncate", Length: 16},
+			{Type: "suffix", Value: "..."},
+		},
+		"impact": {
+			{Type: "map", Values: map[string]string{"critical": "1", "warning": "2"}, Default: &unknown},
+		},
+		"urgency": {
+			{Type: "map", Values: map[string]string{"critical": "1"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { fieldTransforms = nil }()
+
+	incident := Incident{
+		"short_description": "  Disk   almost full on server01  ",
+		"impact":            "info",
+		"urgency":           "warning",
+	}
+	transformIncident(incident)
+
+	want := Incident{
+		"short_description": "[AM] Disk almost...",
+		"impact":            "3",
+		"urgency":           "warning",
+	}
+	for field, value := range want {
+		if incident[field] != value {
+			t.Errorf("Unexpected %s: got %q, want %q", field, incident[field], value)
+		}
+	}
+}
+
+func TestCompileFieldTransforms_Invalid(t *testing.T) {
+	tests := map[string]TransformConfig{
+		"unknown_type":     {Type: "upper"},
+		"invalid_regex":    {Type: "regex_replace", Regex: "("},
+		"invalid_truncate": {Type: "truncate"},
+	}
+	for name, transform := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := compileFieldTransforms(map[string][]TransformConfig{"description": {transform}}); err == nil {
+				t.Errorf("Expected an error, got none")
+			}
+		})
+	}
+}
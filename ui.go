@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+const statusRecentEntries = 50
+
+// statusResponse is the live processing status served on /api/v1/status
+type statusResponse struct {
+	Recent           []recentEntry     `json:"recent"`
+	Incidents        []recentEntry     `json:"incidents"`
+	Inflight         int               `json:"inflight"`
+	ScheduledActions int               `json:"scheduled_actions"`
+	PausedRoutes     []pausedRouteRef  `json:"paused_routes"`
+	ServiceNow       *serviceNowHealth `json:"servicenow,omitempty"`
+}
+
+// pausedRouteRef summarizes a paused route
+type pausedRouteRef struct {
+	Receiver string    `json:"receiver"`
+	Since    time.Time `json:"since"`
+	Spooled  int       `json:"spooled"`
+}
+
+// statusHandler serves the live processing status on /api/v1/status
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	status := statusResponse{
+		Recent:           history.recent(statusRecentEntries),
+		Incidents:        []recentEntry{},
+		ScheduledActions: len(scheduler.pending()),
+		PausedRoutes:     []pausedRouteRef{},
+		ServiceNow:       health.status(),
+	}
+
+	// The latest incident of each group key
+	seen := make(map[string]bool)
+	for _, entry := range history.recent(0) {
+		if len(entry.Incident) > 0 && !seen[entry.GroupKey] {
+			seen[entry.GroupKey] = true
+			status.Incidents = append(status.Incidents, entry)
+		}
+	}
+
+	coalescer.mu.Lock()
+	status.Inflight = coalescer.inflight
+	coalescer.mu.Unlock()
+
+	for _, route := range pauses.list() {
+		status.PausedRoutes = append(status.PausedRoutes, pausedRouteRef{Receiver: route.Receiver, Since: route.Since, Spooled: len(route.Spool)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+var uiTemplate = template.Must(template.New("ui").Parse(uiPage))
+
+// ui serves the operator web UI on /ui
+func ui(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	uiTemplate.Execute(w, struct{ Prefix string }{Prefix: linkPrefix})
+}
+
+const uiPage = `<!DOCTYPE html>
+<html>
+<head>
+<title>alertmanager-webhook-servicenow</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: left; font-size: 90%; }
+.error { color: #b00; }
+</style>
+</head>
+<body>
+<h1>alertmanager-webhook-servicenow</h1>
+<p>In-flight notifications: <b id="inflight"></b> &mdash; Scheduled actions: <b id="scheduled"></b>
+&mdash; <a href="{{ .Prefix }}/metrics">Metrics</a></p>
+<p>Admin token (resync, pause/resume): <input id="token" type="password" size="30"></p>
+
+<h2>Paused routes</h2>
+<p>Receiver: <input id="receiver" size="30"> <button onclick="route('pause', document.getElementById('receiver').value)">Pause</button></p>
+<table><thead><tr><th>Receiver</th><th>Since</th><th>Spooled</th><th></th></tr></thead><tbody id="paused"></tbody></table>
+
+<h2>Managed incidents</h2>
+<table><thead><tr><th>Time</th><th>Group key</th><th>Incident</th><th>Last action</th><th></th></tr></thead><tbody id="incidents"></tbody></table>
+
+<h2>Recent notifications</h2>
+<table><thead><tr><th>Time</th><th>Group key</th><th>Status</th><th>Action</th><th>Incident</th><th>Error</th></tr></thead><tbody id="recent"></tbody></table>
+
+<script>
+var prefix = {{ .Prefix }};
+function cell(row, text, className) {
+  var td = document.createElement('td');
+  td.textContent = text || '';
+  if (className) { td.className = className; }
+  row.appendChild(td);
+  return td;
+}
+function button(row, label, action) {
+  var b = document.createElement('button');
+  b.textContent = label;
+  b.onclick = action;
+  row.appendChild(document.createElement('td')).appendChild(b);
+}
+function fill(id, items, render) {
+  var body = document.getElementById(id);
+  body.innerHTML = '';
+  items.forEach(function(item) { var row = document.createElement('tr'); render(row, item); body.appendChild(row); });
+}
+function post(url, headers) {
+  fetch(url, {method: 'POST', headers: headers || {}}).then(function(r) { return r.json(); })
+    .then(function(r) { alert(r.Message); refresh(); });
+}
+function resync(groupKey) {
+  post(prefix + '/-/resync?group_key=' + encodeURIComponent(groupKey),
+    {'Authorization': 'Bearer ' + document.getElementById('token').value});
+}
+function route(action, receiver) {
+  post(prefix + '/api/v1/' + action + '?receiver=' + encodeURIComponent(receiver),
+    {'Authorization': 'Bearer ' + document.getElementById('token').value});
+}
+function refresh() {
+  fetch(prefix + '/api/v1/status').then(function(r) { return r.json(); }).then(function(status) {
+    document.getElementById('inflight').textContent = status.inflight;
+    document.getElementById('scheduled').textContent = status.scheduled_actions;
+    fill('paused', status.paused_routes, function(row, p) {
+      cell(row, p.receiver); cell(row, p.since); cell(row, p.spooled);
+      button(row, 'Resume', function() { route('resume', p.receiver); });
+    });
+    fill('incidents', status.incidents, function(row, e) {
+      cell(row, e.time); cell(row, e.group_key); cell(row, e.incident); cell(row, e.action);
+      button(row, 'Resync', function() { resync(e.group_key); });
+    });
+    fill('recent', status.recent || [], function(row, e) {
+      cell(row, e.time); cell(row, e.group_key); cell(row, e.status); cell(row, e.action); cell(row, e.incident);
+      cell(row, e.error, 'error');
+    });
+  });
+}
+refresh();
+setInterval(refresh, 5000);
+</script>
+</body>
+</html>
+`
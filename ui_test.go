@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusHandler(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() {
+		now = time.Now
+		history = newGroupHistory(defaultHistoryMaxEntries)
+		pauses = newRoutePauses()
+	}()
+	history = newGroupHistory(defaultHistoryMaxEntries)
+	pauses = newRoutePauses()
+	pauses.pause("team")
+
+	current := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, e := range []struct {
+		groupKey string
+		action   string
+		incident string
+		err      error
+	}{
+		{"a", "create", "INC1", nil},
+		{"b", "create", "INC2", nil},
+		{"a", "update", "INC1", nil},
+		{"c", "lookup", "", errors.New("unavailable")},
+	} {
+		current = current.Add(time.Minute)
+		now = func() time.Time { return current }
+		history.record(e.groupKey, "firing", e.action, e.incident, e.err)
+	}
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(statusHandler).ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/status", nil))
+	status := statusResponse{}
+	if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(status.Recent) != 4 || status.Recent[0].GroupKey != "c" || status.Recent[0].Error != "unavailable" {
+		t.Errorf("Unexpected recent notifications: %+v", status.Recent)
+	}
+	if len(status.Incidents) != 2 || status.Incidents[0].GroupKey != "a" || status.Incidents[0].Action != "update" || status.Incidents[1].Incident != "INC2" {
+		t.Errorf("Unexpected incidents: %+v", status.Incidents)
+	}
+	if len(status.PausedRoutes) != 1 || status.PausedRoutes[0].Receiver != "team" {
+		t.Errorf("Unexpected paused routes: %+v", status.PausedRoutes)
+	}
+}
+
+func TestUI(t *testing.T) {
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(ui).ServeHTTP(rr, httptest.NewRequest("GET", "/ui", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "/api/v1/status") {
+		t.Errorf("Unexpected UI page: %v", rr.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// Modes selecting the notifications processed per alert
+const (
+	ungroupedNever     = "never"
+	ungroupedReceivers = "receivers"
+	ungroupedAlways    = "always"
+)
+
+// UngroupedConfig - Per-alert processing of the notifications of Alertmanager routes with grouping disabled
+// (group_by: ['...']), each alert being keyed by its fingerprint. Notifications of several alerts are split per alert,
+// so that the keying only depends on the configuration. Grouping disabled is not detected from the payload shape:
+// a grouped route notifying a single alert whose labels are the group labels looks the same, and the key of its
+// alert group, then of its incident, would change with the number of alerts.
+type UngroupedConfig struct {
+	// never (default), receivers: the notifications of the listed receivers, or always: all notifications
+	Mode      string   `yaml:"mode"`
+	Receivers []string `yaml:"receivers"`
+	// Leave the open incident as is on the repeated notifications of the firing alert, as they carry the same
+	// alert. Updated by default.
+	SkipFiringUpdates bool `yaml:"skip_firing_updates"`
+}
+
+func (c UngroupedConfig) validate() error {
+	switch c.Mode {
+	case "", ungroupedNever, ungroupedAlways:
+		return nil
+	case ungroupedReceivers:
+		if len(c.Receivers) == 0 {
+			return fmt.Errorf("ungrouped receivers must be set in receivers mode")
+		}
+		return nil
+	}
+	return fmt.Errorf("ungrouped mode %q is invalid, must be one of: never, receivers, always", c.Mode)
+}
+
+// isPerAlert returns true if the notifications of the receiver are processed per alert, keyed by the fingerprint
+// of their alert
+func isPerAlert(data template.Data) bool {
+	switch config.Workflow.Ungrouped.Mode {
+	case ungroupedReceivers:
+		for _, receiver := range config.Workflow.Ungrouped.Receivers {
+			if receiver == data.Receiver {
+				return true
+			}
+		}
+	case ungroupedAlways:
+		return true
+	}
+	return false
+}
+
+// splitPerAlert returns a notification of each alert of the notification, its labels being the group labels as sent
+// by Alertmanager for routes with group_by: ['...']
+func splitPerAlert(data template.Data) []template.Data {
+	split := make([]template.Data, 0, len(data.Alerts))
+	for _, alert := range data.Alerts {
+		alertData := data
+		alertData.Status = alert.Status
+		alertData.Alerts = template.Alerts{alert}
+		alertData.GroupLabels = alert.Labels
+		alertData.CommonLabels = alert.Labels
+		alertData.CommonAnnotations = alert.Annotations
+		split = append(split, alertData)
+	}
+	return split
+}
+
+// skipPerAlertUpdate returns true if the open incident of a firing alert processed per alert is left as is,
+// the notification repeating the alert the incident was created for, when skip_firing_updates is set
+func skipPerAlertUpdate(ctx context.Context, data template.Data, incident Incident) bool {
+	if !config.Workflow.Ungrouped.SkipFiringUpdates || !isPerAlert(data) {
+		return false
+	}
+	webhookPerAlertUpdatesSkipped.Inc()
+	incidentLog(ctx, data, incident).Infof("Incident (%s) is already open for alert fingerprint: %s, update skipped", incident.GetNumber(), getGroupKey(data))
+	return true
+}
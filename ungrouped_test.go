@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/stretchr/testify/mock"
+)
+
+func ungroupedData(status string) template.Data {
+	labels := template.KV{"alertname": "Ungrouped", "instance": "host:9100"}
+	return template.Data{
+		Status:       status,
+		Alerts:       template.Alerts{{Status: status, Labels: labels, Fingerprint: "0123456789abcdef"}},
+		GroupLabels:  labels,
+		CommonLabels: labels,
+	}
+}
+
+func TestIsPerAlert(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { config.Workflow.Ungrouped = UngroupedConfig{} }()
+
+	grouped := ungroupedData("firing")
+	grouped.Receiver = "ungrouped"
+	grouped.Alerts = append(grouped.Alerts, template.Alert{Status: "firing", Fingerprint: "fedcba9876543210"})
+	tests := []struct {
+		config UngroupedConfig
+		data   template.Data
+		want   bool
+	}{
+		{UngroupedConfig{}, ungroupedData("firing"), false},
+		{UngroupedConfig{Mode: ungroupedNever}, ungroupedData("firing"), false},
+		{UngroupedConfig{Mode: ungroupedReceivers, Receivers: []string{"ungrouped"}}, ungroupedData("firing"), false},
+		{UngroupedConfig{Mode: ungroupedReceivers, Receivers: []string{"ungrouped"}}, grouped, true},
+		{UngroupedConfig{Mode: ungroupedAlways}, ungroupedData("firing"), true},
+		{UngroupedConfig{Mode: ungroupedAlways}, grouped, true},
+	}
+	for _, test := range tests {
+		config.Workflow.Ungrouped = test.config
+		if got := isPerAlert(test.data); got != test.want {
+			t.Errorf("Unexpected per alert processing with %+v of %+v: got %v, want %v", test.config, test.data, got, test.want)
+		}
+	}
+
+	config.Workflow.Ungrouped = UngroupedConfig{Mode: ungroupedAlways}
+	if got := getGroupKey(ungroupedData("firing")); got != "0123456789abcdef" {
+		t.Errorf("Unexpected group key: got %v, want the alert fingerprint", got)
+	}
+	// The alerts of a notification of several alerts keep the keys they get on their own
+	for i, alertData := range splitPerAlert(grouped) {
+		if got := getGroupKey(alertData); got != grouped.Alerts[i].Fingerprint {
+			t.Errorf("Unexpected group key of split alert %d: got %v, want %v", i, got, grouped.Alerts[i].Fingerprint)
+		}
+	}
+}
+
+func TestOnAlertGroup_Ungrouped_Split(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { config.Workflow.Ungrouped = UngroupedConfig{} }()
+	config.Workflow.Ungrouped = UngroupedConfig{Mode: ungroupedAlways}
+	incidents = newIncidentCache()
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.Anything).Return(Incident{"number": "INC42", "sys_id": "42"}, nil)
+
+	data := ungroupedData("firing")
+	data.Alerts = append(data.Alerts, template.Alert{Status: "firing", Labels: template.KV{"alertname": "Ungrouped"}, Fingerprint: "fedcba9876543210"})
+	if err := onAlertGroup(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 2)
+}
+
+func TestUngroupedConfig_Validate(t *testing.T) {
+	if err := (UngroupedConfig{Mode: ungroupedReceivers, Receivers: []string{"ungrouped"}}).validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := (UngroupedConfig{Mode: ungroupedReceivers}).validate(); err == nil {
+		t.Errorf("Receivers mode without receivers must be invalid")
+	}
+	if err := (UngroupedConfig{Mode: "disabled"}).validate(); err == nil {
+		t.Errorf("Unknown mode must be invalid")
+	}
+}
+
+func TestOnAlertGroup_Ungrouped_SkipUpdate(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { config.Workflow.Ungrouped = UngroupedConfig{} }()
+
+	for _, skipFiringUpdates := range []bool{false, true} {
+		config.Workflow.Ungrouped = UngroupedConfig{Mode: ungroupedAlways, SkipFiringUpdates: skipFiringUpdates}
+		incidents = newIncidentCache()
+		snClientMock := new(MockedSnClient)
+		serviceNow = snClientMock
+		snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{{"state": "1", "number": "INC42", "sys_id": "42"}}, nil)
+		snClientMock.On("UpdateIncident", mock.Anything, "42").Return(Incident{"sys_id": "42"}, nil)
+
+		if err := manageAlertGroupIncident(context.Background(), ungroupedData("firing")); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if skipFiringUpdates {
+			snClientMock.AssertNotCalled(t, "UpdateIncident", mock.Anything, mock.Anything)
+		} else {
+			snClientMock.AssertNumberOfCalls(t, "UpdateIncident", 1)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// Actions on resolved alert groups without any known incident
+const (
+	unknownResolvedIgnore = "ignore"
+	unknownResolvedLog    = "log"
+	unknownResolvedCreate = "create_resolved"
+)
+
+// UnknownResolvedConfig - Handling of resolved notifications for alert groups without any incident, e.g. when the
+// incident was deleted or the firing notification was never processed
+type UnknownResolvedConfig struct {
+	// ignore (default), log a warning, or create_resolved an incident already resolved, for audit
+	Action string `yaml:"action"`
+	// State and fields of the created resolved incident, those of auto_resolve by default
+	State  json.Number       `yaml:"state"`
+	Fields map[string]string `yaml:"fields"`
+}
+
+func (c UnknownResolvedConfig) validate(autoResolve AutoResolveConfig) error {
+	switch c.Action {
+	case "", unknownResolvedIgnore, unknownResolvedLog:
+	case unknownResolvedCreate:
+		if len(c.State) == 0 && len(autoResolve.State) == 0 {
+			return fmt.Errorf("unknown_resolved state is missing, and no auto_resolve state is set")
+		}
+	default:
+		return fmt.Errorf("unknown_resolved action %q is invalid, must be one of: ignore, log, create_resolved", c.Action)
+	}
+	return nil
+}
+
+func (c UnknownResolvedConfig) action() string {
+	if len(c.Action) == 0 {
+		return unknownResolvedIgnore
+	}
+	return c.Action
+}
+
+// onUnknownResolvedGroup applies the configured action to a resolved alert group without any incident
+func onUnknownResolvedGroup(ctx context.Context, data template.Data) error {
+	inhibitions.track(ctx, data, nil)
+	c := config.Workflow.UnknownResolved
+	action := c.action()
+	if !isDryRun(ctx) {
+		webhookUnknownResolved.WithLabelValues(action).Inc()
+	}
+	switch action {
+	case unknownResolvedLog:
+		alertGroupLog(ctx, data).Warnf("Found no incident for resolved alert group key: %s, it was deleted or never created.", getGroupKey(data))
+		return nil
+	case unknownResolvedCreate:
+	default:
+		alertGroupLog(ctx, data).Infof("Found no incident for resolved alert group key: %s. No incident will be created/updated.", getGroupKey(data))
+		return nil
+	}
+
+	incidentCreateParam, err := alertGroupToIncident(ctx, data)
+	if err != nil {
+		return err
+	}
+	state, fields := c.State, c.Fields
+	if len(state) == 0 {
+		state = config.Workflow.AutoResolve.State
+	}
+	if len(fields) == 0 {
+		fields = config.Workflow.AutoResolve.Fields
+	}
+	resolveParam := Incident{"state": state.String()}
+	for field, value := range fields {
+		resolveParam[field] = value
+	}
+	applyIncidentTemplate(ctx, resolveParam, data)
+	for field, value := range resolveParam {
+		incidentCreateParam[field] = value
+	}
+	applyJournal(ctx, data, journalAlertsResolved, incidentCreateParam)
+
+	alertGroupLog(ctx, data).Infof("Found no incident for resolved alert group key: %s, a resolved incident is created for audit.", getGroupKey(data))
+	if vetoed, err := runIncidentHooks(ctx, data, "create", "", incidentCreateParam); vetoed || err != nil {
+		return err
+	}
+	createdIncident, err := serviceNowFor(ctx, data).CreateIncident(incidentCreateParam)
+	cacheIncidentResult(ctx, data, createdIncident, err)
+	observeIncidentAction(ctx, data, incidentCreateParam, unknownResolvedCreate, createdIncident.GetNumber(), err)
+	if err != nil {
+		serviceNowError.Inc()
+		return stageError(stageCreate, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOnAlertGroup_UnknownResolved(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { config.Workflow.UnknownResolved = UnknownResolvedConfig{} }()
+	data := template.Data{
+		Status:       "resolved",
+		Alerts:       template.Alerts{{Status: "resolved"}},
+		GroupLabels:  template.KV{"alertname": "UnknownResolved"},
+		CommonLabels: template.KV{"alertname": "UnknownResolved"},
+	}
+
+	for _, action := range []string{"", unknownResolvedLog} {
+		config.Workflow.UnknownResolved = UnknownResolvedConfig{Action: action}
+		incidents = newIncidentCache()
+		snClientMock := new(MockedSnClient)
+		serviceNow = snClientMock
+		snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+
+		if err := manageAlertGroupIncident(context.Background(), data); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		snClientMock.AssertNotCalled(t, "CreateIncident", mock.Anything)
+	}
+	if got := testutil.ToFloat64(webhookUnknownResolved.WithLabelValues(unknownResolvedLog)); got < 1 {
+		t.Errorf("Logged unknown resolved notification must be counted")
+	}
+
+	config.Workflow.UnknownResolved = UnknownResolvedConfig{Action: unknownResolvedCreate, State: "6", Fields: map[string]string{"close_notes": "{{ .Status }}, no incident found"}}
+	incidents = newIncidentCache()
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.MatchedBy(func(param Incident) bool {
+		return param["state"] == "6" && param["close_notes"] == "resolved, no incident found"
+	})).Return(Incident{"sys_id": "42", "number": "INC42"}, nil)
+
+	if err := manageAlertGroupIncident(context.Background(), data); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+}
+
+func TestUnknownResolvedConfig_Validate(t *testing.T) {
+	tests := []struct {
+		config      UnknownResolvedConfig
+		autoResolve AutoResolveConfig
+		valid       bool
+	}{
+		{UnknownResolvedConfig{}, AutoResolveConfig{}, true},
+		{UnknownResolvedConfig{Action: "log"}, AutoResolveConfig{}, true},
+		{UnknownResolvedConfig{Action: "create_resolved", State: "6"}, AutoResolveConfig{}, true},
+		{UnknownResolvedConfig{Action: "create_resolved"}, AutoResolveConfig{State: "6"}, true},
+		{UnknownResolvedConfig{Action: "create_resolved"}, AutoResolveConfig{}, false},
+		{UnknownResolvedConfig{Action: "create"}, AutoResolveConfig{}, false},
+	}
+	for _, test := range tests {
+		if err := test.config.validate(test.autoResolve); (err == nil) != test.valid {
+			t.Errorf("Unexpected validation of %+v: %v", test.config, err)
+		}
+	}
+}
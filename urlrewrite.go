@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+// URLRewritingConfig - Rewriting of the GeneratorURL of the alerts and of the ExternalURL of the alert group before they
+// are rendered in incidents, e.g. from the internal Prometheus address to the external Grafana or Thanos one
+type URLRewritingConfig struct {
+	// Rules applied in order, each to the result of the previous one
+	Rules []URLRewriteRuleConfig `yaml:"rules"`
+	// Remove the URLs which are not absolute http(s) URLs once rewritten, instead of rendering them as is
+	DropInvalid bool `yaml:"drop_invalid"`
+}
+
+// URLRewriteRuleConfig - Regex replacement of an URL, supporting regex group references (e.g.: "$1")
+type URLRewriteRuleConfig struct {
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+}
+
+type urlRewriteRule struct {
+	regexp      *regexp.Regexp
+	replacement string
+}
+
+// compileURLRewriteRules compiles the configured URL rewrite rules
+func compileURLRewriteRules(rules []URLRewriteRuleConfig) ([]urlRewriteRule, error) {
+	compiled := make([]urlRewriteRule, 0, len(rules))
+	for i, r := range rules {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("url_rewriting rules[%d]: %v", i, err)
+		}
+		compiled = append(compiled, urlRewriteRule{regexp: re, replacement: r.Replacement})
+	}
+	return compiled, nil
+}
+
+// rewriteAlertURLs returns a copy of the alert group with its ExternalURL and the GeneratorURL of its alerts rewritten
+func rewriteAlertURLs(ctx context.Context, data template.Data) template.Data {
+	if len(urlRewriteRules) == 0 && !config.URLRewriting.DropInvalid {
+		return data
+	}
+
+	data.ExternalURL = rewriteURL(ctx, data, "ExternalURL", data.ExternalURL)
+	alerts := make(template.Alerts, len(data.Alerts))
+	for i, alert := range data.Alerts {
+		alert.GeneratorURL = rewriteURL(ctx, data, "GeneratorURL", alert.GeneratorURL)
+		alerts[i] = alert
+	}
+	data.Alerts = alerts
+	return data
+}
+
+// rewriteURL applies the rewrite rules to the URL, and drops it if invalid and configured so
+func rewriteURL(ctx context.Context, data template.Data, field string, value string) string {
+	if len(value) == 0 {
+		return value
+	}
+	rewritten := value
+	for _, rule := range urlRewriteRules {
+		rewritten = rule.regexp.ReplaceAllString(rewritten, rule.replacement)
+	}
+	if rewritten != value {
+		webhookAlertURLsRewritten.WithLabelValues(field).Inc()
+	}
+
+	if config.URLRewriting.DropInvalid && !isAbsoluteHTTPURL(rewritten) {
+		webhookAlertURLsDropped.WithLabelValues(field).Inc()
+		alertGroupLog(ctx, data).Warnf("%s %q of alert group key: %s is not an absolute http(s) URL, it is dropped", field, rewritten, getGroupKey(data))
+		return ""
+	}
+	return rewritten
+}
+
+func isAbsoluteHTTPURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) > 0
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRewriteAlertURLs(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { config.URLRewriting = URLRewritingConfig{}; urlRewriteRules = nil }()
+	config.URLRewriting = URLRewritingConfig{
+		Rules: []URLRewriteRuleConfig{
+			{Regex: `^http://prometheus-k8s-\d\.monitoring\.svc:9090/(.*)$`, Replacement: "https://thanos.example.com/$1"},
+			{Regex: `^http://alertmanager:9093`, Replacement: "https://alertmanager.example.com"},
+		},
+		DropInvalid: true,
+	}
+	var err error
+	if urlRewriteRules, err = compileURLRewriteRules(config.URLRewriting.Rules); err != nil {
+		t.Fatal(err)
+	}
+
+	data := template.Data{
+		ExternalURL: "http://alertmanager:9093",
+		Alerts: template.Alerts{
+			{GeneratorURL: "http://prometheus-k8s-0.monitoring.svc:9090/graph?g0.expr=up"},
+			{GeneratorURL: "/graph?g0.expr=up"},
+			{GeneratorURL: "https://prometheus.example.com/graph"},
+			{},
+		},
+	}
+	dropped := testutil.ToFloat64(webhookAlertURLsDropped.WithLabelValues("GeneratorURL"))
+	rewritten := rewriteAlertURLs(context.Background(), data)
+	if rewritten.ExternalURL != "https://alertmanager.example.com" {
+		t.Errorf("Unexpected ExternalURL: %s", rewritten.ExternalURL)
+	}
+	want := []string{"https://thanos.example.com/graph?g0.expr=up", "", "https://prometheus.example.com/graph", ""}
+	for i, alert := range rewritten.Alerts {
+		if alert.GeneratorURL != want[i] {
+			t.Errorf("Unexpected GeneratorURL of alert %d: got %q, want %q", i, alert.GeneratorURL, want[i])
+		}
+	}
+	if got := testutil.ToFloat64(webhookAlertURLsDropped.WithLabelValues("GeneratorURL")); got != dropped+1 {
+		t.Errorf("Unexpected dropped URLs: got %v, want %v", got, dropped+1)
+	}
+	if data.Alerts[0].GeneratorURL != "http://prometheus-k8s-0.monitoring.svc:9090/graph?g0.expr=up" {
+		t.Errorf("Alert group must not be modified")
+	}
+}
+
+func TestOnAlertGroup_RewrittenURLs(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	defer func() { config.URLRewriting = URLRewritingConfig{}; urlRewriteRules = nil }()
+	config.DefaultIncident["description"] = "{{ range .Alerts }}{{ .GeneratorURL }}{{ end }}"
+	config.URLRewriting = URLRewritingConfig{Rules: []URLRewriteRuleConfig{{Regex: "^http://prometheus:9090", Replacement: "https://prometheus.example.com"}}}
+	urlRewriteRules, _ = compileURLRewriteRules(config.URLRewriting.Rules)
+	incidents = newIncidentCache()
+
+	snClientMock := new(MockedSnClient)
+	serviceNow = snClientMock
+	snClientMock.On("GetIncidents", mock.Anything).Return([]Incident{}, nil)
+	snClientMock.On("CreateIncident", mock.MatchedBy(func(param Incident) bool {
+		return param["description"] == "https://prometheus.example.com/graph"
+	})).Return(Incident{"sys_id": "42", "number": "INC42"}, nil)
+
+	data := template.Data{
+		Status:      "firing",
+		GroupLabels: template.KV{"alertname": "RewrittenURLs"},
+		Alerts:      template.Alerts{{Status: "firing", GeneratorURL: "http://prometheus:9090/graph"}},
+	}
+	if err := onAlertGroup(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	snClientMock.AssertNumberOfCalls(t, "CreateIncident", 1)
+}
+
+func TestCompileURLRewriteRules(t *testing.T) {
+	if _, err := compileURLRewriteRules([]URLRewriteRuleConfig{{Regex: "("}}); err == nil {
+		t.Errorf("Expected an invalid regex error")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+const defaultTemplateVariant = "default"
+
+// TemplateVariantConfig - Variant of the default incident templates, rolled out to a percentage of the alert groups
+type TemplateVariantConfig struct {
+	Name    string `yaml:"name"`
+	Percent int    `yaml:"percent"`
+	// Fields overriding the default_incident ones
+	DefaultIncident map[string]string `yaml:"default_incident"`
+}
+
+// TemplateVariantsConfig - Gradual rollout of incident template variants
+type TemplateVariantsConfig struct {
+	// Incident field holding the name of the variant the incident was rendered with
+	Field    string                  `yaml:"field"`
+	Variants []TemplateVariantConfig `yaml:"variants"`
+}
+
+func (c TemplateVariantsConfig) validate() error {
+	var errs strings.Builder
+	names := map[string]bool{defaultTemplateVariant: true}
+	total := 0
+	for i, variant := range c.Variants {
+		if len(variant.Name) == 0 {
+			errs.WriteString(fmt.Sprintf("template variant %d name is missing\n", i))
+		} else if names[variant.Name] {
+			errs.WriteString(fmt.Sprintf("template variant name %q is reserved or duplicated\n", variant.Name))
+		}
+		names[variant.Name] = true
+		if variant.Percent < 0 || variant.Percent > 100 {
+			errs.WriteString(fmt.Sprintf("template variant %q percent must be between 0 and 100\n", variant.Name))
+		}
+		total += variant.Percent
+	}
+	if total > 100 {
+		errs.WriteString("template variants percents must not exceed 100 in total\n")
+	}
+	if errs.Len() > 0 {
+		return fmt.Errorf("%s", strings.TrimSuffix(errs.String(), "\n"))
+	}
+	return nil
+}
+
+// selectTemplateVariant returns the variant of the alert group, keyed by its group key hash
+// so that an alert group keeps its variant, or nil for the default templates
+func selectTemplateVariant(data template.Data) *TemplateVariantConfig {
+	if len(config.TemplateVariants.Variants) == 0 {
+		return nil
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(getGroupKey(data)))
+	bucket := int(hash.Sum32() % 100)
+
+	for i, variant := range config.TemplateVariants.Variants {
+		if bucket < variant.Percent {
+			return &config.TemplateVariants.Variants[i]
+		}
+		bucket -= variant.Percent
+	}
+	return nil
+}
+
+// getTemplateVariantName returns the name of the variant of the alert group
+func getTemplateVariantName(data template.Data) string {
+	if variant := selectTemplateVariant(data); variant != nil {
+		return variant.Name
+	}
+	return defaultTemplateVariant
+}
+
+// selectDefaultIncident returns the default incident templates of the alert group profile, template set and variant
+func selectDefaultIncident(data template.Data) map[string]string {
+	profile := selectProfile(data)
+	set := selectTemplateSet(data)
+	variant := selectTemplateVariant(data)
+	if profile == nil && set == nil && variant == nil && len(config.TemplateVariants.Field) == 0 {
+		return config.DefaultIncident
+	}
+
+	defaultIncident := make(map[string]string, len(config.DefaultIncident))
+	for field, value := range config.DefaultIncident {
+		defaultIncident[field] = value
+	}
+	if profile != nil {
+		for field, value := range profile.DefaultIncident {
+			defaultIncident[field] = value
+		}
+	}
+	if set != nil {
+		for field, value := range set.DefaultIncident {
+			defaultIncident[field] = value
+		}
+	}
+	if variant != nil {
+		for field, value := range variant.DefaultIncident {
+			defaultIncident[field] = value
+		}
+	}
+	if len(config.TemplateVariants.Field) > 0 {
+		defaultIncident[config.TemplateVariants.Field] = getTemplateVariantName(data)
+	}
+	return defaultIncident
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/alertmanager/template"
+)
+
+func TestSelectTemplateVariant(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.TemplateVariants = TemplateVariantsConfig{
+		Variants: []TemplateVariantConfig{
+			{Name: "v2", Percent: 30, DefaultIncident: map[string]string{"impact": "1"}},
+			{Name: "v3", Percent: 20},
+		},
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		data := template.Data{GroupLabels: template.KV{"alertname": fmt.Sprintf("alert-%d", i)}}
+		name := getTemplateVariantName(data)
+		if getTemplateVariantName(data) != name {
+			t.Fatalf("An alert group must keep its variant")
+		}
+		counts[name]++
+	}
+	for name, want := range map[string]int{"v2": 300, "v3": 200, "default": 500} {
+		if counts[name] < want-60 || counts[name] > want+60 {
+			t.Errorf("Unexpected split for variant %s: got %d, want about %d", name, counts[name], want)
+		}
+	}
+}
+
+func TestSelectDefaultIncident(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.TemplateVariants = TemplateVariantsConfig{
+		Field:    "u_template_variant",
+		Variants: []TemplateVariantConfig{{Name: "v2", Percent: 100, DefaultIncident: map[string]string{"impact": "1"}}},
+	}
+
+	defaultIncident := selectDefaultIncident(template.Data{})
+	if defaultIncident["impact"] != "1" || defaultIncident["u_template_variant"] != "v2" {
+		t.Errorf("Unexpected variant default incident: %v", defaultIncident)
+	}
+	if defaultIncident["assignment_group"] != config.DefaultIncident["assignment_group"] {
+		t.Errorf("Fields not overridden by the variant must be kept: %v", defaultIncident)
+	}
+	if config.DefaultIncident["impact"] == "1" {
+		t.Errorf("Default incident must not be modified")
+	}
+
+	config.TemplateVariants.Variants[0].Percent = 0
+	if got := selectDefaultIncident(template.Data{})["u_template_variant"]; got != "default" {
+		t.Errorf("Unexpected variant field: got %v, want default", got)
+	}
+}
+
+func TestTemplateVariantsConfig_Validate(t *testing.T) {
+	tests := []struct {
+		variants []TemplateVariantConfig
+		valid    bool
+	}{
+		{[]TemplateVariantConfig{{Name: "v2", Percent: 50}, {Name: "v3", Percent: 50}}, true},
+		{[]TemplateVariantConfig{{Name: "v2", Percent: 60}, {Name: "v3", Percent: 50}}, false},
+		{[]TemplateVariantConfig{{Name: "v2", Percent: 10}, {Name: "v2", Percent: 10}}, false},
+		{[]TemplateVariantConfig{{Name: "default", Percent: 10}}, false},
+		{[]TemplateVariantConfig{{Percent: 10}}, false},
+		{[]TemplateVariantConfig{{Name: "v2", Percent: -1}}, false},
+	}
+	for _, test := range tests {
+		if err := (TemplateVariantsConfig{Variants: test.variants}).validate(); (err == nil) != test.valid {
+			t.Errorf("Unexpected validation of %+v: %v", test.variants, err)
+		}
+	}
+}
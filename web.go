@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	externalURLFlag = kingpin.Flag("web.external-url", "The URL under which the webhook is externally reachable, e.g. behind a reverse proxy. Used in the generated links, its path is the default route prefix.").String()
+	routePrefixFlag = kingpin.Flag("web.route-prefix", "Prefix of the routes of all the HTTP endpoints. Defaults to the path of --web.external-url.").String()
+)
+
+var (
+	// linkPrefix is the path prefix of the links generated in responses and pages, e.g. /servicenow
+	linkPrefix string
+	// routePrefix is the path prefix the endpoints are served under, shared by the sharding replicas
+	routePrefix string
+)
+
+// webPrefixes returns the route prefix and the link prefix, without trailing slash, from the external URL and the
+// route prefix flags
+func webPrefixes(externalURL string, routePrefix string) (string, string, error) {
+	var externalPath string
+	if len(externalURL) > 0 {
+		u, err := url.Parse(externalURL)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid --web.external-url %q: %v", externalURL, err)
+		}
+		if len(u.Scheme) == 0 || len(u.Host) == 0 {
+			return "", "", fmt.Errorf("invalid --web.external-url %q: scheme and host are required", externalURL)
+		}
+		externalPath = normalizePrefix(u.Path)
+	}
+	if len(routePrefix) == 0 {
+		return externalPath, externalPath, nil
+	}
+	return normalizePrefix(routePrefix), externalPath, nil
+}
+
+// normalizePrefix returns the path with a leading slash and without trailing slash, empty for the root
+func normalizePrefix(path string) string {
+	path = strings.TrimRight(path, "/")
+	if len(path) > 0 && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// withRoutePrefix serves the routes of the handler under the prefix, the root being redirected to it
+func withRoutePrefix(handler http.Handler, prefix string) http.Handler {
+	if len(prefix) == 0 {
+		return handler
+	}
+	mux := http.NewServeMux()
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, handler))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, prefix+"/", http.StatusFound)
+	})
+	return mux
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebPrefixes(t *testing.T) {
+	tests := []struct {
+		externalURL string
+		routePrefix string
+		wantRoute   string
+		wantLink    string
+		wantErr     bool
+	}{
+		{"", "", "", "", false},
+		{"http://proxy.example.com/servicenow/", "", "/servicenow", "/servicenow", false},
+		{"http://proxy.example.com/servicenow", "/", "", "/servicenow", false},
+		{"http://proxy.example.com", "internal", "/internal", "", false},
+		{"", "/prefix/", "/prefix", "", false},
+		{"proxy.example.com/servicenow", "", "", "", true},
+	}
+	for _, test := range tests {
+		route, link, err := webPrefixes(test.externalURL, test.routePrefix)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Unexpected error for %q: %v", test.externalURL, err)
+			continue
+		}
+		if route != test.wantRoute || link != test.wantLink {
+			t.Errorf("Unexpected prefixes for %q, %q: got %q, %q, want %q, %q", test.externalURL, test.routePrefix, route, link, test.wantRoute, test.wantLink)
+		}
+	}
+}
+
+func TestWithRoutePrefix(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/groups/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	})
+	handler := withRoutePrefix(mux, "/servicenow")
+
+	tests := []struct {
+		path     string
+		code     int
+		body     string
+		location string
+	}{
+		{"/servicenow/api/v1/groups/abc/history", http.StatusOK, "/api/v1/groups/abc/history", ""},
+		{"/", http.StatusFound, "", "/servicenow/"},
+		{"/api/v1/groups/abc/history", http.StatusNotFound, "", ""},
+	}
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", test.path, nil))
+		if rr.Code != test.code {
+			t.Errorf("Unexpected code for %s: got %d, want %d", test.path, rr.Code, test.code)
+		}
+		if len(test.body) > 0 && rr.Body.String() != test.body {
+			t.Errorf("Unexpected body for %s: %s", test.path, rr.Body.String())
+		}
+		if got := rr.Header().Get("Location"); got != test.location {
+			t.Errorf("Unexpected location for %s: %s", test.path, got)
+		}
+	}
+}
+
+func TestUI_LinkPrefix(t *testing.T) {
+	linkPrefix = "/servicenow"
+	defer func() { linkPrefix = "" }()
+
+	for path, handler := range map[string]http.HandlerFunc{"/": homepage, "/ui": ui} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if !strings.Contains(rr.Body.String(), `href="/servicenow/metrics"`) {
+			t.Errorf("Links of %s must include the prefix: %s", path, rr.Body.String())
+		}
+	}
+}
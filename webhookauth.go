@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// WebhookAuthConfig - Authentication of the incoming notifications on /webhook and /cloudevents, with basic
+// authentication or a bearer token. Disabled when neither is set.
+type WebhookAuthConfig struct {
+	BasicAuth       BasicAuthConfig `yaml:"basic_auth"`
+	BearerToken     string          `yaml:"bearer_token"`
+	BearerTokenFile string          `yaml:"bearer_token_file"`
+}
+
+// BasicAuthConfig - Basic authentication credentials
+type BasicAuthConfig struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+// enabled returns true if basic authentication credentials are configured
+func (c BasicAuthConfig) enabled() bool {
+	return len(c.Username) > 0
+}
+
+// validate checks that a non empty password, or a readable password file holding one, is set along the username
+func (c BasicAuthConfig) validate() error {
+	if !c.enabled() {
+		return nil
+	}
+	password := c.Password
+	if len(c.PasswordFile) > 0 {
+		var err error
+		if password, err = readSecretFile(c.PasswordFile); err != nil {
+			return fmt.Errorf("webhook_auth basic_auth password_file is not readable: %v", err)
+		}
+	}
+	if len(password) == 0 {
+		return errors.New("webhook_auth basic_auth password is missing")
+	}
+	return nil
+}
+
+// enabled returns true if an authentication method is configured
+func (c WebhookAuthConfig) enabled() bool {
+	return c.BasicAuth.enabled() || len(c.BearerToken) > 0 || len(c.BearerTokenFile) > 0
+}
+
+// authorize checks the credentials of the request against the configured methods, any of them being accepted
+func (c WebhookAuthConfig) authorize(r *http.Request) error {
+	if _, _, ok := r.BasicAuth(); ok && c.BasicAuth.enabled() {
+		return c.BasicAuth.authorize(r)
+	}
+	if len(c.BearerToken) > 0 || len(c.BearerTokenFile) > 0 {
+		return authorizeBearerToken(r, c.BearerToken, c.BearerTokenFile)
+	}
+	return errors.New("missing basic authentication")
+}
+
+// authorize checks the basic authentication of the request
+func (c BasicAuthConfig) authorize(r *http.Request) error {
+	password := c.Password
+	if len(c.PasswordFile) > 0 {
+		var err error
+		if password, err = readSecretFile(c.PasswordFile); err != nil {
+			return err
+		}
+	}
+	// An empty password must not let any request with the username in
+	if len(password) == 0 {
+		return errors.New("no basic authentication password is configured")
+	}
+	username, requestPassword, _ := r.BasicAuth()
+	usernameOK := subtle.ConstantTimeCompare([]byte(username), []byte(c.Username)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(requestPassword), []byte(password)) == 1
+	if !usernameOK || !passwordOK {
+		return errors.New("invalid basic authentication")
+	}
+	return nil
+}
+
+// authorizeWebhook checks the credentials of an incoming notification before its body is read, and sends
+// a 401 response if they are not valid. It returns false if the notification must not be processed.
+func authorizeWebhook(w http.ResponseWriter, r *http.Request, c WebhookAuthConfig, endpoint string) bool {
+	if !c.enabled() {
+		return true
+	}
+	if err := c.authorize(r); err != nil {
+		log.Warnf("Unauthorized notification on %s from %s: %v", endpoint, r.RemoteAddr, err)
+		webhookUnauthorizedRequests.WithLabelValues(endpoint).Inc()
+		w.Header().Set("WWW-Authenticate", `Basic realm="alertmanager-webhook-servicenow"`)
+		sendJSONResponse(w, http.StatusUnauthorized, "Unauthorized")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWebhook_Unauthorized(t *testing.T) {
+	loadConfig("config/servicenow_example.yml")
+	config.WebhookAuth = WebhookAuthConfig{
+		BasicAuth:   BasicAuthConfig{Username: "alertmanager", Password: "secret"},
+		BearerToken: "token",
+	}
+	before := testutil.ToFloat64(webhookUnauthorizedRequests.WithLabelValues("/webhook"))
+
+	tests := []struct {
+		name      string
+		configure func(r *http.Request)
+	}{
+		{"no credentials", func(r *http.Request) {}},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("alertmanager", "wrong") }},
+		{"wrong token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }},
+	}
+	for _, test := range tests {
+		// The body is not parsed before authentication
+		req := httptest.NewRequest("POST", "/webhook", bytes.NewReader([]byte("not json")))
+		test.configure(req)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(webhook).ServeHTTP(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Wrong status code with %s: got %v, want %v", test.name, rr.Code, http.StatusUnauthorized)
+		}
+	}
+	if got := testutil.ToFloat64(webhookUnauthorizedRequests.WithLabelValues("/webhook")) - before; got != 3 {
+		t.Errorf("Unexpected unauthorized requests: got %v, want 3", got)
+	}
+}
+
+func TestWebhookAuthConfig_Authorize(t *testing.T) {
+	c := WebhookAuthConfig{BasicAuth: BasicAuthConfig{Username: "alertmanager", Password: "secret"}, BearerToken: "token"}
+
+	req := httptest.NewRequest("POST", "/webhook", nil)
+	req.SetBasicAuth("alertmanager", "secret")
+	if err := c.authorize(req); err != nil {
+		t.Errorf("Basic authentication must be accepted: %v", err)
+	}
+
+	req = httptest.NewRequest("POST", "/webhook", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	if err := c.authorize(req); err != nil {
+		t.Errorf("Bearer token must be accepted: %v", err)
+	}
+
+	basicOnly := WebhookAuthConfig{BasicAuth: c.BasicAuth}
+	if err := basicOnly.authorize(req); err == nil {
+		t.Errorf("Bearer token must not be accepted without bearer_token")
+	}
+}
+
+func TestBasicAuthConfig_EmptyPassword(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhookauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	emptyFile := filepath.Join(dir, "empty")
+	if err := ioutil.WriteFile(emptyFile, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []BasicAuthConfig{
+		{Username: "alertmanager"},
+		{Username: "alertmanager", PasswordFile: emptyFile},
+		{Username: "alertmanager", PasswordFile: filepath.Join(dir, "missing")},
+	} {
+		if err := c.validate(); err == nil {
+			t.Errorf("Basic authentication without password must be invalid: %+v", c)
+		}
+		req := httptest.NewRequest("POST", "/webhook", nil)
+		req.SetBasicAuth("alertmanager", "")
+		if err := c.authorize(req); err == nil {
+			t.Errorf("Empty password must not be accepted: %+v", c)
+		}
+	}
+}